@@ -1,10 +1,12 @@
 package api
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"log"
 	"net/http"
 	"danto/manager"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,6 +16,8 @@ type Server struct {
 	router        *gin.Engine
 	traderManager *manager.TraderManager
 	port          int
+
+	killSwitchToken string // 紧急平仓接口的访问令牌（为空时接口关闭）
 }
 
 // NewServer 创建API服务器
@@ -38,6 +42,11 @@ func NewServer(traderManager *manager.TraderManager, port int) *Server {
 	return s
 }
 
+// SetKillSwitchToken 设置紧急平仓接口的访问令牌（为空时接口返回403）
+func (s *Server) SetKillSwitchToken(token string) {
+	s.killSwitchToken = token
+}
+
 // corsMiddleware CORS中间件
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -77,9 +86,47 @@ func (s *Server) setupRoutes() {
 		api.GET("/statistics", s.handleStatistics)
 		api.GET("/equity-history", s.handleEquityHistory)
 		api.GET("/performance", s.handlePerformance)
+
+		// 紧急平仓（需要 Authorization: Bearer <kill_switch_token>）
+		api.POST("/kill-switch", s.killSwitchAuth(), s.handleKillSwitch)
 	}
 }
 
+// killSwitchAuth 校验紧急平仓接口的访问令牌
+func (s *Server) killSwitchAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.killSwitchToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "紧急平仓接口未开启（未配置kill_switch_token）"})
+			return
+		}
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.killSwitchToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的访问令牌"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleKillSwitch 紧急平仓：停止所有trader，撤销所有挂单并平掉所有持仓，返回各trader的最终状态
+func (s *Server) handleKillSwitch(c *gin.Context) {
+	log.Printf("🚨 收到紧急平仓请求（来自 %s）", c.ClientIP())
+	reports := s.traderManager.FlattenAll()
+
+	flat := true
+	for _, report := range reports {
+		flat = flat && report.Flat
+	}
+	status := http.StatusOK
+	if !flat {
+		status = http.StatusInternalServerError
+	}
+	c.JSON(status, gin.H{
+		"flat":    flat,
+		"traders": reports,
+	})
+}
+
 // handleHealth 健康检查
 func (s *Server) handleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -416,6 +463,7 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/statistics?trader_id=xxx - Statistics for specified trader")
 	log.Printf("  • GET  /api/equity-history?trader_id=xxx - Equity history data for specified trader")
 	log.Printf("  • GET  /api/performance?trader_id=xxx - AI learning performance analysis for specified trader")
+	log.Printf("  • POST /api/kill-switch      - Emergency stop: flatten all traders (Authorization: Bearer <kill_switch_token>)")
 	log.Printf("  • GET  /health               - Health check")
 	log.Println()
 
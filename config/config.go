@@ -11,7 +11,7 @@ import (
 type TraderConfig struct {
 	ID      string `json:"id"`
 	Name    string `json:"name"`
-	Enabled bool   `json:"enabled"` // 是否启用该trader
+	Enabled bool   `json:"enabled"`  // 是否启用该trader
 	AIModel string `json:"ai_model"` // "qwen" or "deepseek"
 
 	// 交易平台选择（二选一）
@@ -46,10 +46,59 @@ type TraderConfig struct {
 	CustomAPIKey    string `json:"custom_api_key,omitempty"`
 	CustomModelName string `json:"custom_model_name,omitempty"`
 
+	// 提示词语言（"zh" 或 "en"，默认zh）和模板目录（需包含 system.tmpl 和 user.tmpl，为空则使用该语言的内置提示词包）
+	PromptLanguage    string `json:"prompt_language,omitempty"`
+	PromptTemplateDir string `json:"prompt_template_dir,omitempty"`
+
+	// token预算（0=自动：按模型上下文窗口计算prompt预算，输出默认2000 tokens）
+	PromptTokenBudget int `json:"prompt_token_budget,omitempty"`
+	MaxOutputTokens   int `json:"max_output_tokens,omitempty"`
+
+	// 工具调用模式（AI可按需调用工具获取K线/订单簿/资金费率/历史交易，max_tool_iterations默认5）
+	ToolMode          bool `json:"tool_mode,omitempty"`
+	MaxToolIterations int  `json:"max_tool_iterations,omitempty"`
+
+	// 跨周期记忆（memory_cycles=0 表示不启用；memory_token_budget 默认1500）
+	MemoryCycles      int `json:"memory_cycles,omitempty"`
+	MemoryTokenBudget int `json:"memory_token_budget,omitempty"`
+
+	// 限价开仓单的挂单有效期（分钟，超时未成交自动撤销，默认15）
+	EntryOrderTTLMinutes int `json:"entry_order_ttl_minutes,omitempty"`
+
+	// 保证金模式（"isolated"/"cross"）和持仓模式（"hedge"/"one_way"），为空时使用默认值（币安/Hyperliquid逐仓，持仓模式沿用账户当前设置）
+	MarginMode   string `json:"margin_mode,omitempty"`
+	PositionMode string `json:"position_mode,omitempty"`
+
+	// 录制AI请求/响应的目录（可选，用于离线回放和测试）
+	LLMRecordDir string `json:"llm_record_dir,omitempty"`
+
+	// 多模型集成决策配置（可选，主模型+这里列出的模型一起投票）
+	Ensemble *EnsembleConfig `json:"ensemble,omitempty"`
+
 	InitialBalance      float64 `json:"initial_balance"`
 	ScanIntervalMinutes int     `json:"scan_interval_minutes"`
 }
 
+// EnsembleModelConfig 集成决策中的单个AI模型配置
+type EnsembleModelConfig struct {
+	AIModel string `json:"ai_model"` // "qwen", "deepseek", "minimax" or "custom"
+
+	QwenKey     string `json:"qwen_key,omitempty"`
+	DeepSeekKey string `json:"deepseek_key,omitempty"`
+	MiniMaxKey  string `json:"minimax_key,omitempty"`
+
+	CustomAPIURL    string `json:"custom_api_url,omitempty"`
+	CustomAPIKey    string `json:"custom_api_key,omitempty"`
+	CustomModelName string `json:"custom_model_name,omitempty"`
+}
+
+// EnsembleConfig 多模型集成决策配置
+type EnsembleConfig struct {
+	Models     []EnsembleModelConfig `json:"models"`      // 额外参与投票的模型
+	SizePolicy string                `json:"size_policy"` // 仓位合并策略: "average"（平均）或 "min"（最小）
+	MinVotes   int                   `json:"min_votes"`   // 执行动作所需的最少同意票数（0=过半数）
+}
+
 // LeverageConfig 杠杆配置
 type LeverageConfig struct {
 	BTCETHLeverage  int `json:"btc_eth_leverage"` // BTC和ETH的杠杆倍数（主账户建议5-50，子账户≤5）
@@ -67,7 +116,11 @@ type Config struct {
 	MaxDailyLoss       float64        `json:"max_daily_loss"`
 	MaxDrawdown        float64        `json:"max_drawdown"`
 	StopTradingMinutes int            `json:"stop_trading_minutes"`
-	Leverage           LeverageConfig `json:"leverage"` // 杠杆配置
+	Leverage           LeverageConfig `json:"leverage"`                    // 杠杆配置
+	KillSwitchToken    string         `json:"kill_switch_token,omitempty"` // 紧急平仓接口的访问令牌（为空时不开放该接口）
+	FlattenOnExit      bool           `json:"flatten_on_exit,omitempty"`   // 收到退出信号时先紧急平仓再退出
+
+	ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds,omitempty"` // 退出时等待进行中的交易周期执行完的最长时间（秒，默认60）
 }
 
 // LoadConfig 从文件加载配置
@@ -136,8 +189,8 @@ func (c *Config) Validate() error {
 		if trader.Exchange == "" {
 			trader.Exchange = "binance" // 默认使用币安
 		}
-		if trader.Exchange != "binance" && trader.Exchange != "hyperliquid" && trader.Exchange != "aster" {
-			return fmt.Errorf("trader[%d]: exchange必须是 'binance', 'hyperliquid' 或 'aster'", i)
+		if trader.Exchange != "binance" && trader.Exchange != "hyperliquid" && trader.Exchange != "aster" && trader.Exchange != "delta" {
+			return fmt.Errorf("trader[%d]: exchange必须是 'binance', 'hyperliquid', 'aster' 或 'delta'", i)
 		}
 
 		// 根据平台验证对应的密钥
@@ -180,6 +233,35 @@ func (c *Config) Validate() error {
 				return fmt.Errorf("trader[%d]: when using custom API, must configure custom_model_name", i)
 			}
 		}
+		if trader.PromptLanguage != "" && trader.PromptLanguage != "zh" && trader.PromptLanguage != "en" {
+			return fmt.Errorf("trader[%d]: prompt_language必须是 'zh' 或 'en'", i)
+		}
+		if trader.PromptTokenBudget < 0 || trader.MaxOutputTokens < 0 {
+			return fmt.Errorf("trader[%d]: prompt_token_budget和max_output_tokens不能为负数", i)
+		}
+		if trader.MemoryCycles < 0 || trader.MemoryTokenBudget < 0 {
+			return fmt.Errorf("trader[%d]: memory_cycles和memory_token_budget不能为负数", i)
+		}
+		if trader.EntryOrderTTLMinutes < 0 {
+			return fmt.Errorf("trader[%d]: entry_order_ttl_minutes不能为负数", i)
+		}
+		if trader.MarginMode != "" && trader.MarginMode != "isolated" && trader.MarginMode != "cross" {
+			return fmt.Errorf("trader[%d]: margin_mode必须是 'isolated' 或 'cross'", i)
+		}
+		if trader.PositionMode != "" && trader.PositionMode != "hedge" && trader.PositionMode != "one_way" {
+			return fmt.Errorf("trader[%d]: position_mode必须是 'hedge' 或 'one_way'", i)
+		}
+		if trader.MaxToolIterations < 0 {
+			return fmt.Errorf("trader[%d]: max_tool_iterations不能为负数", i)
+		}
+		if trader.ToolMode && trader.Ensemble != nil {
+			return fmt.Errorf("trader[%d]: tool_mode暂不支持与ensemble同时使用", i)
+		}
+		if trader.Ensemble != nil {
+			if err := trader.Ensemble.validate(); err != nil {
+				return fmt.Errorf("trader[%d]: %w", i, err)
+			}
+		}
 		if trader.InitialBalance <= 0 {
 			return fmt.Errorf("trader[%d]: initial_balance必须大于0", i)
 		}
@@ -191,6 +273,9 @@ func (c *Config) Validate() error {
 	if c.APIServerPort <= 0 {
 		c.APIServerPort = 8080 // 默认8080端口
 	}
+	if c.ShutdownTimeoutSeconds <= 0 {
+		c.ShutdownTimeoutSeconds = 60 // 默认等待60秒
+	}
 
 	// 设置杠杆默认值（适配币安子账户限制，最大5倍）
 	if c.Leverage.BTCETHLeverage <= 0 {
@@ -209,6 +294,45 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validate 验证集成决策配置
+func (e *EnsembleConfig) validate() error {
+	if len(e.Models) == 0 {
+		return fmt.Errorf("ensemble.models不能为空")
+	}
+	if e.SizePolicy != "" && e.SizePolicy != "average" && e.SizePolicy != "min" {
+		return fmt.Errorf("ensemble.size_policy必须是 'average' 或 'min'")
+	}
+	// 主模型 + 额外模型 = 总票数
+	totalVotes := len(e.Models) + 1
+	if e.MinVotes < 0 || e.MinVotes > totalVotes {
+		return fmt.Errorf("ensemble.min_votes必须在0-%d之间", totalVotes)
+	}
+
+	for j, m := range e.Models {
+		switch m.AIModel {
+		case "qwen":
+			if m.QwenKey == "" {
+				return fmt.Errorf("ensemble.models[%d]: when using Qwen, must configure qwen_key", j)
+			}
+		case "deepseek":
+			if m.DeepSeekKey == "" {
+				return fmt.Errorf("ensemble.models[%d]: when using DeepSeek, must configure deepseek_key", j)
+			}
+		case "minimax":
+			if m.MiniMaxKey == "" {
+				return fmt.Errorf("ensemble.models[%d]: when using MiniMax, must configure minimax_key", j)
+			}
+		case "custom":
+			if m.CustomAPIURL == "" || m.CustomAPIKey == "" || m.CustomModelName == "" {
+				return fmt.Errorf("ensemble.models[%d]: when using custom API, must configure custom_api_url, custom_api_key and custom_model_name", j)
+			}
+		default:
+			return fmt.Errorf("ensemble.models[%d]: ai_model必须是 'qwen', 'deepseek', 'minimax' 或 'custom'", j)
+		}
+	}
+	return nil
+}
+
 // GetScanInterval 获取扫描间隔
 func (tc *TraderConfig) GetScanInterval() time.Duration {
 	return time.Duration(tc.ScanIntervalMinutes) * time.Minute
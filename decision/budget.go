@@ -0,0 +1,256 @@
+package decision
+
+import (
+	"danto/market"
+	"danto/mcp"
+	"fmt"
+	"log"
+	"sort"
+)
+
+const (
+	compressedSeriesPoints = 5 // 第一级压缩后每个序列保留的数据点数
+)
+
+// PromptBudget 提示词token预算
+type PromptBudget struct {
+	MaxTokens int              // system+user prompt 可用的最大token数（<=0 表示不限制）
+	Estimate  func(string) int // token估算函数（为空时按通用tokenizer估算）
+}
+
+// BudgetForClients 取多个模型中最严格的预算（集成模式下所有模型共用同一份prompt）
+func BudgetForClients(clients ...*mcp.Client) PromptBudget {
+	var budget PromptBudget
+	for _, client := range clients {
+		if budget.Estimate == nil {
+			budget.Estimate = client.EstimateTokens
+		}
+		max := client.PromptTokenBudget()
+		if max <= 0 {
+			continue
+		}
+		if budget.MaxTokens == 0 || max < budget.MaxTokens {
+			budget = PromptBudget{MaxTokens: max, Estimate: client.EstimateTokens}
+		}
+	}
+	return budget
+}
+
+// Estimator 预算使用的token估算函数
+func (b PromptBudget) Estimator() func(string) int {
+	if b.Estimate != nil {
+		return b.Estimate
+	}
+	return func(s string) int { return mcp.EstimateTokens(mcp.ProviderCustom, s) }
+}
+
+// renderedPrompt 渲染后的prompt及预算信息
+type renderedPrompt struct {
+	System     string
+	User       string
+	Tokens     int      // 估算的 system+user token数
+	Reductions []string // 为满足预算而实施的压缩步骤
+}
+
+// renderWithinBudget 渲染prompt；超出预算时逐级压缩候选币种的市场数据：
+// 1. 序列只保留最近几个点 2. 只保留摘要指标 3. 按排名从低到高丢弃候选币种 4. 持仓币种也只保留摘要
+func renderWithinBudget(ctx *Context, prompt *PromptTemplate, budget PromptBudget) (*renderedPrompt, error) {
+	estimate := budget.Estimator()
+
+	render := func(c *Context) (*renderedPrompt, error) {
+		systemPrompt, userPrompt, err := prompt.Render(c)
+		if err != nil {
+			return nil, err
+		}
+		return &renderedPrompt{
+			System: systemPrompt,
+			User:   userPrompt,
+			Tokens: estimate(systemPrompt) + estimate(userPrompt),
+		}, nil
+	}
+
+	result, err := render(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if budget.MaxTokens <= 0 || result.Tokens <= budget.MaxTokens {
+		return result, nil
+	}
+
+	originalTokens := result.Tokens
+	work := cloneForCompression(ctx)
+	var reductions []string
+
+	positionSymbols := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		positionSymbols[pos.Symbol] = true
+	}
+
+	// compressCandidates 对所有非持仓的候选币种应用压缩
+	compressCandidates := func(points int) {
+		for _, coin := range work.CandidateCoins {
+			if positionSymbols[coin.Symbol] {
+				continue
+			}
+			if data, ok := work.MarketDataMap[coin.Symbol]; ok {
+				work.MarketDataMap[coin.Symbol] = compressMarketData(data, points)
+			}
+		}
+	}
+
+	// tryStep 应用一步压缩并重新渲染，返回是否已满足预算
+	tryStep := func(desc string, apply func()) (bool, error) {
+		apply()
+		r, err := render(work)
+		if err != nil {
+			return false, err
+		}
+		reductions = append(reductions, fmt.Sprintf("%s（%d → %d tokens）", desc, result.Tokens, r.Tokens))
+		result = r
+		return result.Tokens <= budget.MaxTokens, nil
+	}
+
+	done, err := tryStep(fmt.Sprintf("候选币种序列截断至最近%d个点", compressedSeriesPoints), func() {
+		compressCandidates(compressedSeriesPoints)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !done {
+		done, err = tryStep("候选币种仅保留摘要指标", func() { compressCandidates(0) })
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !done {
+		// 按排名从低到高逐个丢弃候选币种（持仓币种不丢弃）
+		ranked := rankCandidates(work)
+		dropped := 0
+		before := result.Tokens
+		for i := len(ranked) - 1; i >= 0 && !done; i-- {
+			symbol := ranked[i].Symbol
+			if positionSymbols[symbol] {
+				continue
+			}
+			work.CandidateCoins = removeCandidate(work.CandidateCoins, symbol)
+			delete(work.MarketDataMap, symbol)
+			dropped++
+
+			r, err := render(work)
+			if err != nil {
+				return nil, err
+			}
+			result = r
+			done = result.Tokens <= budget.MaxTokens
+		}
+		if dropped > 0 {
+			reductions = append(reductions, fmt.Sprintf("丢弃%d个低排名候选币种（%d → %d tokens）", dropped, before, result.Tokens))
+		}
+	}
+
+	if !done && len(ctx.Positions) > 0 {
+		done, err = tryStep("持仓币种仅保留摘要指标", func() {
+			for symbol := range positionSymbols {
+				if data, ok := work.MarketDataMap[symbol]; ok {
+					work.MarketDataMap[symbol] = compressMarketData(data, 0)
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Reductions = reductions
+	for _, r := range reductions {
+		log.Printf("✂️  Prompt压缩: %s", r)
+	}
+	if !done {
+		log.Printf("⚠️  Prompt压缩后仍超出预算: %d > %d tokens（原始%d）", result.Tokens, budget.MaxTokens, originalTokens)
+	}
+
+	return result, nil
+}
+
+// cloneForCompression 复制上下文中会被压缩修改的部分（不影响原始上下文）
+func cloneForCompression(ctx *Context) *Context {
+	work := *ctx
+	work.CandidateCoins = append([]CandidateCoin(nil), ctx.CandidateCoins...)
+	work.MarketDataMap = make(map[string]*market.Data, len(ctx.MarketDataMap))
+	for symbol, data := range ctx.MarketDataMap {
+		work.MarketDataMap[symbol] = data
+	}
+	return &work
+}
+
+// compressMarketData 复制市场数据并压缩序列：points>0 时保留最近points个点，points=0 时去掉所有序列只保留摘要指标
+func compressMarketData(data *market.Data, points int) *market.Data {
+	compressed := *data
+
+	if points <= 0 {
+		compressed.IntradaySeries = nil
+		if data.LongerTermContext != nil {
+			longer := *data.LongerTermContext
+			longer.MACDValues = nil
+			longer.RSI14Values = nil
+			compressed.LongerTermContext = &longer
+		}
+		return &compressed
+	}
+
+	if data.IntradaySeries != nil {
+		compressed.IntradaySeries = &market.IntradayData{
+			MidPrices:   lastPoints(data.IntradaySeries.MidPrices, points),
+			EMA20Values: lastPoints(data.IntradaySeries.EMA20Values, points),
+			MACDValues:  lastPoints(data.IntradaySeries.MACDValues, points),
+			RSI7Values:  lastPoints(data.IntradaySeries.RSI7Values, points),
+			RSI14Values: lastPoints(data.IntradaySeries.RSI14Values, points),
+		}
+	}
+	if data.LongerTermContext != nil {
+		longer := *data.LongerTermContext
+		longer.MACDValues = lastPoints(longer.MACDValues, points)
+		longer.RSI14Values = lastPoints(longer.RSI14Values, points)
+		compressed.LongerTermContext = &longer
+	}
+	return &compressed
+}
+
+// lastPoints 取序列最后n个点
+func lastPoints(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	return values[len(values)-n:]
+}
+
+// rankCandidates 候选币种排名（高→低）：双重信号优先，其次OI Top排名靠前，同级保持原顺序（AI500评分顺序）
+func rankCandidates(ctx *Context) []CandidateCoin {
+	ranked := append([]CandidateCoin(nil), ctx.CandidateCoins...)
+	oiRank := func(symbol string) int {
+		if oi, ok := ctx.OITopDataMap[symbol]; ok && oi.Rank > 0 {
+			return oi.Rank
+		}
+		return 1 << 30
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if len(ranked[i].Sources) != len(ranked[j].Sources) {
+			return len(ranked[i].Sources) > len(ranked[j].Sources)
+		}
+		return oiRank(ranked[i].Symbol) < oiRank(ranked[j].Symbol)
+	})
+	return ranked
+}
+
+// removeCandidate 从候选列表中移除指定币种
+func removeCandidate(coins []CandidateCoin, symbol string) []CandidateCoin {
+	result := coins[:0]
+	for _, coin := range coins {
+		if coin.Symbol != symbol {
+			result = append(result, coin)
+		}
+	}
+	return result
+}
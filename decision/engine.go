@@ -1,12 +1,13 @@
 package decision
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
 	"danto/market"
 	"danto/mcp"
 	"danto/pool"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
 	"strings"
 	"time"
 )
@@ -23,7 +24,29 @@ type PositionInfo struct {
 	UnrealizedPnLPct float64 `json:"unrealized_pnl_pct"`
 	LiquidationPrice float64 `json:"liquidation_price"`
 	MarginUsed       float64 `json:"margin_used"`
-	UpdateTime       int64   `json:"update_time"` // 持仓更新时间戳（毫秒）
+	StopLoss         float64 `json:"stop_loss,omitempty"`         // 当前止损价（0=未知）
+	TakeProfit       float64 `json:"take_profit,omitempty"`       // 当前止盈价（0=未知）
+	TrailingStopPct  float64 `json:"trailing_stop_pct,omitempty"` // 跟踪止损回调比例（0=没有跟踪止损）
+	UpdateTime       int64   `json:"update_time"`                 // 持仓更新时间戳（毫秒）
+
+	Funding          float64 `json:"funding,omitempty"`           // 持仓期间累计的资金费（正数为收入，负数为支出）
+	FundingRate      float64 `json:"funding_rate,omitempty"`      // 当前资金费率（一个结算周期）
+	NextFundingTime  int64   `json:"next_funding_time,omitempty"` // 下次资金费结算时间戳（毫秒，0=未知）
+	ProjectedFunding float64 `json:"projected_funding,omitempty"` // 按当前费率和持仓价值预计下次结算的资金费（正数为收入）
+
+	TakeProfitLevels []TakeProfitLevel `json:"take_profit_levels,omitempty"` // 尚未成交的止盈阶梯（price为实际挂单价）
+}
+
+// PendingOrderInfo 挂单中的限价开仓单
+type PendingOrderInfo struct {
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"` // "long" or "short"
+	Price      float64 `json:"price"`
+	Quantity   float64 `json:"quantity"`
+	StopLoss   float64 `json:"stop_loss"`
+	TakeProfit float64 `json:"take_profit"`
+	AgeMinutes int     `json:"age_minutes"` // 已挂单分钟数
+	TTLMinutes int     `json:"ttl_minutes"` // 超过该分钟数未成交会自动撤销
 }
 
 // AccountInfo 账户信息
@@ -60,33 +83,63 @@ type Context struct {
 	CallCount       int                     `json:"call_count"`
 	Account         AccountInfo             `json:"account"`
 	Positions       []PositionInfo          `json:"positions"`
+	PendingOrders   []PendingOrderInfo      `json:"pending_orders,omitempty"`
 	CandidateCoins  []CandidateCoin         `json:"candidate_coins"`
 	MarketDataMap   map[string]*market.Data `json:"-"` // 不序列化，但内部使用
 	OITopDataMap    map[string]*OITopData   `json:"-"` // OI Top数据映射
 	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
 	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
 	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+
+	Exchange            string          `json:"-"` // 交易平台（用于prompt描述）
+	ScanIntervalMinutes int             `json:"-"` // 扫描间隔分钟数（用于prompt描述）
+	PromptTemplate      *PromptTemplate `json:"-"` // 提示词模板（nil=内置默认模板）
+	Memory              *MemoryDigest   `json:"-"` // 跨周期记忆（nil=不启用）
+
+	TradeHistory func(symbol string) (interface{}, error) `json:"-"` // 工具调用模式：查询本trader在某币种上的历史交易
 }
 
 // Decision AI的交易决策
 type Decision struct {
 	Symbol          string  `json:"symbol"`
-	Action          string  `json:"action"` // "open_long", "open_short", "close_long", "close_short", "hold", "wait"
+	Action          string  `json:"action"` // "open_long", "open_short", "close_long", "close_short", "update_stop_loss", "update_take_profit", "partial_close", "add_to_position", "hold", "wait"
 	Leverage        int     `json:"leverage,omitempty"`
 	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
 	StopLoss        float64 `json:"stop_loss,omitempty"`
 	TakeProfit      float64 `json:"take_profit,omitempty"`
-	Confidence      int     `json:"confidence,omitempty"` // 信心度 (0-100)
-	RiskUSD         float64 `json:"risk_usd,omitempty"`   // 最大美元风险
+	OrderType       string  `json:"order_type,omitempty"`        // 开仓订单类型："market"（默认）, "limit", "post_only", "ioc"
+	EntryPrice      float64 `json:"entry_price,omitempty"`       // 限价开仓的挂单价（order_type非market时必填）
+	TrailingStopPct float64 `json:"trailing_stop_pct,omitempty"` // 开仓后设置的跟踪止损回调比例（0.1-10，可选）
+	Side            string  `json:"side,omitempty"`              // 调整止损止盈/部分平仓/加仓的持仓方向 "long"/"short"（同币种同时有多空仓时必填）
+	ClosePercentage float64 `json:"close_percentage,omitempty"`  // 部分平仓的比例（0-100，与close_quantity二选一）
+	CloseQuantity   float64 `json:"close_quantity,omitempty"`    // 部分平仓的数量（币数量，与close_percentage二选一）
+	Confidence      int     `json:"confidence,omitempty"`        // 信心度 (0-100)
+	RiskUSD         float64 `json:"risk_usd,omitempty"`          // 最大美元风险
 	Reasoning       string  `json:"reasoning"`
+
+	TakeProfitLevels []TakeProfitLevel `json:"take_profit_levels,omitempty"` // 分批止盈阶梯（开仓可选，设置后按档挂止盈单，代替单张take_profit止盈单）
+}
+
+// TakeProfitLevel 分批止盈的一档：price（触发价）、r_multiple（按风险倍数计算触发价）、
+// trailing_stop_pct（这部分仓位用跟踪止损离场）三选一
+type TakeProfitLevel struct {
+	Percentage      float64 `json:"percentage"`                  // 这一档平掉的开仓数量比例（0-100，各档之和为100）
+	Price           float64 `json:"price,omitempty"`             // 触发价
+	RMultiple       float64 `json:"r_multiple,omitempty"`        // 触发价 = 入场价 ± r_multiple × |入场价-止损价|（市价开仓按下单时价格，限价开仓按挂单价）
+	TrailingStopPct float64 `json:"trailing_stop_pct,omitempty"` // 跟踪止损回调比例（0.1-10）
 }
 
 // FullDecision AI的完整决策（包含思维链）
 type FullDecision struct {
-	UserPrompt string     `json:"user_prompt"` // 发送给AI的输入prompt
-	CoTTrace   string     `json:"cot_trace"`   // 思维链分析（AI输出）
-	Decisions  []Decision `json:"decisions"`   // 具体决策列表
-	Timestamp  time.Time  `json:"timestamp"`
+	UserPrompt       string           `json:"user_prompt"`                 // 发送给AI的输入prompt
+	PromptVersion    string           `json:"prompt_version"`              // 提示词模板版本哈希
+	PromptTokens     int              `json:"prompt_tokens"`               // 估算的prompt token数
+	PromptReductions []string         `json:"prompt_reductions,omitempty"` // 为满足token预算实施的压缩步骤
+	CoTTrace         string           `json:"cot_trace"`                   // 思维链分析（AI输出）
+	Decisions        []Decision       `json:"decisions"`                   // 具体决策列表
+	Votes            []ModelVote      `json:"votes,omitempty"`             // 集成模式下各模型的投票
+	ToolCalls        []ToolCallRecord `json:"tool_calls,omitempty"`        // 工具调用模式下的工具调用记录
+	Timestamp        time.Time        `json:"timestamp"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -96,12 +149,15 @@ func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error)
 		return nil, fmt.Errorf("获取市场数据失败: %w", err)
 	}
 
-	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
-	systemPrompt := buildSystemPrompt(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
-	userPrompt := buildUserPrompt(ctx)
+	// 2. 用模板渲染 System Prompt（固定规则）和 User Prompt（动态数据），超出模型token预算时压缩市场数据
+	prompt := promptTemplateFor(ctx)
+	rendered, err := renderWithinBudget(ctx, prompt, BudgetForClients(mcpClient))
+	if err != nil {
+		return nil, err
+	}
 
 	// 3. 调用AI API（使用 system + user prompt）
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	aiResponse, err := mcpClient.CallWithMessages(rendered.System, rendered.User)
 	if err != nil {
 		return nil, fmt.Errorf("调用AI API失败: %w", err)
 	}
@@ -113,7 +169,10 @@ func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error)
 	}
 
 	decision.Timestamp = time.Now()
-	decision.UserPrompt = userPrompt // 保存输入prompt
+	decision.UserPrompt = rendered.User // 保存输入prompt
+	decision.PromptVersion = prompt.Version
+	decision.PromptTokens = rendered.Tokens
+	decision.PromptReductions = rendered.Reductions
 	return decision, nil
 }
 
@@ -199,223 +258,6 @@ func calculateMaxCandidates(ctx *Context) int {
 	return len(ctx.CandidateCoins)
 }
 
-// buildSystemPrompt 构建 System Prompt（固定规则，可缓存）
-func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int) string {
-	var sb strings.Builder
-
-	// === 核心使命 ===
-	sb.WriteString("你是专业的加密货币交易AI，在币安合约市场进行自主交易。\n\n")
-	sb.WriteString("# 🎯 核心目标\n\n")
-	sb.WriteString("**最大化夏普比率（Sharpe Ratio）**\n\n")
-	sb.WriteString("夏普比率 = 平均收益 / 收益波动率\n\n")
-	sb.WriteString("**这意味着**：\n")
-	sb.WriteString("- ✅ 高质量交易（高胜率、大盈亏比）→ 提升夏普\n")
-	sb.WriteString("- ✅ 稳定收益、控制回撤 → 提升夏普\n")
-	sb.WriteString("- ✅ 耐心持仓、让利润奔跑 → 提升夏普\n")
-	sb.WriteString("- ❌ 频繁交易、小盈小亏 → 增加波动，严重降低夏普\n")
-	sb.WriteString("- ❌ 过度交易、手续费损耗 → 直接亏损\n")
-	sb.WriteString("- ❌ 过早平仓、频繁进出 → 错失大行情\n\n")
-	sb.WriteString("**关键认知**: 系统每3分钟扫描一次，但不意味着每次都要交易！\n")
-	sb.WriteString("大多数时候应该是 `wait` 或 `hold`，只在极佳机会时才开仓。\n\n")
-
-	// === 硬约束（风险控制）===
-	sb.WriteString("# ⚖️ 硬约束（风险控制）\n\n")
-	sb.WriteString("1. **风险回报比**: 必须 ≥ 1:3（冒1%风险，赚3%+收益）\n")
-	sb.WriteString("2. **最多持仓**: 3个币种（质量>数量）\n")
-	sb.WriteString(fmt.Sprintf("3. **单币仓位**: 山寨%.0f-%.0f U(%dx杠杆) | BTC/ETH %.0f-%.0f U(%dx杠杆)\n",
-		accountEquity*0.8, accountEquity*1.5, altcoinLeverage, accountEquity*5, accountEquity*10, btcEthLeverage))
-	sb.WriteString("4. **保证金**: 总使用率 ≤ 90%\n\n")
-
-	// === 做空激励 ===
-	sb.WriteString("# 📉 做多做空平衡\n\n")
-	sb.WriteString("**重要**: 下跌趋势做空的利润 = 上涨趋势做多的利润\n\n")
-	sb.WriteString("- 上涨趋势 → 做多\n")
-	sb.WriteString("- 下跌趋势 → 做空\n")
-	sb.WriteString("- 震荡市场 → 观望\n\n")
-	sb.WriteString("**不要有做多偏见！做空是你的核心工具之一**\n\n")
-
-	// === 交易频率认知 ===
-	sb.WriteString("# ⏱️ 交易频率认知\n\n")
-	sb.WriteString("**量化标准**:\n")
-	sb.WriteString("- 优秀交易员：每天2-4笔 = 每小时0.1-0.2笔\n")
-	sb.WriteString("- 过度交易：每小时>2笔 = 严重问题\n")
-	sb.WriteString("- 最佳节奏：开仓后持有至少30-60分钟\n\n")
-	sb.WriteString("**自查**:\n")
-	sb.WriteString("如果你发现自己每个周期都在交易 → 说明标准太低\n")
-	sb.WriteString("如果你发现持仓<30分钟就平仓 → 说明太急躁\n\n")
-
-	// === 开仓信号强度 ===
-	sb.WriteString("# 🎯 开仓标准（严格）\n\n")
-	sb.WriteString("只在**强信号**时开仓，不确定就观望。\n\n")
-	sb.WriteString("**你拥有的完整数据**：\n")
-	sb.WriteString("- 📊 **原始序列**：3分钟价格序列(MidPrices数组) + 4小时K线序列\n")
-	sb.WriteString("- 📈 **技术序列**：EMA20序列、MACD序列、RSI7序列、RSI14序列\n")
-	sb.WriteString("- 💰 **资金序列**：成交量序列、持仓量(OI)序列、资金费率\n")
-	sb.WriteString("- 🎯 **筛选标记**：AI500评分 / OI_Top排名（如果有标注）\n\n")
-	sb.WriteString("**分析方法**（完全由你自主决定）：\n")
-	sb.WriteString("- 自由运用序列数据，你可以做但不限于趋势分析、形态识别、支撑阻力、技术阻力位、斐波那契、波动带计算\n")
-	sb.WriteString("- 多维度交叉验证（价格+量+OI+指标+序列形态）\n")
-	sb.WriteString("- 用你认为最有效的方法发现高确定性机会\n")
-	sb.WriteString("- 综合信心度 ≥ 75 才开仓\n\n")
-	sb.WriteString("**避免低质量信号**：\n")
-	sb.WriteString("- 单一维度（只看一个指标）\n")
-	sb.WriteString("- 相互矛盾（涨但量萎缩）\n")
-	sb.WriteString("- 横盘震荡\n")
-	sb.WriteString("- 刚平仓不久（<15分钟）\n\n")
-
-	// === 夏普比率自我进化 ===
-	sb.WriteString("# 🧬 夏普比率自我进化\n\n")
-	sb.WriteString("每次你会收到**夏普比率**作为绩效反馈（周期级别）：\n\n")
-	sb.WriteString("**夏普比率 < -0.5** (持续亏损):\n")
-	sb.WriteString("  → 🛑 停止交易，连续观望至少6个周期（18分钟）\n")
-	sb.WriteString("  → 🔍 深度反思：\n")
-	sb.WriteString("     • 交易频率过高？（每小时>2次就是过度）\n")
-	sb.WriteString("     • 持仓时间过短？（<30分钟就是过早平仓）\n")
-	sb.WriteString("     • 信号强度不足？（信心度<75）\n")
-	sb.WriteString("     • 是否在做空？（单边做多是错误的）\n\n")
-	sb.WriteString("**夏普比率 -0.5 ~ 0** (轻微亏损):\n")
-	sb.WriteString("  → ⚠️ 严格控制：只做信心度>80的交易\n")
-	sb.WriteString("  → 减少交易频率：每小时最多1笔新开仓\n")
-	sb.WriteString("  → 耐心持仓：至少持有30分钟以上\n\n")
-	sb.WriteString("**夏普比率 0 ~ 0.7** (正收益):\n")
-	sb.WriteString("  → ✅ 维持当前策略\n\n")
-	sb.WriteString("**夏普比率 > 0.7** (优异表现):\n")
-	sb.WriteString("  → 🚀 可适度扩大仓位\n\n")
-	sb.WriteString("**关键**: 夏普比率是唯一指标，它会自然惩罚频繁交易和过度进出。\n\n")
-
-	// === 决策流程 ===
-	sb.WriteString("# 📋 决策流程\n\n")
-	sb.WriteString("1. **分析夏普比率**: 当前策略是否有效？需要调整吗？\n")
-	sb.WriteString("2. **评估持仓**: 趋势是否改变？是否该止盈/止损？\n")
-	sb.WriteString("3. **寻找新机会**: 有强信号吗？多空机会？\n")
-	sb.WriteString("4. **输出决策**: 思维链分析 + JSON\n\n")
-
-	// === 输出格式 ===
-	sb.WriteString("# 📤 输出格式\n\n")
-	sb.WriteString("**第一步: 思维链（纯文本）**\n")
-	sb.WriteString("简洁分析你的思考过程\n\n")
-	sb.WriteString("**第二步: JSON决策数组**\n\n")
-	sb.WriteString("```json\n[\n")
-	sb.WriteString(fmt.Sprintf("  {\"symbol\": \"BTCUSDT\", \"action\": \"open_short\", \"leverage\": %d, \"position_size_usd\": %.0f, \"stop_loss\": 97000, \"take_profit\": 91000, \"confidence\": 85, \"risk_usd\": 300, \"reasoning\": \"下跌趋势+MACD死叉\"},\n", btcEthLeverage, accountEquity*5))
-	sb.WriteString("  {\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"reasoning\": \"止盈离场\"}\n")
-	sb.WriteString("]\n```\n\n")
-	sb.WriteString("**字段说明**:\n")
-	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
-	sb.WriteString("- `confidence`: 0-100（开仓建议≥75）\n")
-	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n\n")
-
-	// === 关键提醒 ===
-	sb.WriteString("---\n\n")
-	sb.WriteString("**记住**: \n")
-	sb.WriteString("- 目标是夏普比率，不是交易频率\n")
-	sb.WriteString("- 做空 = 做多，都是赚钱工具\n")
-	sb.WriteString("- 宁可错过，不做低质量交易\n")
-	sb.WriteString("- 风险回报比1:3是底线\n")
-
-	return sb.String()
-}
-
-// buildUserPrompt 构建 User Prompt（动态数据）
-func buildUserPrompt(ctx *Context) string {
-	var sb strings.Builder
-
-	// 系统状态
-	sb.WriteString(fmt.Sprintf("**时间**: %s | **周期**: #%d | **运行**: %d分钟\n\n",
-		ctx.CurrentTime, ctx.CallCount, ctx.RuntimeMinutes))
-
-	// BTC 市场
-	if btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]; hasBTC {
-		sb.WriteString(fmt.Sprintf("**BTC**: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f\n\n",
-			btcData.CurrentPrice, btcData.PriceChange1h, btcData.PriceChange4h,
-			btcData.CurrentMACD, btcData.CurrentRSI7))
-	}
-
-	// 账户
-	sb.WriteString(fmt.Sprintf("**账户**: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
-		ctx.Account.TotalEquity,
-		ctx.Account.AvailableBalance,
-		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
-		ctx.Account.TotalPnLPct,
-		ctx.Account.MarginUsedPct,
-		ctx.Account.PositionCount))
-
-	// 持仓（完整市场数据）
-	if len(ctx.Positions) > 0 {
-		sb.WriteString("## 当前持仓\n")
-		for i, pos := range ctx.Positions {
-			// 计算持仓时长
-			holdingDuration := ""
-			if pos.UpdateTime > 0 {
-				durationMs := time.Now().UnixMilli() - pos.UpdateTime
-				durationMin := durationMs / (1000 * 60) // 转换为分钟
-				if durationMin < 60 {
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
-				} else {
-					durationHour := durationMin / 60
-					durationMinRemainder := durationMin % 60
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d小时%d分钟", durationHour, durationMinRemainder)
-				}
-			}
-
-			sb.WriteString(fmt.Sprintf("%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
-				i+1, pos.Symbol, strings.ToUpper(pos.Side),
-				pos.EntryPrice, pos.MarkPrice, pos.UnrealizedPnLPct,
-				pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration))
-
-			// 使用FormatMarketData输出完整市场数据
-			if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
-				sb.WriteString(market.Format(marketData))
-				sb.WriteString("\n")
-			}
-		}
-	} else {
-		sb.WriteString("**当前持仓**: 无\n\n")
-	}
-
-	// 候选币种（完整市场数据）
-	sb.WriteString(fmt.Sprintf("## 候选币种 (%d个)\n\n", len(ctx.MarketDataMap)))
-	displayedCount := 0
-	for _, coin := range ctx.CandidateCoins {
-		marketData, hasData := ctx.MarketDataMap[coin.Symbol]
-		if !hasData {
-			continue
-		}
-		displayedCount++
-
-		sourceTags := ""
-		if len(coin.Sources) > 1 {
-			sourceTags = " (AI500+OI_Top双重信号)"
-		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
-			sourceTags = " (OI_Top持仓增长)"
-		}
-
-		// 使用FormatMarketData输出完整市场数据
-		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
-		sb.WriteString(market.Format(marketData))
-		sb.WriteString("\n")
-	}
-	sb.WriteString("\n")
-
-	// 夏普比率（直接传值，不要复杂格式化）
-	if ctx.Performance != nil {
-		// 直接从interface{}中提取SharpeRatio
-		type PerformanceData struct {
-			SharpeRatio float64 `json:"sharpe_ratio"`
-		}
-		var perfData PerformanceData
-		if jsonData, err := json.Marshal(ctx.Performance); err == nil {
-			if err := json.Unmarshal(jsonData, &perfData); err == nil {
-				sb.WriteString(fmt.Sprintf("## 📊 夏普比率: %.2f\n\n", perfData.SharpeRatio))
-			}
-		}
-	}
-
-	sb.WriteString("---\n\n")
-	sb.WriteString("现在请分析并输出决策（思维链 + JSON）\n")
-
-	return sb.String()
-}
-
 // parseFullDecisionResponse 解析AI的完整决策响应
 func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
 	// 1. 提取思维链
@@ -498,6 +340,62 @@ func fixMissingQuotes(jsonStr string) string {
 	return jsonStr
 }
 
+// validateTakeProfitLevels 验证开仓的分批止盈阶梯（可选）
+func validateTakeProfitLevels(d *Decision) error {
+	if len(d.TakeProfitLevels) == 0 {
+		return nil
+	}
+	if len(d.TakeProfitLevels) > 5 {
+		return fmt.Errorf("take_profit_levels最多5档，实际: %d", len(d.TakeProfitLevels))
+	}
+
+	totalPercentage := 0.0
+	trailingLevels := 0
+	for i, level := range d.TakeProfitLevels {
+		if level.Percentage <= 0 {
+			return fmt.Errorf("第%d档止盈的percentage必须大于0: %.2f", i+1, level.Percentage)
+		}
+		totalPercentage += level.Percentage
+
+		kinds := 0
+		for _, v := range []float64{level.Price, level.RMultiple, level.TrailingStopPct} {
+			if v != 0 {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return fmt.Errorf("第%d档止盈必须且只能提供price、r_multiple或trailing_stop_pct之一", i+1)
+		}
+
+		switch {
+		case level.Price != 0:
+			if level.Price < 0 || (d.Action == "open_long" && level.Price <= d.StopLoss) || (d.Action == "open_short" && level.Price >= d.StopLoss) {
+				return fmt.Errorf("第%d档止盈价(%.4f)必须在止损价(%.4f)的盈利一侧", i+1, level.Price, d.StopLoss)
+			}
+		case level.RMultiple != 0:
+			if level.RMultiple < 0 {
+				return fmt.Errorf("第%d档止盈的r_multiple必须大于0: %.2f", i+1, level.RMultiple)
+			}
+		default:
+			if level.TrailingStopPct < 0.1 || level.TrailingStopPct > 10 {
+				return fmt.Errorf("第%d档止盈的trailing_stop_pct必须在0.1-10之间: %.2f", i+1, level.TrailingStopPct)
+			}
+			trailingLevels++
+		}
+	}
+
+	if trailingLevels > 1 {
+		return fmt.Errorf("take_profit_levels最多只能有一档跟踪止损")
+	}
+	if trailingLevels == 1 && d.TrailingStopPct != 0 {
+		return fmt.Errorf("take_profit_levels中有跟踪止损档时不能再设置trailing_stop_pct")
+	}
+	if math.Abs(totalPercentage-100) > 0.5 {
+		return fmt.Errorf("take_profit_levels各档percentage之和必须为100，实际: %.2f", totalPercentage)
+	}
+	return nil
+}
+
 // validateDecisions 验证所有决策（需要账户信息和杠杆配置）
 func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
 	for i, decision := range decisions {
@@ -530,6 +428,14 @@ func findMatchingBracket(s string, start int) int {
 	return -1
 }
 
+// PositionLimits 单币种的杠杆上限和仓位价值上限（山寨币1.5倍账户净值，BTC/ETH 10倍账户净值）
+func PositionLimits(symbol string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (int, float64) {
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		return btcEthLeverage, accountEquity * 10 // BTC和ETH使用配置的杠杆
+	}
+	return altcoinLeverage, accountEquity * 1.5 // 山寨币使用配置的杠杆
+}
+
 // validateDecision 验证单个决策的有效性
 func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
 	// 验证action
@@ -540,21 +446,62 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 		"close_short": true,
 		"hold":        true,
 		"wait":        true,
+
+		"update_stop_loss":   true,
+		"update_take_profit": true,
+		"partial_close":      true,
+		"add_to_position":    true,
 	}
 
 	if !validActions[d.Action] {
 		return fmt.Errorf("无效的action: %s", d.Action)
 	}
 
+	// 调整止损止盈：新价格必须大于0（与标记价格的关系在执行时按实时价格校验）
+	if d.Action == "update_stop_loss" && d.StopLoss <= 0 {
+		return fmt.Errorf("update_stop_loss必须提供大于0的stop_loss")
+	}
+	if d.Action == "update_take_profit" && d.TakeProfit <= 0 {
+		return fmt.Errorf("update_take_profit必须提供大于0的take_profit")
+	}
+	if d.Side != "" && d.Side != "long" && d.Side != "short" {
+		return fmt.Errorf("side必须为long或short: %s", d.Side)
+	}
+
+	// 部分平仓：比例和数量二选一（平仓后剩余仓位的止损止盈在执行时按新数量调整）
+	if d.Action == "partial_close" {
+		if (d.ClosePercentage > 0) == (d.CloseQuantity > 0) {
+			return fmt.Errorf("partial_close必须且只能提供close_percentage或close_quantity之一")
+		}
+		if d.ClosePercentage < 0 || d.ClosePercentage >= 100 {
+			return fmt.Errorf("close_percentage必须在0-100之间（全部平仓请用close_long/close_short）: %.2f", d.ClosePercentage)
+		}
+		if d.CloseQuantity < 0 {
+			return fmt.Errorf("close_quantity必须大于0: %.4f", d.CloseQuantity)
+		}
+	}
+
+	// 加仓：杠杆和本次加仓金额的上限同开仓（加仓后的总仓位价值在执行时按现有持仓校验）
+	if d.Action == "add_to_position" {
+		maxLeverage, maxPositionValue := PositionLimits(d.Symbol, accountEquity, btcEthLeverage, altcoinLeverage)
+		if d.Leverage <= 0 || d.Leverage > maxLeverage {
+			return fmt.Errorf("杠杆必须在1-%d之间（%s，当前配置上限%d倍）: %d", maxLeverage, d.Symbol, maxLeverage, d.Leverage)
+		}
+		if d.PositionSizeUSD <= 0 {
+			return fmt.Errorf("加仓金额必须大于0: %.2f", d.PositionSizeUSD)
+		}
+		if d.PositionSizeUSD > maxPositionValue*1.01 {
+			return fmt.Errorf("加仓金额不能超过单币种仓位上限%.0f USDT，实际: %.0f", maxPositionValue, d.PositionSizeUSD)
+		}
+		if d.StopLoss < 0 || d.TakeProfit < 0 {
+			return fmt.Errorf("止损和止盈不能为负数")
+		}
+	}
+
 	// 开仓操作必须提供完整参数
 	if d.Action == "open_long" || d.Action == "open_short" {
 		// 根据币种使用配置的杠杆上限
-		maxLeverage := altcoinLeverage          // 山寨币使用配置的杠杆
-		maxPositionValue := accountEquity * 1.5 // 山寨币最多1.5倍账户净值
-		if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-			maxLeverage = btcEthLeverage          // BTC和ETH使用配置的杠杆
-			maxPositionValue = accountEquity * 10 // BTC/ETH最多10倍账户净值
-		}
+		maxLeverage, maxPositionValue := PositionLimits(d.Symbol, accountEquity, btcEthLeverage, altcoinLeverage)
 
 		if d.Leverage <= 0 || d.Leverage > maxLeverage {
 			return fmt.Errorf("杠杆必须在1-%d之间（%s，当前配置上限%d倍）: %d", maxLeverage, d.Symbol, maxLeverage, d.Leverage)
@@ -586,10 +533,34 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 			}
 		}
 
+		// 验证订单类型和限价
+		switch d.OrderType {
+		case "", "market":
+		case "limit", "post_only", "ioc":
+			if d.EntryPrice <= 0 {
+				return fmt.Errorf("%s订单必须提供entry_price", d.OrderType)
+			}
+			if d.EntryPrice <= math.Min(d.StopLoss, d.TakeProfit) || d.EntryPrice >= math.Max(d.StopLoss, d.TakeProfit) {
+				return fmt.Errorf("entry_price(%.4f)必须在止损(%.4f)和止盈(%.4f)之间", d.EntryPrice, d.StopLoss, d.TakeProfit)
+			}
+		default:
+			return fmt.Errorf("无效的order_type: %s", d.OrderType)
+		}
+
+		// 验证跟踪止损回调比例（可选）
+		if d.TrailingStopPct != 0 && (d.TrailingStopPct < 0.1 || d.TrailingStopPct > 10) {
+			return fmt.Errorf("trailing_stop_pct必须在0.1-10之间: %.2f", d.TrailingStopPct)
+		}
+		if err := validateTakeProfitLevels(d); err != nil {
+			return err
+		}
+
 		// 验证风险回报比（必须≥1:3）
-		// 计算入场价（假设当前市价）
+		// 计算入场价（限价单使用挂单价，否则假设当前市价）
 		var entryPrice float64
-		if d.Action == "open_long" {
+		if d.EntryPrice > 0 {
+			entryPrice = d.EntryPrice
+		} else if d.Action == "open_long" {
 			// 做多：入场价在止损和止盈之间
 			entryPrice = d.StopLoss + (d.TakeProfit-d.StopLoss)*0.2 // 假设在20%位置入场
 		} else {
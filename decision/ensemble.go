@@ -0,0 +1,508 @@
+package decision
+
+import (
+	"danto/mcp"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnsemblePolicy 集成决策合并策略
+type EnsemblePolicy struct {
+	SizePolicy string // 仓位合并: "average"（平均仓位）或 "min"（最小仓位），默认average
+	MinVotes   int    // 执行动作所需的最少同意票数（0=过半数）
+}
+
+// ModelVote 单个模型的投票（完整输出）
+type ModelVote struct {
+	Model     string     `json:"model"`
+	CoTTrace  string     `json:"cot_trace"`
+	Decisions []Decision `json:"decisions"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// GetEnsembleDecision 并行询问多个模型（相同prompt），按策略合并决策
+func GetEnsembleDecision(ctx *Context, clients []*mcp.Client, policy EnsemblePolicy) (*FullDecision, error) {
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("集成决策至少需要一个模型")
+	}
+
+	// 1. 为所有币种获取市场数据
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	}
+
+	// 2. 所有模型使用相同的prompt
+	prompt := promptTemplateFor(ctx)
+	rendered, err := renderWithinBudget(ctx, prompt, BudgetForClients(clients...))
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 并行调用各模型
+	votes := make([]ModelVote, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *mcp.Client) {
+			defer wg.Done()
+			votes[i] = collectVote(ctx, client, rendered.System, rendered.User)
+		}(i, client)
+	}
+	wg.Wait()
+
+	// 4. 合并有效投票
+	var validVotes []ModelVote
+	for _, vote := range votes {
+		if vote.Error != "" {
+			log.Printf("⚠️  [集成] %s 投票无效: %s", vote.Model, vote.Error)
+			continue
+		}
+		validVotes = append(validVotes, vote)
+	}
+
+	decision := &FullDecision{
+		UserPrompt:       rendered.User,
+		PromptVersion:    prompt.Version,
+		PromptTokens:     rendered.Tokens,
+		PromptReductions: rendered.Reductions,
+		CoTTrace:         joinVoteCoT(votes),
+		Votes:            votes,
+		Timestamp:        time.Now(),
+	}
+
+	if len(validVotes) == 0 {
+		decision.Decisions = []Decision{}
+		return decision, fmt.Errorf("所有模型均未给出有效决策")
+	}
+
+	decision.Decisions = mergeVotes(validVotes, len(clients), ctx.Positions, policy, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	return decision, nil
+}
+
+// collectVote 调用单个模型并解析其决策
+func collectVote(ctx *Context, client *mcp.Client, systemPrompt, userPrompt string) ModelVote {
+	vote := ModelVote{Model: client.Name()}
+
+	aiResponse, err := client.CallWithMessages(systemPrompt, userPrompt)
+	if err != nil {
+		vote.Error = fmt.Sprintf("调用AI API失败: %v", err)
+		return vote
+	}
+
+	parsed, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	if parsed != nil {
+		vote.CoTTrace = parsed.CoTTrace
+		vote.Decisions = parsed.Decisions
+	}
+	if err != nil {
+		vote.Error = fmt.Sprintf("解析AI响应失败: %v", err)
+	}
+	return vote
+}
+
+// joinVoteCoT 拼接所有模型的思维链
+func joinVoteCoT(votes []ModelVote) string {
+	var sb strings.Builder
+	for _, vote := range votes {
+		sb.WriteString(fmt.Sprintf("=== %s ===\n", vote.Model))
+		if vote.Error != "" {
+			sb.WriteString(fmt.Sprintf("（无效投票: %s）\n", vote.Error))
+		}
+		sb.WriteString(vote.CoTTrace)
+		sb.WriteString("\n\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// mergeVotes 按币种合并投票：每个动作分别多数表决（同一模型可对一个币种给出多个动作，如先平多再开空）（开仓还需订单类型一致），限价挂单价取中位数，仓位平均/取最小，止损和跟踪止损取最严格，止盈阶梯取第一个
+// totalModels为配置的模型总数（含投票无效的模型），过半数按总数计算，无效投票相当于弃权；
+// positions用于确定未给出side的止损调整属于哪个方向的持仓
+func mergeVotes(votes []ModelVote, totalModels int, positions []PositionInfo, policy EnsemblePolicy, accountEquity float64, btcEthLeverage, altcoinLeverage int) []Decision {
+	if totalModels < len(votes) {
+		totalModels = len(votes)
+	}
+	required := policy.MinVotes
+	if required <= 0 {
+		required = totalModels/2 + 1 // 严格过半数
+	}
+
+	// 保持币种首次出现的顺序
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, vote := range votes {
+		for _, d := range vote.Decisions {
+			if !seen[d.Symbol] {
+				seen[d.Symbol] = true
+				symbols = append(symbols, d.Symbol)
+			}
+		}
+	}
+
+	var merged []Decision
+	for _, symbol := range symbols {
+		merged = append(merged, mergeSymbolVotes(votes, symbol, totalModels, required, positions, policy, accountEquity, btcEthLeverage, altcoinLeverage)...)
+	}
+
+	return merged
+}
+
+// mergeSymbolVotes 合并所有模型对一个币种的投票
+// 模型对该币种的每个可执行动作各投一票（没有可执行动作时投hold/wait），每轮选出得票最多的动作，
+// 下一轮只统计同意了已通过动作的模型，因此只有同一批模型共同给出的动作组合才会一起执行；
+// 未达成共识的其余动作不执行，写日志说明票型
+func mergeSymbolVotes(votes []ModelVote, symbol string, totalModels, required int, positions []PositionInfo, policy EnsemblePolicy, accountEquity float64, btcEthLeverage, altcoinLeverage int) []Decision {
+	ballotsByModel := make([][]Decision, len(votes))
+	voters := make([]int, 0, len(votes))
+	for i, vote := range votes {
+		ballotsByModel[i] = symbolBallots(vote.Decisions, symbol)
+		voters = append(voters, i)
+	}
+
+	var merged []Decision
+	var mergedKeys []string
+	passed := make(map[string]bool)
+	for {
+		ballots := make(map[string][]Decision)
+		supporters := make(map[string][]int)
+		var actionOrder []string
+		for _, i := range voters {
+			for _, d := range ballotsByModel[i] {
+				key := ballotKey(d)
+				if passed[key] {
+					continue
+				}
+				if _, exists := ballots[key]; !exists {
+					actionOrder = append(actionOrder, key)
+				}
+				ballots[key] = append(ballots[key], d)
+				supporters[key] = append(supporters[key], i)
+			}
+		}
+
+		// 找出得票最多的可执行动作（平仓与其他动作票数相同时平仓优先，之后再表决其余动作）
+		bestAction := ""
+		bestCount := 0
+		tie := false
+		for _, action := range actionOrder {
+			if !isActionable(action) {
+				continue
+			}
+			count := len(ballots[action])
+			switch {
+			case count > bestCount, count == bestCount && isClose(action) && !isClose(bestAction):
+				bestAction, bestCount, tie = action, count, false
+			case count == bestCount && isClose(action) == isClose(bestAction):
+				tie = true
+			}
+		}
+
+		if bestAction == "" || tie || bestCount < required {
+			if len(merged) == 0 {
+				merged = append(merged, passiveDecision(symbol, ballots, actionOrder, totalModels))
+			} else if bestAction != "" {
+				log.Printf("⚠️  [集成] %s 其余动作未达成共识，不执行: %s", symbol, ballotTally(ballots, actionOrder, totalModels))
+			}
+			break
+		}
+
+		d := mergeAgreeing(symbol, ballots[bestAction][0].Action, ballots[bestAction], policy, totalModels)
+		if d.Action == "update_stop_loss" {
+			d.StopLoss = strictestStopLoss(ballots[bestAction], positionSide(d.Side, symbol, positions))
+		}
+		if err := validateDecision(&d, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
+			log.Printf("⚠️  [集成] %s %s 合并后验证失败，改为观望: %v", symbol, bestAction, err)
+			if len(merged) == 0 {
+				merged = append(merged, Decision{
+					Symbol:    symbol,
+					Action:    "wait",
+					Reasoning: fmt.Sprintf("集成共识 %s %d/%d，但合并后参数无效: %v", bestAction, bestCount, totalModels, err),
+				})
+			}
+			break
+		}
+		merged = append(merged, d)
+		mergedKeys = append(mergedKeys, bestAction)
+		passed[bestAction] = true
+		voters = supporters[bestAction]
+	}
+
+	// 按同意的模型给出的顺序执行（如先平仓再反向开仓）
+	if len(mergedKeys) > 1 {
+		position := make(map[string]int)
+		for i, d := range ballotsByModel[voters[0]] {
+			position[ballotKey(d)] = i
+		}
+		sort.SliceStable(merged, func(i, j int) bool {
+			return position[mergedKeys[i]] < position[mergedKeys[j]]
+		})
+	}
+	return merged
+}
+
+// ballotKey 投票分组的键：开仓按动作+订单类型分组（市价和限价开仓不算同意），其他动作按动作分组
+func ballotKey(d Decision) string {
+	if d.Action != "open_long" && d.Action != "open_short" {
+		return d.Action
+	}
+	orderType := d.OrderType
+	if orderType == "" {
+		orderType = "market"
+	}
+	if orderType == "market" {
+		return d.Action
+	}
+	return d.Action + ":" + orderType
+}
+
+// medianEntryPrice 限价开仓挂单价的中位数（偶数个时取中间两个的平均），避免被单个模型的极端挂单价带偏
+func medianEntryPrice(agreeing []Decision) float64 {
+	prices := make([]float64, 0, len(agreeing))
+	for _, d := range agreeing {
+		prices = append(prices, d.EntryPrice)
+	}
+	sort.Float64s(prices)
+	mid := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return (prices[mid-1] + prices[mid]) / 2
+	}
+	return prices[mid]
+}
+
+// positionSide 止损调整针对的持仓方向：决策给出side时直接使用，否则取该币种唯一持仓的方向（无法确定时为空）
+func positionSide(side, symbol string, positions []PositionInfo) string {
+	if side != "" {
+		return side
+	}
+	for _, pos := range positions {
+		if pos.Symbol != symbol {
+			continue
+		}
+		if side != "" {
+			return ""
+		}
+		side = pos.Side
+	}
+	return side
+}
+
+// strictestStopLoss 最严格的止损价：多仓取最高，空仓取最低；方向未知时无法判断，取平均
+func strictestStopLoss(agreeing []Decision, side string) float64 {
+	stopLoss, sum := agreeing[0].StopLoss, 0.0
+	for _, d := range agreeing {
+		sum += d.StopLoss
+		if (side == "long" && d.StopLoss > stopLoss) || (side == "short" && d.StopLoss < stopLoss) {
+			stopLoss = d.StopLoss
+		}
+	}
+	if side != "long" && side != "short" {
+		return sum / float64(len(agreeing))
+	}
+	return stopLoss
+}
+
+// symbolBallots 某个模型对指定币种的选票：所有可执行动作（按给出顺序，同一动作只计一票），没有时取第一个hold/wait
+func symbolBallots(decisions []Decision, symbol string) []Decision {
+	var ballots []Decision
+	var fallback []Decision
+	seen := make(map[string]bool)
+	for _, d := range decisions {
+		if d.Symbol != symbol {
+			continue
+		}
+		if !isActionable(d.Action) {
+			if fallback == nil {
+				fallback = []Decision{d}
+			}
+			continue
+		}
+		if key := ballotKey(d); !seen[key] {
+			seen[key] = true
+			ballots = append(ballots, d)
+		}
+	}
+	if len(ballots) == 0 {
+		return fallback
+	}
+	return ballots
+}
+
+// isActionable 是否为需要执行的动作（非hold/wait）
+func isActionable(action string) bool {
+	return action != "hold" && action != "wait"
+}
+
+// isClose 是否为平仓动作
+func isClose(action string) bool {
+	return action == "close_long" || action == "close_short"
+}
+
+// passiveDecision 未达成共识时生成hold/wait决策，并记录票型
+func passiveDecision(symbol string, ballots map[string][]Decision, actionOrder []string, totalVotes int) Decision {
+	action := "wait"
+	if len(ballots["hold"]) > 0 {
+		action = "hold"
+	}
+
+	return Decision{
+		Symbol:    symbol,
+		Action:    action,
+		Reasoning: fmt.Sprintf("集成投票未达成共识: %s", ballotTally(ballots, actionOrder, totalVotes)),
+	}
+}
+
+// ballotTally 票型描述，如 "close_long 2/3, open_short 1/3"
+func ballotTally(ballots map[string][]Decision, actionOrder []string, totalVotes int) string {
+	var tally []string
+	for _, a := range actionOrder {
+		tally = append(tally, fmt.Sprintf("%s %d/%d", a, len(ballots[a]), totalVotes))
+	}
+	return strings.Join(tally, ", ")
+}
+
+// mergeAgreeing 合并同意同一动作的多个决策
+func mergeAgreeing(symbol, action string, agreeing []Decision, policy EnsemblePolicy, totalVotes int) Decision {
+	var reasons []string
+	for _, d := range agreeing {
+		reasons = append(reasons, d.Reasoning)
+	}
+
+	merged := Decision{
+		Symbol:    symbol,
+		Action:    action,
+		Reasoning: fmt.Sprintf("集成共识 %d/%d: %s", len(agreeing), totalVotes, strings.Join(reasons, " | ")),
+	}
+
+	// 调整止损止盈：新止损取最严格（由mergeVotes按持仓方向选取），新止盈取平均，持仓方向取第一个给出side的模型
+	if action == "update_stop_loss" || action == "update_take_profit" {
+		takeProfitSum := 0.0
+		for _, d := range agreeing {
+			takeProfitSum += d.TakeProfit
+			if merged.Side == "" {
+				merged.Side = d.Side
+			}
+		}
+		if action == "update_take_profit" {
+			merged.TakeProfit = takeProfitSum / float64(len(agreeing))
+		}
+		return merged
+	}
+
+	// 部分平仓：取最保守的平仓量（优先按比例取最小值，都给数量时取最小数量）
+	if action == "partial_close" {
+		for _, d := range agreeing {
+			if merged.Side == "" {
+				merged.Side = d.Side
+			}
+			if d.ClosePercentage > 0 && (merged.ClosePercentage == 0 || d.ClosePercentage < merged.ClosePercentage) {
+				merged.ClosePercentage = d.ClosePercentage
+			}
+			if d.CloseQuantity > 0 && (merged.CloseQuantity == 0 || d.CloseQuantity < merged.CloseQuantity) {
+				merged.CloseQuantity = d.CloseQuantity
+			}
+		}
+		if merged.ClosePercentage > 0 {
+			merged.CloseQuantity = 0
+		}
+		return merged
+	}
+
+	// 加仓：杠杆取最小，加仓金额按仓位策略合并，新的止损止盈取第一个给出的模型
+	if action == "add_to_position" {
+		first := agreeing[0]
+		merged.Leverage = first.Leverage
+		sizeSum, minSize := 0.0, first.PositionSizeUSD
+		for _, d := range agreeing {
+			if merged.Side == "" {
+				merged.Side = d.Side
+			}
+			if d.Leverage < merged.Leverage {
+				merged.Leverage = d.Leverage
+			}
+			if d.PositionSizeUSD < minSize {
+				minSize = d.PositionSizeUSD
+			}
+			sizeSum += d.PositionSizeUSD
+			if merged.StopLoss == 0 {
+				merged.StopLoss = d.StopLoss
+			}
+			if merged.TakeProfit == 0 {
+				merged.TakeProfit = d.TakeProfit
+			}
+		}
+		if policy.SizePolicy == "min" {
+			merged.PositionSizeUSD = minSize
+		} else {
+			merged.PositionSizeUSD = sizeSum / float64(len(agreeing))
+		}
+		return merged
+	}
+
+	if action != "open_long" && action != "open_short" {
+		return merged
+	}
+
+	first := agreeing[0]
+	merged.Leverage = first.Leverage
+	merged.StopLoss = first.StopLoss
+	merged.RiskUSD = first.RiskUSD
+	// 同意的模型订单类型相同（按ballotKey分组），限价单挂单价取中位数
+	merged.OrderType = first.OrderType
+	if merged.OrderType != "" && merged.OrderType != "market" {
+		merged.EntryPrice = medianEntryPrice(agreeing)
+	}
+	sizeSum, minSize := 0.0, first.PositionSizeUSD
+	takeProfitSum := 0.0
+	confidenceSum := 0
+
+	for _, d := range agreeing {
+		// 杠杆取最小
+		if d.Leverage < merged.Leverage {
+			merged.Leverage = d.Leverage
+		}
+		// 止损取最严格（多单取最高，空单取最低）
+		if action == "open_long" && d.StopLoss > merged.StopLoss {
+			merged.StopLoss = d.StopLoss
+		}
+		if action == "open_short" && d.StopLoss < merged.StopLoss {
+			merged.StopLoss = d.StopLoss
+		}
+		if d.RiskUSD < merged.RiskUSD {
+			merged.RiskUSD = d.RiskUSD
+		}
+		if d.PositionSizeUSD < minSize {
+			minSize = d.PositionSizeUSD
+		}
+		sizeSum += d.PositionSizeUSD
+		takeProfitSum += d.TakeProfit
+		confidenceSum += d.Confidence
+		// 跟踪止损回调取最紧（只要有模型给出）
+		if d.TrailingStopPct > 0 && (merged.TrailingStopPct == 0 || d.TrailingStopPct < merged.TrailingStopPct) {
+			merged.TrailingStopPct = d.TrailingStopPct
+		}
+		// 止盈阶梯无法平均，取第一个给出阶梯的模型
+		if len(merged.TakeProfitLevels) == 0 {
+			merged.TakeProfitLevels = d.TakeProfitLevels
+		}
+	}
+
+	n := float64(len(agreeing))
+	if policy.SizePolicy == "min" {
+		merged.PositionSizeUSD = minSize
+	} else {
+		merged.PositionSizeUSD = sizeSum / n
+	}
+	merged.TakeProfit = takeProfitSum / n
+	merged.Confidence = confidenceSum / len(agreeing)
+	// 阶梯中已有跟踪止损档时，不再叠加其他模型给出的整体跟踪止损
+	for _, level := range merged.TakeProfitLevels {
+		if level.TrailingStopPct > 0 {
+			merged.TrailingStopPct = 0
+		}
+	}
+
+	return merged
+}
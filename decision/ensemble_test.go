@@ -0,0 +1,95 @@
+package decision
+
+import "testing"
+
+func TestMergeVotesMajorityCountsAllModels(t *testing.T) {
+	votes := []ModelVote{{
+		Model:     "a",
+		Decisions: []Decision{{Symbol: "BTCUSDT", Action: "close_long"}},
+	}}
+
+	merged := mergeVotes(votes, 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 1 || merged[0].Action != "wait" {
+		t.Fatalf("一票有效（共3个模型）不应达成多数: %+v", merged)
+	}
+
+	merged = mergeVotes(append(votes, votes[0]), 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 1 || merged[0].Action != "close_long" {
+		t.Fatalf("2/3同意应执行: %+v", merged)
+	}
+}
+
+func TestMergeVotesStrictestStopLoss(t *testing.T) {
+	vote := func(stopLoss float64, side string) ModelVote {
+		return ModelVote{Decisions: []Decision{{Symbol: "BTCUSDT", Action: "update_stop_loss", StopLoss: stopLoss, Side: side}}}
+	}
+
+	tests := []struct {
+		name      string
+		votes     []ModelVote
+		positions []PositionInfo
+		want      float64
+	}{
+		{"多仓取最高", []ModelVote{vote(95, "long"), vote(97, "long")}, nil, 97},
+		{"空仓取最低", []ModelVote{vote(105, "short"), vote(103, "short")}, nil, 103},
+		{"未给side按持仓方向", []ModelVote{vote(105, ""), vote(103, "")}, []PositionInfo{{Symbol: "BTCUSDT", Side: "short"}}, 103},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeVotes(tt.votes, len(tt.votes), tt.positions, EnsemblePolicy{}, 1000, 5, 5)
+			if len(merged) != 1 || merged[0].Action != "update_stop_loss" || merged[0].StopLoss != tt.want {
+				t.Fatalf("got %+v, want stop_loss %.0f", merged, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeVotesLimitEntry(t *testing.T) {
+	open := func(orderType string, entryPrice float64) ModelVote {
+		return ModelVote{Decisions: []Decision{{
+			Symbol: "BTCUSDT", Action: "open_long", OrderType: orderType, EntryPrice: entryPrice,
+			Leverage: 5, PositionSizeUSD: 100, StopLoss: 90, TakeProfit: 130, Confidence: 80,
+		}}}
+	}
+
+	merged := mergeVotes([]ModelVote{open("limit", 100), open("limit", 98), open("limit", 101)}, 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 1 || merged[0].Action != "open_long" || merged[0].OrderType != "limit" || merged[0].EntryPrice != 100 {
+		t.Fatalf("限价开仓应保留订单类型并取挂单价中位数: %+v", merged)
+	}
+
+	merged = mergeVotes([]ModelVote{open("limit", 100), open("", 0), open("market", 0)}, 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 1 || merged[0].Action != "open_long" || merged[0].OrderType != "" || merged[0].EntryPrice != 0 {
+		t.Fatalf("2/3市价开仓应按市价执行: %+v", merged)
+	}
+
+	merged = mergeVotes([]ModelVote{open("limit", 100), open("post_only", 99), open("", 0)}, 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 1 || merged[0].Action == "open_long" {
+		t.Fatalf("订单类型不一致不应达成共识: %+v", merged)
+	}
+}
+
+func TestMergeVotesCloseThenReverse(t *testing.T) {
+	reverse := ModelVote{Decisions: []Decision{
+		{Symbol: "BTCUSDT", Action: "close_long", Reasoning: "趋势反转"},
+		{Symbol: "BTCUSDT", Action: "open_short", Leverage: 5, PositionSizeUSD: 100, StopLoss: 110, TakeProfit: 80, Confidence: 80},
+	}}
+	closeOnly := ModelVote{Decisions: []Decision{{Symbol: "BTCUSDT", Action: "close_long"}}}
+
+	merged := mergeVotes([]ModelVote{reverse, reverse, closeOnly}, 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 2 || merged[0].Action != "close_long" || merged[1].Action != "open_short" {
+		t.Fatalf("多数模型先平多再开空，两个动作都应按顺序执行: %+v", merged)
+	}
+
+	// 只有一个模型要反向开仓：平仓通过，开空未达成共识
+	merged = mergeVotes([]ModelVote{reverse, closeOnly, closeOnly}, 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 1 || merged[0].Action != "close_long" {
+		t.Fatalf("只有平仓达成共识: %+v", merged)
+	}
+
+	// 开空的票只统计同意平多的模型
+	openShort := ModelVote{Decisions: []Decision{{Symbol: "BTCUSDT", Action: "open_short", Leverage: 5, PositionSizeUSD: 100, StopLoss: 110, TakeProfit: 80}}}
+	merged = mergeVotes([]ModelVote{reverse, closeOnly, openShort}, 3, nil, EnsemblePolicy{}, 1000, 5, 5)
+	if len(merged) != 1 || merged[0].Action != "close_long" {
+		t.Fatalf("开空的两票来自不同的平仓意见，不应执行: %+v", merged)
+	}
+}
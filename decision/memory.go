@@ -0,0 +1,88 @@
+package decision
+
+import (
+	"strings"
+)
+
+const (
+	maxCycleSummaryRunes = 300 // 每个周期思维链摘要的最大长度
+	maxThesisRunes       = 400 // 每条入场理由的最大长度
+	memoryEntryOverhead  = 12  // 每条记忆的格式开销（token）
+)
+
+// MemoryDigest 跨周期记忆摘要（最近N个周期的思考与动作 + 当前持仓的入场理由）
+type MemoryDigest struct {
+	Cycles []CycleMemory    // 最近的决策周期（从旧到新）
+	Theses []PositionThesis // 当前持仓的入场理由
+}
+
+// CycleMemory 单个决策周期的记忆
+type CycleMemory struct {
+	CycleNumber int
+	Time        string   // 周期时间（MM-DD HH:MM）
+	Equity      float64  // 当时账户净值
+	Summary     string   // 思维链摘要
+	Actions     []string // 执行的动作（如 "BTCUSDT open_long ✓"）
+}
+
+// PositionThesis 持仓的入场理由
+type PositionThesis struct {
+	Symbol     string
+	Side       string // "long" or "short"
+	OpenTime   string // 开仓时间（MM-DD HH:MM）
+	EntryPrice float64
+	StopLoss   float64
+	TakeProfit float64
+	Thesis     string // 开仓时AI给出的理由
+}
+
+// Fit 按token预算裁剪记忆
+// 先截断摘要和理由，仍超预算则从最旧的周期开始丢弃，最后才丢弃入场理由
+func (m *MemoryDigest) Fit(tokenBudget int, estimate func(string) int) {
+	for i := range m.Cycles {
+		m.Cycles[i].Summary = SummarizeText(m.Cycles[i].Summary, maxCycleSummaryRunes)
+	}
+	for i := range m.Theses {
+		m.Theses[i].Thesis = SummarizeText(m.Theses[i].Thesis, maxThesisRunes)
+	}
+
+	for len(m.Cycles) > 0 && m.EstimateTokens(estimate) > tokenBudget {
+		m.Cycles = m.Cycles[1:]
+	}
+	for len(m.Theses) > 0 && m.EstimateTokens(estimate) > tokenBudget {
+		m.Theses = m.Theses[:len(m.Theses)-1]
+	}
+}
+
+// EstimateTokens 用estimate（与prompt预算相同的估算函数）估算记忆渲染后占用的token数
+func (m *MemoryDigest) EstimateTokens(estimate func(string) int) int {
+	if estimate == nil {
+		estimate = PromptBudget{}.Estimator()
+	}
+	total := 0
+	for _, c := range m.Cycles {
+		total += memoryEntryOverhead + estimate(c.Summary)
+		for _, a := range c.Actions {
+			total += estimate(a) + 1
+		}
+	}
+	for _, t := range m.Theses {
+		total += memoryEntryOverhead + estimate(t.Thesis)
+	}
+	return total
+}
+
+// IsEmpty 是否没有任何记忆
+func (m *MemoryDigest) IsEmpty() bool {
+	return m == nil || (len(m.Cycles) == 0 && len(m.Theses) == 0)
+}
+
+// SummarizeText 压缩空白并截断到最多maxRunes个字符
+func SummarizeText(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return string(runes[:maxRunes]) + "…"
+}
@@ -0,0 +1,257 @@
+package decision
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"danto/market"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 模板目录中的文件名
+const (
+	systemTemplateFile = "system.tmpl"
+	userTemplateFile   = "user.tmpl"
+)
+
+//go:embed prompts
+var builtinPrompts embed.FS
+
+// PromptTemplate 提示词模板（System + User），渲染数据为 *Context
+type PromptTemplate struct {
+	Name     string // 模板名称（内置名或目录路径）
+	Language string // 模板语言（决定辅助函数输出的语言，如市场数据字段名）
+	Version  string // 模板内容哈希（用于把绩效归因到prompt版本）
+	system   *template.Template
+	user     *template.Template
+}
+
+// PromptLanguages 内置提示词包支持的语言
+var PromptLanguages = []string{"zh", "en"}
+
+// builtinPromptTemplates 内置提示词包（按语言）
+var builtinPromptTemplates = loadBuiltinPrompts()
+
+// DefaultPromptTemplate 内置默认模板（中文）
+var DefaultPromptTemplate = builtinPromptTemplates["zh"]
+
+// BuiltinPromptTemplate 获取指定语言的内置提示词包（空字符串=中文）
+func BuiltinPromptTemplate(lang string) (*PromptTemplate, error) {
+	if lang == "" {
+		return DefaultPromptTemplate, nil
+	}
+	tmpl, ok := builtinPromptTemplates[lang]
+	if !ok {
+		return nil, fmt.Errorf("不支持的提示词语言: %s", lang)
+	}
+	return tmpl, nil
+}
+
+// LoadPromptTemplate 从目录加载模板（目录下需有 system.tmpl 和 user.tmpl）
+// lang 决定辅助函数的输出语言；修改模板文件后重启即可生效，无需重新编译
+func LoadPromptTemplate(dir, lang string) (*PromptTemplate, error) {
+	if lang == "" {
+		lang = "zh"
+	}
+	if _, ok := builtinPromptTemplates[lang]; !ok {
+		return nil, fmt.Errorf("不支持的提示词语言: %s", lang)
+	}
+
+	systemText, err := os.ReadFile(filepath.Join(dir, systemTemplateFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取system模板失败: %w", err)
+	}
+	userText, err := os.ReadFile(filepath.Join(dir, userTemplateFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取user模板失败: %w", err)
+	}
+	return newPromptTemplate(dir, lang, string(systemText), string(userText))
+}
+
+// loadBuiltinPrompts 加载所有内置提示词包（内置模板错误属于编程错误，直接panic）
+func loadBuiltinPrompts() map[string]*PromptTemplate {
+	templates := make(map[string]*PromptTemplate)
+	for _, lang := range PromptLanguages {
+		systemText, err := builtinPrompts.ReadFile("prompts/" + lang + "/" + systemTemplateFile)
+		if err != nil {
+			panic(err)
+		}
+		userText, err := builtinPrompts.ReadFile("prompts/" + lang + "/" + userTemplateFile)
+		if err != nil {
+			panic(err)
+		}
+		tmpl, err := newPromptTemplate(lang, lang, string(systemText), string(userText))
+		if err != nil {
+			panic(err)
+		}
+		templates[lang] = tmpl
+	}
+	return templates
+}
+
+// newPromptTemplate 解析模板并计算版本哈希
+func newPromptTemplate(name, lang, systemText, userText string) (*PromptTemplate, error) {
+	funcs := promptFuncs(lang)
+	system, err := template.New(systemTemplateFile).Funcs(funcs).Parse(systemText)
+	if err != nil {
+		return nil, fmt.Errorf("解析system模板失败: %w", err)
+	}
+	user, err := template.New(userTemplateFile).Funcs(funcs).Parse(userText)
+	if err != nil {
+		return nil, fmt.Errorf("解析user模板失败: %w", err)
+	}
+
+	hash := sha256.Sum256([]byte(lang + "\x00" + systemText + "\x00" + userText))
+	return &PromptTemplate{
+		Name:     name,
+		Language: lang,
+		Version:  hex.EncodeToString(hash[:])[:12],
+		system:   system,
+		user:     user,
+	}, nil
+}
+
+// Render 用交易上下文渲染 System Prompt 和 User Prompt
+func (p *PromptTemplate) Render(ctx *Context) (systemPrompt, userPrompt string, err error) {
+	var buf bytes.Buffer
+	if err := p.system.Execute(&buf, ctx); err != nil {
+		return "", "", fmt.Errorf("渲染system模板失败: %w", err)
+	}
+	systemPrompt = buf.String()
+
+	buf.Reset()
+	if err := p.user.Execute(&buf, ctx); err != nil {
+		return "", "", fmt.Errorf("渲染user模板失败: %w", err)
+	}
+	userPrompt = buf.String()
+
+	return systemPrompt, userPrompt, nil
+}
+
+// promptTemplateFor 获取上下文使用的模板（未指定则使用内置默认模板）
+func promptTemplateFor(ctx *Context) *PromptTemplate {
+	if ctx.PromptTemplate != nil {
+		return ctx.PromptTemplate
+	}
+	return DefaultPromptTemplate
+}
+
+// promptFuncs 模板中可用的辅助函数（输出文字的函数按lang本地化）
+func promptFuncs(lang string) template.FuncMap {
+	zh := lang == "zh"
+
+	return template.FuncMap{
+		"upper": strings.ToUpper,
+		"join":  strings.Join,
+		"add":   func(a, b int) int { return a + b },
+		"imul":  func(a, b int) int { return a * b },
+		"mul":   func(a, b float64) float64 { return a * b },
+		"pct":   func(a, b float64) float64 { return (a / b) * 100 },
+
+		// formatMarket 输出单个币种的完整市场数据
+		"formatMarket": func(data *market.Data) string {
+			return market.FormatWithLanguage(data, lang)
+		},
+
+		// exchangeName 交易平台显示名称
+		"exchangeName": func(exchange string) string {
+			switch exchange {
+			case "", "binance":
+				if zh {
+					return "币安"
+				}
+				return "Binance"
+			case "hyperliquid":
+				return "Hyperliquid"
+			case "aster":
+				return "Aster"
+			case "delta":
+				return "Delta Exchange"
+			default:
+				return exchange
+			}
+		},
+
+		// scanMinutes 扫描间隔分钟数（未配置时按3分钟）
+		"scanMinutes": func(ctx *Context) int {
+			if ctx.ScanIntervalMinutes > 0 {
+				return ctx.ScanIntervalMinutes
+			}
+			return 3
+		},
+
+		// holdingDuration 持仓时长描述（" | 持仓时长..."，无记录时为空）
+		"holdingDuration": func(updateTime int64) string {
+			if updateTime <= 0 {
+				return ""
+			}
+			durationMin := (time.Now().UnixMilli() - updateTime) / (1000 * 60)
+			if zh {
+				if durationMin < 60 {
+					return fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
+				}
+				return fmt.Sprintf(" | 持仓时长%d小时%d分钟", durationMin/60, durationMin%60)
+			}
+			if durationMin < 60 {
+				return fmt.Sprintf(" | Held %dm", durationMin)
+			}
+			return fmt.Sprintf(" | Held %dh%dm", durationMin/60, durationMin%60)
+		},
+
+		// fundingSummary 持仓的资金费描述（" | 资金费..."：累计收支、当前费率、距下次结算的时间和预计收支，无数据时为空）
+		"fundingSummary": func(pos PositionInfo) string {
+			if pos.NextFundingTime <= 0 {
+				if pos.Funding == 0 {
+					return ""
+				}
+				if zh {
+					return fmt.Sprintf(" | 资金费累计%+.2f", pos.Funding)
+				}
+				return fmt.Sprintf(" | Funding accrued %+.2f", pos.Funding)
+			}
+			untilMin := (pos.NextFundingTime - time.Now().UnixMilli()) / (1000 * 60)
+			if untilMin < 0 {
+				untilMin = 0
+			}
+			if zh {
+				return fmt.Sprintf(" | 资金费累计%+.2f，费率%.4f%%，%d分钟后结算预计%+.2f", pos.Funding, pos.FundingRate*100, untilMin, pos.ProjectedFunding)
+			}
+			return fmt.Sprintf(" | Funding accrued %+.2f, rate %.4f%%, next settlement in %dm est. %+.2f", pos.Funding, pos.FundingRate*100, untilMin, pos.ProjectedFunding)
+		},
+
+		// sourceTag 候选币种来源标记
+		"sourceTag": func(sources []string) string {
+			if len(sources) > 1 {
+				if zh {
+					return " (AI500+OI_Top双重信号)"
+				}
+				return " (AI500+OI_Top dual signal)"
+			}
+			if len(sources) == 1 && sources[0] == "oi_top" {
+				if zh {
+					return " (OI_Top持仓增长)"
+				}
+				return " (OI_Top open interest growth)"
+			}
+			return ""
+		},
+
+		// sharpeRatio 从历史表现中提取夏普比率
+		"sharpeRatio": func(performance interface{}) float64 {
+			var perfData struct {
+				SharpeRatio float64 `json:"sharpe_ratio"`
+			}
+			if jsonData, err := json.Marshal(performance); err == nil {
+				json.Unmarshal(jsonData, &perfData)
+			}
+			return perfData.SharpeRatio
+		},
+	}
+}
@@ -0,0 +1,269 @@
+package decision
+
+import (
+	"danto/market"
+	"danto/mcp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	defaultMaxToolIterations = 5    // 每个周期默认最多几轮工具调用
+	maxToolResultRunes       = 6000 // 单次工具结果返回给模型的最大长度
+)
+
+// ToolCallRecord 工具调用模式下的一次工具调用记录
+type ToolCallRecord struct {
+	Iteration int    `json:"iteration"` // 第几轮对话
+	Name      string `json:"name"`      // 工具名称
+	Arguments string `json:"arguments"` // 调用参数（JSON）
+	Result    string `json:"result"`    // 返回给模型的结果
+	Error     string `json:"error,omitempty"`
+}
+
+// decisionTools 工具调用模式下提供给模型的工具
+var decisionTools = []mcp.Tool{
+	{
+		Name:        "get_klines",
+		Description: "Get OHLCV klines for a symbol at any timeframe. Returns rows of [open_time_ms, open, high, low, close, volume], oldest first.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"symbol":   map[string]interface{}{"type": "string", "description": "e.g. BTCUSDT"},
+				"interval": map[string]interface{}{"type": "string", "enum": []string{"1m", "3m", "5m", "15m", "30m", "1h", "2h", "4h", "6h", "12h", "1d"}},
+				"limit":    map[string]interface{}{"type": "integer", "description": "number of klines, 1-100 (default 30)"},
+			},
+			"required": []string{"symbol", "interval"},
+		},
+	},
+	{
+		Name:        "get_order_book",
+		Description: "Get the current order book (bids and asks) for a symbol.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"symbol": map[string]interface{}{"type": "string"},
+				"limit":  map[string]interface{}{"type": "integer", "enum": []int{5, 10, 20}, "description": "depth levels per side (default 10)"},
+			},
+			"required": []string{"symbol"},
+		},
+	},
+	{
+		Name:        "get_funding_history",
+		Description: "Get recent funding rate settlements for a symbol, oldest first.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"symbol": map[string]interface{}{"type": "string"},
+				"limit":  map[string]interface{}{"type": "integer", "description": "number of records, 1-50 (default 10)"},
+			},
+			"required": []string{"symbol"},
+		},
+	},
+	{
+		Name:        "get_trade_history",
+		Description: "Get this trader's own closed trades on a symbol (entry, exit, PnL, holding time).",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"symbol": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"symbol"},
+		},
+	},
+}
+
+// GetToolDecision 工具调用模式：prompt只带摘要数据，模型可按需调用工具获取更多数据，最后输出决策
+func GetToolDecision(ctx *Context, mcpClient *mcp.Client, maxIterations int) (*FullDecision, error) {
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxToolIterations
+	}
+
+	// 1. 获取市场数据（用于流动性过滤和摘要）
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	}
+
+	// 2. 候选币种只提供摘要指标，细节由模型通过工具获取
+	summary := cloneForCompression(ctx)
+	positionSymbols := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		positionSymbols[pos.Symbol] = true
+	}
+	for symbol, data := range summary.MarketDataMap {
+		if !positionSymbols[symbol] {
+			summary.MarketDataMap[symbol] = compressMarketData(data, 0)
+		}
+	}
+
+	prompt := promptTemplateFor(ctx)
+	rendered, err := renderWithinBudget(summary, prompt, BudgetForClients(mcpClient))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []mcp.Message{
+		{Role: "system", Content: rendered.System + toolInstructions(prompt.Language, maxIterations)},
+		{Role: "user", Content: rendered.User},
+	}
+
+	// 3. 多轮对话：执行模型请求的工具，直到给出最终决策或达到轮数上限
+	var transcript []ToolCallRecord
+	var aiResponse string
+	for iteration := 1; ; iteration++ {
+		tools := decisionTools
+		if iteration > maxIterations {
+			// 超过上限：不再提供工具，要求直接给出决策
+			tools = nil
+			messages = append(messages, mcp.Message{Role: "user", Content: toolLimitNotice(prompt.Language)})
+		}
+
+		response, err := mcpClient.CallWithTools(messages, tools)
+		if err != nil {
+			return &FullDecision{UserPrompt: rendered.User, ToolCalls: transcript, Timestamp: time.Now()},
+				fmt.Errorf("调用AI API失败: %w", err)
+		}
+
+		if len(response.ToolCalls) == 0 || tools == nil {
+			aiResponse = response.Content
+			break
+		}
+
+		messages = append(messages, mcp.Message{Role: "assistant", Content: response.Content, ToolCalls: response.ToolCalls})
+		for _, call := range response.ToolCalls {
+			record := ToolCallRecord{Iteration: iteration, Name: call.Name, Arguments: call.Arguments}
+			result, err := executeTool(ctx, call)
+			if err != nil {
+				record.Error = err.Error()
+				result = fmt.Sprintf(`{"error": %q}`, err.Error())
+			}
+			record.Result = SummarizeText(result, maxToolResultRunes)
+			transcript = append(transcript, record)
+			log.Printf("🔧 [工具调用 #%d] %s %s", iteration, call.Name, call.Arguments)
+
+			messages = append(messages, mcp.Message{Role: "tool", Content: record.Result, ToolCallID: call.ID})
+		}
+	}
+
+	// 4. 解析最终决策
+	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	if decision == nil {
+		decision = &FullDecision{}
+	}
+	decision.Timestamp = time.Now()
+	decision.UserPrompt = rendered.User
+	decision.PromptVersion = prompt.Version
+	decision.PromptTokens = rendered.Tokens
+	decision.PromptReductions = rendered.Reductions
+	decision.ToolCalls = transcript
+	if err != nil {
+		return decision, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+	return decision, nil
+}
+
+// executeTool 在本地执行一次工具调用，返回JSON结果
+func executeTool(ctx *Context, call mcp.ToolCall) (string, error) {
+	var args struct {
+		Symbol   string `json:"symbol"`
+		Interval string `json:"interval"`
+		Limit    int    `json:"limit"`
+	}
+	if call.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return "", fmt.Errorf("参数解析失败: %w", err)
+		}
+	}
+	if args.Symbol == "" {
+		return "", fmt.Errorf("缺少symbol参数")
+	}
+	symbol := market.Normalize(args.Symbol)
+
+	var result interface{}
+	switch call.Name {
+	case "get_klines":
+		if args.Interval == "" {
+			return "", fmt.Errorf("缺少interval参数")
+		}
+		klines, err := market.GetKlines(symbol, args.Interval, clampLimit(args.Limit, 30, 100))
+		if err != nil {
+			return "", fmt.Errorf("获取K线失败: %w", err)
+		}
+		rows := make([][]interface{}, 0, len(klines))
+		for _, k := range klines {
+			rows = append(rows, []interface{}{k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume})
+		}
+		result = map[string]interface{}{"symbol": symbol, "interval": args.Interval, "klines": rows}
+
+	case "get_order_book":
+		limit := 10
+		if args.Limit == 5 || args.Limit == 20 {
+			limit = args.Limit
+		}
+		book, err := market.GetOrderBook(symbol, limit)
+		if err != nil {
+			return "", fmt.Errorf("获取订单簿失败: %w", err)
+		}
+		result = book
+
+	case "get_funding_history":
+		history, err := market.GetFundingHistory(symbol, clampLimit(args.Limit, 10, 50))
+		if err != nil {
+			return "", fmt.Errorf("获取资金费率历史失败: %w", err)
+		}
+		result = map[string]interface{}{"symbol": symbol, "funding": history}
+
+	case "get_trade_history":
+		if ctx.TradeHistory == nil {
+			return "", fmt.Errorf("交易历史不可用")
+		}
+		trades, err := ctx.TradeHistory(symbol)
+		if err != nil {
+			return "", fmt.Errorf("获取交易历史失败: %w", err)
+		}
+		result = map[string]interface{}{"symbol": symbol, "trades": trades}
+
+	default:
+		return "", fmt.Errorf("未知工具: %s", call.Name)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// clampLimit 限制数量参数范围（<=0 使用默认值）
+func clampLimit(limit, defaultLimit, maxLimit int) int {
+	if limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+// toolInstructions 追加到System Prompt的工具使用说明
+func toolInstructions(lang string, maxIterations int) string {
+	if lang == "en" {
+		return fmt.Sprintf("\n\n# 🔧 Tools\n\n"+
+			"Candidate data above is summary-only. Use the tools (klines at any timeframe, order book, funding history, your own trade history) "+
+			"to look deeper at symbols that matter before deciding. You have at most %d rounds of tool calls; "+
+			"when you are done, reply with the chain of thought + JSON decision array as specified above.\n", maxIterations)
+	}
+	return fmt.Sprintf("\n\n# 🔧 工具\n\n"+
+		"上面的候选币种只提供摘要指标。决策前可调用工具（任意周期K线、订单簿、资金费率历史、你自己在该币种上的历史交易）深入查看重要币种。"+
+		"最多%d轮工具调用；分析完成后，按上面的格式输出思维链 + JSON决策数组。\n", maxIterations)
+}
+
+// toolLimitNotice 工具调用轮数用完时的提示
+func toolLimitNotice(lang string) string {
+	if lang == "en" {
+		return "Tool call limit reached. Output your final chain of thought + JSON decision array now."
+	}
+	return "工具调用次数已用完，请现在输出最终的思维链 + JSON决策数组。"
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"danto/config"
+	"danto/manager"
+	"danto/trader"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// killSwitchTimeout 等待运行中的实例完成紧急平仓的最长时间（需要等待进行中的交易周期结束）
+const killSwitchTimeout = 5 * time.Minute
+
+// runKillSwitch 命令行紧急平仓：优先调用运行中实例的 /api/kill-switch（同时停止其交易循环），
+// 没有运行中的实例时按配置直接连接各交易所平仓。全部清空时返回0，否则返回1
+func runKillSwitch(configFile string) int {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		log.Printf("❌ Failed to load configuration: %v", err)
+		return 1
+	}
+
+	reports, err := requestKillSwitch(cfg)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		log.Printf("⚠️  No running instance on port %d, flattening directly from %s", cfg.APIServerPort, configFile)
+		reports, err = flattenFromConfig(cfg)
+	}
+	if err != nil {
+		log.Printf("❌ Kill switch failed: %v", err)
+		return 1
+	}
+
+	if !printFlattenReports(reports) {
+		return 1
+	}
+	return 0
+}
+
+// requestKillSwitch 调用本机运行中实例的紧急平仓接口
+func requestKillSwitch(cfg *config.Config) ([]*trader.FlattenReport, error) {
+	url := fmt.Sprintf("http://localhost:%d/api/kill-switch", cfg.APIServerPort)
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.KillSwitchToken)
+
+	log.Printf("🚨 Requesting kill switch from %s ...", url)
+	client := &http.Client{Timeout: killSwitchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var result struct {
+		Traders []*trader.FlattenReport `json:"traders"`
+		Error   string                  `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid response (HTTP %d): %s", resp.StatusCode, string(body))
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, result.Error)
+	}
+	return result.Traders, nil
+}
+
+// flattenFromConfig 按配置创建所有启用的trader（不启动交易循环）并直接紧急平仓
+func flattenFromConfig(cfg *config.Config) ([]*trader.FlattenReport, error) {
+	traderManager := manager.NewTraderManager()
+	for _, traderCfg := range cfg.Traders {
+		if !traderCfg.Enabled {
+			continue
+		}
+		err := traderManager.AddTrader(
+			traderCfg,
+			cfg.CoinPoolAPIURL,
+			cfg.MaxDailyLoss,
+			cfg.MaxDrawdown,
+			cfg.StopTradingMinutes,
+			cfg.Leverage,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize trader %s: %w", traderCfg.Name, err)
+		}
+	}
+	return traderManager.FlattenAll(), nil
+}
+
+// printFlattenReports 打印各trader紧急平仓后的最终状态，全部清空时返回true
+func printFlattenReports(reports []*trader.FlattenReport) bool {
+	allFlat := true
+	fmt.Println()
+	fmt.Println("🚨 Kill switch result:")
+	for _, report := range reports {
+		status := "✅ flat"
+		if !report.Flat {
+			status = "❌ NOT flat"
+			allFlat = false
+		}
+		fmt.Printf("  • %s (%s, %s): %s after %d attempt(s)\n",
+			report.TraderName, report.TraderID, report.Exchange, status, report.Attempts)
+		for _, closed := range report.Closed {
+			fmt.Printf("      closed %v %v %v @ %v\n", closed["symbol"], closed["side"], closed["quantity"], closed["price"])
+		}
+		for _, pos := range report.RemainingPositions {
+			fmt.Printf("      still open: %v %v %v\n", pos["symbol"], pos["side"], pos["quantity"])
+		}
+		for symbol, count := range report.RemainingOrders {
+			fmt.Printf("      open orders left: %s x%d\n", symbol, count)
+		}
+		for _, msg := range report.Errors {
+			fmt.Printf("      error: %s\n", msg)
+		}
+	}
+	fmt.Println()
+	return allFlat
+}
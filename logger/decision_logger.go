@@ -7,23 +7,47 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
 // DecisionRecord 决策记录
 type DecisionRecord struct {
-	Timestamp      time.Time          `json:"timestamp"`       // 决策时间
-	CycleNumber    int                `json:"cycle_number"`    // 周期编号
-	InputPrompt    string             `json:"input_prompt"`    // 发送给AI的输入prompt
-	CoTTrace       string             `json:"cot_trace"`       // AI思维链（输出）
-	DecisionJSON   string             `json:"decision_json"`   // 决策JSON
-	AccountState   AccountSnapshot    `json:"account_state"`   // 账户状态快照
-	Positions      []PositionSnapshot `json:"positions"`       // 持仓快照
-	CandidateCoins []string           `json:"candidate_coins"` // 候选币种列表
-	Decisions      []DecisionAction   `json:"decisions"`       // 执行的决策
-	ExecutionLog   []string           `json:"execution_log"`   // 执行日志
-	Success        bool               `json:"success"`         // 是否成功
-	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
+	Timestamp        time.Time          `json:"timestamp"`                   // 决策时间
+	CycleNumber      int                `json:"cycle_number"`                // 周期编号
+	InputPrompt      string             `json:"input_prompt"`                // 发送给AI的输入prompt
+	PromptVersion    string             `json:"prompt_version"`              // 提示词模板版本哈希
+	PromptTokens     int                `json:"prompt_tokens"`               // 估算的prompt token数
+	PromptReductions []string           `json:"prompt_reductions,omitempty"` // 为满足token预算实施的压缩步骤
+	CoTTrace         string             `json:"cot_trace"`                   // AI思维链（输出）
+	DecisionJSON     string             `json:"decision_json"`               // 决策JSON
+	AccountState     AccountSnapshot    `json:"account_state"`               // 账户状态快照
+	Positions        []PositionSnapshot `json:"positions"`                   // 持仓快照
+	CandidateCoins   []string           `json:"candidate_coins"`             // 候选币种列表
+	Decisions        []DecisionAction   `json:"decisions"`                   // 执行的决策
+	ExecutionLog     []string           `json:"execution_log"`               // 执行日志
+	Success          bool               `json:"success"`                     // 是否成功
+	ErrorMessage     string             `json:"error_message"`               // 错误信息（如果有）
+	EnsembleVotes    []EnsembleVote     `json:"ensemble_votes,omitempty"`    // 集成模式下各模型的原始投票
+	ToolCalls        []ToolCallRecord   `json:"tool_calls,omitempty"`        // 工具调用模式下的完整工具调用记录
+}
+
+// EnsembleVote 集成模式下单个模型的投票
+type EnsembleVote struct {
+	Model        string `json:"model"`         // 模型标识（provider/model）
+	CoTTrace     string `json:"cot_trace"`     // 该模型的思维链
+	DecisionJSON string `json:"decision_json"` // 该模型的决策JSON
+	Error        string `json:"error,omitempty"`
+}
+
+// ToolCallRecord 工具调用模式下的一次工具调用
+type ToolCallRecord struct {
+	Iteration int    `json:"iteration"` // 第几轮对话
+	Name      string `json:"name"`      // 工具名称
+	Arguments string `json:"arguments"` // 调用参数（JSON）
+	Result    string `json:"result"`    // 返回给模型的结果
+	Error     string `json:"error,omitempty"`
 }
 
 // AccountSnapshot 账户状态快照
@@ -49,21 +73,40 @@ type PositionSnapshot struct {
 
 // DecisionAction 决策动作
 type DecisionAction struct {
-	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short
+	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, partial_close, add_to_position, take_profit_level, funding, place_entry（限价开仓挂单）, cancel_entry（限价开仓单撤销/超时）
 	Symbol    string    `json:"symbol"`    // 币种
-	Quantity  float64   `json:"quantity"`  // 数量
+	Quantity  float64   `json:"quantity"`  // 数量（部分平仓/止盈阶梯成交为平掉的数量，加仓为新增的数量）
 	Leverage  int       `json:"leverage"`  // 杠杆（开仓时）
-	Price     float64   `json:"price"`     // 执行价格
+	Price     float64   `json:"price"`     // 执行价格（交易所返回成交均价时为实际成交价）
 	OrderID   int64     `json:"order_id"`  // 订单ID
 	Timestamp time.Time `json:"timestamp"` // 执行时间
 	Success   bool      `json:"success"`   // 是否成功
 	Error     string    `json:"error"`     // 错误信息
+
+	// 开仓时的计划（用于跨周期记忆中的入场理由），调整止损止盈时为新的价格
+	StopLoss   float64 `json:"stop_loss,omitempty"`
+	TakeProfit float64 `json:"take_profit,omitempty"`
+	Reasoning  string  `json:"reasoning,omitempty"`
+	Side       string  `json:"side,omitempty"` // 调整止损止盈/部分平仓/加仓/止盈阶梯成交/资金费/限价开仓挂单和撤单的持仓方向（"long"/"short"）
+
+	TrailingStopPct float64 `json:"trailing_stop_pct,omitempty"` // 开仓时设置的跟踪止损回调比例
+	Level           int     `json:"level,omitempty"`             // 止盈阶梯成交的档位（从1开始）
+
+	// 成交质量：ExpectedPrice为下单前的参考价（市价单为当时的行情价，止盈阶梯为触发价；交易所没有返回成交信息时为空）
+	ExpectedPrice float64 `json:"expected_price,omitempty"`
+	SlippageBps   float64 `json:"slippage_bps,omitempty"` // 滑点（基点，正数表示成交价比参考价不利）
+	Fee           float64 `json:"fee,omitempty"`          // 手续费
+	FeeAsset      string  `json:"fee_asset,omitempty"`    // 手续费币种
+	Exchange      string  `json:"exchange,omitempty"`     // 交易平台（按交易所统计执行成本）
+
+	Funding float64 `json:"funding,omitempty"` // 资金费收支（funding动作，正数为收入，负数为支出）
 }
 
 // DecisionLogger 决策日志记录器
 type DecisionLogger struct {
 	logDir      string
 	cycleNumber int
+	mu          sync.Mutex // 保护cycleNumber和写文件（交易循环与紧急平仓可能同时记录）
 }
 
 // NewDecisionLogger 创建决策日志记录器
@@ -83,8 +126,11 @@ func NewDecisionLogger(logDir string) *DecisionLogger {
 	}
 }
 
-// LogDecision 记录决策
+// LogDecision 记录决策（写入并落盘后才返回，进程随后退出也不会丢失或只写入一半）
 func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.cycleNumber++
 	record.CycleNumber = l.cycleNumber
 	record.Timestamp = time.Now()
@@ -103,7 +149,7 @@ func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
 	}
 
 	// 写入文件
-	if err := ioutil.WriteFile(filepath, data, 0644); err != nil {
+	if err := writeFileSync(filepath, data); err != nil {
 		return fmt.Errorf("写入决策记录失败: %w", err)
 	}
 
@@ -111,6 +157,30 @@ func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
 	return nil
 }
 
+// writeFileSync 先写入同目录的临时文件（以.开头，读取记录时跳过）并fsync，再重命名为目标文件
+func writeFileSync(path string, data []byte) error {
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
 // GetLatestRecords 获取最近N条记录（按时间正序：从旧到新）
 func (l *DecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
 	files, err := ioutil.ReadDir(l.logDir)
@@ -123,7 +193,7 @@ func (l *DecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
 	count := 0
 	for i := len(files) - 1; i >= 0 && count < n; i-- {
 		file := files[i]
-		if file.IsDir() {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
 			continue
 		}
 
@@ -189,7 +259,7 @@ func (l *DecisionLogger) CleanOldRecords(days int) error {
 
 	removedCount := 0
 	for _, file := range files {
-		if file.IsDir() {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
 			continue
 		}
 
@@ -220,7 +290,7 @@ func (l *DecisionLogger) GetStatistics() (*Statistics, error) {
 	stats := &Statistics{}
 
 	for _, file := range files {
-		if file.IsDir() {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
 			continue
 		}
 
@@ -277,12 +347,17 @@ type TradeOutcome struct {
 	ClosePrice    float64   `json:"close_price"`    // 平仓价
 	PositionValue float64   `json:"position_value"` // 仓位价值（quantity × openPrice）
 	MarginUsed    float64   `json:"margin_used"`    // 保证金使用（positionValue / leverage）
-	PnL           float64   `json:"pn_l"`           // 盈亏（USDT）
+	PnL           float64   `json:"pn_l"`           // 盈亏（USDT，已扣除手续费并计入资金费）
 	PnLPct        float64   `json:"pn_l_pct"`       // 盈亏百分比（相对保证金）
 	Duration      string    `json:"duration"`       // 持仓时长
 	OpenTime      time.Time `json:"open_time"`      // 开仓时间
 	CloseTime     time.Time `json:"close_time"`     // 平仓时间
 	WasStopLoss   bool      `json:"was_stop_loss"`  // 是否止损
+
+	Level int     `json:"level,omitempty"` // 止盈阶梯的档位（0表示不是阶梯止盈成交）
+	Fees  float64 `json:"fees,omitempty"`  // 手续费（按平仓数量分摊的开仓手续费 + 平仓手续费）
+
+	Funding float64 `json:"funding,omitempty"` // 持仓期间的资金费（按平仓数量分摊，正数为收入）
 }
 
 // PerformanceAnalysis 交易表现分析
@@ -299,6 +374,8 @@ type PerformanceAnalysis struct {
 	SymbolStats   map[string]*SymbolPerformance `json:"symbol_stats"`   // 各币种表现
 	BestSymbol    string                        `json:"best_symbol"`    // 表现最好的币种
 	WorstSymbol   string                        `json:"worst_symbol"`   // 表现最差的币种
+
+	ExecutionCosts map[string]*ExchangeExecutionCost `json:"execution_costs"` // 执行成本（按交易所，再按币种）
 }
 
 // SymbolPerformance 币种表现统计
@@ -321,160 +398,76 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 
 	if len(records) == 0 {
 		return &PerformanceAnalysis{
-			RecentTrades: []TradeOutcome{},
-			SymbolStats:  make(map[string]*SymbolPerformance),
+			RecentTrades:   []TradeOutcome{},
+			SymbolStats:    make(map[string]*SymbolPerformance),
+			ExecutionCosts: make(map[string]*ExchangeExecutionCost),
 		}, nil
 	}
 
 	analysis := &PerformanceAnalysis{
-		RecentTrades: []TradeOutcome{},
-		SymbolStats:  make(map[string]*SymbolPerformance),
+		RecentTrades:   []TradeOutcome{},
+		SymbolStats:    make(map[string]*SymbolPerformance),
+		ExecutionCosts: make(map[string]*ExchangeExecutionCost),
 	}
 
-	// 追踪持仓状态：symbol_side -> {side, openPrice, openTime, quantity, leverage}
-	openPositions := make(map[string]map[string]interface{})
+	// 追踪持仓状态：开仓/加仓/部分平仓/平仓按顺序回放（加仓后为加权平均开仓价）
+	ledger := newTradeLedger()
 
-	// 为了避免开仓记录在窗口外导致匹配失败，需要先从所有历史记录中找出未平仓的持仓
+	// 为了避免开仓记录在窗口外导致匹配失败，需要先从窗口之前的历史记录中回放出未平仓的持仓
 	// 获取更多历史记录来构建完整的持仓状态（使用更大的窗口）
 	allRecords, err := l.GetLatestRecords(lookbackCycles * 3) // 扩大3倍窗口
 	if err == nil && len(allRecords) > len(records) {
-		// 先从扩大的窗口中收集所有开仓记录
-		for _, record := range allRecords {
+		for _, record := range allRecords[:len(allRecords)-len(records)] {
 			for _, action := range record.Decisions {
-				if !action.Success {
-					continue
-				}
-
-				symbol := action.Symbol
-				side := ""
-				if action.Action == "open_long" || action.Action == "close_long" {
-					side = "long"
-				} else if action.Action == "open_short" || action.Action == "close_short" {
-					side = "short"
-				}
-				posKey := symbol + "_" + side
-
-				switch action.Action {
-				case "open_long", "open_short":
-					// 记录开仓
-					openPositions[posKey] = map[string]interface{}{
-						"side":      side,
-						"openPrice": action.Price,
-						"openTime":  action.Timestamp,
-						"quantity":  action.Quantity,
-						"leverage":  action.Leverage,
-					}
-				case "close_long", "close_short":
-					// 移除已平仓记录
-					delete(openPositions, posKey)
+				if action.Success {
+					ledger.apply(action)
 				}
 			}
 		}
 	}
 
-	// 遍历分析窗口内的记录，生成交易结果
+	// 遍历分析窗口内的记录，生成交易结果（部分平仓按平掉的数量单独计为一笔）
 	for _, record := range records {
 		for _, action := range record.Decisions {
 			if !action.Success {
 				continue
 			}
+			addExecutionCost(analysis.ExecutionCosts, action)
 
-			symbol := action.Symbol
-			side := ""
-			if action.Action == "open_long" || action.Action == "close_long" {
-				side = "long"
-			} else if action.Action == "open_short" || action.Action == "close_short" {
-				side = "short"
+			outcome := ledger.apply(action)
+			if outcome == nil {
+				continue
 			}
-			posKey := symbol + "_" + side // 使用symbol_side作为key，区分多空持仓
-
-			switch action.Action {
-			case "open_long", "open_short":
-				// 更新开仓记录（可能已经在预填充时记录过了）
-				openPositions[posKey] = map[string]interface{}{
-					"side":      side,
-					"openPrice": action.Price,
-					"openTime":  action.Timestamp,
-					"quantity":  action.Quantity,
-					"leverage":  action.Leverage,
-				}
+			pnl := outcome.PnL
+
+			analysis.RecentTrades = append(analysis.RecentTrades, *outcome)
+			analysis.TotalTrades++
+
+			// 分类交易：盈利、亏损、持平（避免将pnl=0算入亏损）
+			if pnl > 0 {
+				analysis.WinningTrades++
+				analysis.AvgWin += pnl
+			} else if pnl < 0 {
+				analysis.LosingTrades++
+				analysis.AvgLoss += pnl
+			}
+			// pnl == 0 的交易不计入盈利也不计入亏损，但计入总交易数
 
-			case "close_long", "close_short":
-				// 查找对应的开仓记录（可能来自预填充或当前窗口）
-				if openPos, exists := openPositions[posKey]; exists {
-					openPrice := openPos["openPrice"].(float64)
-					openTime := openPos["openTime"].(time.Time)
-					side := openPos["side"].(string)
-					quantity := openPos["quantity"].(float64)
-					leverage := openPos["leverage"].(int)
-
-					// 计算实际盈亏（USDT）
-					// 合约交易 PnL 计算：quantity × 价格差
-					// 注意：杠杆不影响绝对盈亏，只影响保证金需求
-					var pnl float64
-					if side == "long" {
-						pnl = quantity * (action.Price - openPrice)
-					} else {
-						pnl = quantity * (openPrice - action.Price)
-					}
-
-					// 计算盈亏百分比（相对保证金）
-					positionValue := quantity * openPrice
-					marginUsed := positionValue / float64(leverage)
-					pnlPct := 0.0
-					if marginUsed > 0 {
-						pnlPct = (pnl / marginUsed) * 100
-					}
-
-					// 记录交易结果
-					outcome := TradeOutcome{
-						Symbol:        symbol,
-						Side:          side,
-						Quantity:      quantity,
-						Leverage:      leverage,
-						OpenPrice:     openPrice,
-						ClosePrice:    action.Price,
-						PositionValue: positionValue,
-						MarginUsed:    marginUsed,
-						PnL:           pnl,
-						PnLPct:        pnlPct,
-						Duration:      action.Timestamp.Sub(openTime).String(),
-						OpenTime:      openTime,
-						CloseTime:     action.Timestamp,
-					}
-
-					analysis.RecentTrades = append(analysis.RecentTrades, outcome)
-					analysis.TotalTrades++
-
-					// 分类交易：盈利、亏损、持平（避免将pnl=0算入亏损）
-					if pnl > 0 {
-						analysis.WinningTrades++
-						analysis.AvgWin += pnl
-					} else if pnl < 0 {
-						analysis.LosingTrades++
-						analysis.AvgLoss += pnl
-					}
-					// pnl == 0 的交易不计入盈利也不计入亏损，但计入总交易数
-
-					// 更新币种统计
-					if _, exists := analysis.SymbolStats[symbol]; !exists {
-						analysis.SymbolStats[symbol] = &SymbolPerformance{
-							Symbol: symbol,
-						}
-					}
-					stats := analysis.SymbolStats[symbol]
-					stats.TotalTrades++
-					stats.TotalPnL += pnl
-					if pnl > 0 {
-						stats.WinningTrades++
-					} else if pnl < 0 {
-						stats.LosingTrades++
-					}
-
-					// 移除已平仓记录
-					delete(openPositions, posKey)
+			// 更新币种统计
+			symbol := outcome.Symbol
+			if _, exists := analysis.SymbolStats[symbol]; !exists {
+				analysis.SymbolStats[symbol] = &SymbolPerformance{
+					Symbol: symbol,
 				}
 			}
+			stats := analysis.SymbolStats[symbol]
+			stats.TotalTrades++
+			stats.TotalPnL += pnl
+			if pnl > 0 {
+				stats.WinningTrades++
+			} else if pnl < 0 {
+				stats.LosingTrades++
+			}
 		}
 	}
 
@@ -536,6 +529,13 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}
 	}
 
+	for _, cost := range analysis.ExecutionCosts {
+		cost.finish()
+		for _, symbolCost := range cost.Symbols {
+			symbolCost.finish()
+		}
+	}
+
 	// 计算夏普比率（需要至少2个数据点）
 	analysis.SharpeRatio = l.calculateSharpeRatio(records)
 
@@ -0,0 +1,64 @@
+package logger
+
+// ExecutionCost 执行成本统计（滑点和手续费）
+type ExecutionCost struct {
+	Orders         int     `json:"orders"`           // 有成交信息的订单数
+	Notional       float64 `json:"notional"`         // 成交额（数量 × 成交价）
+	Fees           float64 `json:"fees"`             // 手续费合计
+	FeeBps         float64 `json:"fee_bps"`          // 手续费占成交额的比例（基点）
+	SlippageCost   float64 `json:"slippage_cost"`    // 滑点成本合计（数量 × 不利价差，负数表示成交比参考价有利）
+	AvgSlippageBps float64 `json:"avg_slippage_bps"` // 按成交额加权的平均滑点（基点）
+}
+
+// ExchangeExecutionCost 单个交易所的执行成本（Symbols为各币种明细）
+type ExchangeExecutionCost struct {
+	ExecutionCost
+	Symbols map[string]*ExecutionCost `json:"symbols"`
+}
+
+// addExecutionCost 把一个成功执行的动作计入所属交易所和币种的执行成本（没有成交信息的动作忽略）
+// 早期的记录在没有成交信息时也写了ExpectedPrice（与Price相同，没有滑点和手续费），同样忽略
+func addExecutionCost(costs map[string]*ExchangeExecutionCost, action DecisionAction) {
+	if action.ExpectedPrice <= 0 && action.Fee == 0 {
+		return
+	}
+	if action.ExpectedPrice == action.Price && action.SlippageBps == 0 && action.Fee == 0 {
+		return
+	}
+	exchange := action.Exchange
+	if exchange == "" {
+		exchange = "unknown"
+	}
+	cost, exists := costs[exchange]
+	if !exists {
+		cost = &ExchangeExecutionCost{Symbols: make(map[string]*ExecutionCost)}
+		costs[exchange] = cost
+	}
+	symbolCost, exists := cost.Symbols[action.Symbol]
+	if !exists {
+		symbolCost = &ExecutionCost{}
+		cost.Symbols[action.Symbol] = symbolCost
+	}
+	cost.add(action)
+	symbolCost.add(action)
+}
+
+// add 累加一个动作的成交额、手续费和滑点（平均值在finish中计算）
+func (c *ExecutionCost) add(action DecisionAction) {
+	notional := action.Quantity * action.Price
+	c.Orders++
+	c.Notional += notional
+	c.Fees += action.Fee
+	c.SlippageCost += action.Quantity * action.ExpectedPrice * action.SlippageBps / 10000
+	c.AvgSlippageBps += action.SlippageBps * notional
+}
+
+// finish 计算按成交额加权的平均滑点和手续费比例
+func (c *ExecutionCost) finish() {
+	if c.Notional <= 0 {
+		c.AvgSlippageBps = 0
+		return
+	}
+	c.AvgSlippageBps /= c.Notional
+	c.FeeBps = c.Fees / c.Notional * 10000
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExecutionCostSkipsActionsWithoutFills(t *testing.T) {
+	costs := make(map[string]*ExchangeExecutionCost)
+	for _, action := range []DecisionAction{
+		// 有成交信息：参考价100，成交101（滑点100bps），手续费0.0505
+		{Action: "open_long", Symbol: "BTCUSDT", Exchange: "binance", Quantity: 1, Price: 101, ExpectedPrice: 100, SlippageBps: 100, Fee: 0.0505},
+		// 交易所没有返回成交信息
+		{Action: "open_long", Symbol: "ETHUSDT", Exchange: "binance", Quantity: 10, Price: 100},
+		// 早期记录：没有成交信息时ExpectedPrice与Price相同
+		{Action: "close_long", Symbol: "BTCUSDT", Exchange: "binance", Quantity: 5, Price: 100, ExpectedPrice: 100},
+	} {
+		addExecutionCost(costs, action)
+	}
+	for _, cost := range costs {
+		cost.finish()
+		for _, symbolCost := range cost.Symbols {
+			symbolCost.finish()
+		}
+	}
+
+	cost := costs["binance"]
+	if cost == nil || cost.Orders != 1 || cost.Notional != 101 {
+		t.Fatalf("只应统计有成交信息的订单: %+v", cost)
+	}
+	if math.Abs(cost.AvgSlippageBps-100) > 1e-9 || math.Abs(cost.FeeBps-5) > 1e-9 {
+		t.Fatalf("平均滑点和手续费比例不应被没有成交信息的订单拉低: %+v", cost.ExecutionCost)
+	}
+	if _, exists := cost.Symbols["ETHUSDT"]; exists {
+		t.Fatalf("没有成交信息的币种不应出现在明细中: %+v", cost.Symbols)
+	}
+}
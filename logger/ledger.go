@@ -0,0 +1,169 @@
+package logger
+
+import "time"
+
+// ledgerPosition 账本中的未平仓持仓（加仓后开仓价为按数量加权的平均价）
+type ledgerPosition struct {
+	Side      string
+	OpenPrice float64
+	OpenTime  time.Time
+	Quantity  float64
+	Leverage  int
+	Fees      float64 // 尚未分摊到交易结果的开仓/加仓手续费
+	Funding   float64 // 尚未分摊到交易结果的资金费（正数为收入）
+}
+
+// tradeLedger 按决策记录回放持仓：开仓建立持仓，加仓更新加权平均开仓价，资金费累计到持仓上，
+// 部分平仓、止盈阶梯成交和全部平仓按平仓数量生成交易结果（开仓手续费和资金费按平仓数量分摊）
+type tradeLedger struct {
+	positions map[string]*ledgerPosition // symbol_side -> 未平仓持仓
+}
+
+func newTradeLedger() *tradeLedger {
+	return &tradeLedger{positions: make(map[string]*ledgerPosition)}
+}
+
+// actionSide 动作对应的持仓方向（部分平仓/加仓使用记录的side）
+func actionSide(action DecisionAction) string {
+	switch action.Action {
+	case "open_long", "close_long":
+		return "long"
+	case "open_short", "close_short":
+		return "short"
+	case "partial_close", "add_to_position", "take_profit_level", "funding":
+		return action.Side
+	}
+	return ""
+}
+
+// openFunding 未平仓持仓尚未分摊的资金费（symbol_side -> 金额）
+func (l *tradeLedger) openFunding() map[string]float64 {
+	result := make(map[string]float64, len(l.positions))
+	for key, pos := range l.positions {
+		result[key] = pos.Funding
+	}
+	return result
+}
+
+// apply 回放一个成功执行的动作，平仓（含部分平仓）时返回这部分仓位的交易结果
+// 找不到对应开仓记录的加仓/平仓（开仓在回看窗口之外）会被忽略
+func (l *tradeLedger) apply(action DecisionAction) *TradeOutcome {
+	side := actionSide(action)
+	if side == "" {
+		return nil
+	}
+	posKey := action.Symbol + "_" + side
+
+	switch action.Action {
+	case "open_long", "open_short":
+		l.positions[posKey] = &ledgerPosition{
+			Side:      side,
+			OpenPrice: action.Price,
+			OpenTime:  action.Timestamp,
+			Quantity:  action.Quantity,
+			Leverage:  action.Leverage,
+			Fees:      action.Fee,
+		}
+
+	case "add_to_position":
+		pos, exists := l.positions[posKey]
+		if !exists || action.Quantity <= 0 {
+			return nil
+		}
+		total := pos.Quantity + action.Quantity
+		pos.OpenPrice = (pos.Quantity*pos.OpenPrice + action.Quantity*action.Price) / total
+		pos.Quantity = total
+		pos.Fees += action.Fee
+		if action.Leverage > 0 {
+			pos.Leverage = action.Leverage
+		}
+
+	case "funding":
+		if pos, exists := l.positions[posKey]; exists {
+			pos.Funding += action.Funding
+		}
+
+	case "partial_close", "take_profit_level":
+		pos, exists := l.positions[posKey]
+		if !exists || action.Quantity <= 0 {
+			return nil
+		}
+		quantity := action.Quantity
+		openFees, funding := pos.takeShare(quantity)
+		if quantity >= pos.Quantity {
+			quantity = pos.Quantity
+			delete(l.positions, posKey)
+		} else {
+			pos.Quantity -= quantity
+		}
+		outcome := newTradeOutcome(action, pos, quantity, openFees, funding)
+		return &outcome
+
+	case "close_long", "close_short":
+		pos, exists := l.positions[posKey]
+		if !exists {
+			return nil
+		}
+		delete(l.positions, posKey)
+		outcome := newTradeOutcome(action, pos, pos.Quantity, pos.Fees, pos.Funding)
+		return &outcome
+	}
+	return nil
+}
+
+// takeShare 按平仓数量分摊开仓手续费和资金费（在减少持仓数量之前调用）
+func (p *ledgerPosition) takeShare(quantity float64) (fees, funding float64) {
+	if p.Quantity <= 0 || quantity >= p.Quantity {
+		fees, funding = p.Fees, p.Funding
+		p.Fees, p.Funding = 0, 0
+		return fees, funding
+	}
+	fees = p.Fees * quantity / p.Quantity
+	funding = p.Funding * quantity / p.Quantity
+	p.Fees -= fees
+	p.Funding -= funding
+	return fees, funding
+}
+
+// newTradeOutcome 计算按平仓价平掉quantity数量持仓的交易结果（openFees和funding为分摊的开仓手续费和资金费）
+func newTradeOutcome(action DecisionAction, pos *ledgerPosition, quantity, openFees, funding float64) TradeOutcome {
+	// 合约交易 PnL 计算：quantity × 价格差，再扣除开平仓手续费、计入资金费
+	// 注意：杠杆不影响绝对盈亏，只影响保证金需求
+	pnl := quantity * (action.Price - pos.OpenPrice)
+	if pos.Side == "short" {
+		pnl = -pnl
+	}
+	fees := openFees + action.Fee
+	pnl -= fees
+	pnl += funding
+
+	// 计算盈亏百分比（相对保证金）
+	positionValue := quantity * pos.OpenPrice
+	marginUsed := positionValue
+	if pos.Leverage > 0 {
+		marginUsed = positionValue / float64(pos.Leverage)
+	}
+	pnlPct := 0.0
+	if marginUsed > 0 {
+		pnlPct = (pnl / marginUsed) * 100
+	}
+
+	return TradeOutcome{
+		Symbol:        action.Symbol,
+		Side:          pos.Side,
+		Quantity:      quantity,
+		Leverage:      pos.Leverage,
+		OpenPrice:     pos.OpenPrice,
+		ClosePrice:    action.Price,
+		PositionValue: positionValue,
+		MarginUsed:    marginUsed,
+		PnL:           pnl,
+		PnLPct:        pnlPct,
+		Duration:      action.Timestamp.Sub(pos.OpenTime).String(),
+		OpenTime:      pos.OpenTime,
+		CloseTime:     action.Timestamp,
+		Level:         action.Level,
+		Fees:          fees,
+		Funding:       funding,
+	}
+}
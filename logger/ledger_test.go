@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// replay 按顺序回放成功的动作，返回产生的交易结果
+func replay(ledger *tradeLedger, actions []DecisionAction) []TradeOutcome {
+	var outcomes []TradeOutcome
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, action := range actions {
+		action.Success = true
+		action.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if outcome := ledger.apply(action); outcome != nil {
+			outcomes = append(outcomes, *outcome)
+		}
+	}
+	return outcomes
+}
+
+func TestLedgerLimitEntryPartialFill(t *testing.T) {
+	ledger := newTradeLedger()
+	outcomes := replay(ledger, []DecisionAction{
+		{Action: "place_entry", Symbol: "BTCUSDT", Side: "long", Quantity: 1, Price: 100, Leverage: 5},
+		{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.4, Price: 100, Leverage: 5},
+		{Action: "cancel_entry", Symbol: "BTCUSDT", Side: "long", Quantity: 0.6, Price: 100},
+		{Action: "close_long", Symbol: "BTCUSDT", Price: 110},
+	})
+
+	if len(outcomes) != 1 {
+		t.Fatalf("应产生1笔交易结果: %+v", outcomes)
+	}
+	if outcomes[0].Quantity != 0.4 || math.Abs(outcomes[0].PnL-4) > 1e-9 {
+		t.Fatalf("交易结果应按成交数量0.4计算: %+v", outcomes[0])
+	}
+	if len(ledger.positions) != 0 {
+		t.Fatalf("平仓后不应有未平仓持仓: %+v", ledger.positions)
+	}
+}
+
+func TestLedgerLimitEntryTTLCancel(t *testing.T) {
+	ledger := newTradeLedger()
+	replay(ledger, []DecisionAction{
+		{Action: "place_entry", Symbol: "ETHUSDT", Side: "short", Quantity: 2, Price: 3000, Leverage: 3},
+		{Action: "cancel_entry", Symbol: "ETHUSDT", Side: "short", Quantity: 2, Price: 3000},
+	})
+	if len(ledger.positions) != 0 {
+		t.Fatalf("未成交的限价开仓单不应建立持仓: %+v", ledger.positions)
+	}
+
+	// 之后的平仓（如手动开的仓）找不到开仓记录，不应产生交易结果
+	if outcomes := replay(ledger, []DecisionAction{{Action: "close_short", Symbol: "ETHUSDT", Price: 2900}}); len(outcomes) != 0 {
+		t.Fatalf("撤销的挂单不应产生交易结果: %+v", outcomes)
+	}
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// PositionThesis 持仓的入场理由（来自开仓时的决策记录）
+type PositionThesis struct {
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`
+	OpenTime   time.Time `json:"open_time"`
+	EntryPrice float64   `json:"entry_price"` // 加仓后为加权平均开仓价
+	Quantity   float64   `json:"quantity"`
+	StopLoss   float64   `json:"stop_loss"`
+	TakeProfit float64   `json:"take_profit"`
+	Reasoning  string    `json:"reasoning"`
+}
+
+// GetOpenPositionTheses 从最近N个周期中找出仍未平仓的开仓记录及其入场理由
+// 返回 symbol_side -> PositionThesis
+func (l *DecisionLogger) GetOpenPositionTheses(lookbackCycles int) (map[string]*PositionThesis, error) {
+	records, err := l.GetLatestRecords(lookbackCycles)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %w", err)
+	}
+
+	theses := make(map[string]*PositionThesis)
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if !action.Success {
+				continue
+			}
+
+			switch action.Action {
+			case "open_long", "open_short":
+				side := "long"
+				if action.Action == "open_short" {
+					side = "short"
+				}
+				theses[action.Symbol+"_"+side] = &PositionThesis{
+					Symbol:     action.Symbol,
+					Side:       side,
+					OpenTime:   action.Timestamp,
+					EntryPrice: action.Price,
+					Quantity:   action.Quantity,
+					StopLoss:   action.StopLoss,
+					TakeProfit: action.TakeProfit,
+					Reasoning:  action.Reasoning,
+				}
+			case "close_long":
+				delete(theses, action.Symbol+"_long")
+			case "close_short":
+				delete(theses, action.Symbol+"_short")
+			case "add_to_position":
+				if thesis, ok := theses[action.Symbol+"_"+action.Side]; ok && action.Quantity > 0 {
+					total := thesis.Quantity + action.Quantity
+					thesis.EntryPrice = (thesis.Quantity*thesis.EntryPrice + action.Quantity*action.Price) / total
+					thesis.Quantity = total
+					if action.StopLoss > 0 {
+						thesis.StopLoss = action.StopLoss
+					}
+					if action.TakeProfit > 0 {
+						thesis.TakeProfit = action.TakeProfit
+					}
+					if action.Reasoning != "" {
+						thesis.Reasoning += "；加仓: " + action.Reasoning
+					}
+				}
+			case "partial_close", "take_profit_level":
+				if thesis, ok := theses[action.Symbol+"_"+action.Side]; ok {
+					thesis.Quantity -= action.Quantity
+					if thesis.Quantity <= 0 {
+						delete(theses, action.Symbol+"_"+action.Side)
+					}
+				}
+			case "update_stop_loss":
+				if thesis, ok := theses[action.Symbol+"_"+action.Side]; ok {
+					thesis.StopLoss = action.StopLoss
+				}
+			case "update_take_profit":
+				if thesis, ok := theses[action.Symbol+"_"+action.Side]; ok {
+					thesis.TakeProfit = action.TakeProfit
+				}
+			}
+		}
+	}
+
+	return theses, nil
+}
+
+// GetTradeHistory 获取指定币种最近N个周期内已平仓的交易（从旧到新）
+func (l *DecisionLogger) GetTradeHistory(symbol string, lookbackCycles int) ([]TradeOutcome, error) {
+	records, err := l.GetLatestRecords(lookbackCycles)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %w", err)
+	}
+
+	// 加仓按加权平均开仓价计算，部分平仓单独计为一笔
+	ledger := newTradeLedger()
+	var trades []TradeOutcome
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if !action.Success || action.Symbol != symbol {
+				continue
+			}
+			if outcome := ledger.apply(action); outcome != nil {
+				trades = append(trades, *outcome)
+			}
+		}
+	}
+
+	return trades, nil
+}
+
+// GetOpenPositionFunding 回放最近N个周期的决策记录，返回未平仓持仓累计的资金费（symbol_side -> 金额，正数为收入）
+// 已部分平仓的持仓只包含剩余数量对应的部分；开仓在回看窗口之外的持仓不包含
+func (l *DecisionLogger) GetOpenPositionFunding(lookbackCycles int) (map[string]float64, error) {
+	records, err := l.GetLatestRecords(lookbackCycles)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %w", err)
+	}
+
+	ledger := newTradeLedger()
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if action.Success {
+				ledger.apply(action)
+			}
+		}
+	}
+	return ledger.openFunding(), nil
+}
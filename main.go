@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"danto/api"
@@ -11,9 +12,19 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 )
 
 func main() {
+	// Kill switch command: flatten every trader and exit
+	if len(os.Args) > 1 && os.Args[1] == "kill-switch" {
+		configFile := "config.json"
+		if len(os.Args) > 2 {
+			configFile = os.Args[2]
+		}
+		os.Exit(runKillSwitch(configFile))
+	}
+
 	fmt.Println("╔════════════════════════════════════════════════════════════╗")
 	fmt.Println("║    🏆 AI Trading Competition System - Multi-Agent Battle   ║")
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
@@ -114,25 +125,36 @@ func main() {
 
 	// Create and start API server
 	apiServer := api.NewServer(traderManager, cfg.APIServerPort)
+	apiServer.SetKillSwitchToken(cfg.KillSwitchToken)
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			log.Printf("❌ API server error: %v", err)
 		}
 	}()
 
-	// Set up graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// Set up graceful shutdown: the exit signal cancels every trader loop
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
 
 	// Start all traders
-	traderManager.StartAll()
+	traderManager.StartAll(ctx)
 
 	// Wait for exit signal
-	<-sigChan
+	<-ctx.Done()
+	// A second Ctrl+C exits immediately
+	stopSignals()
 	fmt.Println()
 	fmt.Println()
-	log.Println("📛 Received exit signal, stopping all traders...")
-	traderManager.StopAll()
+	if cfg.FlattenOnExit {
+		log.Println("📛 Received exit signal, flattening all positions before exit (flatten_on_exit)...")
+		printFlattenReports(traderManager.FlattenAll())
+	} else {
+		log.Println("📛 Received exit signal, waiting for in-flight cycles to finish...")
+	}
+	shutdownTimeout := time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second
+	if err := traderManager.StopAll(shutdownTimeout); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
 
 	fmt.Println()
 	fmt.Println("👋 Thank you for using DANTO AI Trading System!")
@@ -1,10 +1,12 @@
 package manager
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"danto/config"
 	"danto/trader"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,6 +15,9 @@ import (
 type TraderManager struct {
 	traders map[string]*trader.AutoTrader // key: trader ID
 	mu      sync.RWMutex
+
+	cancel  context.CancelFunc // 取消所有trader的交易循环（StartAll时设置）
+	running sync.WaitGroup     // 运行中的交易循环
 }
 
 // NewTraderManager 创建trader管理器
@@ -56,6 +61,18 @@ func (tm *TraderManager) AddTrader(cfg config.TraderConfig, coinPoolURL string,
 		CustomAPIURL:          cfg.CustomAPIURL,
 		CustomAPIKey:          cfg.CustomAPIKey,
 		CustomModelName:       cfg.CustomModelName,
+		PromptLanguage:        cfg.PromptLanguage,
+		PromptTemplateDir:     cfg.PromptTemplateDir,
+		PromptTokenBudget:     cfg.PromptTokenBudget,
+		MaxOutputTokens:       cfg.MaxOutputTokens,
+		ToolMode:              cfg.ToolMode,
+		MaxToolIterations:     cfg.MaxToolIterations,
+		MemoryCycles:          cfg.MemoryCycles,
+		MemoryTokenBudget:     cfg.MemoryTokenBudget,
+		LLMRecordDir:          cfg.LLMRecordDir,
+		EntryOrderTTL:         time.Duration(cfg.EntryOrderTTLMinutes) * time.Minute,
+		MarginMode:            cfg.MarginMode,
+		PositionMode:          cfg.PositionMode,
 		ScanInterval:          cfg.GetScanInterval(),
 		InitialBalance:        cfg.InitialBalance,
 		BTCETHLeverage:        leverage.BTCETHLeverage,  // 使用配置的杠杆倍数
@@ -65,6 +82,24 @@ func (tm *TraderManager) AddTrader(cfg config.TraderConfig, coinPoolURL string,
 		StopTradingTime:       time.Duration(stopTradingMinutes) * time.Minute,
 	}
 
+	// 集成决策模式
+	if cfg.Ensemble != nil {
+		for _, m := range cfg.Ensemble.Models {
+			traderConfig.EnsembleModels = append(traderConfig.EnsembleModels, trader.AIModelConfig{
+				AIModel:         m.AIModel,
+				UseQwen:         m.AIModel == "qwen",
+				DeepSeekKey:     m.DeepSeekKey,
+				QwenKey:         m.QwenKey,
+				MiniMaxKey:      m.MiniMaxKey,
+				CustomAPIURL:    m.CustomAPIURL,
+				CustomAPIKey:    m.CustomAPIKey,
+				CustomModelName: m.CustomModelName,
+			})
+		}
+		traderConfig.EnsembleSizePolicy = cfg.Ensemble.SizePolicy
+		traderConfig.EnsembleMinVotes = cfg.Ensemble.MinVotes
+	}
+
 	// 创建trader实例
 	at, err := trader.NewAutoTrader(traderConfig)
 	if err != nil {
@@ -112,31 +147,75 @@ func (tm *TraderManager) GetTraderIDs() []string {
 	return ids
 }
 
-// StartAll 启动所有trader
-func (tm *TraderManager) StartAll() {
-	tm.mu.RLock()
-	defer tm.mu.RUnlock()
+// StartAll 启动所有trader，ctx取消或调用StopAll时停止
+func (tm *TraderManager) StartAll(ctx context.Context) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
 
+	ctx, tm.cancel = context.WithCancel(ctx)
 	log.Println("🚀 启动所有Trader...")
 	for id, t := range tm.traders {
+		tm.running.Add(1)
 		go func(traderID string, at *trader.AutoTrader) {
+			defer tm.running.Done()
 			log.Printf("▶️  启动 %s...", at.GetName())
-			if err := at.Run(); err != nil {
+			if err := at.Run(ctx); err != nil {
 				log.Printf("❌ %s 运行错误: %v", at.GetName(), err)
 			}
 		}(id, t)
 	}
 }
 
-// StopAll 停止所有trader
-func (tm *TraderManager) StopAll() {
+// StopAll 停止所有trader，并等待进行中的交易周期执行完（决策执行到一半时不会中断），
+// 超过timeout仍未全部退出时返回错误
+func (tm *TraderManager) StopAll(timeout time.Duration) error {
 	tm.mu.RLock()
-	defer tm.mu.RUnlock()
-
 	log.Println("⏹  停止所有Trader...")
+	if tm.cancel != nil {
+		tm.cancel()
+	}
 	for _, t := range tm.traders {
 		t.Stop()
 	}
+	tm.mu.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		tm.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("✓ 所有Trader已停止")
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("等待Trader停止超时（%v），仍有交易周期在执行", timeout)
+	}
+}
+
+// FlattenAll 紧急平仓（kill switch）：所有trader并行停止交易、撤销挂单并平掉所有持仓，返回各trader的最终状态（按ID排序）
+func (tm *TraderManager) FlattenAll() []*trader.FlattenReport {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	log.Println("🚨 紧急平仓：停止所有Trader并平掉所有持仓...")
+	ids := make([]string, 0, len(tm.traders))
+	for id := range tm.traders {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	reports := make([]*trader.FlattenReport, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, at *trader.AutoTrader) {
+			defer wg.Done()
+			reports[i] = at.Flatten()
+		}(i, tm.traders[id])
+	}
+	wg.Wait()
+	return reports
 }
 
 // GetComparisonData 获取对比数据
@@ -10,6 +10,14 @@ import (
 	"strings"
 )
 
+// apiBaseURL 行情数据API地址（默认币安合约）
+var apiBaseURL = "https://fapi.binance.com"
+
+// SetAPIBaseURL 设置行情数据API地址（用于本地测试服务器或离线回放）
+func SetAPIBaseURL(url string) {
+	apiBaseURL = strings.TrimSuffix(url, "/")
+}
+
 // Data 市场数据结构
 type Data struct {
 	Symbol            string
@@ -138,8 +146,8 @@ func Get(symbol string) (*Data, error) {
 
 // getKlines 从Binance获取K线数据
 func getKlines(symbol, interval string, limit int) ([]Kline, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/klines?symbol=%s&interval=%s&limit=%d",
-		symbol, interval, limit)
+	url := fmt.Sprintf("%s/fapi/v1/klines?symbol=%s&interval=%s&limit=%d",
+		apiBaseURL, symbol, interval, limit)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -388,7 +396,7 @@ func calculateLongerTermData(klines []Kline) *LongerTermData {
 
 // getOpenInterestData 获取OI数据
 func getOpenInterestData(symbol string) (*OIData, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
+	url := fmt.Sprintf("%s/fapi/v1/openInterest?symbol=%s", apiBaseURL, symbol)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -421,7 +429,7 @@ func getOpenInterestData(symbol string) (*OIData, error) {
 
 // getFundingRate 获取资金费率
 func getFundingRate(symbol string) (float64, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
+	url := fmt.Sprintf("%s/fapi/v1/premiumIndex?symbol=%s", apiBaseURL, symbol)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -452,65 +460,128 @@ func getFundingRate(symbol string) (float64, error) {
 	return rate, nil
 }
 
-// Format 格式化输出市场数据
+// formatLabels Format输出使用的字段名称（按语言）
+type formatLabels struct {
+	Current       string // 当前指标行（price, ema20, macd, rsi7）
+	OIIntro       string // 持仓量/资金费率引导语（symbol）
+	OpenInterest  string
+	FundingRate   string
+	Intraday      string
+	MidPrices     string
+	EMA20Series   string
+	MACDSeries    string
+	RSI7Series    string
+	RSI14Series   string
+	LongerTerm    string
+	EMACompare    string
+	ATRCompare    string
+	VolumeCompare string
+}
+
+// formatLabelsByLang 各语言的字段名称
+var formatLabelsByLang = map[string]formatLabels{
+	"en": {
+		Current:       "current_price = %.2f, current_ema20 = %.3f, current_macd = %.3f, current_rsi (7 period) = %.3f\n\n",
+		OIIntro:       "In addition, here is the latest %s open interest and funding rate for perps:\n\n",
+		OpenInterest:  "Open Interest: Latest: %.2f Average: %.2f\n\n",
+		FundingRate:   "Funding Rate: %.2e\n\n",
+		Intraday:      "Intraday series (3‑minute intervals, oldest → latest):\n\n",
+		MidPrices:     "Mid prices: %s\n\n",
+		EMA20Series:   "EMA indicators (20‑period): %s\n\n",
+		MACDSeries:    "MACD indicators: %s\n\n",
+		RSI7Series:    "RSI indicators (7‑Period): %s\n\n",
+		RSI14Series:   "RSI indicators (14‑Period): %s\n\n",
+		LongerTerm:    "Longer‑term context (4‑hour timeframe):\n\n",
+		EMACompare:    "20‑Period EMA: %.3f vs. 50‑Period EMA: %.3f\n\n",
+		ATRCompare:    "3‑Period ATR: %.3f vs. 14‑Period ATR: %.3f\n\n",
+		VolumeCompare: "Current Volume: %.3f vs. Average Volume: %.3f\n\n",
+	},
+	"zh": {
+		Current:       "当前价格 = %.2f, 当前EMA20 = %.3f, 当前MACD = %.3f, 当前RSI(7周期) = %.3f\n\n",
+		OIIntro:       "以下是 %s 永续合约最新的持仓量和资金费率:\n\n",
+		OpenInterest:  "持仓量: 最新: %.2f 平均: %.2f\n\n",
+		FundingRate:   "资金费率: %.2e\n\n",
+		Intraday:      "日内序列（3分钟间隔，从旧到新）:\n\n",
+		MidPrices:     "中间价: %s\n\n",
+		EMA20Series:   "EMA指标(20周期): %s\n\n",
+		MACDSeries:    "MACD指标: %s\n\n",
+		RSI7Series:    "RSI指标(7周期): %s\n\n",
+		RSI14Series:   "RSI指标(14周期): %s\n\n",
+		LongerTerm:    "长期背景（4小时时间框架）:\n\n",
+		EMACompare:    "20周期EMA: %.3f vs. 50周期EMA: %.3f\n\n",
+		ATRCompare:    "3周期ATR: %.3f vs. 14周期ATR: %.3f\n\n",
+		VolumeCompare: "当前成交量: %.3f vs. 平均成交量: %.3f\n\n",
+	},
+}
+
+// Format 格式化输出市场数据（英文字段名）
 func Format(data *Data) string {
+	return FormatWithLanguage(data, "en")
+}
+
+// FormatWithLanguage 按语言格式化输出市场数据（不支持的语言使用英文）
+func FormatWithLanguage(data *Data, lang string) string {
+	labels, ok := formatLabelsByLang[lang]
+	if !ok {
+		labels = formatLabelsByLang["en"]
+	}
+
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("current_price = %.2f, current_ema20 = %.3f, current_macd = %.3f, current_rsi (7 period) = %.3f\n\n",
+	sb.WriteString(fmt.Sprintf(labels.Current,
 		data.CurrentPrice, data.CurrentEMA20, data.CurrentMACD, data.CurrentRSI7))
 
-	sb.WriteString(fmt.Sprintf("In addition, here is the latest %s open interest and funding rate for perps:\n\n",
-		data.Symbol))
+	sb.WriteString(fmt.Sprintf(labels.OIIntro, data.Symbol))
 
 	if data.OpenInterest != nil {
-		sb.WriteString(fmt.Sprintf("Open Interest: Latest: %.2f Average: %.2f\n\n",
+		sb.WriteString(fmt.Sprintf(labels.OpenInterest,
 			data.OpenInterest.Latest, data.OpenInterest.Average))
 	}
 
-	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
+	sb.WriteString(fmt.Sprintf(labels.FundingRate, data.FundingRate))
 
 	if data.IntradaySeries != nil {
-		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
+		sb.WriteString(labels.Intraday)
 
 		if len(data.IntradaySeries.MidPrices) > 0 {
-			sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatFloatSlice(data.IntradaySeries.MidPrices)))
+			sb.WriteString(fmt.Sprintf(labels.MidPrices, formatFloatSlice(data.IntradaySeries.MidPrices)))
 		}
 
 		if len(data.IntradaySeries.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("EMA indicators (20‑period): %s\n\n", formatFloatSlice(data.IntradaySeries.EMA20Values)))
+			sb.WriteString(fmt.Sprintf(labels.EMA20Series, formatFloatSlice(data.IntradaySeries.EMA20Values)))
 		}
 
 		if len(data.IntradaySeries.MACDValues) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.IntradaySeries.MACDValues)))
+			sb.WriteString(fmt.Sprintf(labels.MACDSeries, formatFloatSlice(data.IntradaySeries.MACDValues)))
 		}
 
 		if len(data.IntradaySeries.RSI7Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (7‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI7Values)))
+			sb.WriteString(fmt.Sprintf(labels.RSI7Series, formatFloatSlice(data.IntradaySeries.RSI7Values)))
 		}
 
 		if len(data.IntradaySeries.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI14Values)))
+			sb.WriteString(fmt.Sprintf(labels.RSI14Series, formatFloatSlice(data.IntradaySeries.RSI14Values)))
 		}
 	}
 
 	if data.LongerTermContext != nil {
-		sb.WriteString("Longer‑term context (4‑hour timeframe):\n\n")
+		sb.WriteString(labels.LongerTerm)
 
-		sb.WriteString(fmt.Sprintf("20‑Period EMA: %.3f vs. 50‑Period EMA: %.3f\n\n",
+		sb.WriteString(fmt.Sprintf(labels.EMACompare,
 			data.LongerTermContext.EMA20, data.LongerTermContext.EMA50))
 
-		sb.WriteString(fmt.Sprintf("3‑Period ATR: %.3f vs. 14‑Period ATR: %.3f\n\n",
+		sb.WriteString(fmt.Sprintf(labels.ATRCompare,
 			data.LongerTermContext.ATR3, data.LongerTermContext.ATR14))
 
-		sb.WriteString(fmt.Sprintf("Current Volume: %.3f vs. Average Volume: %.3f\n\n",
+		sb.WriteString(fmt.Sprintf(labels.VolumeCompare,
 			data.LongerTermContext.CurrentVolume, data.LongerTermContext.AverageVolume))
 
 		if len(data.LongerTermContext.MACDValues) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.LongerTermContext.MACDValues)))
+			sb.WriteString(fmt.Sprintf(labels.MACDSeries, formatFloatSlice(data.LongerTermContext.MACDValues)))
 		}
 
 		if len(data.LongerTermContext.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(data.LongerTermContext.RSI14Values)))
+			sb.WriteString(fmt.Sprintf(labels.RSI14Series, formatFloatSlice(data.LongerTermContext.RSI14Values)))
 		}
 	}
 
@@ -0,0 +1,113 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// OrderBookLevel 订单簿单档
+type OrderBookLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderBook 订单簿快照
+type OrderBook struct {
+	Symbol string           `json:"symbol"`
+	Bids   []OrderBookLevel `json:"bids"` // 买盘（价格从高到低）
+	Asks   []OrderBookLevel `json:"asks"` // 卖盘（价格从低到高）
+}
+
+// FundingRecord 历史资金费率
+type FundingRecord struct {
+	FundingTime int64   `json:"funding_time"` // 结算时间（毫秒）
+	FundingRate float64 `json:"funding_rate"`
+}
+
+// GetKlines 获取任意周期的K线（interval 如 "1m", "15m", "1h", "1d"）
+func GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	return getKlines(Normalize(symbol), interval, limit)
+}
+
+// GetOrderBook 获取订单簿（limit 可选 5, 10, 20, 50, 100, 500, 1000）
+func GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	symbol = Normalize(symbol)
+	url := fmt.Sprintf("%s/fapi/v1/depth?symbol=%s&limit=%d", apiBaseURL, symbol, limit)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Bids [][]string `json:"bids"`
+		Asks [][]string `json:"asks"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	return &OrderBook{
+		Symbol: symbol,
+		Bids:   parseBookLevels(result.Bids),
+		Asks:   parseBookLevels(result.Asks),
+	}, nil
+}
+
+// GetFundingHistory 获取最近的历史资金费率（从旧到新）
+func GetFundingHistory(symbol string, limit int) ([]FundingRecord, error) {
+	symbol = Normalize(symbol)
+	url := fmt.Sprintf("%s/fapi/v1/fundingRate?symbol=%s&limit=%d", apiBaseURL, symbol, limit)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		FundingTime int64  `json:"fundingTime"`
+		FundingRate string `json:"fundingRate"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	records := make([]FundingRecord, 0, len(result))
+	for _, item := range result {
+		rate, _ := strconv.ParseFloat(item.FundingRate, 64)
+		records = append(records, FundingRecord{
+			FundingTime: item.FundingTime,
+			FundingRate: rate,
+		})
+	}
+	return records, nil
+}
+
+// parseBookLevels 解析订单簿档位 [["price", "qty"], ...]
+func parseBookLevels(raw [][]string) []OrderBookLevel {
+	levels := make([]OrderBookLevel, 0, len(raw))
+	for _, item := range raw {
+		if len(item) < 2 {
+			continue
+		}
+		price, _ := strconv.ParseFloat(item[0], 64)
+		quantity, _ := strconv.ParseFloat(item[1], 64)
+		levels = append(levels, OrderBookLevel{Price: price, Quantity: quantity})
+	}
+	return levels
+}
@@ -29,6 +29,13 @@ type Client struct {
 	Model      string
 	Timeout    time.Duration
 	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
+
+	MaxTokens        int // 单次回复的最大输出token数（默认2000）
+	ContextWindow    int // 模型上下文窗口大小（token）
+	PromptTokenLimit int // 输入prompt的token上限（0=按上下文窗口自动计算）
+
+	Backend  Backend   // 替代HTTP的请求后端（回放/脚本，为空则请求真实API）
+	Recorder *Recorder // 录制每次请求/响应（为空则不录制）
 }
 
 func New() *Client {
@@ -38,6 +45,9 @@ func New() *Client {
 		BaseURL:  "https://api.deepseek.com/v1",
 		Model:    "deepseek-chat",
 		Timeout:  120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
+
+		MaxTokens:     defaultMaxTokens,
+		ContextWindow: 65536,
 	}
 	return &defaultClient
 }
@@ -48,6 +58,7 @@ func (cfg *Client) SetDeepSeekAPIKey(apiKey string) {
 	cfg.APIKey = apiKey
 	cfg.BaseURL = "https://api.deepseek.com/v1"
 	cfg.Model = "deepseek-chat"
+	cfg.ContextWindow = 65536
 }
 
 // SetMiniMaxAPIKey 设置MiniMax API密钥
@@ -57,6 +68,7 @@ func (cfg *Client) SetMiniMaxAPIKey(apiKey string) {
 	cfg.BaseURL = "https://api.minimax.io/anthropic"
 	cfg.Model = "MiniMax-M2"
 	cfg.Timeout = 300 * time.Second // 5 minutes for free tier
+	cfg.ContextWindow = 204800
 }
 
 // SetQwenAPIKey 设置阿里云Qwen API密钥
@@ -66,6 +78,7 @@ func (cfg *Client) SetQwenAPIKey(apiKey, secretKey string) {
 	cfg.SecretKey = secretKey
 	cfg.BaseURL = "https://dashscope.aliyuncs.com/compatible-mode/v1"
 	cfg.Model = "qwen-plus" // 可选: qwen-turbo, qwen-plus, qwen-max
+	cfg.ContextWindow = 131072
 }
 
 // SetCustomAPI 设置自定义OpenAI兼容API
@@ -84,6 +97,7 @@ func (cfg *Client) SetCustomAPI(apiURL, apiKey, modelName string) {
 
 	cfg.Model = modelName
 	cfg.Timeout = 120 * time.Second
+	cfg.ContextWindow = 32768 // 未知模型按保守的32K估计，可通过PromptTokenLimit覆盖
 }
 
 // SetClient 设置完整的AI配置（高级用户）
@@ -94,12 +108,28 @@ func (cfg *Client) SetClient(Client Client) {
 	cfg = &Client
 }
 
+// Name 返回模型标识（provider/model），用于日志和集成投票记录
+func (cfg *Client) Name() string {
+	return fmt.Sprintf("%s/%s", cfg.Provider, cfg.Model)
+}
+
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
 	if cfg.APIKey == "" {
 		return "", fmt.Errorf("AI API key not set, please call SetDeepSeekAPIKey(), SetQwenAPIKey(), or SetMiniMaxAPIKey() first")
 	}
 
+	var result string
+	err := cfg.withRetry(func() error {
+		var err error
+		result, err = cfg.callOnce(systemPrompt, userPrompt)
+		return err
+	})
+	return result, err
+}
+
+// withRetry 对网络类错误自动重试（最多3次）
+func (cfg *Client) withRetry(call func() error) error {
 	// Retry configuration
 	maxRetries := 3
 	var lastErr error
@@ -109,18 +139,18 @@ func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, er
 			fmt.Printf("⚠️  AI API call failed, retrying (%d/%d)...\n", attempt, maxRetries)
 		}
 
-		result, err := cfg.callOnce(systemPrompt, userPrompt)
+		err := call()
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API retry successful\n")
 			}
-			return result, nil
+			return nil
 		}
 
 		lastErr = err
 		// If not a network error, don't retry
 		if !isRetryableError(err) {
-			return "", err
+			return err
 		}
 
 		// Wait before retry
@@ -131,7 +161,7 @@ func (cfg *Client) CallWithMessages(systemPrompt, userPrompt string) (string, er
 		}
 	}
 
-	return "", fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+	return fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
 }
 
 // callOnce 单次调用AI API（内部使用）
@@ -158,17 +188,64 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 		"model":       cfg.Model,
 		"messages":    messages,
 		"temperature": 0.5, // 降低temperature以提高JSON格式稳定性
-		"max_tokens":  2000,
+		"max_tokens":  cfg.maxTokens(),
 	}
 
 	// 注意：response_format 参数仅 OpenAI 支持，DeepSeek/Qwen 不支持
 	// 我们通过强化 prompt 和后处理来确保 JSON 格式正确
 
+	body, err := cfg.doRequest(requestBody)
+	if err != nil {
+		return "", err
+	}
+
+	// 解析响应
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("API返回空响应")
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+// doRequest 发送chat completions请求并返回响应体（内部使用）
+func (cfg *Client) doRequest(requestBody map[string]interface{}) ([]byte, error) {
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	var body []byte
+	if cfg.Backend != nil {
+		body, err = cfg.Backend.Do(jsonData)
+	} else {
+		body, err = cfg.sendHTTP(jsonData)
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	if cfg.Recorder != nil {
+		if err := cfg.Recorder.Record(jsonData, body); err != nil {
+			fmt.Printf("⚠️  录制AI请求失败: %v\n", err)
+		}
+	}
+	return body, nil
+}
+
+// sendHTTP 通过HTTP发送请求体（内部使用）
+func (cfg *Client) sendHTTP(jsonData []byte) ([]byte, error) {
 	// 创建HTTP请求
 	var url string
 	if cfg.UseFullURL {
@@ -180,7 +257,7 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 	}
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -205,38 +282,21 @@ func (cfg *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
 	client := &http.Client{Timeout: cfg.Timeout}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
 	}
 
-	// 解析响应
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
-	}
-
-	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("API返回空响应")
-	}
-
-	return result.Choices[0].Message.Content, nil
+	return body, nil
 }
 
 // isRetryableError determines if an error is retryable
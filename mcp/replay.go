@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend 可替换的请求后端：接收chat completions请求体，返回响应体
+// 用于离线回放、脚本化测试等不访问网络的场景
+type Backend interface {
+	Do(requestBody []byte) ([]byte, error)
+}
+
+// Exchange 一次录制的请求/响应
+type Exchange struct {
+	Key      string          `json:"key"`      // 请求哈希（RequestKey）
+	Request  json.RawMessage `json:"request"`  // 请求体
+	Response json.RawMessage `json:"response"` // 响应体
+}
+
+// RequestKey 计算请求体的哈希（同一prompt、模型和参数得到相同的key）
+func RequestKey(requestBody []byte) string {
+	hash := sha256.Sum256(requestBody)
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// Recorder 把每次请求/响应保存为目录下的JSON文件（<序号>_<key>.json），供ReplayBackend回放
+type Recorder struct {
+	Dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder 创建录制器（目录不存在时自动创建，序号接着目录中已有的文件继续）
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("读取录制目录失败: %w", err)
+	}
+	return &Recorder{Dir: dir, seq: len(files)}, nil
+}
+
+// Record 保存一次请求/响应
+func (r *Recorder) Record(requestBody, responseBody []byte) error {
+	exchange := Exchange{
+		Key:      RequestKey(requestBody),
+		Request:  json.RawMessage(requestBody),
+		Response: json.RawMessage(responseBody),
+	}
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化录制记录失败: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	filename := filepath.Join(r.Dir, fmt.Sprintf("%06d_%s.json", r.seq, exchange.Key))
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("写入录制文件失败: %w", err)
+	}
+	return nil
+}
+
+// ReplayBackend 回放录制的响应：优先按请求哈希精确匹配；
+// 非严格模式下匹配不到时（如prompt中包含当前时间），按录制顺序返回下一条
+type ReplayBackend struct {
+	Strict bool // 严格模式：只允许精确匹配
+
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+	next      int
+}
+
+// LoadReplay 从录制目录加载回放后端（按文件名顺序）
+func LoadReplay(dir string) (*ReplayBackend, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("读取录制目录失败: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("录制目录为空: %s", dir)
+	}
+	sort.Strings(files)
+
+	exchanges := make([]Exchange, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取录制文件失败: %w", err)
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(data, &exchange); err != nil {
+			return nil, fmt.Errorf("解析录制文件 %s 失败: %w", filepath.Base(file), err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return NewReplayBackend(exchanges), nil
+}
+
+// NewReplayBackend 用内存中的录制记录创建回放后端
+func NewReplayBackend(exchanges []Exchange) *ReplayBackend {
+	return &ReplayBackend{
+		exchanges: exchanges,
+		used:      make([]bool, len(exchanges)),
+	}
+}
+
+// Do 实现Backend接口
+func (b *ReplayBackend) Do(requestBody []byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := RequestKey(requestBody)
+	for i, exchange := range b.exchanges {
+		if !b.used[i] && exchange.Key == key {
+			b.used[i] = true
+			return exchange.Response, nil
+		}
+	}
+	if b.Strict {
+		return nil, fmt.Errorf("回放记录中没有匹配的请求: %s", key)
+	}
+
+	for b.next < len(b.exchanges) && b.used[b.next] {
+		b.next++
+	}
+	if b.next >= len(b.exchanges) {
+		return nil, fmt.Errorf("回放记录已用完（共%d条）", len(b.exchanges))
+	}
+	b.used[b.next] = true
+	return b.exchanges[b.next].Response, nil
+}
+
+// ScriptedBackend 按顺序返回预设的回复内容（如固定的决策JSON），用于测试
+// 回复用完后重复最后一条
+type ScriptedBackend struct {
+	Responses []string
+
+	mu       sync.Mutex
+	next     int
+	requests [][]byte
+}
+
+// Do 实现Backend接口
+func (b *ScriptedBackend) Do(requestBody []byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.Responses) == 0 {
+		return nil, fmt.Errorf("脚本回复为空")
+	}
+	b.requests = append(b.requests, append([]byte(nil), requestBody...))
+
+	content := b.Responses[len(b.Responses)-1]
+	if b.next < len(b.Responses) {
+		content = b.Responses[b.next]
+	}
+	b.next++
+
+	return json.Marshal(map[string]interface{}{
+		"id":      fmt.Sprintf("scripted-%d", b.next),
+		"created": time.Now().Unix(),
+		"choices": []map[string]interface{}{
+			{
+				"index":         0,
+				"finish_reason": "stop",
+				"message":       map[string]interface{}{"role": "assistant", "content": content},
+			},
+		},
+	})
+}
+
+// Requests 返回收到的全部请求体（用于测试断言）
+func (b *ScriptedBackend) Requests() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]byte(nil), b.requests...)
+}
+
+// NewOfflineClient 创建使用指定后端、不访问网络的客户端
+func NewOfflineClient(name string, backend Backend) *Client {
+	client := New()
+	client.Provider = ProviderCustom
+	client.APIKey = "offline"
+	client.BaseURL = "offline://" + strings.ToLower(name)
+	client.Model = name
+	client.Backend = backend
+	return client
+}
+
+// NewReplayClient 创建从录制目录回放的客户端
+func NewReplayClient(dir string) (*Client, error) {
+	backend, err := LoadReplay(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewOfflineClient("replay", backend), nil
+}
+
+// NewScriptedClient 创建按顺序返回预设回复的客户端
+func NewScriptedClient(responses ...string) *Client {
+	return NewOfflineClient("scripted", &ScriptedBackend{Responses: responses})
+}
+
+// EnableRecording 开启录制：之后的每次请求/响应都保存到dir
+func (cfg *Client) EnableRecording(dir string) error {
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		return err
+	}
+	cfg.Recorder = recorder
+	return nil
+}
@@ -0,0 +1,73 @@
+package mcp
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxTokens    = 2000 // 默认单次回复的最大输出token数
+	promptSafetyPercent = 10   // 预留给估算误差的上下文比例（%）
+)
+
+// tokenRatio tokenizer的近似比例
+type tokenRatio struct {
+	perASCII    float64 // 每个ASCII字符约多少token
+	perNonASCII float64 // 每个非ASCII字符（中文等）约多少token
+}
+
+// tokenRatios 各提供商tokenizer的近似比例
+// DeepSeek官方文档：1个英文字符≈0.3 token，1个中文字符≈0.6 token；Qwen接近；其他按OpenAI类tokenizer估计
+var tokenRatios = map[Provider]tokenRatio{
+	ProviderDeepSeek: {perASCII: 0.3, perNonASCII: 0.6},
+	ProviderQwen:     {perASCII: 0.3, perNonASCII: 0.6},
+	ProviderMiniMax:  {perASCII: 0.3, perNonASCII: 1.0},
+	ProviderCustom:   {perASCII: 0.25, perNonASCII: 1.0},
+}
+
+// EstimateTokens 按当前模型的tokenizer粗略估算文本的token数
+func (cfg *Client) EstimateTokens(text string) int {
+	return EstimateTokens(cfg.Provider, text)
+}
+
+// EstimateTokens 按提供商的tokenizer粗略估算文本的token数（未知提供商按通用tokenizer估算）
+func EstimateTokens(provider Provider, text string) int {
+	ratio, ok := tokenRatios[provider]
+	if !ok {
+		ratio = tokenRatios[ProviderCustom]
+	}
+
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return int(math.Ceil(float64(ascii)*ratio.perASCII + float64(other)*ratio.perNonASCII))
+}
+
+// PromptTokenBudget 输入prompt（system+user）可用的token数
+// = 上下文窗口 - 输出token - 安全余量；配置了PromptTokenLimit时以其为准
+func (cfg *Client) PromptTokenBudget() int {
+	if cfg.PromptTokenLimit > 0 {
+		return cfg.PromptTokenLimit
+	}
+	if cfg.ContextWindow <= 0 {
+		return 0
+	}
+	budget := cfg.ContextWindow - cfg.maxTokens() - cfg.ContextWindow*promptSafetyPercent/100
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// maxTokens 单次回复的最大输出token数
+func (cfg *Client) maxTokens() int {
+	if cfg.MaxTokens > 0 {
+		return cfg.MaxTokens
+	}
+	return defaultMaxTokens
+}
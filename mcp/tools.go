@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tool 提供给模型调用的工具（OpenAI function calling 格式）
+type Tool struct {
+	Name        string                 // 工具名称
+	Description string                 // 工具说明
+	Parameters  map[string]interface{} // 参数JSON Schema
+}
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID        string // 调用ID（回传结果时需要）
+	Name      string // 工具名称
+	Arguments string // JSON格式的参数
+}
+
+// Message 对话消息（支持工具调用）
+type Message struct {
+	Role       string     // "system", "user", "assistant" 或 "tool"
+	Content    string     // 文本内容
+	ToolCalls  []ToolCall // assistant消息中的工具调用
+	ToolCallID string     // tool消息对应的调用ID
+}
+
+// ChatResponse 支持工具调用的回复
+type ChatResponse struct {
+	Content   string     // 文本内容（最终回复或调用工具前的思考）
+	ToolCalls []ToolCall // 模型请求的工具调用（为空表示已给出最终回复）
+}
+
+// CallWithTools 携带工具定义调用AI API；tools为空时等同于普通对话
+func (cfg *Client) CallWithTools(messages []Message, tools []Tool) (*ChatResponse, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("AI API key not set, please call SetDeepSeekAPIKey(), SetQwenAPIKey(), or SetMiniMaxAPIKey() first")
+	}
+
+	var result *ChatResponse
+	err := cfg.withRetry(func() error {
+		var err error
+		result, err = cfg.callWithToolsOnce(messages, tools)
+		return err
+	})
+	return result, err
+}
+
+// callWithToolsOnce 单次携带工具调用AI API（内部使用）
+func (cfg *Client) callWithToolsOnce(messages []Message, tools []Tool) (*ChatResponse, error) {
+	requestBody := map[string]interface{}{
+		"model":       cfg.Model,
+		"messages":    encodeMessages(messages),
+		"temperature": 0.5,
+		"max_tokens":  cfg.maxTokens(),
+	}
+	if len(tools) > 0 {
+		requestBody["tools"] = encodeTools(tools)
+	}
+
+	body, err := cfg.doRequest(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("API返回空响应")
+	}
+
+	message := result.Choices[0].Message
+	response := &ChatResponse{Content: message.Content}
+	for _, call := range message.ToolCalls {
+		response.ToolCalls = append(response.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return response, nil
+}
+
+// encodeMessages 转换为API的messages格式
+func encodeMessages(messages []Message) []map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(messages))
+	for _, m := range messages {
+		msg := map[string]interface{}{
+			"role":    m.Role,
+			"content": m.Content,
+		}
+		if len(m.ToolCalls) > 0 {
+			var calls []map[string]interface{}
+			for _, call := range m.ToolCalls {
+				calls = append(calls, map[string]interface{}{
+					"id":   call.ID,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      call.Name,
+						"arguments": call.Arguments,
+					},
+				})
+			}
+			msg["tool_calls"] = calls
+		}
+		if m.ToolCallID != "" {
+			msg["tool_call_id"] = m.ToolCallID
+		}
+		encoded = append(encoded, msg)
+	}
+	return encoded
+}
+
+// encodeTools 转换为API的tools格式
+func encodeTools(tools []Tool) []map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		encoded = append(encoded, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			},
+		})
+	}
+	return encoded
+}
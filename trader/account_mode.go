@@ -0,0 +1,68 @@
+package trader
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 保证金模式和持仓模式（ConfigureAccountModes的参数，空字符串表示沿用默认/账户当前设置）
+const (
+	MarginModeIsolated = "isolated" // 逐仓
+	MarginModeCross    = "cross"    // 全仓
+
+	PositionModeHedge  = "hedge"   // 双向持仓：多空分开持有，下单带positionSide LONG/SHORT
+	PositionModeOneWay = "one_way" // 单向持仓：每个币种只有一个净持仓，平仓单需要reduceOnly
+)
+
+// ValidateAccountModes 检查保证金模式和持仓模式的取值（允许为空）
+func ValidateAccountModes(marginMode, positionMode string) error {
+	if marginMode != "" && marginMode != MarginModeIsolated && marginMode != MarginModeCross {
+		return fmt.Errorf("无效的保证金模式 %q（应为%s或%s）", marginMode, MarginModeIsolated, MarginModeCross)
+	}
+	if positionMode != "" && positionMode != PositionModeHedge && positionMode != PositionModeOneWay {
+		return fmt.Errorf("无效的持仓模式 %q（应为%s或%s）", positionMode, PositionModeHedge, PositionModeOneWay)
+	}
+	return nil
+}
+
+// unsupportedModeError 交易所不支持所配置的模式
+func unsupportedModeError(exchange, mode string) error {
+	return fmt.Errorf("%s 不支持 %s 模式: %w", exchange, mode, ErrUnsupportedAccountMode)
+}
+
+// accountModes 交易器当前使用的保证金模式和持仓模式
+// ConfigureAccountModes在启动时写入，下单时（包括跟踪止损引擎的后台协程）读取
+type accountModes struct {
+	mu           sync.RWMutex
+	marginMode   string
+	positionMode string
+}
+
+// set 更新模式
+func (m *accountModes) set(marginMode, positionMode string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.marginMode = marginMode
+	m.positionMode = positionMode
+}
+
+// get 返回当前的保证金模式和持仓模式
+func (m *accountModes) get() (marginMode, positionMode string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.marginMode, m.positionMode
+}
+
+// hedge 是否为双向持仓模式
+func (m *accountModes) hedge() bool {
+	_, positionMode := m.get()
+	return positionMode == PositionModeHedge
+}
+
+// orderPositionSide 订单的positionSide参数：双向持仓为"LONG"/"SHORT"，单向持仓为"BOTH"
+func (m *accountModes) orderPositionSide(positionSide string) string {
+	if m.hedge() {
+		return positionSide
+	}
+	return "BOTH"
+}
@@ -0,0 +1,519 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 账户推送（WebSocket）的公共参数
+var (
+	// streamReconnectDelay 推送断线后的重连间隔
+	streamReconnectDelay = 5 * time.Second
+	// streamResyncInterval 推送连接正常时也定期用REST快照校正本地模型（可用余额、强平价等推送中没有的字段）
+	streamResyncInterval = 5 * time.Minute
+)
+
+// streamFillBuffer 本地模型保留的最近成交数量（更早的成交由GetFills走REST查询）
+const streamFillBuffer = 1000
+
+// accountModel 由WebSocket推送维护的本地账户模型（余额、持仓、挂单、成交）
+// 推送连接正常且已加载REST快照时各读取方法返回ok=true，交易器直接用本地数据；
+// 断线、尚未加载快照或本交易器刚下过单（dirty）时返回ok=false，交易器退回REST查询并用结果刷新模型
+type accountModel struct {
+	mu   sync.Mutex
+	live bool // 推送连接正常且已加载快照
+
+	// 余额：快照中的字段按推送的钱包余额和未实现盈亏变化量调整
+	balance         map[string]interface{}
+	wallet          float64                           // 推送的最新钱包余额
+	snapWallet      float64                           // 快照时的钱包余额
+	snapUnrealized  float64                           // 快照时的未实现盈亏合计
+	accountDirty    bool                              // 需要先用REST刷新余额和持仓
+	positions       map[string]map[string]interface{} // symbol_side -> 持仓（字段同GetPositions）
+	markPrices      map[string]float64
+	orders          map[int64]map[string]interface{} // 挂单中的订单（字段同GetOpenOrders）
+	orderTypes      map[int64]string                 // 见过的订单类型（成交推送不带订单类型时用来识别止损/止盈触发）
+	ordersDirty     map[string]bool                  // 需要先用REST刷新该币种的挂单
+	fills           []map[string]interface{}
+	fillsFrom       time.Time // 本地成交记录从这个时间起是完整的（推送连接建立的时间）
+	fillSubscribers chan map[string]interface{}
+}
+
+// newAccountModel 创建未连接状态的本地账户模型
+func newAccountModel() *accountModel {
+	return &accountModel{
+		positions:   make(map[string]map[string]interface{}),
+		markPrices:  make(map[string]float64),
+		orders:      make(map[int64]map[string]interface{}),
+		orderTypes:  make(map[int64]string),
+		ordersDirty: make(map[string]bool),
+	}
+}
+
+// connected 推送连接建立后调用：之后收到的成交都会记入本地模型（快照加载完成前读取仍走REST）
+func (m *accountModel) connected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fills = nil
+	m.fillsFrom = time.Now()
+}
+
+// disconnected 推送断线或关闭：之后的读取全部走REST
+func (m *accountModel) disconnected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.live = false
+}
+
+// isLive 推送连接是否正常
+func (m *accountModel) isLive() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.live
+}
+
+// loadAccount 用REST快照替换余额和持仓（wallet为快照中的钱包余额），模型之后进入可用状态
+func (m *accountModel) loadAccount(balance map[string]interface{}, wallet float64, positions []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loadAccountLocked(balance, wallet, positions)
+}
+
+func (m *accountModel) loadAccountLocked(balance map[string]interface{}, wallet float64, positions []map[string]interface{}) {
+	m.balance = copyFields(balance)
+	m.wallet, m.snapWallet = wallet, wallet
+	m.snapUnrealized = 0
+	m.positions = make(map[string]map[string]interface{}, len(positions))
+	for _, pos := range positions {
+		pos = copyFields(pos)
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		m.positions[symbol+"_"+side] = pos
+		unrealized, _ := pos["unRealizedProfit"].(float64)
+		m.snapUnrealized += unrealized
+	}
+	m.accountDirty = false
+	m.live = true
+}
+
+// loadOrders 用REST快照替换挂单（symbol为空表示全部币种）
+func (m *accountModel) loadOrders(symbol string, orders []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loadOrdersLocked(symbol, orders)
+}
+
+func (m *accountModel) loadOrdersLocked(symbol string, orders []map[string]interface{}) {
+	for id, order := range m.orders {
+		if symbol == "" || order["symbol"] == symbol {
+			delete(m.orders, id)
+		}
+	}
+	for _, order := range orders {
+		orderID, _ := order["orderId"].(int64)
+		m.orders[orderID] = copyFields(order)
+		m.rememberTypeLocked(orderID, order["type"])
+	}
+	if symbol == "" {
+		m.ordersDirty = make(map[string]bool)
+	} else {
+		delete(m.ordersDirty, symbol)
+	}
+}
+
+// pushSnapshot 推送的完整账户状态（如Hyperliquid的webData2）：替换余额、持仓和全部挂单
+// 本交易器刚下过单时跳过（推送可能是下单前的状态），等下次读取用REST刷新
+func (m *accountModel) pushSnapshot(balance map[string]interface{}, wallet float64, positions, orders []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.live || m.accountDirty || len(m.ordersDirty) > 0 {
+		return
+	}
+	m.loadAccountLocked(balance, wallet, positions)
+	m.loadOrdersLocked("", orders)
+}
+
+// markDirty 本交易器下单、撤单或改杠杆后调用：推送可能还没到，下次读取余额/持仓（和symbol的挂单）时先用REST刷新
+func (m *accountModel) markDirty(symbol string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accountDirty = true
+	if symbol != "" {
+		m.ordersDirty[symbol] = true
+	}
+}
+
+// getBalance 本地模型中的余额（钱包余额、未实现盈亏按推送更新，可用余额按两者的变化量估算）
+func (m *accountModel) getBalance() (map[string]interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.live || m.accountDirty || m.balance == nil {
+		return nil, false
+	}
+
+	unrealized := 0.0
+	for _, pos := range m.positions {
+		value, _ := pos["unRealizedProfit"].(float64)
+		unrealized += value
+	}
+	delta := (m.wallet - m.snapWallet) + (unrealized - m.snapUnrealized)
+
+	result := copyFields(m.balance)
+	if _, ok := result["totalWalletBalance"]; ok {
+		result["totalWalletBalance"] = m.wallet
+	}
+	if _, ok := result["totalUnrealizedProfit"]; ok {
+		result["totalUnrealizedProfit"] = unrealized
+	}
+	for _, key := range []string{"availableBalance", "totalMarginBalance", "totalEquity"} {
+		if value, ok := result[key].(float64); ok {
+			result[key] = value + delta
+		}
+	}
+	return result, true
+}
+
+// getPositions 本地模型中的持仓
+func (m *accountModel) getPositions() ([]map[string]interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.live || m.accountDirty || m.balance == nil {
+		return nil, false
+	}
+	keys := make([]string, 0, len(m.positions))
+	for key := range m.positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, copyFields(m.positions[key]))
+	}
+	return result, true
+}
+
+// getOpenOrders 本地模型中该币种的挂单
+func (m *accountModel) getOpenOrders(symbol string) ([]map[string]interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.live || m.ordersDirty[symbol] {
+		return nil, false
+	}
+	ids := make([]int64, 0)
+	for id, order := range m.orders {
+		if order["symbol"] == symbol {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	result := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, copyFields(m.orders[id]))
+	}
+	return result, true
+}
+
+// getFills 本地模型中since之后的成交（since早于本地记录的起点时返回ok=false）
+func (m *accountModel) getFills(since time.Time) ([]map[string]interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.live || since.Before(m.fillsFrom) {
+		return nil, false
+	}
+	result := []map[string]interface{}{}
+	for _, fill := range m.fills {
+		if fillTime, _ := fill["time"].(int64); fillTime >= since.UnixMilli() {
+			result = append(result, copyFields(fill))
+		}
+	}
+	return result, true
+}
+
+// setWallet 推送的钱包余额
+func (m *accountModel) setWallet(wallet float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallet = wallet
+}
+
+// updatePosition 推送的持仓变化（amount为0表示已平仓）
+// 新出现的持仓缺少杠杆、强平价等字段，平仓释放的保证金也不在推送中，两者都标记为需要REST刷新
+func (m *accountModel) updatePosition(symbol, side string, amount, entryPrice, unrealized float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := symbol + "_" + side
+	if amount == 0 {
+		if _, exists := m.positions[key]; exists {
+			delete(m.positions, key)
+			m.accountDirty = true
+		}
+		return
+	}
+	pos, exists := m.positions[key]
+	if !exists {
+		pos = map[string]interface{}{"symbol": symbol, "side": side, "markPrice": entryPrice, "leverage": 0.0, "liquidationPrice": 0.0}
+		m.positions[key] = pos
+		m.accountDirty = true
+	}
+	pos["positionAmt"] = amount
+	pos["entryPrice"] = entryPrice
+	pos["unRealizedProfit"] = unrealized
+}
+
+// setMarkPrice 推送的标记价格：按最新价格重新计算该币种持仓的未实现盈亏
+func (m *accountModel) setMarkPrice(symbol string, price float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.markPrices[symbol] = price
+	for _, side := range []string{"long", "short"} {
+		pos, ok := m.positions[symbol+"_"+side]
+		if !ok {
+			continue
+		}
+		amount, _ := pos["positionAmt"].(float64)
+		entryPrice, _ := pos["entryPrice"].(float64)
+		unrealized := (price - entryPrice) * amount
+		if side == "short" {
+			unrealized = -unrealized
+		}
+		pos["markPrice"] = price
+		pos["unRealizedProfit"] = unrealized
+	}
+}
+
+// updateOrder 推送的订单状态（NEW/PARTIALLY_FILLED为挂单中，其他状态从挂单中移除）
+// 已知的挂单只更新推送中带的字段
+func (m *accountModel) updateOrder(order map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	orderID, _ := order["orderId"].(int64)
+	m.rememberTypeLocked(orderID, order["type"])
+	switch order["status"] {
+	case "NEW", "PARTIALLY_FILLED":
+		existing, ok := m.orders[orderID]
+		if !ok {
+			existing = make(map[string]interface{}, len(order))
+			m.orders[orderID] = existing
+		}
+		for k, v := range order {
+			existing[k] = v
+		}
+	default:
+		delete(m.orders, orderID)
+	}
+}
+
+// rememberOrderType 记录订单类型（如交易器自己挂出的触发单，之后的推送可能只带订单ID）
+func (m *accountModel) rememberOrderType(orderID int64, orderType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rememberTypeLocked(orderID, orderType)
+}
+
+// rememberTypeLocked 记录订单类型（超过上限时只保留挂单中的订单）
+func (m *accountModel) rememberTypeLocked(orderID int64, orderType interface{}) {
+	value, ok := orderType.(string)
+	if !ok || value == "" {
+		return
+	}
+	if len(m.orderTypes) >= 2*streamFillBuffer {
+		m.orderTypes = make(map[int64]string, len(m.orders))
+		for id, order := range m.orders {
+			if t, ok := order["type"].(string); ok {
+				m.orderTypes[id] = t
+			}
+		}
+	}
+	m.orderTypes[orderID] = value
+}
+
+// orderType 见过的订单类型（不知道时返回空字符串）
+func (m *accountModel) orderType(orderID int64) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.orderTypes[orderID]
+}
+
+// addFill 记录推送的成交，并发给Fills的订阅者（订阅者消费不及时时丢弃，GetFills仍能查到）
+func (m *accountModel) addFill(fill map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fills = append(m.fills, fill)
+	if len(m.fills) > streamFillBuffer {
+		m.fills = m.fills[len(m.fills)-streamFillBuffer:]
+		// 丢弃了更早的成交，本地记录只从保留的第一笔开始完整
+		if first, _ := m.fills[0]["time"].(int64); first > 0 {
+			m.fillsFrom = time.UnixMilli(first)
+		}
+	}
+	if m.fillSubscribers == nil {
+		return
+	}
+	select {
+	case m.fillSubscribers <- copyFields(fill):
+	default:
+		log.Printf("⚠️  实时成交通道已满，丢弃成交推送（GetFills仍可查询）: %v 订单 %v", fill["symbol"], fill["orderId"])
+	}
+}
+
+// subscribeFills 返回实时成交通道（整个模型只有一个订阅者，重复调用返回同一个通道）
+func (m *accountModel) subscribeFills() <-chan map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fillSubscribers == nil {
+		m.fillSubscribers = make(chan map[string]interface{}, 256)
+	}
+	return m.fillSubscribers
+}
+
+// closeFills 关闭实时成交通道（推送停止时调用）
+func (m *accountModel) closeFills() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fillSubscribers != nil {
+		close(m.fillSubscribers)
+		m.fillSubscribers = nil
+	}
+}
+
+// copyFields 复制一层map（返回给调用方的数据不与模型共享）
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		result[k] = v
+	}
+	return result
+}
+
+// streamRunner 账户推送连接的公共部分：首次连接、读取、断线重连、定期续期和REST校正、关闭
+// 各交易所只需提供建立连接（含订阅）、处理消息和加载快照的方法
+type streamRunner struct {
+	name    string // 日志中的交易所名
+	account *accountModel
+
+	dial         func() (*websocket.Conn, error)  // 建立连接并订阅
+	read         func(conn *websocket.Conn) error // 处理推送，连接出错时返回
+	resync       func() error                     // 用REST快照刷新本地模型
+	ping         func(conn *websocket.Conn) error // 可选：每隔pingInterval调用一次（心跳或续期）
+	pingInterval time.Duration
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	stop chan struct{}
+	done chan struct{}
+}
+
+// start 建立首次连接（失败时返回错误），之后在后台读取推送并自动重连
+func (r *streamRunner) start() error {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	if err := r.connect(); err != nil {
+		return err
+	}
+	go r.run()
+	go r.maintain()
+	return nil
+}
+
+// close 关闭连接并等待后台读取结束，本地模型回到REST模式，实时成交通道被关闭
+func (r *streamRunner) close() {
+	close(r.stop)
+	r.mu.Lock()
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.mu.Unlock()
+	<-r.done
+	r.account.disconnected()
+	r.account.closeFills()
+}
+
+// connect 建立连接并加载REST快照（先连接再加载：快照之后的变化都能通过推送收到）
+func (r *streamRunner) connect() error {
+	conn, err := r.dial()
+	if err != nil {
+		return fmt.Errorf("连接%s账户推送失败: %w", r.name, err)
+	}
+	r.account.connected()
+	if err := r.resync(); err != nil {
+		conn.Close()
+		return err
+	}
+	r.mu.Lock()
+	r.conn = conn
+	r.mu.Unlock()
+	return nil
+}
+
+// run 读取推送直到close；断线后每隔streamReconnectDelay重连，期间读取走REST
+func (r *streamRunner) run() {
+	defer close(r.done)
+	for {
+		r.mu.Lock()
+		conn := r.conn
+		r.mu.Unlock()
+
+		err := r.read(conn)
+		conn.Close()
+		r.account.disconnected()
+
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+		log.Printf("⚠️  %s账户推送断开，%v后重连（期间使用REST查询）: %v", r.name, streamReconnectDelay, err)
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-time.After(streamReconnectDelay):
+			}
+			if err := r.connect(); err != nil {
+				log.Printf("⚠️  %s账户推送重连失败: %v", r.name, err)
+				continue
+			}
+			log.Printf("✓ %s账户推送已重连", r.name)
+			break
+		}
+	}
+}
+
+// maintain 定期调用ping，并在连接正常时用REST快照校正本地模型
+func (r *streamRunner) maintain() {
+	var pings <-chan time.Time
+	if r.ping != nil {
+		ticker := time.NewTicker(r.pingInterval)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+	resync := time.NewTicker(streamResyncInterval)
+	defer resync.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-pings:
+			r.mu.Lock()
+			conn := r.conn
+			r.mu.Unlock()
+			if err := r.ping(conn); err != nil {
+				log.Printf("⚠️  %s账户推送心跳失败: %v", r.name, err)
+			}
+		case <-resync.C:
+			if !r.account.isLive() {
+				continue
+			}
+			if err := r.resync(); err != nil {
+				log.Printf("⚠️  %s账户推送定期校正失败: %v", r.name, err)
+			}
+		}
+	}
+}
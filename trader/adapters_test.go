@@ -0,0 +1,131 @@
+package trader_test
+
+import (
+	"danto/trader"
+	"danto/trader/exchangetest"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testAdapter 连接到exchangetest模拟服务的交易器
+type testAdapter struct {
+	name   string
+	trader trader.Trader
+	server *exchangetest.Server
+}
+
+// newTestAdapters 为每个交易所启动模拟服务（各自独立的账户）并创建连接到它的交易器
+func newTestAdapters(t *testing.T) []testAdapter {
+	t.Helper()
+	newVenue := func() *exchangetest.Venue {
+		return exchangetest.NewVenue(10000, exchangetest.DefaultInstruments()...)
+	}
+	newKey := func() ([]byte, string) {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crypto.FromECDSA(key), crypto.PubkeyToAddress(key.PublicKey).Hex()
+	}
+	var adapters []testAdapter
+
+	binanceServer := exchangetest.NewBinanceServer(newVenue(), "key", "secret")
+	binance := trader.NewFuturesTrader("key", "secret")
+	binance.SetBaseURL(binanceServer.URL)
+	binance.SetCooldowns(0, 0)
+	adapters = append(adapters, testAdapter{"binance", binance, binanceServer})
+
+	_, user := newKey()
+	signerKey, signer := newKey()
+	asterServer := exchangetest.NewAsterServer(newVenue(), user, signer)
+	aster, err := trader.NewAsterTrader(user, signer, hex.EncodeToString(signerKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aster.SetBaseURL(asterServer.URL)
+	adapters = append(adapters, testAdapter{"aster", aster, asterServer})
+
+	deltaServer := exchangetest.NewDeltaServer(newVenue(), "key", "secret")
+	delta := trader.NewDeltaTrader("key", "secret", false)
+	delta.SetBaseURL(deltaServer.URL)
+	adapters = append(adapters, testAdapter{"delta", delta, deltaServer})
+
+	agentKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, wallet := newKey()
+	hyperliquidServer := exchangetest.NewHyperliquidServer(newVenue(), agentKey, wallet)
+	hyperliquid, err := trader.NewHyperliquidTraderWithURL(hex.EncodeToString(crypto.FromECDSA(agentKey)), wallet, hyperliquidServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapters = append(adapters, testAdapter{"hyperliquid", hyperliquid, hyperliquidServer})
+
+	t.Cleanup(func() {
+		for _, adapter := range adapters {
+			adapter.server.Close()
+		}
+	})
+	return adapters
+}
+
+func TestAdaptersSurfaceExchangeErrors(t *testing.T) {
+	for _, adapter := range newTestAdapters(t) {
+		t.Run(adapter.name, func(t *testing.T) {
+			adapter.server.FailNext(http.StatusInternalServerError, `{"code":-1000,"msg":"internal error"}`)
+			if _, err := adapter.trader.GetPositions(); err == nil {
+				t.Fatalf("交易所返回500时GetPositions应返回错误")
+			}
+			if _, err := adapter.trader.GetPositions(); err != nil {
+				t.Fatalf("交易所恢复后GetPositions应成功: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdaptersFunding(t *testing.T) {
+	const rate = 0.0001
+	for _, adapter := range newTestAdapters(t) {
+		t.Run(adapter.name, func(t *testing.T) {
+			venue := adapter.server.Venue
+			start := time.Now().Add(-time.Second)
+			if _, err := adapter.trader.OpenLong("BTCUSDT", 0.01, 5); err != nil {
+				t.Fatalf("开仓失败: %v", err)
+			}
+			defer adapter.trader.CloseLong("BTCUSDT", 0)
+
+			venue.SetFundingRate("BTCUSDT", rate)
+			got, next, err := adapter.trader.GetFundingRate("BTCUSDT")
+			if err != nil {
+				t.Fatalf("GetFundingRate: %v", err)
+			}
+			if got != rate || !next.After(time.Now()) {
+				t.Errorf("GetFundingRate = %v, %v, 期望 %v 和未来的结算时间", got, next, rate)
+			}
+
+			venue.SettleFunding("BTCUSDT")
+			price, _ := venue.Price("BTCUSDT")
+			payments, err := adapter.trader.GetFundingPayments(start)
+			if err != nil {
+				t.Fatalf("GetFundingPayments: %v", err)
+			}
+			total := 0.0
+			for _, payment := range payments {
+				if payment["symbol"] == "BTCUSDT" {
+					amount, _ := payment["amount"].(float64)
+					total += amount
+				}
+			}
+			// 多仓在正费率时支付 数量×价格×费率
+			if want := -0.01 * price * rate; math.Abs(total-want) > 1e-6 {
+				t.Errorf("资金费合计 = %v, 期望 %v: %v", total, want, payments)
+			}
+		})
+	}
+}
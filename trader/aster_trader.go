@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"math/big"
 	"net/http"
 	"net/url"
@@ -33,17 +32,21 @@ type AsterTrader struct {
 	client     *http.Client
 	baseURL    string
 
-	// 缓存交易对精度信息
-	symbolPrecision map[string]SymbolPrecision
-	mu              sync.RWMutex
-}
+	// 交易对规则（exchangeInfo，见GetInstrument）
+	instruments *instrumentRegistry
+
+	// 下过单的币种（成交记录接口必须按币种查询）
+	tradedSymbols      map[string]bool
+	tradedSymbolsMutex sync.Mutex
+
+	// Aster不支持原生跟踪止损，由客户端按价格移动止损单
+	trailing *trailingEngine
 
-// SymbolPrecision 交易对精度信息
-type SymbolPrecision struct {
-	PricePrecision    int
-	QuantityPrecision int
-	TickSize          float64 // 价格步进值
-	StepSize          float64 // 数量步进值
+	// 客户端订单ID（见SetClientOrderIDPrefix）
+	clientIDs clientOrderIDs
+
+	// 保证金模式和持仓模式（见ConfigureAccountModes，默认沿用账户设置+单向持仓）
+	modes accountModes
 }
 
 // NewAsterTrader 创建Aster交易器
@@ -57,12 +60,11 @@ func NewAsterTrader(user, signer, privateKeyHex string) (*AsterTrader, error) {
 		return nil, fmt.Errorf("解析私钥失败: %w", err)
 	}
 
-	return &AsterTrader{
-		ctx:             context.Background(),
-		user:            user,
-		signer:          signer,
-		privateKey:      privKey,
-		symbolPrecision: make(map[string]SymbolPrecision),
+	t := &AsterTrader{
+		ctx:        context.Background(),
+		user:       user,
+		signer:     signer,
+		privateKey: privKey,
 		client: &http.Client{
 			Timeout: 30 * time.Second, // 增加到30秒
 			Transport: &http.Transport{
@@ -72,7 +74,16 @@ func NewAsterTrader(user, signer, privateKeyHex string) (*AsterTrader, error) {
 			},
 		},
 		baseURL: "https://fapi.asterdex.com",
-	}, nil
+	}
+	t.trailing = newTrailingEngine(t, trailingStopInterval)
+	t.modes.set("", PositionModeOneWay)
+	t.instruments = newInstrumentRegistry("Aster", t.loadInstruments)
+	return t, nil
+}
+
+// SetBaseURL 替换API地址（用于本地模拟服务）
+func (t *AsterTrader) SetBaseURL(url string) {
+	t.baseURL = strings.TrimSuffix(url, "/")
 }
 
 // genNonce 生成微秒时间戳
@@ -80,127 +91,59 @@ func (t *AsterTrader) genNonce() uint64 {
 	return uint64(time.Now().UnixMicro())
 }
 
-// getPrecision 获取交易对精度信息
-func (t *AsterTrader) getPrecision(symbol string) (SymbolPrecision, error) {
-	t.mu.RLock()
-	if prec, ok := t.symbolPrecision[symbol]; ok {
-		t.mu.RUnlock()
-		return prec, nil
-	}
-	t.mu.RUnlock()
-
-	// 获取交易所信息
+// loadInstruments 加载全部交易对规则（exchangeInfo与币安格式相同），杠杆档位来自leverageBracket（获取失败时不检查杠杆上限）
+func (t *AsterTrader) loadInstruments() ([]InstrumentInfo, error) {
 	resp, err := t.client.Get(t.baseURL + "/fapi/v3/exchangeInfo")
 	if err != nil {
-		return SymbolPrecision{}, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
 	var info struct {
 		Symbols []struct {
-			Symbol            string `json:"symbol"`
-			PricePrecision    int    `json:"pricePrecision"`
-			QuantityPrecision int    `json:"quantityPrecision"`
-			Filters           []map[string]interface{} `json:"filters"`
+			Symbol  string                   `json:"symbol"`
+			Filters []map[string]interface{} `json:"filters"`
 		} `json:"symbols"`
 	}
-
 	if err := json.Unmarshal(body, &info); err != nil {
-		return SymbolPrecision{}, err
+		return nil, err
 	}
 
-	// 缓存所有交易对的精度
-	t.mu.Lock()
-	for _, s := range info.Symbols {
-		prec := SymbolPrecision{
-			PricePrecision:    s.PricePrecision,
-			QuantityPrecision: s.QuantityPrecision,
-		}
-
-		// 解析filters获取tickSize和stepSize
-		for _, filter := range s.Filters {
-			filterType, _ := filter["filterType"].(string)
-			switch filterType {
-			case "PRICE_FILTER":
-				if tickSizeStr, ok := filter["tickSize"].(string); ok {
-					prec.TickSize, _ = strconv.ParseFloat(tickSizeStr, 64)
-				}
-			case "LOT_SIZE":
-				if stepSizeStr, ok := filter["stepSize"].(string); ok {
-					prec.StepSize, _ = strconv.ParseFloat(stepSizeStr, 64)
-				}
-			}
+	tiers := make(map[string][]MarginTier)
+	var brackets []struct {
+		Symbol   string `json:"symbol"`
+		Brackets []struct {
+			InitialLeverage  int     `json:"initialLeverage"`
+			NotionalFloor    float64 `json:"notionalFloor"`
+			MaintMarginRatio float64 `json:"maintMarginRatio"`
+		} `json:"brackets"`
+	}
+	if body, err := t.request("GET", "/fapi/v3/leverageBracket", map[string]interface{}{}); err != nil {
+		log.Printf("  ⚠ 获取Aster杠杆档位失败（不检查杠杆上限）: %v", err)
+	} else if err := json.Unmarshal(body, &brackets); err != nil {
+		log.Printf("  ⚠ 解析Aster杠杆档位失败（不检查杠杆上限）: %v", err)
+	}
+	for _, bracket := range brackets {
+		for _, b := range bracket.Brackets {
+			tiers[bracket.Symbol] = append(tiers[bracket.Symbol], MarginTier{
+				NotionalFloor:         b.NotionalFloor,
+				MaxLeverage:           b.InitialLeverage,
+				MaintenanceMarginRate: b.MaintMarginRatio,
+			})
 		}
-
-		t.symbolPrecision[s.Symbol] = prec
-	}
-	t.mu.Unlock()
-
-	if prec, ok := t.symbolPrecision[symbol]; ok {
-		return prec, nil
-	}
-
-	return SymbolPrecision{}, fmt.Errorf("未找到交易对 %s 的精度信息", symbol)
-}
-
-// roundToTickSize 将价格/数量四舍五入到tick size/step size的整数倍
-func roundToTickSize(value float64, tickSize float64) float64 {
-	if tickSize <= 0 {
-		return value
 	}
-	// 计算有多少个tick size
-	steps := value / tickSize
-	// 四舍五入到最近的整数
-	roundedSteps := math.Round(steps)
-	// 乘回tick size
-	return roundedSteps * tickSize
-}
 
-// formatPrice 格式化价格到正确精度和tick size
-func (t *AsterTrader) formatPrice(symbol string, price float64) (float64, error) {
-	prec, err := t.getPrecision(symbol)
-	if err != nil {
-		return 0, err
-	}
-
-	// 优先使用tick size，确保价格是tick size的整数倍
-	if prec.TickSize > 0 {
-		return roundToTickSize(price, prec.TickSize), nil
-	}
-
-	// 如果没有tick size，则按精度四舍五入
-	multiplier := math.Pow10(prec.PricePrecision)
-	return math.Round(price*multiplier) / multiplier, nil
-}
-
-// formatQuantity 格式化数量到正确精度和step size
-func (t *AsterTrader) formatQuantity(symbol string, quantity float64) (float64, error) {
-	prec, err := t.getPrecision(symbol)
-	if err != nil {
-		return 0, err
-	}
-
-	// 优先使用step size，确保数量是step size的整数倍
-	if prec.StepSize > 0 {
-		return roundToTickSize(quantity, prec.StepSize), nil
+	infos := make([]InstrumentInfo, 0, len(info.Symbols))
+	for _, s := range info.Symbols {
+		instrument := parseFilterInstrument(s.Symbol, s.Filters)
+		instrument.MarginTiers = tiers[s.Symbol]
+		infos = append(infos, instrument)
 	}
-
-	// 如果没有step size，则按精度四舍五入
-	multiplier := math.Pow10(prec.QuantityPrecision)
-	return math.Round(quantity*multiplier) / multiplier, nil
-}
-
-// formatFloatWithPrecision 将浮点数格式化为指定精度的字符串（去除末尾的0）
-func (t *AsterTrader) formatFloatWithPrecision(value float64, precision int) string {
-	// 使用指定精度格式化
-	formatted := strconv.FormatFloat(value, 'f', precision, 64)
-
-	// 去除末尾的0和小数点（如果有）
-	formatted = strings.TrimRight(formatted, "0")
-	formatted = strings.TrimRight(formatted, ".")
-
-	return formatted
+	return infos, nil
 }
 
 // normalizeAndStringify 对参数进行规范化并序列化为JSON字符串（按key排序）
@@ -346,6 +289,11 @@ func (t *AsterTrader) request(method, endpoint string, params map[string]interfa
 
 		lastErr = err
 
+		// 带客户端订单ID的下单请求不在这里重试：由submitOrder先按ID确认交易所是否已接受
+		if _, ok := params["newClientOrderId"]; ok {
+			return nil, err
+		}
+
 		// 如果是网络超时或临时错误，重试
 		if strings.Contains(err.Error(), "timeout") ||
 			strings.Contains(err.Error(), "connection reset") ||
@@ -522,51 +470,42 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 
 // OpenLong 开多单
 func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	// 开仓前先取消所有挂单,防止残留挂单导致仓位叠加
-	if err := t.CancelAllOrders(symbol); err != nil {
-		log.Printf("  ⚠ 取消挂单失败(继续开仓): %v", err)
-	}
-
-	// 先设置杠杆
-	if err := t.SetLeverage(symbol, leverage); err != nil {
-		return nil, fmt.Errorf("设置杠杆失败: %w", err)
-	}
-
 	// 获取当前价格
 	price, err := t.GetMarketPrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	// 使用限价单模拟市价单（价格设置得稍高一些以确保成交）
-	limitPrice := price * 1.01
-
-	// 格式化价格和数量到正确精度
-	formattedPrice, err := t.formatPrice(symbol, limitPrice)
+	// 按交易对规则取整数量，检查最小名义价值和杠杆档位
+	// 不撤销已有挂单：加仓时另一方向持仓的止损止盈、跟踪止损和限价开仓单都要保留
+	info, quantity, _, err := t.instruments.prepareOrder(symbol, quantity, price, leverage, false)
 	if err != nil {
 		return nil, err
 	}
-	formattedQty, err := t.formatQuantity(symbol, quantity)
-	if err != nil {
-		return nil, err
+
+	// 先设置杠杆
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, fmt.Errorf("设置杠杆失败: %w", err)
 	}
 
-	// 获取精度信息
-	prec, err := t.getPrecision(symbol)
-	if err != nil {
+	// 设置保证金模式（配置了逐仓/全仓时）
+	if err := t.setMarginType(symbol); err != nil {
 		return nil, err
 	}
 
-	// 转换为字符串，使用正确的精度格式
-	priceStr := t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision)
-	qtyStr := t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision)
+	// 使用限价单模拟市价单（价格设置得稍高一些以确保成交）
+	limitPrice := price * 1.01
+
+	// 按交易对规则格式化价格和数量
+	priceStr := info.FormatPrice(limitPrice)
+	qtyStr := info.FormatQuantity(quantity)
 
-	log.Printf("  📏 精度处理: 价格 %.8f -> %s (精度=%d), 数量 %.8f -> %s (精度=%d)",
-		limitPrice, priceStr, prec.PricePrecision, quantity, qtyStr, prec.QuantityPrecision)
+	log.Printf("  📏 精度处理: 价格 %.8f -> %s (tickSize=%v), 数量 %s (stepSize=%v)",
+		limitPrice, priceStr, info.TickSize, qtyStr, info.StepSize)
 
 	params := map[string]interface{}{
 		"symbol":       symbol,
-		"positionSide": "BOTH",
+		"positionSide": t.modes.orderPositionSide("LONG"),
 		"type":         "LIMIT",
 		"side":         "BUY",
 		"timeInForce":  "GTC",
@@ -574,24 +513,34 @@ func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 		"price":        priceStr,
 	}
 
-	body, err := t.request("POST", "/fapi/v3/order", params)
+	body, err := t.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
+	t.rememberSymbol(symbol)
 
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
+	t.addExecution(result, symbol, body)
 
 	return result, nil
 }
 
 // OpenShort 开空单
 func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	// 开仓前先取消所有挂单,防止残留挂单导致仓位叠加
-	if err := t.CancelAllOrders(symbol); err != nil {
-		log.Printf("  ⚠ 取消挂单失败(继续开仓): %v", err)
+	// 获取当前价格
+	price, err := t.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按交易对规则取整数量，检查最小名义价值和杠杆档位
+	// 不撤销已有挂单：加仓时另一方向持仓的止损止盈、跟踪止损和限价开仓单都要保留
+	info, quantity, _, err := t.instruments.prepareOrder(symbol, quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
 	}
 
 	// 先设置杠杆
@@ -599,58 +548,119 @@ func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (
 		return nil, fmt.Errorf("设置杠杆失败: %w", err)
 	}
 
-	// 获取当前价格
-	price, err := t.GetMarketPrice(symbol)
-	if err != nil {
+	// 设置保证金模式（配置了逐仓/全仓时）
+	if err := t.setMarginType(symbol); err != nil {
 		return nil, err
 	}
 
 	// 使用限价单模拟市价单（价格设置得稍低一些以确保成交）
 	limitPrice := price * 0.99
 
-	// 格式化价格和数量到正确精度
-	formattedPrice, err := t.formatPrice(symbol, limitPrice)
+	// 按交易对规则格式化价格和数量
+	priceStr := info.FormatPrice(limitPrice)
+	qtyStr := info.FormatQuantity(quantity)
+
+	log.Printf("  📏 精度处理: 价格 %.8f -> %s (tickSize=%v), 数量 %s (stepSize=%v)",
+		limitPrice, priceStr, info.TickSize, qtyStr, info.StepSize)
+
+	params := map[string]interface{}{
+		"symbol":       symbol,
+		"positionSide": t.modes.orderPositionSide("SHORT"),
+		"type":         "LIMIT",
+		"side":         "SELL",
+		"timeInForce":  "GTC",
+		"quantity":     qtyStr,
+		"price":        priceStr,
+	}
+
+	body, err := t.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
-	formattedQty, err := t.formatQuantity(symbol, quantity)
-	if err != nil {
+	t.rememberSymbol(symbol)
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
+	t.addExecution(result, symbol, body)
 
-	// 获取精度信息
-	prec, err := t.getPrecision(symbol)
+	return result, nil
+}
+
+// OpenLimit 限价开仓（GTC/IOC/POST_ONLY），不会取消已有的止损止盈单
+func (t *AsterTrader) OpenLimit(symbol, positionSide string, quantity, price float64, leverage int, timeInForce string) (map[string]interface{}, error) {
+	if err := validatePositionSide(positionSide); err != nil {
+		return nil, err
+	}
+
+	tif := ""
+	switch timeInForce {
+	case TimeInForceGTC, TimeInForceIOC:
+		tif = timeInForce
+	case TimeInForcePostOnly:
+		tif = "GTX"
+	default:
+		return nil, fmt.Errorf("不支持的timeInForce: %s", timeInForce)
+	}
+
+	// 按交易对规则取整数量和价格，检查最小名义价值和杠杆档位
+	info, quantity, price, err := t.instruments.prepareOrder(symbol, quantity, price, leverage, false)
 	if err != nil {
 		return nil, err
 	}
 
-	// 转换为字符串，使用正确的精度格式
-	priceStr := t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision)
-	qtyStr := t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision)
+	// 先设置杠杆
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, fmt.Errorf("设置杠杆失败: %w", err)
+	}
+
+	// 设置保证金模式（配置了逐仓/全仓时）
+	if err := t.setMarginType(symbol); err != nil {
+		return nil, err
+	}
+
+	priceStr := info.FormatPrice(price)
+	qtyStr := info.FormatQuantity(quantity)
 
-	log.Printf("  📏 精度处理: 价格 %.8f -> %s (精度=%d), 数量 %.8f -> %s (精度=%d)",
-		limitPrice, priceStr, prec.PricePrecision, quantity, qtyStr, prec.QuantityPrecision)
+	side := "BUY"
+	if positionSide == "SHORT" {
+		side = "SELL"
+	}
 
 	params := map[string]interface{}{
 		"symbol":       symbol,
-		"positionSide": "BOTH",
+		"positionSide": t.modes.orderPositionSide(positionSide),
 		"type":         "LIMIT",
-		"side":         "SELL",
-		"timeInForce":  "GTC",
+		"side":         side,
+		"timeInForce":  tif,
 		"quantity":     qtyStr,
 		"price":        priceStr,
 	}
 
-	body, err := t.request("POST", "/fapi/v3/order", params)
+	body, err := t.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
+	t.rememberSymbol(symbol)
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	var order struct {
+		OrderID int64  `json:"orderId"`
+		Symbol  string `json:"symbol"`
+		Status  string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &order); err != nil {
 		return nil, err
 	}
 
+	log.Printf("✓ 限价开仓委托成功: %s %s 数量: %s 价格: %s (%s) 状态: %s", symbol, positionSide, qtyStr, priceStr, timeInForce, order.Status)
+
+	result := map[string]interface{}{
+		"orderId": order.OrderID,
+		"symbol":  order.Symbol,
+		"status":  order.Status,
+	}
+	t.addExecution(result, symbol, body)
 	return result, nil
 }
 
@@ -671,60 +681,55 @@ func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]int
 		}
 
 		if quantity == 0 {
-			return nil, fmt.Errorf("没有找到 %s 的多仓", symbol)
+			return nil, noPositionError(symbol, "long")
 		}
 		log.Printf("  📊 获取到多仓数量: %.8f", quantity)
 	}
 
-	price, err := t.GetMarketPrice(symbol)
+	// 按交易对规则取整数量（平仓不检查最小名义价值）
+	info, quantity, _, err := t.instruments.prepareOrder(symbol, quantity, 0, 0, true)
 	if err != nil {
 		return nil, err
 	}
 
-	limitPrice := price * 0.99
-
-	// 格式化价格和数量到正确精度
-	formattedPrice, err := t.formatPrice(symbol, limitPrice)
-	if err != nil {
-		return nil, err
-	}
-	formattedQty, err := t.formatQuantity(symbol, quantity)
+	price, err := t.GetMarketPrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取精度信息
-	prec, err := t.getPrecision(symbol)
-	if err != nil {
-		return nil, err
-	}
+	limitPrice := price * 0.99
 
-	// 转换为字符串，使用正确的精度格式
-	priceStr := t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision)
-	qtyStr := t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision)
+	// 按交易对规则格式化价格和数量
+	priceStr := info.FormatPrice(limitPrice)
+	qtyStr := info.FormatQuantity(quantity)
 
-	log.Printf("  📏 精度处理: 价格 %.8f -> %s (精度=%d), 数量 %.8f -> %s (精度=%d)",
-		limitPrice, priceStr, prec.PricePrecision, quantity, qtyStr, prec.QuantityPrecision)
+	log.Printf("  📏 精度处理: 价格 %.8f -> %s (tickSize=%v), 数量 %s (stepSize=%v)",
+		limitPrice, priceStr, info.TickSize, qtyStr, info.StepSize)
 
 	params := map[string]interface{}{
 		"symbol":       symbol,
-		"positionSide": "BOTH",
+		"positionSide": t.modes.orderPositionSide("LONG"),
 		"type":         "LIMIT",
 		"side":         "SELL",
 		"timeInForce":  "GTC",
 		"quantity":     qtyStr,
 		"price":        priceStr,
 	}
+	if !t.modes.hedge() {
+		params["reduceOnly"] = "true" // 单向持仓模式下只减仓，避免反向开仓
+	}
 
-	body, err := t.request("POST", "/fapi/v3/order", params)
+	body, err := t.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
+	t.rememberSymbol(symbol)
 
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
+	t.addExecution(result, symbol, body)
 
 	log.Printf("✓ 平多仓成功: %s 数量: %s", symbol, qtyStr)
 
@@ -754,60 +759,55 @@ func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]in
 		}
 
 		if quantity == 0 {
-			return nil, fmt.Errorf("没有找到 %s 的空仓", symbol)
+			return nil, noPositionError(symbol, "short")
 		}
 		log.Printf("  📊 获取到空仓数量: %.8f", quantity)
 	}
 
-	price, err := t.GetMarketPrice(symbol)
+	// 按交易对规则取整数量（平仓不检查最小名义价值）
+	info, quantity, _, err := t.instruments.prepareOrder(symbol, quantity, 0, 0, true)
 	if err != nil {
 		return nil, err
 	}
 
-	limitPrice := price * 1.01
-
-	// 格式化价格和数量到正确精度
-	formattedPrice, err := t.formatPrice(symbol, limitPrice)
-	if err != nil {
-		return nil, err
-	}
-	formattedQty, err := t.formatQuantity(symbol, quantity)
+	price, err := t.GetMarketPrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取精度信息
-	prec, err := t.getPrecision(symbol)
-	if err != nil {
-		return nil, err
-	}
+	limitPrice := price * 1.01
 
-	// 转换为字符串，使用正确的精度格式
-	priceStr := t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision)
-	qtyStr := t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision)
+	// 按交易对规则格式化价格和数量
+	priceStr := info.FormatPrice(limitPrice)
+	qtyStr := info.FormatQuantity(quantity)
 
-	log.Printf("  📏 精度处理: 价格 %.8f -> %s (精度=%d), 数量 %.8f -> %s (精度=%d)",
-		limitPrice, priceStr, prec.PricePrecision, quantity, qtyStr, prec.QuantityPrecision)
+	log.Printf("  📏 精度处理: 价格 %.8f -> %s (tickSize=%v), 数量 %s (stepSize=%v)",
+		limitPrice, priceStr, info.TickSize, qtyStr, info.StepSize)
 
 	params := map[string]interface{}{
 		"symbol":       symbol,
-		"positionSide": "BOTH",
+		"positionSide": t.modes.orderPositionSide("SHORT"),
 		"type":         "LIMIT",
 		"side":         "BUY",
 		"timeInForce":  "GTC",
 		"quantity":     qtyStr,
 		"price":        priceStr,
 	}
+	if !t.modes.hedge() {
+		params["reduceOnly"] = "true" // 单向持仓模式下只减仓，避免反向开仓
+	}
 
-	body, err := t.request("POST", "/fapi/v3/order", params)
+	body, err := t.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
+	t.rememberSymbol(symbol)
 
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
+	t.addExecution(result, symbol, body)
 
 	log.Printf("✓ 平空仓成功: %s 数量: %s", symbol, qtyStr)
 
@@ -830,6 +830,74 @@ func (t *AsterTrader) SetLeverage(symbol string, leverage int) error {
 	return err
 }
 
+// ConfigureAccountModes 检测账户的持仓模式（positionSide/dual），与配置不同时切换
+// 保证金模式按币种设置：配置了逐仓/全仓时开仓前切换，为空时沿用账户当前设置
+func (t *AsterTrader) ConfigureAccountModes(marginMode, positionMode string) error {
+	if err := ValidateAccountModes(marginMode, positionMode); err != nil {
+		return err
+	}
+
+	body, err := t.request("GET", "/fapi/v3/positionSide/dual", map[string]interface{}{})
+	if err != nil {
+		return fmt.Errorf("获取持仓模式失败: %w", err)
+	}
+	var mode struct {
+		DualSidePosition bool `json:"dualSidePosition"`
+	}
+	if err := json.Unmarshal(body, &mode); err != nil {
+		return fmt.Errorf("解析持仓模式失败: %w", err)
+	}
+	current := PositionModeOneWay
+	if mode.DualSidePosition {
+		current = PositionModeHedge
+	}
+
+	if positionMode != "" && positionMode != current {
+		params := map[string]interface{}{
+			"dualSidePosition": positionMode == PositionModeHedge,
+		}
+		if _, err := t.request("POST", "/fapi/v3/positionSide/dual", params); err != nil && !strings.Contains(err.Error(), "No need to change") {
+			return fmt.Errorf("切换持仓模式为 %s 失败（有持仓或挂单时不能切换）: %w", positionMode, err)
+		}
+		log.Printf("  ✓ Aster持仓模式已从 %s 切换为 %s", current, positionMode)
+		current = positionMode
+	}
+
+	t.modes.set(marginMode, current)
+	log.Printf("  ✓ Aster账户模式: 保证金 %s, 持仓 %s", marginMode, current)
+	return nil
+}
+
+// AccountModes 返回当前使用的保证金模式和持仓模式（保证金模式为空表示沿用账户设置）
+func (t *AsterTrader) AccountModes() (marginMode, positionMode string) {
+	return t.modes.get()
+}
+
+// setMarginType 把币种切换为配置的保证金模式（未配置时不切换）
+func (t *AsterTrader) setMarginType(symbol string) error {
+	marginMode, _ := t.modes.get()
+	if marginMode == "" {
+		return nil
+	}
+	marginType := "ISOLATED"
+	if marginMode == MarginModeCross {
+		marginType = "CROSSED"
+	}
+
+	params := map[string]interface{}{
+		"symbol":     symbol,
+		"marginType": marginType,
+	}
+	if _, err := t.request("POST", "/fapi/v3/marginType", params); err != nil {
+		if strings.Contains(err.Error(), "No need to change") {
+			return nil
+		}
+		return fmt.Errorf("设置保证金模式失败: %w", err)
+	}
+	log.Printf("  ✓ %s 保证金模式已切换为 %s", symbol, marginType)
+	return nil
+}
+
 // GetMarketPrice 获取市场价格
 func (t *AsterTrader) GetMarketPrice(symbol string) (float64, error) {
 	// 使用ticker接口获取当前价格
@@ -859,34 +927,26 @@ func (t *AsterTrader) GetMarketPrice(symbol string) (float64, error) {
 
 // SetStopLoss 设置止损
 func (t *AsterTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+
 	side := "SELL"
 	if positionSide == "SHORT" {
 		side = "BUY"
 	}
 
-	// 格式化价格和数量到正确精度
-	formattedPrice, err := t.formatPrice(symbol, stopPrice)
-	if err != nil {
-		return err
-	}
-	formattedQty, err := t.formatQuantity(symbol, quantity)
-	if err != nil {
-		return err
-	}
-
-	// 获取精度信息
-	prec, err := t.getPrecision(symbol)
+	// 按交易对规则格式化触发价和数量
+	info, err := t.instruments.get(symbol)
 	if err != nil {
 		return err
 	}
-
-	// 转换为字符串，使用正确的精度格式
-	priceStr := t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision)
-	qtyStr := t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision)
+	priceStr := info.FormatPrice(stopPrice)
+	qtyStr := info.FormatQuantity(quantity)
 
 	params := map[string]interface{}{
 		"symbol":       symbol,
-		"positionSide": "BOTH",
+		"positionSide": t.modes.orderPositionSide(positionSide),
 		"type":         "STOP_MARKET",
 		"side":         side,
 		"stopPrice":    priceStr,
@@ -894,51 +954,73 @@ func (t *AsterTrader) SetStopLoss(symbol string, positionSide string, quantity,
 		"timeInForce":  "GTC",
 	}
 
-	_, err = t.request("POST", "/fapi/v3/order", params)
+	_, err = t.placeOrder(symbol, params)
 	return err
 }
 
 // SetTakeProfit 设置止盈
 func (t *AsterTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
-	side := "SELL"
-	if positionSide == "SHORT" {
-		side = "BUY"
-	}
+	return t.placeTakeProfit(symbol, positionSide, quantity, takeProfitPrice, false)
+}
 
-	// 格式化价格和数量到正确精度
-	formattedPrice, err := t.formatPrice(symbol, takeProfitPrice)
-	if err != nil {
+// SetTakeProfitLadder 按阶梯挂多张只减仓止盈单
+func (t *AsterTrader) SetTakeProfitLadder(symbol string, positionSide string, quantity float64, levels []TakeProfitLevel) error {
+	return placeTakeProfitLadder(t, symbol, positionSide, quantity, levels, func(quantity, price float64) error {
+		return t.placeTakeProfit(symbol, positionSide, quantity, price, true)
+	})
+}
+
+// placeTakeProfit 挂止盈单（reduceOnly=true时只减仓；双向持仓模式下由positionSide决定只减仓，不发送reduceOnly）
+func (t *AsterTrader) placeTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64, reduceOnly bool) error {
+	if err := validatePositionSide(positionSide); err != nil {
 		return err
 	}
-	formattedQty, err := t.formatQuantity(symbol, quantity)
-	if err != nil {
-		return err
+
+	side := "SELL"
+	if positionSide == "SHORT" {
+		side = "BUY"
 	}
 
-	// 获取精度信息
-	prec, err := t.getPrecision(symbol)
+	// 按交易对规则格式化触发价和数量
+	info, err := t.instruments.get(symbol)
 	if err != nil {
 		return err
 	}
-
-	// 转换为字符串，使用正确的精度格式
-	priceStr := t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision)
-	qtyStr := t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision)
+	priceStr := info.FormatPrice(takeProfitPrice)
+	qtyStr := info.FormatQuantity(quantity)
 
 	params := map[string]interface{}{
 		"symbol":       symbol,
-		"positionSide": "BOTH",
+		"positionSide": t.modes.orderPositionSide(positionSide),
 		"type":         "TAKE_PROFIT_MARKET",
 		"side":         side,
 		"stopPrice":    priceStr,
 		"quantity":     qtyStr,
 		"timeInForce":  "GTC",
 	}
+	if reduceOnly && !t.modes.hedge() {
+		params["reduceOnly"] = "true"
+	}
 
-	_, err = t.request("POST", "/fapi/v3/order", params)
+	_, err = t.placeOrder(symbol, params)
 	return err
 }
 
+// UpdateStopLoss 替换止损单（先挂新单再撤旧单）
+func (t *AsterTrader) UpdateStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "STOP_MARKET", quantity, stopPrice, true)
+}
+
+// UpdateTakeProfit 替换止盈单（先挂新单再撤旧单）
+func (t *AsterTrader) UpdateTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "TAKE_PROFIT_MARKET", quantity, takeProfitPrice, true)
+}
+
+// SetTrailingStop 设置跟踪止损（客户端跟踪引擎随价格移动止损单，数量按当前持仓）
+func (t *AsterTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackRate, activationPrice float64) error {
+	return t.trailing.set(symbol, positionSide, callbackRate, activationPrice)
+}
+
 // CancelAllOrders 取消所有订单
 func (t *AsterTrader) CancelAllOrders(symbol string) error {
 	params := map[string]interface{}{
@@ -949,11 +1031,339 @@ func (t *AsterTrader) CancelAllOrders(symbol string) error {
 	return err
 }
 
-// FormatQuantity 格式化数量（实现Trader接口）
-func (t *AsterTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
-	formatted, err := t.formatQuantity(symbol, quantity)
+// CancelOrder 取消单个订单
+func (t *AsterTrader) CancelOrder(symbol string, orderID int64) error {
+	params := map[string]interface{}{
+		"symbol":  symbol,
+		"orderId": orderID,
+	}
+
+	_, err := t.request("DELETE", "/fapi/v3/order", params)
+	return err
+}
+
+// rememberSymbol 记录下过单的币种（GetFills会查询这些币种的成交）
+func (t *AsterTrader) rememberSymbol(symbol string) {
+	t.tradedSymbolsMutex.Lock()
+	defer t.tradedSymbolsMutex.Unlock()
+	if t.tradedSymbols == nil {
+		t.tradedSymbols = make(map[string]bool)
+	}
+	t.tradedSymbols[symbol] = true
+}
+
+// asterOrder Aster订单接口返回的字段（与币安一致）
+type asterOrder struct {
+	OrderID       int64  `json:"orderId"`
+	Symbol        string `json:"symbol"`
+	Status        string `json:"status"`
+	Type          string `json:"type"`
+	OrigType      string `json:"origType"`
+	Side          string `json:"side"`
+	Price         string `json:"price"`
+	StopPrice     string `json:"stopPrice"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	ReduceOnly    bool   `json:"reduceOnly"`
+	ClosePosition bool   `json:"closePosition"`
+}
+
+// toMap 转为统一的订单格式
+func (o asterOrder) toMap() map[string]interface{} {
+	price, _ := strconv.ParseFloat(o.Price, 64)
+	stopPrice, _ := strconv.ParseFloat(o.StopPrice, 64)
+	quantity, _ := strconv.ParseFloat(o.OrigQty, 64)
+	executed, _ := strconv.ParseFloat(o.ExecutedQty, 64)
+
+	orderType := o.Type
+	if o.OrigType != "" {
+		orderType = o.OrigType
+	}
+
+	return map[string]interface{}{
+		"orderId":     o.OrderID,
+		"symbol":      o.Symbol,
+		"side":        o.Side,
+		"type":        orderType,
+		"status":      o.Status,
+		"price":       price,
+		"stopPrice":   stopPrice,
+		"quantity":    quantity,
+		"executedQty": executed,
+		"reduceOnly":  o.ReduceOnly || o.ClosePosition,
+	}
+}
+
+// GetOpenOrders 获取该币种的挂单
+func (t *AsterTrader) GetOpenOrders(symbol string) ([]map[string]interface{}, error) {
+	params := map[string]interface{}{
+		"symbol": symbol,
+	}
+
+	body, err := t.request("GET", "/fapi/v3/openOrders", params)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	var orders []asterOrder
+	if err := json.Unmarshal(body, &orders); err != nil {
+		return nil, fmt.Errorf("解析挂单失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(orders))
+	for _, order := range orders {
+		result = append(result, order.toMap())
+	}
+	return result, nil
+}
+
+// GetOrder 查询单个订单
+func (t *AsterTrader) GetOrder(symbol string, orderID int64) (map[string]interface{}, error) {
+	params := map[string]interface{}{
+		"symbol":  symbol,
+		"orderId": orderID,
+	}
+
+	body, err := t.request("GET", "/fapi/v3/order", params)
+	if err != nil {
+		if strings.Contains(err.Error(), "-2013") {
+			return nil, fmt.Errorf("%s 订单 %d: %w", symbol, orderID, ErrOrderNotFound)
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	var order asterOrder
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, fmt.Errorf("解析订单失败: %w", err)
+	}
+	return order.toMap(), nil
+}
+
+// GetOrderByClientID 按客户端订单ID查询订单
+func (t *AsterTrader) GetOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, error) {
+	body, err := t.queryOrderByClientID(symbol, clientOrderID)
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("%v", formatted), nil
+
+	var order asterOrder
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, fmt.Errorf("解析订单失败: %w", err)
+	}
+	return order.toMap(), nil
+}
+
+// queryOrderByClientID 按客户端订单ID查询订单，返回订单接口的原始响应
+func (t *AsterTrader) queryOrderByClientID(symbol, clientOrderID string) ([]byte, error) {
+	params := map[string]interface{}{
+		"symbol":            symbol,
+		"origClientOrderId": clientOrderID,
+	}
+
+	body, err := t.request("GET", "/fapi/v3/order", params)
+	if err != nil {
+		if strings.Contains(err.Error(), "-2013") {
+			return nil, fmt.Errorf("%s 订单 %s: %w", symbol, clientOrderID, ErrOrderNotFound)
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	return body, nil
+}
+
+// SetClientOrderIDPrefix 设置之后下单使用的客户端订单ID前缀（newClientOrderId）
+func (t *AsterTrader) SetClientOrderIDPrefix(prefix string) {
+	t.clientIDs.setPrefix(prefix)
+}
+
+// placeOrder 带客户端订单ID下单，下单结果未知时先按ID查询订单（见submitOrder），返回订单接口的原始响应
+func (t *AsterTrader) placeOrder(symbol string, params map[string]interface{}) ([]byte, error) {
+	clientOrderID := t.clientIDs.next()
+	if clientOrderID != "" {
+		params["newClientOrderId"] = clientOrderID
+	}
+
+	var body []byte
+	err := submitOrder(clientOrderID, func() error {
+		var err error
+		body, err = t.request("POST", "/fapi/v3/order", params)
+		return err
+	}, func() error {
+		var err error
+		body, err = t.queryOrderByClientID(symbol, clientOrderID)
+		return err
+	})
+	return body, err
+}
+
+// GetFills 获取since之后的成交记录
+// 成交接口必须指定币种，这里查询当前持仓和本交易器下过单的币种
+func (t *AsterTrader) GetFills(since time.Time) ([]map[string]interface{}, error) {
+	symbols := make(map[string]bool)
+	t.tradedSymbolsMutex.Lock()
+	for symbol := range t.tradedSymbols {
+		symbols[symbol] = true
+	}
+	t.tradedSymbolsMutex.Unlock()
+
+	positions, err := t.GetPositions()
+	if err != nil {
+		return nil, err
+	}
+	for _, pos := range positions {
+		if symbol, ok := pos["symbol"].(string); ok {
+			symbols[symbol] = true
+		}
+	}
+
+	var result []map[string]interface{}
+	for symbol := range symbols {
+		fills, err := t.userTrades(map[string]interface{}{
+			"symbol":    symbol,
+			"startTime": since.UnixMilli(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s 成交记录失败: %w", symbol, err)
+		}
+		result = append(result, fills...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["time"].(int64) < result[j]["time"].(int64)
+	})
+	return result, nil
+}
+
+// userTrades 查询成交记录（params为/fapi/v3/userTrades的参数），转为统一的成交格式
+func (t *AsterTrader) userTrades(params map[string]interface{}) ([]map[string]interface{}, error) {
+	body, err := t.request("GET", "/fapi/v3/userTrades", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var trades []struct {
+		OrderID         int64  `json:"orderId"`
+		Symbol          string `json:"symbol"`
+		Side            string `json:"side"`
+		Price           string `json:"price"`
+		Qty             string `json:"qty"`
+		RealizedPnl     string `json:"realizedPnl"`
+		Commission      string `json:"commission"`
+		CommissionAsset string `json:"commissionAsset"`
+		Time            int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &trades); err != nil {
+		return nil, fmt.Errorf("解析成交记录失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(trades))
+	for _, trade := range trades {
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		quantity, _ := strconv.ParseFloat(trade.Qty, 64)
+		fee, _ := strconv.ParseFloat(trade.Commission, 64)
+		realizedPnl, _ := strconv.ParseFloat(trade.RealizedPnl, 64)
+		result = append(result, map[string]interface{}{
+			"symbol":      trade.Symbol,
+			"orderId":     trade.OrderID,
+			"side":        trade.Side,
+			"time":        trade.Time,
+			"price":       price,
+			"quantity":    quantity,
+			"fee":         fee,
+			"feeAsset":    trade.CommissionAsset,
+			"realizedPnl": realizedPnl,
+		})
+	}
+	return result, nil
+}
+
+// GetFundingPayments 获取since之后的资金费收支（资金流水中的FUNDING_FEE，不区分持仓方向）
+func (t *AsterTrader) GetFundingPayments(since time.Time) ([]map[string]interface{}, error) {
+	body, err := t.request("GET", "/fapi/v3/income", map[string]interface{}{
+		"incomeType": "FUNDING_FEE",
+		"startTime":  since.UnixMilli(),
+		"limit":      1000,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取资金费记录失败: %w", err)
+	}
+
+	var incomes []struct {
+		Symbol string `json:"symbol"`
+		Income string `json:"income"`
+		Asset  string `json:"asset"`
+		Time   int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &incomes); err != nil {
+		return nil, fmt.Errorf("解析资金费记录失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(incomes))
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		result = append(result, map[string]interface{}{
+			"symbol": income.Symbol,
+			"side":   "",
+			"time":   income.Time,
+			"amount": amount,
+			"asset":  income.Asset,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["time"].(int64) < result[j]["time"].(int64)
+	})
+	return result, nil
+}
+
+// GetFundingRate 获取当前资金费率和下次结算时间（premiumIndex）
+func (t *AsterTrader) GetFundingRate(symbol string) (float64, time.Time, error) {
+	resp, err := t.client.Get(fmt.Sprintf("%s/fapi/v3/premiumIndex?symbol=%s", t.baseURL, symbol))
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("获取资金费率失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return 0, time.Time{}, fmt.Errorf("获取资金费率失败: HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var index struct {
+		LastFundingRate string `json:"lastFundingRate"`
+		NextFundingTime int64  `json:"nextFundingTime"`
+	}
+	if err := json.Unmarshal(body, &index); err != nil {
+		return 0, time.Time{}, fmt.Errorf("解析资金费率失败: %w", err)
+	}
+	rate, _ := strconv.ParseFloat(index.LastFundingRate, 64)
+	return rate, time.UnixMilli(index.NextFundingTime), nil
+}
+
+// addExecution 订单已成交时按订单ID查询成交记录，在下单结果中补充成交均价和手续费（body为下单响应）
+func (t *AsterTrader) addExecution(result map[string]interface{}, symbol string, body []byte) {
+	var order struct {
+		OrderID int64  `json:"orderId"`
+		Status  string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &order); err != nil || (order.Status != "FILLED" && order.Status != "PARTIALLY_FILLED") {
+		return
+	}
+	fills, err := t.userTrades(map[string]interface{}{
+		"symbol":  symbol,
+		"orderId": order.OrderID,
+	})
+	if err != nil {
+		log.Printf("  ⚠ 查询订单 %d 的成交记录失败: %v", order.OrderID, err)
+		return
+	}
+	addFillsExecution(result, fills, order.OrderID)
+}
+
+// FormatQuantity 按LOT_SIZE的stepSize向下取整并格式化数量
+func (t *AsterTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return t.instruments.formatQuantity(symbol, quantity)
+}
+
+// GetInstrument 获取交易对规则（exchangeInfo的过滤器和杠杆档位，缓存一小时）
+func (t *AsterTrader) GetInstrument(symbol string) (InstrumentInfo, error) {
+	return t.instruments.get(symbol)
 }
@@ -1,15 +1,24 @@
 package trader
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"danto/decision"
 	"danto/logger"
 	"danto/market"
 	"danto/mcp"
 	"danto/pool"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -55,6 +64,27 @@ type AutoTraderConfig struct {
 	CustomAPIKey    string
 	CustomModelName string
 
+	// 提示词配置
+	PromptLanguage    string // 提示词语言: "zh"（默认）或 "en"
+	PromptTemplateDir string // 提示词模板目录（为空则使用该语言的内置提示词包）
+
+	// token预算（0=自动）
+	PromptTokenBudget int // 输入prompt的token上限（0=按模型上下文窗口计算）
+	MaxOutputTokens   int // AI单次回复的最大token数（0=默认2000）
+
+	// 工具调用模式
+	ToolMode          bool // 是否让AI按需调用工具获取数据
+	MaxToolIterations int  // 每个周期最多几轮工具调用（0=默认5）
+
+	// 跨周期记忆配置
+	MemoryCycles      int // 记忆最近N个周期（0=不启用）
+	MemoryTokenBudget int // 记忆占用的token上限（默认1500）
+
+	// 集成决策配置（为空则只使用上面的单一模型）
+	EnsembleModels     []AIModelConfig // 额外参与投票的模型
+	EnsembleSizePolicy string          // 仓位合并策略: "average" 或 "min"
+	EnsembleMinVotes   int             // 执行动作所需最少同意票数（0=过半数）
+
 	// 扫描配置
 	ScanInterval time.Duration // 扫描间隔（建议3分钟）
 
@@ -65,10 +95,35 @@ type AutoTraderConfig struct {
 	BTCETHLeverage  int // BTC和ETH的杠杆倍数
 	AltcoinLeverage int // 山寨币的杠杆倍数
 
+	// 限价开仓
+	EntryOrderTTL time.Duration // 限价开仓单的挂单有效期，超时未成交自动撤销（0=默认15分钟）
+
+	// 账户模式（启动时传给Trader.ConfigureAccountModes，为空使用默认值）
+	MarginMode   string // 保证金模式: "isolated"（逐仓）或 "cross"（全仓）
+	PositionMode string // 持仓模式: "hedge"（双向）或 "one_way"（单向）
+
 	// 风险控制（仅作为提示，AI可自主决定）
 	MaxDailyLoss    float64       // 最大日亏损百分比（提示）
 	MaxDrawdown     float64       // 最大回撤百分比（提示）
 	StopTradingTime time.Duration // 触发风控后暂停时长
+
+	// 离线测试/回放
+	LLMRecordDir   string      // 录制AI请求/响应的目录（为空则不录制，可用mcp.NewReplayClient回放）
+	DecisionLogDir string      // 决策日志目录（为空则使用 decision_logs/<ID>）
+	Trader         Trader      // 注入的交易器（如FakeTrader，为空则按Exchange创建）
+	AIClient       *mcp.Client // 注入的AI客户端（如回放/脚本客户端，为空则按AIModel创建）
+}
+
+// AIModelConfig 单个AI模型的连接配置
+type AIModelConfig struct {
+	AIModel         string // "qwen", "deepseek", "minimax" 或 "custom"
+	UseQwen         bool
+	DeepSeekKey     string
+	QwenKey         string
+	MiniMaxKey      string
+	CustomAPIURL    string
+	CustomAPIKey    string
+	CustomModelName string
 }
 
 // AutoTrader 自动交易器
@@ -80,15 +135,30 @@ type AutoTrader struct {
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
 	mcpClient             *mcp.Client
-	decisionLogger        *logger.DecisionLogger // 决策日志记录器
+	ensembleClients       []*mcp.Client            // 集成模式下参与投票的全部模型（含主模型）
+	promptTemplate        *decision.PromptTemplate // 提示词模板
+	decisionLogger        *logger.DecisionLogger   // 决策日志记录器
 	initialBalance        float64
 	dailyPnL              float64
 	lastResetTime         time.Time
 	stopUntil             time.Time
-	isRunning             bool
-	startTime             time.Time        // 系统启动时间
-	callCount             int              // AI调用次数
-	positionFirstSeenTime map[string]int64 // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+	isRunning             atomic.Bool
+	runMu                 sync.Mutex
+	cancelRun             context.CancelFunc          // 取消Run的交易循环（Run运行期间有效）
+	startTime             time.Time                   // 系统启动时间
+	callCount             int                         // AI调用次数
+	positionFirstSeenTime map[string]int64            // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+	pendingEntries        map[string]*pendingEntry    // 挂单中的限价开仓单 (symbol_side -> 挂单)
+	protectiveTargets     map[string]protectiveTarget // 持仓的止损止盈价 (symbol_side -> 目标价)
+	lastFillCheck         time.Time                   // 上次同步成交记录的时间
+	lastFundingCheck      time.Time                   // 上次同步资金费记录的时间
+	cycleFunding          map[string]float64          // 本周期同步、尚未写入决策日志的资金费 (symbol_side -> 金额)
+	loggedOrders          map[int64]time.Time         // 已记入决策日志的订单（本交易员下的单和交易所触发的平仓单 orderId -> 记录时间）
+	streamCloses          []logger.DecisionAction     // 推送收到的交易所触发平仓（写入下个周期的决策记录）
+	loggedOrdersMu        sync.Mutex                  // 保护loggedOrders和streamCloses（推送成交和紧急平仓不在交易周期内）
+	targetsMu             sync.Mutex                  // 修改protectiveTargets（含止盈阶梯的订单ID）时持有，推送成交的处理在交易周期外读取
+	cycleMu               sync.Mutex                  // 交易周期执行期间持有（紧急平仓等待进行中的周期结束）
+	killed                atomic.Bool                 // 已触发紧急平仓，不再执行任何交易
 }
 
 // NewAutoTrader 创建自动交易器
@@ -108,25 +178,64 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		}
 	}
 
-	mcpClient := mcp.New()
-
 	// 初始化AI
-	if config.AIModel == "custom" {
-		// 使用自定义API
-		mcpClient.SetCustomAPI(config.CustomAPIURL, config.CustomAPIKey, config.CustomModelName)
-		log.Printf("🤖 [%s] Using custom AI API: %s (model: %s)", config.Name, config.CustomAPIURL, config.CustomModelName)
-	} else if config.AIModel == "minimax" {
-		// 使用MiniMax
-		mcpClient.SetMiniMaxAPIKey(config.MiniMaxKey)
-		log.Printf("🤖 [%s] Using MiniMax M2 AI (FREE)", config.Name)
-	} else if config.UseQwen || config.AIModel == "qwen" {
-		// 使用Qwen
-		mcpClient.SetQwenAPIKey(config.QwenKey, "")
-		log.Printf("🤖 [%s] Using Alibaba Cloud Qwen AI", config.Name)
+	mcpClient := config.AIClient
+	if mcpClient == nil {
+		mcpClient = newAIClient(config.Name, AIModelConfig{
+			AIModel:         config.AIModel,
+			UseQwen:         config.UseQwen,
+			DeepSeekKey:     config.DeepSeekKey,
+			QwenKey:         config.QwenKey,
+			MiniMaxKey:      config.MiniMaxKey,
+			CustomAPIURL:    config.CustomAPIURL,
+			CustomAPIKey:    config.CustomAPIKey,
+			CustomModelName: config.CustomModelName,
+		})
 	} else {
-		// 默认使用DeepSeek
-		mcpClient.SetDeepSeekAPIKey(config.DeepSeekKey)
-		log.Printf("🤖 [%s] Using DeepSeek AI", config.Name)
+		log.Printf("🤖 [%s] Using injected AI client: %s", config.Name, mcpClient.Name())
+	}
+
+	applyTokenLimits(mcpClient, config)
+
+	if config.LLMRecordDir != "" {
+		if err := mcpClient.EnableRecording(config.LLMRecordDir); err != nil {
+			return nil, fmt.Errorf("开启AI录制失败: %w", err)
+		}
+		log.Printf("📼 [%s] Recording AI requests to %s", config.Name, config.LLMRecordDir)
+	}
+
+	// 初始化集成模式的其他模型（主模型作为第一票）
+	var ensembleClients []*mcp.Client
+	if len(config.EnsembleModels) > 0 {
+		ensembleClients = append(ensembleClients, mcpClient)
+		for _, model := range config.EnsembleModels {
+			client := newAIClient(config.Name, model)
+			applyTokenLimits(client, config)
+			client.Recorder = mcpClient.Recorder // 所有模型共用同一个录制器（按顺序编号）
+			ensembleClients = append(ensembleClients, client)
+		}
+		log.Printf("🗳  [%s] 集成决策模式: %d 个模型投票", config.Name, len(ensembleClients))
+	}
+
+	// 加载提示词模板
+	var promptTemplate *decision.PromptTemplate
+	var promptErr error
+	if config.PromptTemplateDir != "" {
+		promptTemplate, promptErr = decision.LoadPromptTemplate(config.PromptTemplateDir, config.PromptLanguage)
+	} else {
+		promptTemplate, promptErr = decision.BuiltinPromptTemplate(config.PromptLanguage)
+	}
+	if promptErr != nil {
+		return nil, fmt.Errorf("加载提示词模板失败: %w", promptErr)
+	}
+	log.Printf("📝 [%s] Prompt template: %s [%s] (version %s)", config.Name, promptTemplate.Name, promptTemplate.Language, promptTemplate.Version)
+
+	if config.EntryOrderTTL <= 0 {
+		config.EntryOrderTTL = 15 * time.Minute
+	}
+
+	if config.MemoryCycles > 0 && config.MemoryTokenBudget <= 0 {
+		config.MemoryTokenBudget = 1500
 	}
 
 	// 初始化币种池API
@@ -140,26 +249,28 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 	}
 
 	// 根据配置创建对应的交易器
-	var trader Trader
+	trader := config.Trader
 	var err error
 
-	switch config.Exchange {
-	case "binance":
+	switch {
+	case trader != nil:
+		log.Printf("🏦 [%s] Using injected trader (%s)", config.Name, config.Exchange)
+	case config.Exchange == "binance":
 		log.Printf("🏦 [%s] Using Binance Futures trading", config.Name)
 		trader = NewFuturesTrader(config.BinanceAPIKey, config.BinanceSecretKey)
-	case "hyperliquid":
+	case config.Exchange == "hyperliquid":
 		log.Printf("🏦 [%s] Using Hyperliquid trading", config.Name)
 		trader, err = NewHyperliquidTrader(config.HyperliquidPrivateKey, config.HyperliquidWalletAddr, config.HyperliquidTestnet)
 		if err != nil {
 			return nil, fmt.Errorf("初始化Hyperliquid交易器失败: %w", err)
 		}
-	case "aster":
+	case config.Exchange == "aster":
 		log.Printf("🏦 [%s] Using Aster trading", config.Name)
 		trader, err = NewAsterTrader(config.AsterUser, config.AsterSigner, config.AsterPrivateKey)
 		if err != nil {
 			return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
 		}
-	case "delta":
+	case config.Exchange == "delta":
 		log.Printf("🏦 [%s] Using Delta Exchange trading", config.Name)
 		trader = NewDeltaTrader(config.DeltaAPIKey, config.DeltaAPISecret, config.DeltaTestnet)
 	default:
@@ -171,8 +282,18 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		return nil, fmt.Errorf("初始金额必须大于0，请在配置中设置InitialBalance")
 	}
 
+	// 检测账户的持仓模式，按配置设置保证金模式和持仓模式
+	if err := trader.ConfigureAccountModes(config.MarginMode, config.PositionMode); err != nil {
+		return nil, fmt.Errorf("设置账户模式失败: %w", err)
+	}
+	marginMode, positionMode := trader.AccountModes()
+	log.Printf("⚙️  [%s] 账户模式: 保证金 %s, 持仓 %s", config.Name, marginMode, positionMode)
+
 	// 初始化决策日志记录器（使用trader ID创建独立目录）
-	logDir := fmt.Sprintf("decision_logs/%s", config.ID)
+	logDir := config.DecisionLogDir
+	if logDir == "" {
+		logDir = fmt.Sprintf("decision_logs/%s", config.ID)
+	}
 	decisionLogger := logger.NewDecisionLogger(logDir)
 
 	return &AutoTrader{
@@ -183,57 +304,131 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		config:                config,
 		trader:                trader,
 		mcpClient:             mcpClient,
+		ensembleClients:       ensembleClients,
+		promptTemplate:        promptTemplate,
 		decisionLogger:        decisionLogger,
 		initialBalance:        config.InitialBalance,
 		lastResetTime:         time.Now(),
 		startTime:             time.Now(),
 		callCount:             0,
-		isRunning:             false,
 		positionFirstSeenTime: make(map[string]int64),
+		pendingEntries:        make(map[string]*pendingEntry),
+		protectiveTargets:     make(map[string]protectiveTarget),
+		lastFillCheck:         time.Now(),
+		lastFundingCheck:      time.Now(),
+		cycleFunding:          make(map[string]float64),
+		loggedOrders:          make(map[int64]time.Time),
 	}, nil
 }
 
-// Run 运行自动交易主循环
-func (at *AutoTrader) Run() error {
-	at.isRunning = true
+// newAIClient 根据模型配置创建AI客户端
+func newAIClient(traderName string, cfg AIModelConfig) *mcp.Client {
+	mcpClient := mcp.New()
+
+	if cfg.AIModel == "custom" {
+		// 使用自定义API
+		mcpClient.SetCustomAPI(cfg.CustomAPIURL, cfg.CustomAPIKey, cfg.CustomModelName)
+		log.Printf("🤖 [%s] Using custom AI API: %s (model: %s)", traderName, cfg.CustomAPIURL, cfg.CustomModelName)
+	} else if cfg.AIModel == "minimax" {
+		// 使用MiniMax
+		mcpClient.SetMiniMaxAPIKey(cfg.MiniMaxKey)
+		log.Printf("🤖 [%s] Using MiniMax M2 AI (FREE)", traderName)
+	} else if cfg.UseQwen || cfg.AIModel == "qwen" {
+		// 使用Qwen
+		mcpClient.SetQwenAPIKey(cfg.QwenKey, "")
+		log.Printf("🤖 [%s] Using Alibaba Cloud Qwen AI", traderName)
+	} else {
+		// 默认使用DeepSeek
+		mcpClient.SetDeepSeekAPIKey(cfg.DeepSeekKey)
+		log.Printf("🤖 [%s] Using DeepSeek AI", traderName)
+	}
+
+	return mcpClient
+}
+
+// applyTokenLimits 应用trader级别的token预算配置
+func applyTokenLimits(client *mcp.Client, config AutoTraderConfig) {
+	if config.MaxOutputTokens > 0 {
+		client.MaxTokens = config.MaxOutputTokens
+	}
+	if config.PromptTokenBudget > 0 {
+		client.PromptTokenLimit = config.PromptTokenBudget
+	}
+}
+
+// Run 运行自动交易主循环，直到ctx取消或调用Stop
+// 取消时正在执行的交易周期会先执行完（尚未开始执行决策的周期跳过执行），然后Run返回
+func (at *AutoTrader) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	at.runMu.Lock()
+	at.cancelRun = cancel
+	at.runMu.Unlock()
+
+	at.isRunning.Store(true)
+	defer at.isRunning.Store(false)
 	log.Println("🚀 AI驱动自动交易系统启动")
 	log.Printf("💰 初始余额: %.2f USDT", at.initialBalance)
 	log.Printf("⚙️  扫描间隔: %v", at.config.ScanInterval)
 	log.Println("🤖 AI将全权决定杠杆、仓位大小、止损止盈等参数")
 
+	// 支持账户推送的交易所：余额/持仓从推送维护的本地数据读取，成交实时记录
+	if stream, ok := at.trader.(AccountStream); ok {
+		fills := stream.Fills()
+		if err := stream.StartStream(); err != nil {
+			log.Printf("⚠️  账户推送连接失败，继续使用REST查询: %v", err)
+		} else {
+			defer stream.StopStream()
+			go at.watchFills(fills)
+		}
+	}
+
 	ticker := time.NewTicker(at.config.ScanInterval)
 	defer ticker.Stop()
 
 	// 首次立即执行
-	if err := at.runCycle(); err != nil {
+	if err := at.runCycle(ctx); err != nil {
 		log.Printf("❌ 执行失败: %v", err)
 	}
 
-	for at.isRunning {
+	for {
 		select {
+		case <-ctx.Done():
+			log.Printf("⏹ %s 交易循环已退出", at.name)
+			return nil
 		case <-ticker.C:
-			if err := at.runCycle(); err != nil {
+			if err := at.runCycle(ctx); err != nil {
 				log.Printf("❌ 执行失败: %v", err)
 			}
 		}
 	}
-
-	return nil
 }
 
-// Stop 停止自动交易
+// Stop 停止自动交易（取消Run的交易循环，不等待进行中的周期结束）
 func (at *AutoTrader) Stop() {
-	at.isRunning = false
+	at.runMu.Lock()
+	if at.cancelRun != nil {
+		at.cancelRun()
+	}
+	at.runMu.Unlock()
 	log.Println("⏹ 自动交易系统停止")
 }
 
 // runCycle 运行一个交易周期（使用AI全权决策）
-func (at *AutoTrader) runCycle() error {
+// runCtx取消后不再开始执行决策，已开始执行的决策会全部完成，保证不会留下执行一半的决策
+func (at *AutoTrader) runCycle(runCtx context.Context) error {
+	at.cycleMu.Lock()
+	defer at.cycleMu.Unlock()
+	if at.killed.Load() || runCtx.Err() != nil {
+		return nil
+	}
+
 	at.callCount++
+	defer at.trader.SetClientOrderIDPrefix("")
 
-	log.Printf("\n" + strings.Repeat("=", 70))
+	log.Print("\n" + strings.Repeat("=", 70))
 	log.Printf("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
-	log.Printf(strings.Repeat("=", 70))
+	log.Print(strings.Repeat("=", 70))
 
 	// 创建决策记录
 	record := &logger.DecisionRecord{
@@ -241,6 +436,38 @@ func (at *AutoTrader) runCycle() error {
 		Success:      true,
 	}
 
+	// 处理挂单中的限价开仓单（成交后设置止损止盈，超时撤销）
+	at.trader.SetClientOrderIDPrefix(at.clientOrderIDPrefix("s"))
+	for _, action := range at.reconcilePendingEntries() {
+		record.Decisions = append(record.Decisions, action)
+		if action.Action == "cancel_entry" {
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⌛ %s %s 限价开仓单撤销，未成交 %.4f",
+				action.Symbol, action.Side, action.Quantity))
+		} else {
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 限价开仓单成交 %.4f @ %.4f",
+				action.Symbol, action.Action, action.Quantity, action.Price))
+		}
+	}
+	// 同步成交记录（止盈阶梯的成交和交易所触发的止损/止盈平仓记入决策日志），并为缺少止损/止盈单的持仓补挂
+	for _, action := range append(at.takeStreamCloses(), at.logNewFills()...) {
+		record.Decisions = append(record.Decisions, action)
+		if action.Action == "take_profit_level" {
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🎯 %s %s 第%d档止盈成交 %.4f @ %.4f",
+				action.Symbol, action.Side, action.Level, action.Quantity, action.Price))
+		} else {
+			record.ExecutionLog = append(record.ExecutionLog, closeFillLog(action))
+		}
+	}
+	at.reconcileProtectiveOrders()
+	// 同步资金费收支并归属到各持仓（记入决策日志，平仓时计入该笔交易的盈亏）
+	at.cycleFunding = make(map[string]float64)
+	for _, action := range at.logNewFunding() {
+		record.Decisions = append(record.Decisions, action)
+		at.cycleFunding[action.Symbol+"_"+action.Side] += action.Funding
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("💸 %s %s 资金费 %+.4f",
+			action.Symbol, action.Side, action.Funding))
+	}
+
 	// 1. 检查是否需要停止交易
 	if time.Now().Before(at.stopUntil) {
 		remaining := at.stopUntil.Sub(time.Now())
@@ -299,17 +526,37 @@ func (at *AutoTrader) runCycle() error {
 		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
 	// 4. 调用AI获取完整决策
-	log.Println("🤖 正在请求AI分析并决策...")
-	decision, err := decision.GetFullDecision(ctx, at.mcpClient)
+	decision, err := at.requestDecision(ctx)
 
 	// 即使有错误，也保存思维链、决策和输入prompt（用于debug）
 	if decision != nil {
 		record.InputPrompt = decision.UserPrompt
+		record.PromptVersion = decision.PromptVersion
+		record.PromptTokens = decision.PromptTokens
+		record.PromptReductions = decision.PromptReductions
 		record.CoTTrace = decision.CoTTrace
 		if len(decision.Decisions) > 0 {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
 		}
+		for _, call := range decision.ToolCalls {
+			record.ToolCalls = append(record.ToolCalls, logger.ToolCallRecord{
+				Iteration: call.Iteration,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+				Result:    call.Result,
+				Error:     call.Error,
+			})
+		}
+		for _, vote := range decision.Votes {
+			voteJSON, _ := json.MarshalIndent(vote.Decisions, "", "  ")
+			record.EnsembleVotes = append(record.EnsembleVotes, logger.EnsembleVote{
+				Model:        vote.Model,
+				CoTTrace:     vote.CoTTrace,
+				DecisionJSON: string(voteJSON),
+				Error:        vote.Error,
+			})
+		}
 	}
 
 	if err != nil {
@@ -318,11 +565,11 @@ func (at *AutoTrader) runCycle() error {
 
 		// 打印AI思维链（即使有错误）
 		if decision != nil && decision.CoTTrace != "" {
-			log.Printf("\n" + strings.Repeat("-", 70))
+			log.Print("\n" + strings.Repeat("-", 70))
 			log.Println("💭 AI思维链分析（错误情况）:")
 			log.Println(strings.Repeat("-", 70))
 			log.Println(decision.CoTTrace)
-			log.Printf(strings.Repeat("-", 70) + "\n")
+			log.Print(strings.Repeat("-", 70) + "\n")
 		}
 
 		at.decisionLogger.LogDecision(record)
@@ -330,23 +577,71 @@ func (at *AutoTrader) runCycle() error {
 	}
 
 	// 5. 打印AI思维链
-	log.Printf("\n" + strings.Repeat("-", 70))
+	log.Print("\n" + strings.Repeat("-", 70))
 	log.Println("💭 AI思维链分析:")
 	log.Println(strings.Repeat("-", 70))
 	log.Println(decision.CoTTrace)
-	log.Printf(strings.Repeat("-", 70) + "\n")
+	log.Print(strings.Repeat("-", 70) + "\n")
 
-	// 6. 打印AI决策
+	// 6. 打印AI决策（集成模式下先打印各模型投票）
+	for _, vote := range decision.Votes {
+		if vote.Error != "" {
+			log.Printf("🗳  %s: 无效投票 (%s)", vote.Model, vote.Error)
+			continue
+		}
+		var actions []string
+		for _, d := range vote.Decisions {
+			actions = append(actions, d.Symbol+" "+d.Action)
+		}
+		log.Printf("🗳  %s: %s", vote.Model, strings.Join(actions, ", "))
+	}
 	log.Printf("📋 AI决策列表 (%d 个):\n", len(decision.Decisions))
 	for i, d := range decision.Decisions {
 		log.Printf("  [%d] %s: %s - %s", i+1, d.Symbol, d.Action, d.Reasoning)
 		if d.Action == "open_long" || d.Action == "open_short" {
 			log.Printf("      杠杆: %dx | 仓位: %.2f USDT | 止损: %.4f | 止盈: %.4f",
 				d.Leverage, d.PositionSizeUSD, d.StopLoss, d.TakeProfit)
+			if d.TrailingStopPct > 0 {
+				log.Printf("      跟踪止损: 回调 %.2f%%", d.TrailingStopPct)
+			}
+			for i, level := range d.TakeProfitLevels {
+				switch {
+				case level.TrailingStopPct > 0:
+					log.Printf("      止盈阶梯 第%d档: %.0f%% 跟踪止损 回调 %.2f%%", i+1, level.Percentage, level.TrailingStopPct)
+				case level.RMultiple > 0:
+					log.Printf("      止盈阶梯 第%d档: %.0f%% @ %.1fR", i+1, level.Percentage, level.RMultiple)
+				default:
+					log.Printf("      止盈阶梯 第%d档: %.0f%% @ %.4f", i+1, level.Percentage, level.Price)
+				}
+			}
+		}
+		if d.Action == "update_stop_loss" {
+			log.Printf("      新止损: %.4f", d.StopLoss)
+		}
+		if d.Action == "update_take_profit" {
+			log.Printf("      新止盈: %.4f", d.TakeProfit)
+		}
+		if d.Action == "partial_close" {
+			if d.ClosePercentage > 0 {
+				log.Printf("      平仓比例: %.1f%%", d.ClosePercentage)
+			} else {
+				log.Printf("      平仓数量: %.4f", d.CloseQuantity)
+			}
+		}
+		if d.Action == "add_to_position" {
+			log.Printf("      杠杆: %dx | 加仓: %.2f USDT", d.Leverage, d.PositionSizeUSD)
 		}
 	}
 	log.Println()
 
+	// 等待AI期间收到停止信号：不再开始执行本周期的决策
+	if runCtx.Err() != nil {
+		log.Println("⏹ 收到停止信号，跳过本周期决策的执行")
+		record.ExecutionLog = append(record.ExecutionLog, "⏹ 收到停止信号，未执行本周期决策")
+		at.decisionLogger.LogDecision(record)
+		return nil
+	}
+
 	// 7. 对决策排序：确保先平仓后开仓（防止仓位叠加超限）
 	sortedDecisions := sortDecisionsByPriority(decision.Decisions)
 
@@ -356,8 +651,16 @@ func (at *AutoTrader) runCycle() error {
 	}
 	log.Println()
 
-	// 执行决策并记录结果
-	for _, d := range sortedDecisions {
+	// 执行决策并记录结果（每个决策的订单使用各自的客户端订单ID前缀）
+	for i, d := range sortedDecisions {
+		if at.killed.Load() {
+			log.Printf("🚨 已触发紧急平仓，跳过剩余%d个决策", len(sortedDecisions)-i)
+			break
+		}
+		prefix := at.clientOrderIDPrefix(strconv.Itoa(i))
+		at.trader.SetClientOrderIDPrefix(prefix)
+		log.Printf("  [%d] %s %s 客户端订单ID前缀: %s", i+1, d.Symbol, d.Action, prefix)
+
 		actionRecord := logger.DecisionAction{
 			Action:    d.Action,
 			Symbol:    d.Symbol,
@@ -366,6 +669,13 @@ func (at *AutoTrader) runCycle() error {
 			Price:     0,
 			Timestamp: time.Now(),
 			Success:   false,
+
+			StopLoss:   d.StopLoss,
+			TakeProfit: d.TakeProfit,
+			Reasoning:  d.Reasoning,
+
+			TrailingStopPct: d.TrailingStopPct,
+			Exchange:        at.exchange,
 		}
 
 		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
@@ -379,6 +689,7 @@ func (at *AutoTrader) runCycle() error {
 			time.Sleep(1 * time.Second)
 		}
 
+		at.markOrderLogged(actionRecord.OrderID)
 		record.Decisions = append(record.Decisions, actionRecord)
 	}
 
@@ -390,6 +701,31 @@ func (at *AutoTrader) runCycle() error {
 	return nil
 }
 
+// clientOrderIDPrefix 本周期某一步的客户端订单ID前缀：交易员ID哈希 + 启动时间 + 周期编号 + 步骤
+// （step为决策序号，"s"表示决策前的挂单同步），同一个交易员重启后也不会重复
+func (at *AutoTrader) clientOrderIDPrefix(step string) string {
+	sum := sha256.Sum256([]byte(at.id))
+	return fmt.Sprintf("%s%s-%d-%s", hex.EncodeToString(sum[:3]), strconv.FormatInt(at.startTime.Unix(), 36), at.callCount, step)
+}
+
+// requestDecision 请求AI决策（配置了集成模型时走多模型投票）
+func (at *AutoTrader) requestDecision(ctx *decision.Context) (*decision.FullDecision, error) {
+	if len(at.ensembleClients) == 0 {
+		if at.config.ToolMode {
+			log.Println("🤖 正在请求AI分析并决策（工具调用模式）...")
+			return decision.GetToolDecision(ctx, at.mcpClient, at.config.MaxToolIterations)
+		}
+		log.Println("🤖 正在请求AI分析并决策...")
+		return decision.GetFullDecision(ctx, at.mcpClient)
+	}
+
+	log.Printf("🗳  正在请求 %d 个模型投票决策...", len(at.ensembleClients))
+	return decision.GetEnsembleDecision(ctx, at.ensembleClients, decision.EnsemblePolicy{
+		SizePolicy: at.config.EnsembleSizePolicy,
+		MinVotes:   at.config.EnsembleMinVotes,
+	})
+}
+
 // buildTradingContext 构建交易上下文
 func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	// 1. 获取账户信息
@@ -428,6 +764,18 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	// 当前持仓的key集合（用于清理已平仓的记录）
 	currentPositionKeys := make(map[string]bool)
 
+	// 持仓累计的资金费 = 决策日志中已记录的部分 + 本周期刚同步的部分
+	positionFunding, err := at.decisionLogger.GetOpenPositionFunding(1000)
+	if err != nil {
+		log.Printf("⚠ 读取持仓资金费失败: %v", err)
+		positionFunding = make(map[string]float64)
+	}
+	for key, amount := range at.cycleFunding {
+		positionFunding[key] += amount
+	}
+	// 每个币种只查询一次资金费率（查询失败时为nil）
+	fundingQuotes := make(map[string]*fundingQuote)
+
 	for _, pos := range positions {
 		symbol := pos["symbol"].(string)
 		side := pos["side"].(string)
@@ -465,6 +813,22 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		}
 		updateTime := at.positionFirstSeenTime[posKey]
 
+		quote, queried := fundingQuotes[symbol]
+		if !queried {
+			quote = at.queryFundingQuote(symbol)
+			fundingQuotes[symbol] = quote
+		}
+		fundingRate, nextFundingTime, projectedFunding := 0.0, int64(0), 0.0
+		if quote != nil {
+			fundingRate = quote.rate
+			nextFundingTime = quote.next.UnixMilli()
+			// 费率为正时多仓支付、空仓收取
+			projectedFunding = quote.rate * quantity * markPrice
+			if side == "long" {
+				projectedFunding = -projectedFunding
+			}
+		}
+
 		positionInfos = append(positionInfos, decision.PositionInfo{
 			Symbol:           symbol,
 			Side:             side,
@@ -476,7 +840,17 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			UnrealizedPnLPct: pnlPct,
 			LiquidationPrice: liquidationPrice,
 			MarginUsed:       marginUsed,
+			StopLoss:         at.protectiveTargets[posKey].StopLoss,
+			TakeProfit:       at.protectiveTargets[posKey].TakeProfit,
+			TrailingStopPct:  at.protectiveTargets[posKey].TrailingStopPct,
 			UpdateTime:       updateTime,
+
+			Funding:          positionFunding[posKey],
+			FundingRate:      fundingRate,
+			NextFundingTime:  nextFundingTime,
+			ProjectedFunding: projectedFunding,
+
+			TakeProfitLevels: at.protectiveTargets[posKey].remainingLevels(),
 		})
 	}
 
@@ -532,7 +906,13 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		performance = nil
 	}
 
-	// 6. 构建上下文
+	// 6. 构建跨周期记忆（可选）
+	var memory *decision.MemoryDigest
+	if at.config.MemoryCycles > 0 {
+		memory = at.buildMemoryDigest(positionInfos)
+	}
+
+	// 7. 构建上下文
 	ctx := &decision.Context{
 		CurrentTime:     time.Now().Format("2006-01-02 15:04:05"),
 		RuntimeMinutes:  int(time.Since(at.startTime).Minutes()),
@@ -549,13 +929,89 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			PositionCount:    len(positionInfos),
 		},
 		Positions:      positionInfos,
+		PendingOrders:  at.pendingOrderInfos(),
 		CandidateCoins: candidateCoins,
 		Performance:    performance, // 添加历史表现分析
+
+		Exchange:            at.exchange,
+		ScanIntervalMinutes: int(at.config.ScanInterval.Minutes()),
+		PromptTemplate:      at.promptTemplate,
+		Memory:              memory,
+		TradeHistory: func(symbol string) (interface{}, error) {
+			return at.decisionLogger.GetTradeHistory(symbol, 1000)
+		},
 	}
 
 	return ctx, nil
 }
 
+// buildMemoryDigest 从决策日志构建跨周期记忆：最近N个周期的思考与动作 + 当前持仓的入场理由
+func (at *AutoTrader) buildMemoryDigest(positions []decision.PositionInfo) *decision.MemoryDigest {
+	memory := &decision.MemoryDigest{}
+
+	records, err := at.decisionLogger.GetLatestRecords(at.config.MemoryCycles)
+	if err != nil {
+		log.Printf("⚠️  读取历史决策失败，本周期不注入记忆: %v", err)
+		return nil
+	}
+	for _, record := range records {
+		cycle := decision.CycleMemory{
+			CycleNumber: record.CycleNumber,
+			Time:        record.Timestamp.Format("01-02 15:04"),
+			Equity:      record.AccountState.TotalBalance,
+			Summary:     record.CoTTrace,
+		}
+		for _, action := range record.Decisions {
+			if action.Action == "hold" || action.Action == "wait" {
+				continue
+			}
+			status := "✓"
+			if !action.Success {
+				status = "✗"
+			}
+			cycle.Actions = append(cycle.Actions, fmt.Sprintf("%s %s %s", action.Symbol, action.Action, status))
+		}
+		if !record.Success && record.ErrorMessage != "" {
+			cycle.Actions = append(cycle.Actions, "error: "+record.ErrorMessage)
+		}
+		memory.Cycles = append(memory.Cycles, cycle)
+	}
+
+	// 入场理由只保留仍在持有的仓位（向前多看一些周期，覆盖长期持仓）
+	theses, err := at.decisionLogger.GetOpenPositionTheses(at.config.MemoryCycles * 10)
+	if err != nil {
+		log.Printf("⚠️  读取入场理由失败: %v", err)
+	}
+	for _, pos := range positions {
+		thesis, ok := theses[pos.Symbol+"_"+pos.Side]
+		if !ok {
+			continue
+		}
+		memory.Theses = append(memory.Theses, decision.PositionThesis{
+			Symbol:     thesis.Symbol,
+			Side:       thesis.Side,
+			OpenTime:   thesis.OpenTime.Format("01-02 15:04"),
+			EntryPrice: thesis.EntryPrice,
+			StopLoss:   thesis.StopLoss,
+			TakeProfit: thesis.TakeProfit,
+			Thesis:     thesis.Reasoning,
+		})
+	}
+
+	// 与prompt预算使用同一个估算函数，避免同一段文本按两种比例计算
+	clients := at.ensembleClients
+	if len(clients) == 0 {
+		clients = []*mcp.Client{at.mcpClient}
+	}
+	estimate := decision.BudgetForClients(clients...).Estimator()
+	before := memory.EstimateTokens(estimate)
+	memory.Fit(at.config.MemoryTokenBudget, estimate)
+	log.Printf("🧠 记忆: %d 个周期, %d 条入场理由 (约%d tokens, 原始约%d)",
+		len(memory.Cycles), len(memory.Theses), memory.EstimateTokens(estimate), before)
+
+	return memory
+}
+
 // executeDecisionWithRecord 执行AI决策并记录详细信息
 func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	switch decision.Action {
@@ -567,6 +1023,12 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
 		return at.executeCloseLongWithRecord(decision, actionRecord)
 	case "close_short":
 		return at.executeCloseShortWithRecord(decision, actionRecord)
+	case "update_stop_loss", "update_take_profit":
+		return at.executeUpdateProtectiveWithRecord(decision, actionRecord)
+	case "partial_close":
+		return at.executePartialCloseWithRecord(decision, actionRecord)
+	case "add_to_position":
+		return at.executeAddToPositionWithRecord(decision, actionRecord)
 	case "hold", "wait":
 		// 无需执行，仅记录
 		return nil
@@ -575,6 +1037,26 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
 	}
 }
 
+// checkOneWayConflict 单向持仓模式下每个币种只有一个净持仓，已有反向持仓（或反向限价开仓单）时开仓会抵消它，拒绝开仓
+func (at *AutoTrader) checkOneWayConflict(positions []map[string]interface{}, symbol, oppositeSide string) error {
+	if _, positionMode := at.trader.AccountModes(); positionMode != PositionModeOneWay {
+		return nil
+	}
+	name := "多仓"
+	if oppositeSide == "short" {
+		name = "空仓"
+	}
+	for _, pos := range positions {
+		if pos["symbol"] == symbol && pos["side"] == oppositeSide {
+			return fmt.Errorf("❌ %s 已有%s，单向持仓模式下反向开仓会抵消该持仓，拒绝开仓。如需换仓，请先给出 close_%s 决策", symbol, name, oppositeSide)
+		}
+	}
+	if _, exists := at.pendingEntries[symbol+"_"+oppositeSide]; exists {
+		return fmt.Errorf("❌ %s 已有挂单中的反向限价开仓单，单向持仓模式下拒绝开仓", symbol)
+	}
+	return nil
+}
+
 // executeOpenLongWithRecord 执行开多仓并记录详细信息
 func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  📈 开多仓: %s", decision.Symbol)
@@ -587,6 +1069,17 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 				return fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_long 决策", decision.Symbol)
 			}
 		}
+		if err := at.checkOneWayConflict(positions, decision.Symbol, "short"); err != nil {
+			return err
+		}
+	}
+	if _, exists := at.pendingEntries[decision.Symbol+"_long"]; exists {
+		return fmt.Errorf("❌ %s 已有挂单中的限价开多单，拒绝重复开仓", decision.Symbol)
+	}
+
+	// 限价开仓：挂单成交后才设置止损止盈
+	if isLimitOrderType(decision.OrderType) {
+		return at.executeLimitEntry(decision, actionRecord, "long")
 	}
 
 	// 获取当前价格
@@ -610,6 +1103,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	if orderID, ok := order["orderId"].(int64); ok {
 		actionRecord.OrderID = orderID
 	}
+	recordExecution(actionRecord, order, true)
 
 	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
 
@@ -618,12 +1112,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
 
 	// 设置止损止盈
-	if err := at.trader.SetStopLoss(decision.Symbol, "LONG", quantity, decision.StopLoss); err != nil {
-		log.Printf("  ⚠ 设置止损失败: %v", err)
-	}
-	if err := at.trader.SetTakeProfit(decision.Symbol, "LONG", quantity, decision.TakeProfit); err != nil {
-		log.Printf("  ⚠ 设置止盈失败: %v", err)
-	}
+	at.placeProtectiveOrders(decision.Symbol, "LONG", quantity, newProtectiveTarget(decision, marketData.CurrentPrice))
 
 	return nil
 }
@@ -640,6 +1129,17 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 				return fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_short 决策", decision.Symbol)
 			}
 		}
+		if err := at.checkOneWayConflict(positions, decision.Symbol, "long"); err != nil {
+			return err
+		}
+	}
+	if _, exists := at.pendingEntries[decision.Symbol+"_short"]; exists {
+		return fmt.Errorf("❌ %s 已有挂单中的限价开空单，拒绝重复开仓", decision.Symbol)
+	}
+
+	// 限价开仓：挂单成交后才设置止损止盈
+	if isLimitOrderType(decision.OrderType) {
+		return at.executeLimitEntry(decision, actionRecord, "short")
 	}
 
 	// 获取当前价格
@@ -663,6 +1163,7 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	if orderID, ok := order["orderId"].(int64); ok {
 		actionRecord.OrderID = orderID
 	}
+	recordExecution(actionRecord, order, false)
 
 	log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
 
@@ -671,93 +1172,1291 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
 
 	// 设置止损止盈
-	if err := at.trader.SetStopLoss(decision.Symbol, "SHORT", quantity, decision.StopLoss); err != nil {
-		log.Printf("  ⚠ 设置止损失败: %v", err)
-	}
-	if err := at.trader.SetTakeProfit(decision.Symbol, "SHORT", quantity, decision.TakeProfit); err != nil {
-		log.Printf("  ⚠ 设置止盈失败: %v", err)
-	}
+	at.placeProtectiveOrders(decision.Symbol, "SHORT", quantity, newProtectiveTarget(decision, marketData.CurrentPrice))
 
 	return nil
 }
 
-// executeCloseLongWithRecord 执行平多仓并记录详细信息
-func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
-	log.Printf("  🔄 平多仓: %s", decision.Symbol)
+// pendingEntry 挂单中的限价开仓单
+type pendingEntry struct {
+	Symbol   string
+	Side     string // "long" 或 "short"
+	OrderID  int64
+	Quantity float64
+	Price    float64
+	PlacedAt time.Time
+	Target   protectiveTarget      // 成交后设置的止损止盈（止盈阶梯按挂单价换算）
+	Record   logger.DecisionAction // 下单时的决策记录（成交后按实际成交数量记为开仓）
+}
 
-	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
-	if err != nil {
-		return err
+// isLimitOrderType 决策的订单类型是否为限价单（limit/post_only/ioc）
+func isLimitOrderType(orderType string) bool {
+	return orderType == "limit" || orderType == "post_only" || orderType == "ioc"
+}
+
+// executeLimitEntry 限价开仓：立即成交则设置止损止盈，挂单则记录下来在后续周期跟踪
+func (at *AutoTrader) executeLimitEntry(decision *decision.Decision, actionRecord *logger.DecisionAction, side string) error {
+	positionSide := positionSideFromSide(side)
+	timeInForce := TimeInForceGTC
+	switch decision.OrderType {
+	case "post_only":
+		timeInForce = TimeInForcePostOnly
+	case "ioc":
+		timeInForce = TimeInForceIOC
 	}
-	actionRecord.Price = marketData.CurrentPrice
 
-	// 平仓
-	order, err := at.trader.CloseLong(decision.Symbol, 0) // 0 = 全部平仓
+	// 按挂单价计算数量
+	quantity := decision.PositionSizeUSD / decision.EntryPrice
+	actionRecord.Quantity = quantity
+	actionRecord.Price = decision.EntryPrice
+
+	order, err := at.trader.OpenLimit(decision.Symbol, positionSide, quantity, decision.EntryPrice, decision.Leverage, timeInForce)
 	if err != nil {
 		return err
 	}
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
+	orderID, _ := order["orderId"].(int64)
+	actionRecord.OrderID = orderID
+
+	posKey := decision.Symbol + "_" + side
+	switch order["status"] {
+	case "FILLED":
+		log.Printf("  ✓ 限价单已成交，订单ID: %d, 数量: %.4f", orderID, quantity)
+		recordExecution(actionRecord, order, side == "long")
+		at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+		at.placeProtectiveOrders(decision.Symbol, positionSide, quantity, newProtectiveTarget(decision, decision.EntryPrice))
+	case "EXPIRED":
+		return fmt.Errorf("%s 限价单未能立即成交，已撤销 (%s @ %.4f)", decision.Symbol, timeInForce, decision.EntryPrice)
+	default:
+		// 挂单不是开仓：成交后才按实际成交数量记录open_long/open_short（账本据此建立持仓）
+		actionRecord.Action = "place_entry"
+		actionRecord.Side = side
+		at.pendingEntries[posKey] = &pendingEntry{
+			Symbol:   decision.Symbol,
+			Side:     side,
+			OrderID:  orderID,
+			Quantity: quantity,
+			Price:    decision.EntryPrice,
+			PlacedAt: time.Now(),
+			Target:   newProtectiveTarget(decision, decision.EntryPrice),
+			Record:   *actionRecord,
+		}
+		log.Printf("  ⏳ 限价单挂单中，订单ID: %d, 价格: %.4f, %.0f分钟内未成交将撤销",
+			orderID, decision.EntryPrice, at.config.EntryOrderTTL.Minutes())
 	}
 
-	log.Printf("  ✓ 平仓成功")
 	return nil
 }
 
-// executeCloseShortWithRecord 执行平空仓并记录详细信息
-func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
-	log.Printf("  🔄 平空仓: %s", decision.Symbol)
+// reconcilePendingEntries 检查挂单中的限价开仓单：已成交的设置止损止盈，超时的撤单
+// 返回需要记入决策日志的动作：成交（含部分成交）按实际成交数量记为开仓，未成交的部分记为cancel_entry
+func (at *AutoTrader) reconcilePendingEntries() []logger.DecisionAction {
+	if len(at.pendingEntries) == 0 {
+		return nil
+	}
 
-	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	positions, err := at.trader.GetPositions()
 	if err != nil {
-		return err
+		log.Printf("⚠ 获取持仓失败，跳过限价挂单检查: %v", err)
+		return nil
 	}
-	actionRecord.Price = marketData.CurrentPrice
+	filledAmount := make(map[string]float64)
+	entryPrice := make(map[string]float64)
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		amount, _ := pos["positionAmt"].(float64)
+		filledAmount[symbol+"_"+side] = amount
+		entryPrice[symbol+"_"+side], _ = pos["entryPrice"].(float64)
+	}
+
+	var actions []logger.DecisionAction
+	for key, entry := range at.pendingEntries {
+		positionSide := positionSideFromSide(entry.Side)
+		amount := filledAmount[key]
+
+		// 全部成交（允许1%的精度误差）
+		if amount >= entry.Quantity*0.99 {
+			log.Printf("✓ 限价开仓单已成交: %s %s 数量: %.4f", entry.Symbol, entry.Side, amount)
+			actions = append(actions, at.entryFillAction(entry, amount, entryPrice[key]))
+			at.positionFirstSeenTime[key] = time.Now().UnixMilli()
+			at.placeProtectiveOrders(entry.Symbol, positionSide, amount, entry.Target)
+			delete(at.pendingEntries, key)
+			continue
+		}
 
-	// 平仓
-	order, err := at.trader.CloseShort(decision.Symbol, 0) // 0 = 全部平仓
-	if err != nil {
-		return err
+		// 挂单已被交易所撤销/拒绝（如手动撤单），不再等待
+		order, err := at.trader.GetOrder(entry.Symbol, entry.OrderID)
+		orderGone := errors.Is(err, ErrOrderNotFound)
+		if err == nil {
+			switch order["status"] {
+			case "CANCELED", "EXPIRED", "REJECTED":
+				orderGone = true
+			}
+		}
+
+		reason := "限价开仓单已被交易所撤销"
+		if orderGone {
+			log.Printf("⚠ 限价开仓单已不在交易所挂单中: %s %s 订单ID: %d", entry.Symbol, entry.Side, entry.OrderID)
+		} else if time.Since(entry.PlacedAt) < at.config.EntryOrderTTL {
+			continue
+		} else {
+			// 超时：撤销剩余挂单，已部分成交的数量补上止损止盈
+			reason = fmt.Sprintf("限价开仓单%.0f分钟未成交，超时撤单", at.config.EntryOrderTTL.Minutes())
+			log.Printf("⌛ 限价开仓单超时未成交，撤单: %s %s 订单ID: %d", entry.Symbol, entry.Side, entry.OrderID)
+			if err := at.trader.CancelOrder(entry.Symbol, entry.OrderID); err != nil {
+				log.Printf("  ⚠ 撤单失败（可能已成交或已撤销）: %v", err)
+			}
+		}
+		if amount > 0 {
+			log.Printf("  部分成交 %.4f / %.4f，为已成交部分设置止损止盈", amount, entry.Quantity)
+			actions = append(actions, at.entryFillAction(entry, amount, entryPrice[key]))
+			at.positionFirstSeenTime[key] = time.Now().UnixMilli()
+			at.placeProtectiveOrders(entry.Symbol, positionSide, amount, entry.Target)
+		}
+		actions = append(actions, logger.DecisionAction{
+			Action:    "cancel_entry",
+			Symbol:    entry.Symbol,
+			Side:      entry.Side,
+			Quantity:  math.Max(entry.Quantity-amount, 0),
+			Price:     entry.Price,
+			OrderID:   entry.OrderID,
+			Timestamp: time.Now(),
+			Success:   true,
+			Reasoning: reason,
+			Exchange:  at.exchange,
+		})
+		delete(at.pendingEntries, key)
 	}
+	return actions
+}
 
-	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
-		actionRecord.OrderID = orderID
+// entryFillAction 限价开仓单成交（含部分成交）后的开仓记录：数量为实际成交数量，价格为成交均价
+// （查询不到成交记录时使用持仓的开仓均价positionEntryPrice），参考价为挂单价
+func (at *AutoTrader) entryFillAction(entry *pendingEntry, amount, positionEntryPrice float64) logger.DecisionAction {
+	action := entry.Record
+	action.Action = "open_" + entry.Side
+	action.Side = ""
+	action.Quantity = amount
+	action.Price = entry.Price
+	action.Timestamp = time.Now()
+	action.Success = true
+	action.Error = ""
+
+	execution := make(map[string]interface{})
+	if fills, err := at.trader.GetFills(entry.PlacedAt.Add(-time.Minute)); err == nil {
+		addFillsExecution(execution, fills, entry.OrderID)
+	}
+	if _, ok := execution["avgPrice"]; !ok {
+		setOrderExecution(execution, positionEntryPrice, amount, 0, "")
+	}
+	recordExecution(&action, execution, entry.Side == "long")
+	return action
+}
+
+// placeProtectiveOrders 新开仓后设置止损、止盈（或止盈阶梯）和可选的跟踪止损（失败只记录日志），并记录目标供后续周期补挂
+func (at *AutoTrader) placeProtectiveOrders(symbol, positionSide string, quantity float64, target protectiveTarget) {
+	at.setProtectiveTarget(symbol+"_"+sideFromPositionSide(positionSide), target)
+
+	at.cancelStaleProtectiveOrders(symbol, sideFromPositionSide(positionSide))
+
+	if err := at.trader.SetStopLoss(symbol, positionSide, quantity, target.StopLoss); err != nil {
+		log.Printf("  ⚠ 设置止损失败: %v", err)
 	}
+	if len(target.Ladder) > 0 {
+		at.placeTakeProfitLadder(symbol, positionSide, quantity, target)
+	} else if err := at.trader.SetTakeProfit(symbol, positionSide, quantity, target.TakeProfit); err != nil {
+		log.Printf("  ⚠ 设置止盈失败: %v", err)
+	}
+	at.placeTrailingStop(symbol, positionSide, quantity, target)
+}
 
-	log.Printf("  ✓ 平仓成功")
-	return nil
+// cancelStaleProtectiveOrders 撤销该方向之前的持仓残留的条件单（如止损触发后留下的止盈单），
+// 只撤与side持仓方向相反的止损/止盈/跟踪止损单，另一方向持仓的保护单和限价开仓单不受影响
+func (at *AutoTrader) cancelStaleProtectiveOrders(symbol, side string) {
+	orders, err := at.trader.GetOpenOrders(symbol)
+	if err != nil {
+		log.Printf("  ⚠ 获取 %s 挂单失败，跳过清理残留止损止盈: %v", symbol, err)
+		return
+	}
+	closingSide := "SELL"
+	if side == "short" {
+		closingSide = "BUY"
+	}
+	for _, order := range orders {
+		if order["side"] != closingSide {
+			continue
+		}
+		switch order["type"] {
+		case "STOP_MARKET", "TAKE_PROFIT_MARKET", "TRAILING_STOP_MARKET":
+			orderID, _ := order["orderId"].(int64)
+			if err := at.trader.CancelOrder(symbol, orderID); err != nil {
+				log.Printf("  ⚠ 撤销残留条件单失败 (订单ID: %d): %v", orderID, err)
+			}
+		}
+	}
 }
 
-// GetID 获取trader ID
-func (at *AutoTrader) GetID() string {
-	return at.id
+// placeTrailingStop 设置跟踪止损（有止盈阶梯时只覆盖跟踪止损档对应的数量，失败只记录日志）
+func (at *AutoTrader) placeTrailingStop(symbol, positionSide string, quantity float64, target protectiveTarget) {
+	_, trailingFraction := target.remainingLadder()
+	if target.TrailingStopPct <= 0 || trailingFraction <= 0 {
+		return
+	}
+	if err := at.trader.SetTrailingStop(symbol, positionSide, quantity*trailingFraction, target.TrailingStopPct, 0); err != nil {
+		log.Printf("  ⚠ 设置跟踪止损失败: %v", err)
+	}
 }
 
-// GetName 获取trader名称
-func (at *AutoTrader) GetName() string {
-	return at.name
+// protectiveTarget 持仓的止损止盈目标价
+type protectiveTarget struct {
+	StopLoss        float64
+	TakeProfit      float64
+	TrailingStopPct float64           // 跟踪止损回调比例（0表示没有跟踪止损）
+	Ladder          []*takeProfitStep // 止盈阶梯（为空表示单张止盈单）
 }
 
-// GetAIModel 获取AI模型
-func (at *AutoTrader) GetAIModel() string {
-	return at.aiModel
+// takeProfitStep 止盈阶梯的一档及其执行状态
+type takeProfitStep struct {
+	Price    float64 // 触发价（跟踪止损档为0）
+	Fraction float64 // 占开仓数量的比例（0-1）
+	Trailing bool    // 这一档用跟踪止损离场
+	OrderID  int64   // 交易所上的止盈单ID（0表示未知）
+	Filled   bool    // 止盈单已成交
 }
 
-// GetDecisionLogger 获取决策日志记录器
-func (at *AutoTrader) GetDecisionLogger() *logger.DecisionLogger {
-	return at.decisionLogger
+// newProtectiveTarget 按开仓决策生成止损止盈目标，止盈阶梯的r_multiple按入场价换算为触发价
+func newProtectiveTarget(d *decision.Decision, entryPrice float64) protectiveTarget {
+	target := protectiveTarget{
+		StopLoss:        d.StopLoss,
+		TakeProfit:      d.TakeProfit,
+		TrailingStopPct: d.TrailingStopPct,
+	}
+	risk := math.Abs(entryPrice - d.StopLoss)
+	for _, level := range d.TakeProfitLevels {
+		step := &takeProfitStep{Price: level.Price, Fraction: level.Percentage / 100}
+		switch {
+		case level.TrailingStopPct > 0:
+			step.Trailing = true
+			target.TrailingStopPct = level.TrailingStopPct
+		case level.RMultiple > 0 && d.Action == "open_short":
+			step.Price = entryPrice - level.RMultiple*risk
+		case level.RMultiple > 0:
+			step.Price = entryPrice + level.RMultiple*risk
+		}
+		target.Ladder = append(target.Ladder, step)
+	}
+	return target
 }
 
-// GetStatus 获取系统状态（用于API）
-func (at *AutoTrader) GetStatus() map[string]interface{} {
-	aiProvider := "DeepSeek"
-	if at.config.UseQwen {
-		aiProvider = "Qwen"
+// remainingLadder 未成交的止盈档位（比例按剩余仓位重新归一）和跟踪止损覆盖的剩余仓位比例
+// 没有阶梯时跟踪止损覆盖整个持仓
+func (p protectiveTarget) remainingLadder() ([]TakeProfitLevel, float64) {
+	if len(p.Ladder) == 0 {
+		return nil, 1
+	}
+	remaining := 0.0
+	for _, step := range p.Ladder {
+		if !step.Filled {
+			remaining += step.Fraction
+		}
+	}
+	if remaining <= 0 {
+		return nil, 0
+	}
+
+	var levels []TakeProfitLevel
+	trailingFraction := 0.0
+	for _, step := range p.Ladder {
+		switch {
+		case step.Filled:
+		case step.Trailing:
+			trailingFraction = step.Fraction / remaining
+		default:
+			levels = append(levels, TakeProfitLevel{Price: step.Price, Fraction: step.Fraction / remaining})
+		}
+	}
+	return levels, trailingFraction
+}
+
+// remainingLevels 未成交的止盈档位（提供给AI参考，比例为占开仓数量的百分比）
+func (p protectiveTarget) remainingLevels() []decision.TakeProfitLevel {
+	var levels []decision.TakeProfitLevel
+	for _, step := range p.Ladder {
+		if step.Filled {
+			continue
+		}
+		level := decision.TakeProfitLevel{Percentage: step.Fraction * 100, Price: step.Price}
+		if step.Trailing {
+			level.TrailingStopPct = p.TrailingStopPct
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// placeTakeProfitLadder 为未成交的档位挂止盈单，并按触发价记下各档的订单ID（用于识别成交的档位）
+func (at *AutoTrader) placeTakeProfitLadder(symbol, positionSide string, quantity float64, target protectiveTarget) {
+	levels, _ := target.remainingLadder()
+	if len(levels) == 0 {
+		return
+	}
+	if err := at.trader.SetTakeProfitLadder(symbol, positionSide, quantity, levels); err != nil {
+		// 已挂出的档位照常记录，缺失的档位由后续周期补挂
+		log.Printf("  ⚠ 设置止盈阶梯失败: %v", err)
+	}
+
+	orders, err := at.trader.GetOpenOrders(symbol)
+	if err != nil {
+		log.Printf("  ⚠ 获取 %s 挂单失败，无法记录止盈阶梯的订单: %v", symbol, err)
+		return
+	}
+	closingSide := "SELL"
+	if positionSide == "SHORT" {
+		closingSide = "BUY"
+	}
+	at.targetsMu.Lock()
+	defer at.targetsMu.Unlock()
+	used := make(map[int64]bool)
+	for _, step := range target.Ladder {
+		if step.Filled || step.Trailing {
+			continue
+		}
+		// 交易所会按价格精度取整，取触发价最接近（0.5%以内）的一张
+		step.OrderID = 0
+		best := step.Price * 0.005
+		for _, order := range orders {
+			orderID, _ := order["orderId"].(int64)
+			stopPrice, _ := order["stopPrice"].(float64)
+			if order["type"] != "TAKE_PROFIT_MARKET" || order["side"] != closingSide || used[orderID] {
+				continue
+			}
+			if diff := math.Abs(stopPrice - step.Price); diff <= best {
+				step.OrderID, best = orderID, diff
+			}
+		}
+		used[step.OrderID] = true
+	}
+}
+
+// replaceTakeProfitLadder 撤销持仓现有的止盈单，按当前数量重新挂未成交的档位
+func (at *AutoTrader) replaceTakeProfitLadder(symbol, positionSide string, quantity float64, target protectiveTarget) {
+	closingSide := "SELL"
+	if positionSide == "SHORT" {
+		closingSide = "BUY"
+	}
+	orders, err := at.trader.GetOpenOrders(symbol)
+	if err != nil {
+		log.Printf("  ⚠ 获取 %s 挂单失败，无法重新挂止盈阶梯: %v", symbol, err)
+		return
+	}
+	for _, order := range orders {
+		if order["type"] != "TAKE_PROFIT_MARKET" || order["side"] != closingSide {
+			continue
+		}
+		orderID, _ := order["orderId"].(int64)
+		if err := at.trader.CancelOrder(symbol, orderID); err != nil {
+			log.Printf("  ⚠ 撤销旧的止盈单 %d 失败: %v", orderID, err)
+		}
+	}
+	at.placeTakeProfitLadder(symbol, positionSide, quantity, target)
+}
+
+// reconcileProtectiveOrders 检查每个持仓的止损/止盈单，缺失的按记录的目标价和当前持仓数量补挂
+// 没有记录目标价的持仓（如重启后）从交易所现有的止损/止盈单学习目标价
+func (at *AutoTrader) reconcileProtectiveOrders() {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠ 获取持仓失败，跳过止损止盈检查: %v", err)
+		return
+	}
+
+	open := make(map[string]bool)
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		amount, _ := pos["positionAmt"].(float64)
+		if amount <= 0 {
+			continue
+		}
+		key := symbol + "_" + side
+		open[key] = true
+		if _, pending := at.pendingEntries[key]; pending {
+			continue // 限价开仓单还在成交中，成交后统一设置
+		}
+
+		orders, err := at.trader.GetOpenOrders(symbol)
+		if err != nil {
+			log.Printf("⚠ 获取 %s 挂单失败，跳过止损止盈检查: %v", symbol, err)
+			continue
+		}
+
+		// 止损/止盈单的方向与持仓相反
+		closingSide := "SELL"
+		if side == "short" {
+			closingSide = "BUY"
+		}
+		target := at.protectiveTargets[key]
+		hasStopLoss, hasTakeProfit, hasTrailingStop := false, false, false
+		openTakeProfits := make(map[int64]bool)
+		for _, order := range orders {
+			if order["side"] != closingSide {
+				continue
+			}
+			stopPrice, _ := order["stopPrice"].(float64)
+			switch order["type"] {
+			case "STOP_MARKET":
+				hasStopLoss = true
+				// 客户端跟踪止损会移动止损单，以交易所上的止损价为准
+				if target.StopLoss == 0 || target.TrailingStopPct > 0 {
+					target.StopLoss = stopPrice
+				}
+			case "TAKE_PROFIT_MARKET":
+				hasTakeProfit = true
+				orderID, _ := order["orderId"].(int64)
+				openTakeProfits[orderID] = true
+				if target.TakeProfit == 0 {
+					target.TakeProfit = stopPrice
+				}
+			case "TRAILING_STOP_MARKET":
+				hasTrailingStop = true
+			}
+		}
+		at.setProtectiveTarget(key, target)
+
+		positionSide := positionSideFromSide(side)
+		if !hasStopLoss {
+			if target.StopLoss > 0 {
+				log.Printf("🛡 %s %s 缺少止损单，补挂止损 %.4f 数量 %.4f", symbol, side, target.StopLoss, amount)
+				if err := at.trader.SetStopLoss(symbol, positionSide, amount, target.StopLoss); err != nil {
+					log.Printf("  ⚠ 补挂止损失败: %v", err)
+				}
+			} else {
+				log.Printf("⚠ %s %s 没有止损单，也没有记录的止损价，无法补挂", symbol, side)
+			}
+		}
+		if len(target.Ladder) > 0 {
+			if at.ladderIncomplete(symbol, target, openTakeProfits) {
+				log.Printf("🛡 %s %s 止盈阶梯缺少档位，按当前数量 %.4f 重新挂单", symbol, side, amount)
+				at.replaceTakeProfitLadder(symbol, positionSide, amount, target)
+			}
+		} else if !hasTakeProfit && target.TakeProfit > 0 {
+			log.Printf("🛡 %s %s 缺少止盈单，补挂止盈 %.4f 数量 %.4f", symbol, side, target.TakeProfit, amount)
+			if err := at.trader.SetTakeProfit(symbol, positionSide, amount, target.TakeProfit); err != nil {
+				log.Printf("  ⚠ 补挂止盈失败: %v", err)
+			}
+		}
+		// 没有原生跟踪止损单时重新设置（客户端跟踪的交易所重复设置只会重新确认当前止损）
+		if !hasTrailingStop {
+			at.placeTrailingStop(symbol, positionSide, amount, target)
+		}
+	}
+
+	// 已平仓的持仓不再跟踪，撤销残留的跟踪止损单（按数量下单，不会随持仓平掉自动失效）
+	for key, target := range at.protectiveTargets {
+		if open[key] {
+			continue
+		}
+		if target.TrailingStopPct > 0 {
+			at.cancelTrailingStopOrders(key)
+		}
+		at.targetsMu.Lock()
+		delete(at.protectiveTargets, key)
+		at.targetsMu.Unlock()
+	}
+}
+
+// ladderIncomplete 止盈阶梯是否有未成交的档位不在挂单中
+// 不在挂单中的档位先查询订单状态，已成交的（成交记录未同步到）标记为已成交，不会重新挂单
+func (at *AutoTrader) ladderIncomplete(symbol string, target protectiveTarget, openTakeProfits map[int64]bool) bool {
+	incomplete := false
+	for _, step := range target.Ladder {
+		if step.Filled || step.Trailing || openTakeProfits[step.OrderID] {
+			continue
+		}
+		if step.OrderID != 0 {
+			if order, err := at.trader.GetOrder(symbol, step.OrderID); err == nil && order["status"] == "FILLED" {
+				step.Filled = true
+				continue
+			}
+		}
+		incomplete = true
+	}
+	return incomplete
+}
+
+// cancelTrailingStopOrders 撤销已平仓持仓（symbol_side）残留的跟踪止损单
+func (at *AutoTrader) cancelTrailingStopOrders(key string) {
+	symbol, side, _ := strings.Cut(key, "_")
+	closingSide := "SELL"
+	if side == "short" {
+		closingSide = "BUY"
+	}
+	orders, err := at.trader.GetOpenOrders(symbol)
+	if err != nil {
+		log.Printf("⚠ 获取 %s 挂单失败，无法撤销残留的跟踪止损单: %v", symbol, err)
+		return
+	}
+	for _, order := range orders {
+		if order["type"] != "TRAILING_STOP_MARKET" || order["side"] != closingSide {
+			continue
+		}
+		orderID, _ := order["orderId"].(int64)
+		log.Printf("🧹 %s %s 已平仓，撤销残留的跟踪止损单 %d", symbol, side, orderID)
+		if err := at.trader.CancelOrder(symbol, orderID); err != nil {
+			log.Printf("  ⚠ 撤销跟踪止损单失败: %v", err)
+		}
+	}
+}
+
+// logNewFills 记录上次同步以来的成交（止损/止盈触发、限价单成交等），
+// 返回止盈阶梯各档的成交（按档统计部分离场）和交易所触发的止损/止盈平仓（记入决策日志）
+func (at *AutoTrader) logNewFills() []logger.DecisionAction {
+	since := at.lastFillCheck
+	now := time.Now()
+	fills, err := at.trader.GetFills(since)
+	if err != nil {
+		log.Printf("⚠ 获取成交记录失败: %v", err)
+		return nil
+	}
+	at.lastFillCheck = now
+	at.pruneLoggedOrders(now.Add(-loggedOrderRetention))
+
+	var actions []logger.DecisionAction
+	for _, fill := range fills {
+		price, _ := fill["price"].(float64)
+		quantity, _ := fill["quantity"].(float64)
+		realizedPnl, _ := fill["realizedPnl"].(float64)
+		log.Printf("💱 成交: %v %v 数量 %.4f 价格 %.4f 订单ID %v 已实现盈亏 %.2f",
+			fill["symbol"], fill["side"], quantity, price, fill["orderId"], realizedPnl)
+		if action := at.ladderFillAction(fill); action != nil {
+			actions = append(actions, *action)
+		} else if action := at.closeFillAction(fill, fills); action != nil {
+			actions = append(actions, *action)
+		}
+	}
+	return actions
+}
+
+// logNewFunding 同步上次检查以来的资金费收支，返回归属到各持仓的资金费动作（记入决策日志）
+// 交易所不提供持仓方向时按数量分摊到该币种的多空持仓；找不到对应持仓的资金费只打印日志
+func (at *AutoTrader) logNewFunding() []logger.DecisionAction {
+	since := at.lastFundingCheck
+	now := time.Now()
+	payments, err := at.trader.GetFundingPayments(since)
+	if err != nil {
+		log.Printf("⚠ 获取资金费记录失败: %v", err)
+		return nil
+	}
+	if len(payments) == 0 {
+		at.lastFundingCheck = now
+		return nil
+	}
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠ 获取持仓失败，资金费下个周期再归属: %v", err)
+		return nil
+	}
+	at.lastFundingCheck = now
+
+	quantities := make(map[string]float64) // symbol_side -> 持仓数量
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		quantity, _ := pos["positionAmt"].(float64)
+		quantities[symbol+"_"+side] += math.Abs(quantity)
+	}
+
+	var actions []logger.DecisionAction
+	for _, payment := range payments {
+		symbol, _ := payment["symbol"].(string)
+		side, _ := payment["side"].(string)
+		amount, _ := payment["amount"].(float64)
+		paidAt, _ := payment["time"].(int64)
+
+		sides := []string{side}
+		if side == "" {
+			sides = []string{"long", "short"}
+		}
+		total := 0.0
+		for _, s := range sides {
+			total += quantities[symbol+"_"+s]
+		}
+		if total == 0 {
+			log.Printf("💸 资金费: %s %+.4f %v（没有对应持仓，不计入交易）", symbol, amount, payment["asset"])
+			continue
+		}
+
+		for _, s := range sides {
+			quantity := quantities[symbol+"_"+s]
+			if quantity == 0 {
+				continue
+			}
+			share := amount * quantity / total
+			log.Printf("💸 资金费: %s %s %+.4f %v", symbol, s, share, payment["asset"])
+			actions = append(actions, logger.DecisionAction{
+				Action:    "funding",
+				Symbol:    symbol,
+				Side:      s,
+				Funding:   share,
+				Timestamp: time.UnixMilli(paidAt),
+				Success:   true,
+			})
+		}
+	}
+	return actions
+}
+
+// fundingQuote 币种当前的资金费率和下次结算时间
+type fundingQuote struct {
+	rate float64
+	next time.Time
+}
+
+// queryFundingQuote 查询币种的资金费率，失败时返回nil
+func (at *AutoTrader) queryFundingQuote(symbol string) *fundingQuote {
+	rate, next, err := at.trader.GetFundingRate(symbol)
+	if err != nil {
+		log.Printf("⚠ 获取%s资金费率失败: %v", symbol, err)
+		return nil
+	}
+	return &fundingQuote{rate: rate, next: next}
+}
+
+// watchFills 实时记录推送的成交（止损/止盈触发单独提示）
+// 交易所触发的止损/止盈平仓暂存后写入下个周期的决策记录；止盈阶梯各档的成交由下个周期的logNewFills记录
+func (at *AutoTrader) watchFills(fills <-chan map[string]interface{}) {
+	for fill := range fills {
+		price, _ := fill["price"].(float64)
+		quantity, _ := fill["quantity"].(float64)
+		realizedPnl, _ := fill["realizedPnl"].(float64)
+		orderType, _ := fill["orderType"].(string)
+		switch {
+		case strings.HasPrefix(orderType, "STOP"), strings.HasPrefix(orderType, "TRAILING"):
+			log.Printf("🛑 止损触发: %v %v 数量 %.4f 价格 %.4f 已实现盈亏 %.2f",
+				fill["symbol"], fill["side"], quantity, price, realizedPnl)
+		case strings.HasPrefix(orderType, "TAKE_PROFIT"):
+			log.Printf("🎯 止盈触发: %v %v 数量 %.4f 价格 %.4f 已实现盈亏 %.2f",
+				fill["symbol"], fill["side"], quantity, price, realizedPnl)
+		default:
+			log.Printf("⚡ 实时成交: %v %v 数量 %.4f 价格 %.4f 订单ID %v",
+				fill["symbol"], fill["side"], quantity, price, fill["orderId"])
+			// 订单类型未知时（Hyperliquid没见过的订单）由closeFillAction查询，其他订单不是交易所触发的平仓
+			if orderType != "" {
+				continue
+			}
+		}
+		at.queueStreamClose(fill)
+	}
+}
+
+// queueStreamClose 推送的成交属于交易所触发的平仓时暂存，由下个交易周期（或紧急平仓）写入决策记录
+// 不等待进行中的交易周期（周期内等待AI时也要及时消费推送），只持有去重和止损止盈目标的锁
+func (at *AutoTrader) queueStreamClose(fill map[string]interface{}) {
+	if orderID, _ := fill["orderId"].(int64); at.isLadderOrder(orderID) {
+		return
+	}
+	action := at.closeFillAction(fill, []map[string]interface{}{fill})
+	if action == nil {
+		return
+	}
+	at.loggedOrdersMu.Lock()
+	defer at.loggedOrdersMu.Unlock()
+	at.streamCloses = append(at.streamCloses, *action)
+}
+
+// takeStreamCloses 取出暂存的推送平仓
+func (at *AutoTrader) takeStreamCloses() []logger.DecisionAction {
+	at.loggedOrdersMu.Lock()
+	defer at.loggedOrdersMu.Unlock()
+	closes := at.streamCloses
+	at.streamCloses = nil
+	return closes
+}
+
+// closeFillAction 成交来自交易所触发的止损/止盈/跟踪止损单时返回对应的平仓动作（持仓方向按成交方向判断）
+// 成交没有orderType时查询订单类型；同一订单只记录一次（fills中属于该订单的成交按数量加权汇总），
+// 本交易员自己下的单已在执行决策时记录，不会再记为平仓
+func (at *AutoTrader) closeFillAction(fill map[string]interface{}, fills []map[string]interface{}) *logger.DecisionAction {
+	orderID, _ := fill["orderId"].(int64)
+	symbol, _ := fill["symbol"].(string)
+	// 先占用订单：推送和周期同步并发处理同一订单时只有一方记录；其他订单（如手动下单）之后也不再查询
+	if orderID == 0 || !at.claimOrder(orderID) {
+		return nil
+	}
+	orderType, _ := fill["orderType"].(string)
+	if orderType == "" {
+		order, err := at.trader.GetOrder(symbol, orderID)
+		if err != nil {
+			log.Printf("⚠ 查询成交订单%d的类型失败: %v", orderID, err)
+			at.releaseOrder(orderID)
+			return nil
+		}
+		orderType, _ = order["type"].(string)
+	}
+
+	side := "long"
+	if fillSide, _ := fill["side"].(string); fillSide == "BUY" {
+		side = "short"
+	}
+	at.targetsMu.Lock()
+	target := at.protectiveTargets[symbol+"_"+side]
+	ladder := len(target.Ladder) > 0
+	at.targetsMu.Unlock()
+	var reason string
+	var expected float64
+	switch {
+	case strings.HasPrefix(orderType, "TRAILING"):
+		reason = "跟踪止损触发"
+	case strings.HasPrefix(orderType, "STOP"):
+		reason, expected = "止损触发", target.StopLoss
+	case strings.HasPrefix(orderType, "TAKE_PROFIT") && !ladder:
+		reason, expected = "止盈触发", target.TakeProfit
+	default:
+		// 止盈阶梯的一档（订单ID未能对应到档位）只平掉部分持仓，其他订单不是交易所触发的平仓
+		return nil
+	}
+
+	execution := make(map[string]interface{})
+	addFillsExecution(execution, fills, orderID)
+	price, _ := execution["avgPrice"].(float64)
+	quantity, _ := execution["executedQty"].(float64)
+	fee, _ := execution["fee"].(float64)
+	feeAsset, _ := execution["feeAsset"].(string)
+	fillTime, _ := fill["time"].(int64)
+	action := &logger.DecisionAction{
+		Action:    "close_" + side,
+		Symbol:    symbol,
+		Side:      side,
+		Quantity:  quantity,
+		Price:     price,
+		OrderID:   orderID,
+		Timestamp: time.UnixMilli(fillTime),
+		Success:   true,
+		Reasoning: reason,
+
+		Fee:      fee,
+		FeeAsset: feeAsset,
+		Exchange: at.exchange,
+	}
+	if expected > 0 {
+		action.ExpectedPrice = expected
+		action.SlippageBps = slippageBps(expected, price, side == "short")
+	}
+	log.Printf("🛑 %s %s %s，交易所已平仓: 数量 %.4f 价格 %.4f", symbol, side, reason, quantity, price)
+	return action
+}
+
+// closeFillLog 交易所触发平仓的执行日志
+func closeFillLog(action logger.DecisionAction) string {
+	return fmt.Sprintf("🛑 %s %s %s，平仓 %.4f @ %.4f", action.Symbol, action.Side, action.Reasoning, action.Quantity, action.Price)
+}
+
+// loggedOrderRetention 已记录订单的保留时间（只用于对同一订单的成交去重，超过后清理）
+const loggedOrderRetention = 24 * time.Hour
+
+// markOrderLogged 标记订单已记入决策日志（orderID为0时忽略）
+func (at *AutoTrader) markOrderLogged(orderID int64) {
+	if orderID == 0 {
+		return
+	}
+	at.loggedOrdersMu.Lock()
+	defer at.loggedOrdersMu.Unlock()
+	at.loggedOrders[orderID] = time.Now()
+}
+
+// claimOrder 订单尚未记入决策日志时标记为已记录并返回true，已记录时返回false
+func (at *AutoTrader) claimOrder(orderID int64) bool {
+	at.loggedOrdersMu.Lock()
+	defer at.loggedOrdersMu.Unlock()
+	if _, ok := at.loggedOrders[orderID]; ok {
+		return false
+	}
+	at.loggedOrders[orderID] = time.Now()
+	return true
+}
+
+// releaseOrder 撤销claimOrder的标记（之后再处理该订单的成交）
+func (at *AutoTrader) releaseOrder(orderID int64) {
+	at.loggedOrdersMu.Lock()
+	defer at.loggedOrdersMu.Unlock()
+	delete(at.loggedOrders, orderID)
+}
+
+// pruneLoggedOrders 清理before之前记录的订单
+func (at *AutoTrader) pruneLoggedOrders(before time.Time) {
+	at.loggedOrdersMu.Lock()
+	defer at.loggedOrdersMu.Unlock()
+	for orderID, loggedAt := range at.loggedOrders {
+		if loggedAt.Before(before) {
+			delete(at.loggedOrders, orderID)
+		}
+	}
+}
+
+// setProtectiveTarget 记录持仓的止损止盈目标
+func (at *AutoTrader) setProtectiveTarget(key string, target protectiveTarget) {
+	at.targetsMu.Lock()
+	defer at.targetsMu.Unlock()
+	at.protectiveTargets[key] = target
+}
+
+// isLadderOrder 订单是否为止盈阶梯某一档的止盈单
+func (at *AutoTrader) isLadderOrder(orderID int64) bool {
+	if orderID == 0 {
+		return false
+	}
+	at.targetsMu.Lock()
+	defer at.targetsMu.Unlock()
+	for _, target := range at.protectiveTargets {
+		for _, step := range target.Ladder {
+			if step.OrderID == orderID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ladderFillAction 成交属于止盈阶梯的某一档时标记该档已成交，并返回对应的决策动作
+func (at *AutoTrader) ladderFillAction(fill map[string]interface{}) *logger.DecisionAction {
+	orderID, _ := fill["orderId"].(int64)
+	if orderID == 0 {
+		return nil
+	}
+	for key, target := range at.protectiveTargets {
+		for i, step := range target.Ladder {
+			if step.OrderID != orderID {
+				continue
+			}
+			step.Filled = true
+			symbol, side, _ := strings.Cut(key, "_")
+			price, _ := fill["price"].(float64)
+			quantity, _ := fill["quantity"].(float64)
+			fillTime, _ := fill["time"].(int64)
+			fee, _ := fill["fee"].(float64)
+			feeAsset, _ := fill["feeAsset"].(string)
+			log.Printf("🎯 %s %s 第%d档止盈成交: 数量 %.4f 价格 %.4f", symbol, side, i+1, quantity, price)
+			return &logger.DecisionAction{
+				Action:     "take_profit_level",
+				Symbol:     symbol,
+				Side:       side,
+				Quantity:   quantity,
+				Price:      price,
+				OrderID:    orderID,
+				Timestamp:  time.UnixMilli(fillTime),
+				Success:    true,
+				TakeProfit: step.Price,
+				Level:      i + 1,
+
+				ExpectedPrice: step.Price,
+				SlippageBps:   slippageBps(step.Price, price, side == "short"),
+				Fee:           fee,
+				FeeAsset:      feeAsset,
+				Exchange:      at.exchange,
+			}
+		}
+	}
+	return nil
+}
+
+// pendingOrderInfos 挂单中的限价开仓单（提供给AI参考）
+func (at *AutoTrader) pendingOrderInfos() []decision.PendingOrderInfo {
+	var infos []decision.PendingOrderInfo
+	for _, entry := range at.pendingEntries {
+		infos = append(infos, decision.PendingOrderInfo{
+			Symbol:     entry.Symbol,
+			Side:       entry.Side,
+			Price:      entry.Price,
+			Quantity:   entry.Quantity,
+			StopLoss:   entry.Target.StopLoss,
+			TakeProfit: entry.Target.TakeProfit,
+			AgeMinutes: int(time.Since(entry.PlacedAt).Minutes()),
+			TTLMinutes: int(at.config.EntryOrderTTL.Minutes()),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Symbol+infos[i].Side < infos[j].Symbol+infos[j].Side
+	})
+	return infos
+}
+
+// executeUpdateProtectiveWithRecord 调整持仓的止损或止盈（替换交易所上的条件单）
+func (at *AutoTrader) executeUpdateProtectiveWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	isStopLoss := decision.Action == "update_stop_loss"
+	label, newPrice := "止盈", decision.TakeProfit
+	if isStopLoss {
+		label, newPrice = "止损", decision.StopLoss
+	}
+	log.Printf("  🛡 调整%s: %s → %.4f", label, decision.Symbol, newPrice)
+
+	pos, err := at.findDecisionPosition(decision, "调整"+label)
+	if err != nil {
+		return err
+	}
+
+	side, _ := pos["side"].(string)
+	quantity, _ := pos["positionAmt"].(float64)
+	markPrice, _ := pos["markPrice"].(float64)
+	if markPrice <= 0 {
+		if markPrice, err = at.trader.GetMarketPrice(decision.Symbol); err != nil {
+			return err
+		}
+	}
+
+	// 新价格必须在标记价格的正确一侧，否则会立即触发（多仓：止损 < 标记价 < 止盈，空仓相反）
+	belowMark := (side == "long") == isStopLoss
+	if belowMark && newPrice >= markPrice {
+		return fmt.Errorf("%s %s仓的新%s(%.4f)必须低于当前标记价格(%.4f)", decision.Symbol, side, label, newPrice, markPrice)
+	}
+	if !belowMark && newPrice <= markPrice {
+		return fmt.Errorf("%s %s仓的新%s(%.4f)必须高于当前标记价格(%.4f)", decision.Symbol, side, label, newPrice, markPrice)
+	}
+
+	actionRecord.Side = side
+	actionRecord.Quantity = quantity
+	actionRecord.Price = markPrice
+
+	positionSide := positionSideFromSide(side)
+	if isStopLoss {
+		err = at.trader.UpdateStopLoss(decision.Symbol, positionSide, quantity, newPrice)
+	} else {
+		err = at.trader.UpdateTakeProfit(decision.Symbol, positionSide, quantity, newPrice)
+	}
+	if err != nil {
+		return fmt.Errorf("调整%s失败: %w", label, err)
+	}
+
+	// 后续周期按新价格补挂缺失的条件单
+	key := decision.Symbol + "_" + side
+	target := at.protectiveTargets[key]
+	if isStopLoss {
+		target.StopLoss = newPrice
+	} else {
+		// 单张止盈单替换了整个止盈阶梯，跟踪止损改为覆盖整个持仓
+		if len(target.Ladder) > 0 {
+			log.Printf("  止盈阶梯已替换为单一止盈")
+			target.Ladder = nil
+		}
+		target.TakeProfit = newPrice
+	}
+	at.setProtectiveTarget(key, target)
+
+	log.Printf("  ✓ %s %s仓%s已调整为 %.4f（标记价格 %.4f，数量 %.4f）", decision.Symbol, side, label, newPrice, markPrice, quantity)
+	return nil
+}
+
+// findDecisionPosition 找出决策要操作的持仓（按币种和可选的side，同币种同时有多空仓时必须指定side）
+func (at *AutoTrader) findDecisionPosition(decision *decision.Decision, operation string) (map[string]interface{}, error) {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+	var matched []map[string]interface{}
+	for _, pos := range positions {
+		if pos["symbol"] != decision.Symbol {
+			continue
+		}
+		if decision.Side != "" && pos["side"] != decision.Side {
+			continue
+		}
+		matched = append(matched, pos)
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("%s 没有可%s的持仓: %w", decision.Symbol, operation, ErrNoPosition)
+	}
+	if len(matched) > 1 {
+		return nil, fmt.Errorf("%s 同时有多仓和空仓，需要用side指定%s哪个方向", decision.Symbol, operation)
+	}
+	return matched[0], nil
+}
+
+// positionAmount 查询持仓的当前数量（持仓不存在返回0）
+func (at *AutoTrader) positionAmount(symbol, side string) (float64, error) {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return 0, err
+	}
+	for _, pos := range positions {
+		if pos["symbol"] == symbol && pos["side"] == side {
+			amount, _ := pos["positionAmt"].(float64)
+			return amount, nil
+		}
+	}
+	return 0, nil
+}
+
+// maxPositionValue 单币种仓位价值上限（与决策验证使用相同的规则）
+func (at *AutoTrader) maxPositionValue(symbol string, accountEquity float64) float64 {
+	_, maxValue := decision.PositionLimits(symbol, accountEquity, at.config.BTCETHLeverage, at.config.AltcoinLeverage)
+	return maxValue
+}
+
+// resizeProtectiveOrders 按持仓的新数量替换该方向的止损止盈（失败只记录日志，后续周期补挂）
+func (at *AutoTrader) resizeProtectiveOrders(symbol, side string, quantity float64) {
+	target := at.protectiveTargets[symbol+"_"+side]
+	positionSide := positionSideFromSide(side)
+	if target.StopLoss > 0 {
+		if err := at.trader.UpdateStopLoss(symbol, positionSide, quantity, target.StopLoss); err != nil {
+			log.Printf("  ⚠ 按新数量调整止损失败: %v", err)
+		}
+	} else {
+		log.Printf("  ⚠ %s %s 没有记录的止损价，无法按新数量挂止损", symbol, side)
+	}
+	if len(target.Ladder) > 0 {
+		at.replaceTakeProfitLadder(symbol, positionSide, quantity, target)
+	} else if target.TakeProfit > 0 {
+		if err := at.trader.UpdateTakeProfit(symbol, positionSide, quantity, target.TakeProfit); err != nil {
+			log.Printf("  ⚠ 按新数量调整止盈失败: %v", err)
+		}
+	}
+	at.placeTrailingStop(symbol, positionSide, quantity, target)
+}
+
+// executePartialCloseWithRecord 按比例或数量部分平仓，剩余仓位的止损止盈按新数量调整
+func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	log.Printf("  ✂️ 部分平仓: %s", decision.Symbol)
+
+	pos, err := at.findDecisionPosition(decision, "部分平仓")
+	if err != nil {
+		return err
+	}
+	side, _ := pos["side"].(string)
+	amount, _ := pos["positionAmt"].(float64)
+
+	quantity := decision.CloseQuantity
+	if decision.ClosePercentage > 0 {
+		quantity = amount * decision.ClosePercentage / 100
+	}
+	if quantity <= 0 {
+		return fmt.Errorf("部分平仓数量必须大于0: %w", ErrInvalidQuantity)
+	}
+	if quantity >= amount {
+		return fmt.Errorf("%s %s仓部分平仓数量(%.4f)不小于持仓数量(%.4f)，全部平仓请用close_%s", decision.Symbol, side, quantity, amount, side)
+	}
+
+	// 获取当前价格
+	marketData, err := market.Get(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	actionRecord.Side = side
+	actionRecord.Quantity = quantity
+	actionRecord.Price = marketData.CurrentPrice
+
+	var order map[string]interface{}
+	if side == "long" {
+		order, err = at.trader.CloseLong(decision.Symbol, quantity)
+	} else {
+		order, err = at.trader.CloseShort(decision.Symbol, quantity)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 记录订单ID
+	if orderID, ok := order["orderId"].(int64); ok {
+		actionRecord.OrderID = orderID
+	}
+	recordExecution(actionRecord, order, side == "short")
+
+	// 按交易所实际剩余数量（已按步长取整）调整止损止盈
+	remaining, err := at.positionAmount(decision.Symbol, side)
+	if err != nil {
+		log.Printf("  ⚠ 获取剩余持仓失败，按计算值调整止损止盈: %v", err)
+		remaining = amount - quantity
+	}
+	if remaining > 0 {
+		at.resizeProtectiveOrders(decision.Symbol, side, remaining)
+	}
+
+	log.Printf("  ✓ 部分平仓成功，平仓数量: %.4f，剩余: %.4f", quantity, remaining)
+	return nil
+}
+
+// executeAddToPositionWithRecord 对已有持仓加仓（杠杆须与现有持仓一致，检查仓位上限和可用保证金），止损止盈按新数量调整
+func (at *AutoTrader) executeAddToPositionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	log.Printf("  ➕ 加仓: %s", decision.Symbol)
+
+	pos, err := at.findDecisionPosition(decision, "加仓")
+	if err != nil {
+		return err
+	}
+	side, _ := pos["side"].(string)
+	amount, _ := pos["positionAmt"].(float64)
+	key := decision.Symbol + "_" + side
+	if _, exists := at.pendingEntries[key]; exists {
+		return fmt.Errorf("❌ %s 已有挂单中的限价开仓单，拒绝加仓", decision.Symbol)
+	}
+
+	// 杠杆检查：交易所按币种设置杠杆，加仓时改杠杆会改变整个持仓的保证金
+	if lev, ok := pos["leverage"].(float64); ok && lev > 0 && int(lev) != decision.Leverage {
+		return fmt.Errorf("%s 加仓杠杆(%dx)必须与现有持仓杠杆(%dx)一致", decision.Symbol, decision.Leverage, int(lev))
+	}
+
+	// 获取当前价格
+	marketData, err := market.Get(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	price := marketData.CurrentPrice
+
+	// 保证金检查：加仓后总仓位价值不超过单币种上限，新增保证金不超过可用余额
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	unrealized, _ := balance["totalUnrealizedProfit"].(float64)
+	available, _ := balance["availableBalance"].(float64)
+	maxValue := at.maxPositionValue(decision.Symbol, wallet+unrealized)
+	totalValue := amount*price + decision.PositionSizeUSD
+	if totalValue > maxValue*1.01 {
+		return fmt.Errorf("%s 加仓后仓位价值(%.0f USDT)超过单币种上限(%.0f USDT)", decision.Symbol, totalValue, maxValue)
+	}
+	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
+	if requiredMargin > available {
+		return fmt.Errorf("%s 加仓需要保证金%.2f USDT，可用余额仅%.2f USDT", decision.Symbol, requiredMargin, available)
+	}
+
+	// 新的止损止盈（可选）必须在当前价格的正确一侧
+	if decision.StopLoss > 0 && (side == "long") != (decision.StopLoss < price) {
+		return fmt.Errorf("%s %s仓的新止损(%.4f)在当前价格(%.4f)错误的一侧", decision.Symbol, side, decision.StopLoss, price)
+	}
+	if decision.TakeProfit > 0 && (side == "long") != (decision.TakeProfit > price) {
+		return fmt.Errorf("%s %s仓的新止盈(%.4f)在当前价格(%.4f)错误的一侧", decision.Symbol, side, decision.TakeProfit, price)
+	}
+
+	// 计算数量
+	quantity := decision.PositionSizeUSD / price
+	actionRecord.Side = side
+	actionRecord.Quantity = quantity
+	actionRecord.Price = price
+
+	var order map[string]interface{}
+	if side == "long" {
+		order, err = at.trader.OpenLong(decision.Symbol, quantity, decision.Leverage)
+	} else {
+		order, err = at.trader.OpenShort(decision.Symbol, quantity, decision.Leverage)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 记录订单ID
+	if orderID, ok := order["orderId"].(int64); ok {
+		actionRecord.OrderID = orderID
+	}
+	recordExecution(actionRecord, order, side == "long")
+
+	// 更新止损止盈目标价（未给出的沿用原目标价），并记录到决策日志
+	target := at.protectiveTargets[key]
+	if decision.StopLoss > 0 {
+		target.StopLoss = decision.StopLoss
+	}
+	if decision.TakeProfit > 0 {
+		target.TakeProfit = decision.TakeProfit
+		target.Ladder = nil // 新的止盈价代替原来的止盈阶梯
+	}
+	at.setProtectiveTarget(key, target)
+	actionRecord.StopLoss = target.StopLoss
+	actionRecord.TakeProfit = target.TakeProfit
+
+	total, err := at.positionAmount(decision.Symbol, side)
+	if err != nil {
+		log.Printf("  ⚠ 获取加仓后持仓失败，按计算值调整止损止盈: %v", err)
+		total = amount + quantity
+	}
+	at.resizeProtectiveOrders(decision.Symbol, side, total)
+
+	log.Printf("  ✓ 加仓成功，订单ID: %v, 加仓数量: %.4f，总数量: %.4f", order["orderId"], quantity, total)
+	return nil
+}
+
+// executeCloseLongWithRecord 执行平多仓并记录详细信息
+func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	log.Printf("  🔄 平多仓: %s", decision.Symbol)
+
+	// 获取当前价格
+	marketData, err := market.Get(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	actionRecord.Price = marketData.CurrentPrice
+
+	// 平仓
+	order, err := at.trader.CloseLong(decision.Symbol, 0) // 0 = 全部平仓
+	if err != nil {
+		return err
+	}
+
+	// 记录订单ID
+	if orderID, ok := order["orderId"].(int64); ok {
+		actionRecord.OrderID = orderID
+	}
+	recordExecution(actionRecord, order, false)
+
+	log.Printf("  ✓ 平仓成功")
+	return nil
+}
+
+// executeCloseShortWithRecord 执行平空仓并记录详细信息
+func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	log.Printf("  🔄 平空仓: %s", decision.Symbol)
+
+	// 获取当前价格
+	marketData, err := market.Get(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	actionRecord.Price = marketData.CurrentPrice
+
+	// 平仓
+	order, err := at.trader.CloseShort(decision.Symbol, 0) // 0 = 全部平仓
+	if err != nil {
+		return err
+	}
+
+	// 记录订单ID
+	if orderID, ok := order["orderId"].(int64); ok {
+		actionRecord.OrderID = orderID
+	}
+	recordExecution(actionRecord, order, true)
+
+	log.Printf("  ✓ 平仓成功")
+	return nil
+}
+
+// GetID 获取trader ID
+func (at *AutoTrader) GetID() string {
+	return at.id
+}
+
+// GetName 获取trader名称
+func (at *AutoTrader) GetName() string {
+	return at.name
+}
+
+// GetAIModel 获取AI模型
+func (at *AutoTrader) GetAIModel() string {
+	return at.aiModel
+}
+
+// GetDecisionLogger 获取决策日志记录器
+func (at *AutoTrader) GetDecisionLogger() *logger.DecisionLogger {
+	return at.decisionLogger
+}
+
+// GetStatus 获取系统状态（用于API）
+func (at *AutoTrader) GetStatus() map[string]interface{} {
+	aiProvider := "DeepSeek"
+	if at.config.UseQwen {
+		aiProvider = "Qwen"
+	}
+
+	var ensembleModels []string
+	for _, client := range at.ensembleClients {
+		ensembleModels = append(ensembleModels, client.Name())
 	}
 
 	return map[string]interface{}{
@@ -765,7 +2464,7 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		"trader_name":     at.name,
 		"ai_model":        at.aiModel,
 		"exchange":        at.exchange,
-		"is_running":      at.isRunning,
+		"is_running":      at.isRunning.Load(),
 		"start_time":      at.startTime.Format(time.RFC3339),
 		"runtime_minutes": int(time.Since(at.startTime).Minutes()),
 		"call_count":      at.callCount,
@@ -774,6 +2473,9 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		"stop_until":      at.stopUntil.Format(time.RFC3339),
 		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
 		"ai_provider":     aiProvider,
+		"ensemble_models": ensembleModels,
+		"prompt_version":  at.promptTemplate.Version,
+		"prompt_language": at.promptTemplate.Language,
 	}
 }
 
@@ -910,7 +2612,7 @@ func (at *AutoTrader) GetPositions() ([]map[string]interface{}, error) {
 	return result, nil
 }
 
-// sortDecisionsByPriority 对决策排序：先平仓，再开仓，最后hold/wait
+// sortDecisionsByPriority 对决策排序：先平仓，再调整止损止盈，再开仓，最后hold/wait
 // 这样可以避免换仓时仓位叠加超限
 func sortDecisionsByPriority(decisions []decision.Decision) []decision.Decision {
 	if len(decisions) <= 1 {
@@ -922,10 +2624,14 @@ func sortDecisionsByPriority(decisions []decision.Decision) []decision.Decision
 		switch action {
 		case "close_long", "close_short":
 			return 1 // 最高优先级：先平仓
-		case "open_long", "open_short":
-			return 2 // 次优先级：后开仓
+		case "partial_close":
+			return 1 // 部分平仓与平仓同级，先释放保证金
+		case "update_stop_loss", "update_take_profit":
+			return 2 // 调整现有持仓的止损止盈
+		case "open_long", "open_short", "add_to_position":
+			return 3 // 后开仓/加仓
 		case "hold", "wait":
-			return 3 // 最低优先级：观望
+			return 4 // 最低优先级：观望
 		default:
 			return 999 // 未知动作放最后
 		}
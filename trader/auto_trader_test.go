@@ -0,0 +1,469 @@
+package trader
+
+import (
+	"danto/decision"
+	"danto/logger"
+	"danto/market"
+	"danto/mcp"
+	"danto/pool"
+	"danto/trader/exchangetest"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newTestAutoTrader 使用测试交易器（FakeTrader或连接exchangetest的交易器）和脚本化AI客户端创建AutoTrader（决策日志写到临时目录）
+func newTestAutoTrader(t *testing.T, trader Trader, responses ...string) *AutoTrader {
+	t.Helper()
+	at, err := NewAutoTrader(AutoTraderConfig{
+		ID:             "test",
+		Name:           "test",
+		Exchange:       "binance",
+		InitialBalance: 1000,
+		ScanInterval:   time.Minute,
+		Trader:         trader,
+		AIClient:       mcp.NewScriptedClient(responses...),
+		DecisionLogDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("NewAutoTrader: %v", err)
+	}
+	return at
+}
+
+func TestLimitEntryLoggedAsOpenOnlyWhenFilled(t *testing.T) {
+	fake := NewFakeTrader(1000, map[string]float64{"BTCUSDT": 100, "ETHUSDT": 100})
+	at := newTestAutoTrader(t, fake)
+
+	limitLong := func(symbol string) *decision.Decision {
+		return &decision.Decision{
+			Symbol: symbol, Action: "open_long", OrderType: "limit", EntryPrice: 95,
+			Leverage: 5, PositionSizeUSD: 95, StopLoss: 90, TakeProfit: 120, Reasoning: "回踩做多",
+		}
+	}
+	place := func(symbol string) logger.DecisionAction {
+		d := limitLong(symbol)
+		record := logger.DecisionAction{Action: d.Action, Symbol: d.Symbol, Leverage: d.Leverage, Reasoning: d.Reasoning, Success: true}
+		if err := at.executeOpenLongWithRecord(d, &record); err != nil {
+			t.Fatalf("限价开仓失败: %v", err)
+		}
+		return record
+	}
+
+	if record := place("BTCUSDT"); record.Action != "place_entry" || record.Side != "long" {
+		t.Fatalf("挂单不应记为开仓: %+v", record)
+	}
+	if actions := at.reconcilePendingEntries(); len(actions) != 0 {
+		t.Fatalf("未成交时不应记录动作: %+v", actions)
+	}
+
+	// 价格穿过挂单价：按成交数量和成交价记为开仓（保留开仓理由）
+	fake.SetPrice("BTCUSDT", 94)
+	actions := at.reconcilePendingEntries()
+	if len(actions) != 1 || actions[0].Action != "open_long" || actions[0].Quantity != 1 ||
+		actions[0].Price != 95 || actions[0].Leverage != 5 || actions[0].Reasoning != "回踩做多" {
+		t.Fatalf("成交后应记录开仓: %+v", actions)
+	}
+
+	// 超时未成交：撤单并记录cancel_entry
+	place("ETHUSDT")
+	at.config.EntryOrderTTL = 0
+	actions = at.reconcilePendingEntries()
+	if len(actions) != 1 || actions[0].Action != "cancel_entry" || actions[0].Quantity != 1 || actions[0].Side != "long" {
+		t.Fatalf("超时应记录撤单: %+v", actions)
+	}
+	for _, order := range fake.Orders() {
+		if order.Symbol == "ETHUSDT" && order.Type == "LIMIT" {
+			t.Fatalf("超时的限价单应被撤销: %+v", order)
+		}
+	}
+}
+
+func TestPlaceProtectiveOrdersKeepsOppositeSide(t *testing.T) {
+	fake := NewFakeTrader(1000, map[string]float64{"BTCUSDT": 100})
+	at := newTestAutoTrader(t, fake)
+
+	if _, err := fake.OpenShort("BTCUSDT", 1, 5); err != nil {
+		t.Fatal(err)
+	}
+	at.placeProtectiveOrders("BTCUSDT", "SHORT", 1, protectiveTarget{StopLoss: 110, TakeProfit: 80})
+
+	if _, err := fake.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatal(err)
+	}
+	// 多仓方向残留的止盈单（如之前的多仓止损触发后留下的）
+	if err := fake.SetTakeProfit("BTCUSDT", "LONG", 1, 130); err != nil {
+		t.Fatal(err)
+	}
+	at.placeProtectiveOrders("BTCUSDT", "LONG", 1, protectiveTarget{StopLoss: 90, TakeProfit: 120})
+
+	count := make(map[string]int)
+	for _, order := range fake.Orders() {
+		count[order.PositionSide+" "+order.Type]++
+		if order.Type == "TAKE_PROFIT" && order.Price == 130 {
+			t.Errorf("多仓残留的止盈单应被撤销: %+v", order)
+		}
+	}
+	for _, key := range []string{"SHORT STOP_LOSS", "SHORT TAKE_PROFIT", "LONG STOP_LOSS", "LONG TAKE_PROFIT"} {
+		if count[key] != 1 {
+			t.Errorf("%s 数量 = %d, 期望1: %v", key, count[key], count)
+		}
+	}
+}
+
+// openTestLong 开多仓并挂止损止盈，开仓动作写入决策日志
+func openTestLong(t *testing.T, at *AutoTrader, symbol string, quantity float64, target protectiveTarget) {
+	t.Helper()
+	order, err := at.trader.OpenLong(symbol, quantity, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderID, _ := order["orderId"].(int64)
+	at.markOrderLogged(orderID)
+	at.setProtectiveTarget(symbol+"_long", target)
+	at.placeProtectiveOrders(symbol, "LONG", quantity, target)
+	price, _ := at.trader.GetMarketPrice(symbol)
+	err = at.decisionLogger.LogDecision(&logger.DecisionRecord{Success: true, Decisions: []logger.DecisionAction{{
+		Action: "open_long", Symbol: symbol, Quantity: quantity, Leverage: 5, Price: price,
+		OrderID: orderID, Timestamp: time.Now(), Success: true,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// closeActions 决策日志中全部的平仓动作
+func closeActions(t *testing.T, at *AutoTrader) []logger.DecisionAction {
+	t.Helper()
+	records, err := at.decisionLogger.GetLatestRecords(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closes []logger.DecisionAction
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if action.Action == "close_long" || action.Action == "close_short" {
+				closes = append(closes, action)
+			}
+		}
+	}
+	return closes
+}
+
+func TestExchangeStopFillRecordedAsClose(t *testing.T) {
+	fake := NewFakeTrader(1000, map[string]float64{"BTCUSDT": 100})
+	at := newTestAutoTrader(t, fake)
+	openTestLong(t, at, "BTCUSDT", 1, protectiveTarget{StopLoss: 90, TakeProfit: 120})
+
+	// 止损单在交易所触发：下个周期同步成交时记为平仓（成交没有orderType时按订单类型判断）
+	fake.SetPrice("BTCUSDT", 89)
+	actions := at.logNewFills()
+	if len(actions) != 1 || actions[0].Action != "close_long" || actions[0].Side != "long" ||
+		actions[0].Quantity != 1 || actions[0].Price <= 0 || actions[0].ExpectedPrice != 90 || actions[0].Reasoning != "止损触发" {
+		t.Fatalf("止损触发应记为平仓: %+v", actions)
+	}
+	if err := at.decisionLogger.LogDecision(&logger.DecisionRecord{Success: true, Decisions: actions}); err != nil {
+		t.Fatal(err)
+	}
+
+	trades, err := at.decisionLogger.GetTradeHistory("BTCUSDT", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].ClosePrice != actions[0].Price || trades[0].PnL >= 0 {
+		t.Fatalf("账本应记录止损平仓的亏损交易: %+v", trades)
+	}
+	theses, err := at.decisionLogger.GetOpenPositionTheses(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(theses) != 0 {
+		t.Fatalf("止损平仓后账本不应有未平仓持仓: %+v", theses)
+	}
+}
+
+func TestStreamStopFillRecordedOnce(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 100, "ETHUSDT": 100}
+	fake := NewFakeTrader(1000, prices)
+	at := newTestAutoTrader(t, fake, `观望。[{"symbol": "BTCUSDT", "action": "wait", "reasoning": "观望"}]`)
+	openTestLong(t, at, "BTCUSDT", 1, protectiveTarget{StopLoss: 90, TakeProfit: 120})
+
+	// 本交易员自己的平仓单不会被当作交易所触发的平仓
+	if _, err := fake.OpenLong("ETHUSDT", 1, 5); err != nil {
+		t.Fatal(err)
+	}
+	order, err := fake.CloseLong("ETHUSDT", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownClose, _ := order["orderId"].(int64)
+	at.markOrderLogged(ownClose)
+
+	fake.SetPrice("BTCUSDT", 89)
+	fills, err := fake.GetFills(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := make(chan map[string]interface{}, len(fills)*2)
+	for _, fill := range fills {
+		fill["orderType"] = "MARKET"
+		if fill["symbol"] == "BTCUSDT" && fill["side"] == "SELL" {
+			fill["orderType"] = "STOP_MARKET"
+		}
+		// 推送重连后可能重复推送同一笔成交
+		stream <- fill
+		stream <- fill
+	}
+	close(stream)
+	at.watchFills(stream)
+
+	// 推送的平仓不单独写决策记录，写入下个周期的记录（带账户快照，周期编号连续）
+	if closes := closeActions(t, at); len(closes) != 0 {
+		t.Fatalf("推送的平仓不应单独写决策记录: %+v", closes)
+	}
+	prices["BTCUSDT"] = 89
+	serveTestMarket(t, prices, []string{"BTCUSDT"})
+	at.config.BTCETHLeverage = 10
+	if err := at.runCycle(t.Context()); err != nil {
+		t.Fatalf("runCycle: %v", err)
+	}
+
+	records, err := at.decisionLogger.GetLatestRecords(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("应只有开仓记录和一个周期记录: %d", len(records))
+	}
+	cycle := records[1]
+	if cycle.CycleNumber != 2 || cycle.AccountState.TotalBalance <= 0 {
+		t.Fatalf("周期记录应带账户快照: cycle=%d account=%+v", cycle.CycleNumber, cycle.AccountState)
+	}
+	closes := closeActions(t, at)
+	if len(closes) != 1 || closes[0].Symbol != "BTCUSDT" || closes[0].Reasoning != "止损触发" {
+		t.Fatalf("推送的止损成交应只记录一次平仓: %+v", closes)
+	}
+}
+
+// newTestHyperliquid 启动exchangetest的Hyperliquid模拟服务并创建连接到它的交易器
+func newTestHyperliquid(t *testing.T) (*HyperliquidTrader, *exchangetest.Venue) {
+	t.Helper()
+	venue := exchangetest.NewVenue(10000, exchangetest.DefaultInstruments()...)
+	agentKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := crypto.PubkeyToAddress(walletKey.PublicKey).Hex()
+	server := exchangetest.NewHyperliquidServer(venue, agentKey, wallet)
+	t.Cleanup(server.Close)
+	hyperliquid, err := NewHyperliquidTraderWithURL(hex.EncodeToString(crypto.FromECDSA(agentKey)), wallet, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hyperliquid, venue
+}
+
+func TestHyperliquidStopFillRecordedAsClose(t *testing.T) {
+	hyperliquid, venue := newTestHyperliquid(t)
+	at := newTestAutoTrader(t, hyperliquid)
+	openTestLong(t, at, "BTCUSDT", 0.01, protectiveTarget{StopLoss: 95000, TakeProfit: 110000})
+
+	// 没有推送时周期同步的成交不带订单类型，按查询到的订单类型（止盈单）记为平仓
+	venue.SetPrice("BTCUSDT", 111000)
+	actions := at.logNewFills()
+	if len(actions) != 1 || actions[0].Action != "close_long" || actions[0].Quantity != 0.01 ||
+		actions[0].ExpectedPrice != 110000 || actions[0].Reasoning != "止盈触发" {
+		t.Fatalf("Hyperliquid的止盈成交应记为平仓: %+v", actions)
+	}
+}
+
+func TestHyperliquidStreamStopRecordedAsClose(t *testing.T) {
+	hyperliquid, venue := newTestHyperliquid(t)
+	fills := hyperliquid.Fills()
+	if err := hyperliquid.StartStream(); err != nil {
+		t.Fatal(err)
+	}
+	defer hyperliquid.StopStream()
+
+	at := newTestAutoTrader(t, hyperliquid)
+	openTestLong(t, at, "BTCUSDT", 0.01, protectiveTarget{StopLoss: 95000, TakeProfit: 110000})
+	go at.watchFills(fills)
+
+	// 止损单在交易所触发：交易周期进行中（如等待AI）也立即处理推送的成交，暂存到下个周期
+	at.cycleMu.Lock()
+	venue.SetPrice("BTCUSDT", 94000)
+	var closes []logger.DecisionAction
+	for deadline := time.Now().Add(5 * time.Second); len(closes) == 0 && time.Now().Before(deadline); {
+		time.Sleep(20 * time.Millisecond)
+		closes = at.takeStreamCloses()
+	}
+	if len(closes) != 1 || closes[0].Action != "close_long" || closes[0].Quantity != 0.01 ||
+		closes[0].Price <= 0 || closes[0].ExpectedPrice != 95000 || closes[0].Reasoning != "止损触发" {
+		t.Fatalf("Hyperliquid推送的止损成交应记为平仓: %+v", closes)
+	}
+
+	// 周期同步成交时不再重复记录
+	actions := at.logNewFills()
+	at.cycleMu.Unlock()
+	if len(actions) != 0 {
+		t.Fatalf("已记录的平仓不应重复记录: %+v", actions)
+	}
+	if err := at.decisionLogger.LogDecision(&logger.DecisionRecord{Success: true, Decisions: closes}); err != nil {
+		t.Fatal(err)
+	}
+	trades, err := at.decisionLogger.GetTradeHistory("BTCUSDT", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].PnL >= 0 {
+		t.Fatalf("账本应记录止损平仓的亏损交易: %+v", trades)
+	}
+}
+
+// serveTestMarket 启动本地行情服务（K线收盘价固定为prices中的价格），让market.Get不访问网络；
+// 候选币种使用默认币种列表
+func serveTestMarket(t *testing.T, prices map[string]float64, candidates []string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		price, ok := prices[r.URL.Query().Get("symbol")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var body interface{}
+		switch r.URL.Path {
+		case "/fapi/v1/klines":
+			var klines [][]interface{}
+			now := time.Now().UnixMilli()
+			for i := 0; i < 60; i++ {
+				openTime := now - int64(60-i)*180000
+				klines = append(klines, []interface{}{openTime, price, price * 1.001, price * 0.999, price, 1000.0, openTime + 179999})
+			}
+			body = klines
+		case "/fapi/v1/openInterest":
+			body = map[string]interface{}{"symbol": r.URL.Query().Get("symbol"), "openInterest": "1000000"}
+		case "/fapi/v1/premiumIndex":
+			body = map[string]interface{}{"symbol": r.URL.Query().Get("symbol"), "lastFundingRate": "0.0001"}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	market.SetAPIBaseURL(server.URL)
+	t.Cleanup(func() { market.SetAPIBaseURL("https://fapi.binance.com") })
+	pool.SetUseDefaultCoins(true)
+	pool.SetDefaultCoins(candidates)
+	t.Cleanup(func() { pool.SetUseDefaultCoins(false) })
+}
+
+func TestRunCycleExecutesScriptedDecisions(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 100, "ETHUSDT": 100}
+	serveTestMarket(t, prices, []string{"BTCUSDT"})
+	fake := NewFakeTrader(1000, prices)
+	if _, err := fake.OpenLong("ETHUSDT", 1, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	response := `BTC回踩后企稳，做多；ETH动能衰竭，平掉多仓。
+
+[
+  {"symbol": "BTCUSDT", "action": "open_long", "leverage": 5, "position_size_usd": 200, "stop_loss": 95, "take_profit": 120, "confidence": 80, "reasoning": "回踩企稳"},
+  {"symbol": "ETHUSDT", "action": "close_long", "reasoning": "动能衰竭"}
+]`
+	at := newTestAutoTrader(t, fake, response)
+	at.config.BTCETHLeverage = 10
+	at.config.AltcoinLeverage = 5
+
+	if err := at.runCycle(t.Context()); err != nil {
+		t.Fatalf("runCycle: %v", err)
+	}
+
+	// 决策已在交易器上执行：先平ETH多仓，再开BTC多仓并挂止损止盈
+	positions, err := fake.GetPositions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0]["symbol"] != "BTCUSDT" || positions[0]["side"] != "long" || positions[0]["positionAmt"] != 2.0 {
+		t.Fatalf("持仓应只剩新开的BTC多仓: %+v", positions)
+	}
+	protective := make(map[string]float64)
+	for _, order := range fake.Orders() {
+		protective[order.Type] = order.Price
+	}
+	if protective["STOP_LOSS"] != 95 || protective["TAKE_PROFIT"] != 120 {
+		t.Fatalf("开仓后应挂止损止盈: %+v", fake.Orders())
+	}
+
+	// 决策日志记录了输入、思维链和两个决策的执行结果
+	records, err := at.decisionLogger.GetLatestRecords(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("应写入一条决策记录: %d", len(records))
+	}
+	record := records[0]
+	if !record.Success || record.InputPrompt == "" || !strings.Contains(record.CoTTrace, "BTC回踩后企稳") ||
+		len(record.CandidateCoins) != 1 || record.CandidateCoins[0] != "BTCUSDT" || len(record.Positions) != 1 {
+		t.Fatalf("决策记录不完整: %+v", record)
+	}
+	if len(record.Decisions) != 2 {
+		t.Fatalf("应记录两个决策: %+v", record.Decisions)
+	}
+	closeETH, openBTC := record.Decisions[0], record.Decisions[1]
+	if closeETH.Action != "close_long" || closeETH.Symbol != "ETHUSDT" || !closeETH.Success || closeETH.OrderID == 0 {
+		t.Errorf("平仓决策记录错误: %+v", closeETH)
+	}
+	if openBTC.Action != "open_long" || openBTC.Symbol != "BTCUSDT" || !openBTC.Success || openBTC.OrderID == 0 ||
+		openBTC.Quantity != 2 || openBTC.Leverage != 5 || openBTC.StopLoss != 95 || openBTC.TakeProfit != 120 || openBTC.Reasoning != "回踩企稳" {
+		t.Errorf("开仓决策记录错误: %+v", openBTC)
+	}
+	if !strings.Contains(strings.Join(record.ExecutionLog, "\n"), "✓ BTCUSDT open_long 成功") {
+		t.Errorf("执行日志缺少开仓结果: %v", record.ExecutionLog)
+	}
+
+	backend := at.mcpClient.Backend.(*mcp.ScriptedBackend)
+	if requests := backend.Requests(); len(requests) != 1 || !strings.Contains(string(requests[0]), "BTCUSDT") {
+		t.Errorf("应向AI发送一次包含候选币种的请求: %d", len(requests))
+	}
+}
+
+func TestFlattenRecordsAccountState(t *testing.T) {
+	fake := NewFakeTrader(1000, map[string]float64{"BTCUSDT": 100})
+	at := newTestAutoTrader(t, fake)
+	openTestLong(t, at, "BTCUSDT", 1, protectiveTarget{StopLoss: 90, TakeProfit: 120})
+	fake.SetPrice("BTCUSDT", 110)
+
+	report := at.Flatten()
+	if !report.Flat || len(report.Closed) != 1 {
+		t.Fatalf("紧急平仓应平掉持仓: %+v", report)
+	}
+
+	records, err := at.decisionLogger.GetLatestRecords(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := records[len(records)-1]
+	balance, err := fake.GetBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	// 紧急平仓记录带平仓后的账户快照，净值曲线不会出现0净值的点
+	if record.AccountState.TotalBalance != wallet || record.AccountState.TotalBalance <= 1000 ||
+		record.AccountState.TotalUnrealizedProfit != wallet-1000 || record.AccountState.PositionCount != 0 {
+		t.Fatalf("紧急平仓记录的账户快照不正确: %+v (钱包余额 %.4f)", record.AccountState, wallet)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,19 +30,88 @@ type FuturesTrader struct {
 
 	// 缓存有效期（15秒）
 	cacheDuration time.Duration
+
+	// 切换杠杆/保证金模式后的等待时间（避免冷却期错误）
+	leverageCooldown   time.Duration
+	marginTypeCooldown time.Duration
+
+	// 下过单的币种（成交记录接口必须按币种查询）
+	tradedSymbols      map[string]bool
+	tradedSymbolsMutex sync.Mutex
+
+	// 客户端订单ID（见SetClientOrderIDPrefix）
+	clientIDs clientOrderIDs
+
+	// 保证金模式和持仓模式（见ConfigureAccountModes，默认逐仓+双向持仓）
+	modes accountModes
+
+	// 交易对规则（exchangeInfo + 杠杆档位，见GetInstrument）
+	instruments *instrumentRegistry
+
+	// 用户数据流（见StartStream）：连接正常时余额、持仓、挂单和成交从本地模型读取
+	account   *accountModel
+	streamURL string // WebSocket地址（默认wss://fstream.binance.com）
+	stream    *binanceUserStream
+	streamMu  sync.Mutex
 }
 
 // NewFuturesTrader 创建合约交易器
 func NewFuturesTrader(apiKey, secretKey string) *FuturesTrader {
 	client := futures.NewClient(apiKey, secretKey)
-	return &FuturesTrader{
-		client:        client,
-		cacheDuration: 15 * time.Second, // 15秒缓存
+	t := &FuturesTrader{
+		client:             client,
+		cacheDuration:      15 * time.Second, // 15秒缓存
+		leverageCooldown:   5 * time.Second,
+		marginTypeCooldown: 3 * time.Second,
+	}
+	t.modes.set(MarginModeIsolated, PositionModeHedge)
+	t.instruments = newInstrumentRegistry("币安", t.loadInstruments)
+	t.account = newAccountModel()
+	t.streamURL = "wss://fstream.binance.com"
+	return t
+}
+
+// SetBaseURL 替换API地址（用于测试网或本地模拟服务）
+func (t *FuturesTrader) SetBaseURL(url string) {
+	t.client.BaseURL = strings.TrimSuffix(url, "/")
+}
+
+// SetCooldowns 设置切换杠杆和保证金模式后的等待时间（默认5秒/3秒，测试时可设为0）
+func (t *FuturesTrader) SetCooldowns(leverage, marginType time.Duration) {
+	t.leverageCooldown = leverage
+	t.marginTypeCooldown = marginType
+}
+
+// invalidateCache 清空余额和持仓缓存（下单、切换杠杆后调用，保证下次查询拿到最新数据）
+// 用户数据流连接时本地模型同样标记为需要刷新（推送可能晚于下单响应到达）
+func (t *FuturesTrader) invalidateCache() {
+	t.account.markDirty("")
+
+	t.balanceCacheMutex.Lock()
+	t.cachedBalance = nil
+	t.balanceCacheMutex.Unlock()
+
+	t.positionsCacheMutex.Lock()
+	t.cachedPositions = nil
+	t.positionsCacheMutex.Unlock()
+}
+
+// rememberSymbol 记录下过单的币种（GetFills会查询这些币种的成交）
+func (t *FuturesTrader) rememberSymbol(symbol string) {
+	t.tradedSymbolsMutex.Lock()
+	defer t.tradedSymbolsMutex.Unlock()
+	if t.tradedSymbols == nil {
+		t.tradedSymbols = make(map[string]bool)
 	}
+	t.tradedSymbols[symbol] = true
 }
 
-// GetBalance 获取账户余额（带缓存）
+// GetBalance 获取账户余额（用户数据流连接时读取本地模型，否则使用带缓存的REST查询）
 func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
+	if balance, ok := t.streamBalance(); ok {
+		return balance, nil
+	}
+
 	// 先检查缓存是否有效
 	t.balanceCacheMutex.RLock()
 	if t.cachedBalance != nil && time.Since(t.balanceCacheTime) < t.cacheDuration {
@@ -52,6 +124,22 @@ func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
 
 	// 缓存过期或不存在，调用API
 	log.Printf("🔄 缓存过期，正在调用币安API获取账户余额...")
+	result, err := t.fetchBalance()
+	if err != nil {
+		return nil, err
+	}
+
+	// 更新缓存
+	t.balanceCacheMutex.Lock()
+	t.cachedBalance = result
+	t.balanceCacheTime = time.Now()
+	t.balanceCacheMutex.Unlock()
+
+	return result, nil
+}
+
+// fetchBalance 调用币安API查询账户余额（不使用缓存）
+func (t *FuturesTrader) fetchBalance() (map[string]interface{}, error) {
 	account, err := t.client.NewGetAccountService().Do(context.Background())
 	if err != nil {
 		log.Printf("❌ 币安API调用失败: %v", err)
@@ -67,18 +155,15 @@ func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
 		account.TotalWalletBalance,
 		account.AvailableBalance,
 		account.TotalUnrealizedProfit)
-
-	// 更新缓存
-	t.balanceCacheMutex.Lock()
-	t.cachedBalance = result
-	t.balanceCacheTime = time.Now()
-	t.balanceCacheMutex.Unlock()
-
 	return result, nil
 }
 
-// GetPositions 获取所有持仓（带缓存）
+// GetPositions 获取所有持仓（用户数据流连接时读取本地模型，否则使用带缓存的REST查询）
 func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
+	if positions, ok := t.streamPositions(); ok {
+		return positions, nil
+	}
+
 	// 先检查缓存是否有效
 	t.positionsCacheMutex.RLock()
 	if t.cachedPositions != nil && time.Since(t.positionsCacheTime) < t.cacheDuration {
@@ -91,6 +176,22 @@ func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
 
 	// 缓存过期或不存在，调用API
 	log.Printf("🔄 缓存过期，正在调用币安API获取持仓信息...")
+	result, err := t.fetchPositions()
+	if err != nil {
+		return nil, err
+	}
+
+	// 更新缓存
+	t.positionsCacheMutex.Lock()
+	t.cachedPositions = result
+	t.positionsCacheTime = time.Now()
+	t.positionsCacheMutex.Unlock()
+
+	return result, nil
+}
+
+// fetchPositions 调用币安API查询持仓（不使用缓存）
+func (t *FuturesTrader) fetchPositions() ([]map[string]interface{}, error) {
 	positions, err := t.client.NewGetPositionRiskService().Do(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
@@ -105,7 +206,7 @@ func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
 
 		posMap := make(map[string]interface{})
 		posMap["symbol"] = pos.Symbol
-		posMap["positionAmt"], _ = strconv.ParseFloat(pos.PositionAmt, 64)
+		posMap["positionAmt"] = math.Abs(posAmt) // 统一为正数，方向看side
 		posMap["entryPrice"], _ = strconv.ParseFloat(pos.EntryPrice, 64)
 		posMap["markPrice"], _ = strconv.ParseFloat(pos.MarkPrice, 64)
 		posMap["unRealizedProfit"], _ = strconv.ParseFloat(pos.UnRealizedProfit, 64)
@@ -121,13 +222,6 @@ func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
 
 		result = append(result, posMap)
 	}
-
-	// 更新缓存
-	t.positionsCacheMutex.Lock()
-	t.cachedPositions = result
-	t.positionsCacheTime = time.Now()
-	t.positionsCacheMutex.Unlock()
-
 	return result, nil
 }
 
@@ -169,10 +263,13 @@ func (t *FuturesTrader) SetLeverage(symbol string, leverage int) error {
 	}
 
 	log.Printf("  ✓ %s 杠杆已切换为 %dx", symbol, leverage)
+	t.invalidateCache()
 
-	// 切换杠杆后等待5秒（避免冷却期错误）
-	log.Printf("  ⏱ 等待5秒冷却期...")
-	time.Sleep(5 * time.Second)
+	// 切换杠杆后等待（避免冷却期错误）
+	if t.leverageCooldown > 0 {
+		log.Printf("  ⏱ 等待%.0f秒冷却期...", t.leverageCooldown.Seconds())
+		time.Sleep(t.leverageCooldown)
+	}
 
 	return nil
 }
@@ -195,18 +292,84 @@ func (t *FuturesTrader) SetMarginType(symbol string, marginType futures.MarginTy
 
 	log.Printf("  ✓ %s 保证金模式已切换为 %s", symbol, marginType)
 
-	// 切换保证金模式后等待3秒（避免冷却期错误）
-	log.Printf("  ⏱ 等待3秒冷却期...")
-	time.Sleep(3 * time.Second)
+	// 切换保证金模式后等待（避免冷却期错误）
+	if t.marginTypeCooldown > 0 {
+		log.Printf("  ⏱ 等待%.0f秒冷却期...", t.marginTypeCooldown.Seconds())
+		time.Sleep(t.marginTypeCooldown)
+	}
 
 	return nil
 }
 
+// ConfigureAccountModes 检测账户的持仓模式（positionSide/dual），与配置不同时切换
+// 保证金模式按币种设置，开仓前切换为配置的模式（默认逐仓）
+func (t *FuturesTrader) ConfigureAccountModes(marginMode, positionMode string) error {
+	if err := ValidateAccountModes(marginMode, positionMode); err != nil {
+		return err
+	}
+	if marginMode == "" {
+		marginMode = MarginModeIsolated
+	}
+
+	mode, err := t.client.NewGetPositionModeService().Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("获取持仓模式失败: %w", err)
+	}
+	current := PositionModeOneWay
+	if mode.DualSidePosition {
+		current = PositionModeHedge
+	}
+
+	if positionMode != "" && positionMode != current {
+		err := t.client.NewChangePositionModeService().
+			DualSide(positionMode == PositionModeHedge).
+			Do(context.Background())
+		if err != nil && !contains(err.Error(), "No need to change") {
+			return fmt.Errorf("切换持仓模式为 %s 失败（有持仓或挂单时不能切换）: %w", positionMode, err)
+		}
+		log.Printf("  ✓ 币安持仓模式已从 %s 切换为 %s", current, positionMode)
+		current = positionMode
+	}
+
+	t.modes.set(marginMode, current)
+	log.Printf("  ✓ 币安账户模式: 保证金 %s, 持仓 %s", marginMode, current)
+	return nil
+}
+
+// AccountModes 返回当前使用的保证金模式和持仓模式
+func (t *FuturesTrader) AccountModes() (marginMode, positionMode string) {
+	return t.modes.get()
+}
+
+// marginType 配置的保证金模式对应的币安参数
+func (t *FuturesTrader) marginType() futures.MarginType {
+	if marginMode, _ := t.modes.get(); marginMode == MarginModeCross {
+		return futures.MarginTypeCrossed
+	}
+	return futures.MarginTypeIsolated
+}
+
+// positionSide 订单的positionSide参数（positionSide为"LONG"/"SHORT"，单向持仓模式下为BOTH）
+func (t *FuturesTrader) positionSide(positionSide string) futures.PositionSideType {
+	return futures.PositionSideType(t.modes.orderPositionSide(positionSide))
+}
+
+// reduceOnly 平仓方向的订单在单向持仓模式下加reduceOnly，避免数量超过持仓时反向开仓
+// 双向持仓模式下平仓方向由positionSide决定，币安不接受reduceOnly参数
+func (t *FuturesTrader) reduceOnly(service *futures.CreateOrderService) *futures.CreateOrderService {
+	if t.modes.hedge() {
+		return service
+	}
+	return service.ReduceOnly(true)
+}
+
 // OpenLong 开多仓
 func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	// 先取消该币种的所有委托单（清理旧的止损止盈单）
-	if err := t.CancelAllOrders(symbol); err != nil {
-		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
+	// 按交易对规则取整数量，检查最小名义价值和杠杆档位
+	// 不撤销已有挂单：加仓时另一方向持仓的止损止盈、跟踪止损和限价开仓单都要保留
+	quantityStr, err := t.openQuantity(symbol, quantity, leverage)
+	if err != nil {
+		return nil, err
 	}
 
 	// 设置杠杆
@@ -214,45 +377,38 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 		return nil, err
 	}
 
-	// 设置逐仓模式
-	if err := t.SetMarginType(symbol, futures.MarginTypeIsolated); err != nil {
-		return nil, err
-	}
-
-	// 格式化数量到正确精度
-	quantityStr, err := t.FormatQuantity(symbol, quantity)
-	if err != nil {
+	// 设置保证金模式（逐仓/全仓）
+	if err := t.SetMarginType(symbol, t.marginType()); err != nil {
 		return nil, err
 	}
 
 	// 创建市价买入订单
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.createOrder(symbol, t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeBuy).
-		PositionSide(futures.PositionSideTypeLong).
+		PositionSide(t.positionSide("LONG")).
 		Type(futures.OrderTypeMarket).
-		Quantity(quantityStr).
-		Do(context.Background())
+		Quantity(quantityStr))
 
 	if err != nil {
 		return nil, fmt.Errorf("开多仓失败: %w", err)
 	}
+	t.invalidateCache()
+	t.rememberSymbol(symbol)
 
 	log.Printf("✓ 开多仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf("  订单ID: %d", order.OrderID)
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return t.orderResult(order), nil
 }
 
 // OpenShort 开空仓
 func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	// 先取消该币种的所有委托单（清理旧的止损止盈单）
-	if err := t.CancelAllOrders(symbol); err != nil {
-		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
+	// 按交易对规则取整数量，检查最小名义价值和杠杆档位
+	// 不撤销已有挂单：加仓时另一方向持仓的止损止盈、跟踪止损和限价开仓单都要保留
+	quantityStr, err := t.openQuantity(symbol, quantity, leverage)
+	if err != nil {
+		return nil, err
 	}
 
 	// 设置杠杆
@@ -260,37 +416,95 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 		return nil, err
 	}
 
-	// 设置逐仓模式
-	if err := t.SetMarginType(symbol, futures.MarginTypeIsolated); err != nil {
-		return nil, err
-	}
-
-	// 格式化数量到正确精度
-	quantityStr, err := t.FormatQuantity(symbol, quantity)
-	if err != nil {
+	// 设置保证金模式（逐仓/全仓）
+	if err := t.SetMarginType(symbol, t.marginType()); err != nil {
 		return nil, err
 	}
 
 	// 创建市价卖出订单
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.createOrder(symbol, t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeSell).
-		PositionSide(futures.PositionSideTypeShort).
+		PositionSide(t.positionSide("SHORT")).
 		Type(futures.OrderTypeMarket).
-		Quantity(quantityStr).
-		Do(context.Background())
+		Quantity(quantityStr))
 
 	if err != nil {
 		return nil, fmt.Errorf("开空仓失败: %w", err)
 	}
+	t.invalidateCache()
+	t.rememberSymbol(symbol)
 
 	log.Printf("✓ 开空仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf("  订单ID: %d", order.OrderID)
 
+	return t.orderResult(order), nil
+}
+
+// OpenLimit 限价开仓（GTC/IOC/POST_ONLY），不会取消已有的止损止盈单
+func (t *FuturesTrader) OpenLimit(symbol, positionSide string, quantity, price float64, leverage int, timeInForce string) (map[string]interface{}, error) {
+	if err := validatePositionSide(positionSide); err != nil {
+		return nil, err
+	}
+
+	tif := futures.TimeInForceTypeGTC
+	switch timeInForce {
+	case TimeInForceGTC:
+	case TimeInForceIOC:
+		tif = futures.TimeInForceTypeIOC
+	case TimeInForcePostOnly:
+		tif = futures.TimeInForceTypeGTX
+	default:
+		return nil, fmt.Errorf("不支持的timeInForce: %s", timeInForce)
+	}
+
+	// 按交易对规则取整数量和价格，检查最小名义价值和杠杆档位
+	info, quantity, price, err := t.instruments.prepareOrder(symbol, quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
+	}
+	quantityStr := info.FormatQuantity(quantity)
+	priceStr := info.FormatPrice(price)
+
+	// 设置杠杆
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	// 设置保证金模式（逐仓/全仓）
+	if err := t.SetMarginType(symbol, t.marginType()); err != nil {
+		return nil, err
+	}
+
+	side := futures.SideTypeBuy
+	if positionSide == "SHORT" {
+		side = futures.SideTypeSell
+	}
+
+	order, err := t.createOrder(symbol, t.client.NewCreateOrderService().
+		Symbol(symbol).
+		Side(side).
+		PositionSide(t.positionSide(positionSide)).
+		Type(futures.OrderTypeLimit).
+		TimeInForce(tif).
+		Quantity(quantityStr).
+		Price(priceStr))
+
+	if err != nil {
+		return nil, fmt.Errorf("限价开仓失败: %w", err)
+	}
+	t.invalidateCache()
+	t.rememberSymbol(symbol)
+
+	log.Printf("✓ 限价开仓委托成功: %s %s 数量: %s 价格: %s (%s) 状态: %s", symbol, positionSide, quantityStr, priceStr, timeInForce, order.Status)
+
 	result := make(map[string]interface{})
 	result["orderId"] = order.OrderID
 	result["symbol"] = order.Symbol
-	result["status"] = order.Status
+	result["status"] = string(order.Status)
+	if order.Status == futures.OrderStatusTypeFilled {
+		t.addExecution(result, order)
+	}
 	return result, nil
 }
 
@@ -311,28 +525,30 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 		}
 
 		if quantity == 0 {
-			return nil, fmt.Errorf("没有找到 %s 的多仓", symbol)
+			return nil, noPositionError(symbol, "long")
 		}
 	}
 
-	// 格式化数量
-	quantityStr, err := t.FormatQuantity(symbol, quantity)
+	// 按交易对规则取整数量（平仓不检查最小名义价值）
+	info, quantity, _, err := t.instruments.prepareOrder(symbol, quantity, 0, 0, true)
 	if err != nil {
 		return nil, err
 	}
+	quantityStr := info.FormatQuantity(quantity)
 
 	// 创建市价卖出订单（平多）
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.createOrder(symbol, t.reduceOnly(t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeSell).
-		PositionSide(futures.PositionSideTypeLong).
+		PositionSide(t.positionSide("LONG")).
 		Type(futures.OrderTypeMarket).
-		Quantity(quantityStr).
-		Do(context.Background())
+		Quantity(quantityStr)))
 
 	if err != nil {
 		return nil, fmt.Errorf("平多仓失败: %w", err)
 	}
+	t.invalidateCache()
+	t.rememberSymbol(symbol)
 
 	log.Printf("✓ 平多仓成功: %s 数量: %s", symbol, quantityStr)
 
@@ -341,11 +557,7 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return t.orderResult(order), nil
 }
 
 // CloseShort 平空仓
@@ -359,34 +571,36 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 
 		for _, pos := range positions {
 			if pos["symbol"] == symbol && pos["side"] == "short" {
-				quantity = -pos["positionAmt"].(float64) // 空仓数量是负的，取绝对值
+				quantity = pos["positionAmt"].(float64)
 				break
 			}
 		}
 
 		if quantity == 0 {
-			return nil, fmt.Errorf("没有找到 %s 的空仓", symbol)
+			return nil, noPositionError(symbol, "short")
 		}
 	}
 
-	// 格式化数量
-	quantityStr, err := t.FormatQuantity(symbol, quantity)
+	// 按交易对规则取整数量（平仓不检查最小名义价值）
+	info, quantity, _, err := t.instruments.prepareOrder(symbol, quantity, 0, 0, true)
 	if err != nil {
 		return nil, err
 	}
+	quantityStr := info.FormatQuantity(quantity)
 
 	// 创建市价买入订单（平空）
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.createOrder(symbol, t.reduceOnly(t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeBuy).
-		PositionSide(futures.PositionSideTypeShort).
+		PositionSide(t.positionSide("SHORT")).
 		Type(futures.OrderTypeMarket).
-		Quantity(quantityStr).
-		Do(context.Background())
+		Quantity(quantityStr)))
 
 	if err != nil {
 		return nil, fmt.Errorf("平空仓失败: %w", err)
 	}
+	t.invalidateCache()
+	t.rememberSymbol(symbol)
 
 	log.Printf("✓ 平空仓成功: %s 数量: %s", symbol, quantityStr)
 
@@ -395,11 +609,7 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return t.orderResult(order), nil
 }
 
 // CancelAllOrders 取消该币种的所有挂单
@@ -407,6 +617,7 @@ func (t *FuturesTrader) CancelAllOrders(symbol string) error {
 	err := t.client.NewCancelAllOpenOrdersService().
 		Symbol(symbol).
 		Do(context.Background())
+	t.account.markDirty(symbol)
 
 	if err != nil {
 		return fmt.Errorf("取消挂单失败: %w", err)
@@ -416,6 +627,22 @@ func (t *FuturesTrader) CancelAllOrders(symbol string) error {
 	return nil
 }
 
+// CancelOrder 取消单个挂单
+func (t *FuturesTrader) CancelOrder(symbol string, orderID int64) error {
+	_, err := t.client.NewCancelOrderService().
+		Symbol(symbol).
+		OrderID(orderID).
+		Do(context.Background())
+	t.account.markDirty(symbol)
+
+	if err != nil {
+		return fmt.Errorf("取消订单失败: %w", err)
+	}
+
+	log.Printf("  ✓ 已取消 %s 订单 %d", symbol, orderID)
+	return nil
+}
+
 // GetMarketPrice 获取市场价格
 func (t *FuturesTrader) GetMarketPrice(symbol string) (float64, error) {
 	prices, err := t.client.NewListPricesService().Symbol(symbol).Do(context.Background())
@@ -445,33 +672,39 @@ func (t *FuturesTrader) CalculatePositionSize(balance, riskPercent, price float6
 
 // SetStopLoss 设置止损单
 func (t *FuturesTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
 	var side futures.SideType
 	var posSide futures.PositionSideType
 
 	if positionSide == "LONG" {
 		side = futures.SideTypeSell
-		posSide = futures.PositionSideTypeLong
+		posSide = t.positionSide("LONG")
 	} else {
 		side = futures.SideTypeBuy
-		posSide = futures.PositionSideTypeShort
+		posSide = t.positionSide("SHORT")
 	}
 
-	// 格式化数量
+	// 按交易对规则格式化数量和触发价
 	quantityStr, err := t.FormatQuantity(symbol, quantity)
 	if err != nil {
 		return err
 	}
+	stopPriceStr, err := t.FormatPrice(symbol, stopPrice)
+	if err != nil {
+		return err
+	}
 
-	_, err = t.client.NewCreateOrderService().
+	_, err = t.createOrder(symbol, t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(side).
 		PositionSide(posSide).
 		Type(futures.OrderTypeStopMarket).
-		StopPrice(fmt.Sprintf("%.8f", stopPrice)).
+		StopPrice(stopPriceStr).
 		Quantity(quantityStr).
 		WorkingType(futures.WorkingTypeContractPrice).
-		ClosePosition(true).
-		Do(context.Background())
+		ClosePosition(true))
 
 	if err != nil {
 		return fmt.Errorf("设置止损失败: %w", err)
@@ -483,33 +716,39 @@ func (t *FuturesTrader) SetStopLoss(symbol string, positionSide string, quantity
 
 // SetTakeProfit 设置止盈单
 func (t *FuturesTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
 	var side futures.SideType
 	var posSide futures.PositionSideType
 
 	if positionSide == "LONG" {
 		side = futures.SideTypeSell
-		posSide = futures.PositionSideTypeLong
+		posSide = t.positionSide("LONG")
 	} else {
 		side = futures.SideTypeBuy
-		posSide = futures.PositionSideTypeShort
+		posSide = t.positionSide("SHORT")
 	}
 
-	// 格式化数量
+	// 按交易对规则格式化数量和触发价
 	quantityStr, err := t.FormatQuantity(symbol, quantity)
 	if err != nil {
 		return err
 	}
+	takeProfitPriceStr, err := t.FormatPrice(symbol, takeProfitPrice)
+	if err != nil {
+		return err
+	}
 
-	_, err = t.client.NewCreateOrderService().
+	_, err = t.createOrder(symbol, t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(side).
 		PositionSide(posSide).
 		Type(futures.OrderTypeTakeProfitMarket).
-		StopPrice(fmt.Sprintf("%.8f", takeProfitPrice)).
+		StopPrice(takeProfitPriceStr).
 		Quantity(quantityStr).
 		WorkingType(futures.WorkingTypeContractPrice).
-		ClosePosition(true).
-		Do(context.Background())
+		ClosePosition(true))
 
 	if err != nil {
 		return fmt.Errorf("设置止盈失败: %w", err)
@@ -519,84 +758,469 @@ func (t *FuturesTrader) SetTakeProfit(symbol string, positionSide string, quanti
 	return nil
 }
 
-// GetSymbolPrecision 获取交易对的数量精度
-func (t *FuturesTrader) GetSymbolPrecision(symbol string) (int, error) {
+// UpdateStopLoss 替换止损单（币安每个方向只允许一张closePosition止损/止盈单，先撤旧单再挂新单，失败时恢复旧单）
+func (t *FuturesTrader) UpdateStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "STOP_MARKET", quantity, stopPrice, false)
+}
+
+// UpdateTakeProfit 替换止盈单（币安每个方向只允许一张closePosition止损/止盈单，先撤旧单再挂新单，失败时恢复旧单）
+func (t *FuturesTrader) UpdateTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "TAKE_PROFIT_MARKET", quantity, takeProfitPrice, false)
+}
+
+// SetTakeProfitLadder 按阶梯挂多张止盈单
+// 不能使用closePosition（每个方向只允许一张），每档按数量下单；双向持仓模式下带positionSide的平仓方向订单只会减仓，单向持仓模式加reduceOnly
+func (t *FuturesTrader) SetTakeProfitLadder(symbol string, positionSide string, quantity float64, levels []TakeProfitLevel) error {
+	side := futures.SideTypeSell
+	posSide := t.positionSide("LONG")
+	if positionSide == "SHORT" {
+		side = futures.SideTypeBuy
+		posSide = t.positionSide("SHORT")
+	}
+
+	return placeTakeProfitLadder(t, symbol, positionSide, quantity, levels, func(quantity, price float64) error {
+		quantityStr, err := t.FormatQuantity(symbol, quantity)
+		if err != nil {
+			return err
+		}
+		priceStr, err := t.FormatPrice(symbol, price)
+		if err != nil {
+			return err
+		}
+		_, err = t.createOrder(symbol, t.reduceOnly(t.client.NewCreateOrderService().
+			Symbol(symbol).
+			Side(side).
+			PositionSide(posSide).
+			Type(futures.OrderTypeTakeProfitMarket).
+			StopPrice(priceStr).
+			Quantity(quantityStr).
+			WorkingType(futures.WorkingTypeContractPrice)))
+		return err
+	})
+}
+
+// SetTrailingStop 设置跟踪止损单（TRAILING_STOP_MARKET，回调比例0.1%-10%，精度0.1%）
+// 先挂新单再撤销该持仓已有的跟踪止损单
+func (t *FuturesTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackRate, activationPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+	if callbackRate < 0.1 || callbackRate > 10 {
+		return fmt.Errorf("币安跟踪止损回调比例必须在0.1%%-10%%之间: %.2f", callbackRate)
+	}
+
+	side := futures.SideTypeSell
+	posSide := t.positionSide("LONG")
+	if positionSide == "SHORT" {
+		side = futures.SideTypeBuy
+		posSide = t.positionSide("SHORT")
+	}
+
+	// 格式化数量
+	quantityStr, err := t.FormatQuantity(symbol, quantity)
+	if err != nil {
+		return err
+	}
+
+	orders, err := t.GetOpenOrders(symbol)
+	if err != nil {
+		return fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	service := t.reduceOnly(t.client.NewCreateOrderService().
+		Symbol(symbol).
+		Side(side).
+		PositionSide(posSide).
+		Type(futures.OrderTypeTrailingStopMarket).
+		Quantity(quantityStr).
+		CallbackRate(fmt.Sprintf("%.1f", callbackRate)).
+		WorkingType(futures.WorkingTypeContractPrice))
+	if activationPrice > 0 {
+		activationPriceStr, err := t.FormatPrice(symbol, activationPrice)
+		if err != nil {
+			return err
+		}
+		service = service.ActivationPrice(activationPriceStr)
+	}
+	if _, err := t.createOrder(symbol, service); err != nil {
+		return fmt.Errorf("设置跟踪止损失败: %w", err)
+	}
+
+	for _, order := range orders {
+		if order["type"] != "TRAILING_STOP_MARKET" || order["side"] != string(side) {
+			continue
+		}
+		orderID, _ := order["orderId"].(int64)
+		if err := t.CancelOrder(symbol, orderID); err != nil {
+			log.Printf("  ⚠ 撤销旧的跟踪止损单 %d 失败: %v", orderID, err)
+		}
+	}
+
+	log.Printf("  跟踪止损设置: 回调 %.1f%% 激活价 %.4f", callbackRate, activationPrice)
+	return nil
+}
+
+// GetInstrument 获取交易对规则（exchangeInfo的过滤器和杠杆档位，缓存一小时）
+func (t *FuturesTrader) GetInstrument(symbol string) (InstrumentInfo, error) {
+	return t.instruments.get(symbol)
+}
+
+// loadInstruments 加载全部交易对规则：exchangeInfo提供步长和最小下单限制，leverageBracket（需要签名）提供杠杆档位
+// 杠杆档位获取失败时只记录日志，此时不检查杠杆上限
+func (t *FuturesTrader) loadInstruments() ([]InstrumentInfo, error) {
 	exchangeInfo, err := t.client.NewExchangeInfoService().Do(context.Background())
 	if err != nil {
-		return 0, fmt.Errorf("获取交易规则失败: %w", err)
+		return nil, err
+	}
+
+	tiers := make(map[string][]MarginTier)
+	brackets, err := t.client.NewGetLeverageBracketService().Do(context.Background())
+	if err != nil {
+		log.Printf("  ⚠ 获取币安杠杆档位失败（不检查杠杆上限）: %v", err)
+	}
+	for _, bracket := range brackets {
+		for _, b := range bracket.Brackets {
+			tiers[bracket.Symbol] = append(tiers[bracket.Symbol], MarginTier{
+				NotionalFloor:         b.NotionalFloor,
+				MaxLeverage:           b.InitialLeverage,
+				MaintenanceMarginRate: b.MaintMarginRatio,
+			})
+		}
 	}
 
+	infos := make([]InstrumentInfo, 0, len(exchangeInfo.Symbols))
 	for _, s := range exchangeInfo.Symbols {
-		if s.Symbol == symbol {
-			// 从LOT_SIZE filter获取精度
-			for _, filter := range s.Filters {
-				if filter["filterType"] == "LOT_SIZE" {
-					stepSize := filter["stepSize"].(string)
-					precision := calculatePrecision(stepSize)
-					log.Printf("  %s 数量精度: %d (stepSize: %s)", symbol, precision, stepSize)
-					return precision, nil
-				}
-			}
+		info := parseFilterInstrument(s.Symbol, s.Filters)
+		info.MarginTiers = tiers[s.Symbol]
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// parseFilterInstrument 解析币安格式（币安、Aster）exchangeInfo中的过滤器
+// PRICE_FILTER的tickSize、LOT_SIZE的stepSize/minQty、MIN_NOTIONAL的notional（最大杠杆由杠杆档位得出）
+func parseFilterInstrument(symbol string, filters []map[string]interface{}) InstrumentInfo {
+	info := InstrumentInfo{Symbol: symbol, ContractMultiplier: 1}
+	value := func(filter map[string]interface{}, key string) float64 {
+		s, _ := filter[key].(string)
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	}
+	for _, filter := range filters {
+		switch filter["filterType"] {
+		case "PRICE_FILTER":
+			info.TickSize = value(filter, "tickSize")
+		case "LOT_SIZE":
+			info.StepSize = value(filter, "stepSize")
+			info.MinQuantity = value(filter, "minQty")
+		case "MIN_NOTIONAL":
+			info.MinNotional = value(filter, "notional")
+		}
+	}
+	return info
+}
+
+// openQuantity 开仓前按交易对规则取整数量，并按当前价格检查最小名义价值和杠杆档位，返回格式化后的数量
+func (t *FuturesTrader) openQuantity(symbol string, quantity float64, leverage int) (string, error) {
+	price, err := t.GetMarketPrice(symbol)
+	if err != nil {
+		return "", err
+	}
+	info, quantity, _, err := t.instruments.prepareOrder(symbol, quantity, price, leverage, false)
+	if err != nil {
+		return "", err
+	}
+	return info.FormatQuantity(quantity), nil
+}
+
+// GetOpenOrders 获取该币种的挂单（用户数据流连接时读取本地模型）
+func (t *FuturesTrader) GetOpenOrders(symbol string) ([]map[string]interface{}, error) {
+	if orders, ok := t.account.getOpenOrders(symbol); ok {
+		return orders, nil
+	}
+
+	result, err := t.fetchOpenOrders(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if t.account.isLive() {
+		t.account.loadOrders(symbol, result)
+	}
+	return result, nil
+}
+
+// fetchOpenOrders 调用币安API查询挂单（symbol为空时查询全部币种）
+func (t *FuturesTrader) fetchOpenOrders(symbol string) ([]map[string]interface{}, error) {
+	service := t.client.NewListOpenOrdersService()
+	if symbol != "" {
+		service = service.Symbol(symbol)
+	}
+	orders, err := service.Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(orders))
+	for _, order := range orders {
+		result = append(result, binanceOrderInfo(order))
+	}
+	return result, nil
+}
+
+// GetOrder 查询单个订单
+func (t *FuturesTrader) GetOrder(symbol string, orderID int64) (map[string]interface{}, error) {
+	order, err := t.client.NewGetOrderService().
+		Symbol(symbol).
+		OrderID(orderID).
+		Do(context.Background())
+	if err != nil {
+		if contains(err.Error(), "-2013") {
+			return nil, fmt.Errorf("%s 订单 %d: %w", symbol, orderID, ErrOrderNotFound)
 		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
 	}
+	return binanceOrderInfo(order), nil
+}
 
-	log.Printf("  ⚠ %s 未找到精度信息，使用默认精度3", symbol)
-	return 3, nil // 默认精度为3
+// GetOrderByClientID 按客户端订单ID查询订单
+func (t *FuturesTrader) GetOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, error) {
+	order, err := t.queryOrderByClientID(symbol, clientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return binanceOrderInfo(order), nil
+}
+
+// queryOrderByClientID 按客户端订单ID查询币安订单
+func (t *FuturesTrader) queryOrderByClientID(symbol, clientOrderID string) (*futures.Order, error) {
+	order, err := t.client.NewGetOrderService().
+		Symbol(symbol).
+		OrigClientOrderID(clientOrderID).
+		Do(context.Background())
+	if err != nil {
+		if contains(err.Error(), "-2013") {
+			return nil, fmt.Errorf("%s 订单 %s: %w", symbol, clientOrderID, ErrOrderNotFound)
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	return order, nil
+}
+
+// SetClientOrderIDPrefix 设置之后下单使用的客户端订单ID前缀（newClientOrderId）
+func (t *FuturesTrader) SetClientOrderIDPrefix(prefix string) {
+	t.clientIDs.setPrefix(prefix)
+}
+
+// createOrder 带客户端订单ID下单，下单结果未知时先按ID查询订单（见submitOrder）
+func (t *FuturesTrader) createOrder(symbol string, service *futures.CreateOrderService) (*futures.CreateOrderResponse, error) {
+	clientOrderID := t.clientIDs.next()
+	if clientOrderID != "" {
+		service = service.NewClientOrderID(clientOrderID)
+	}
+
+	// 下单结果以REST为准，推送可能晚到：下次读取该币种挂单时先刷新
+	defer t.account.markDirty(symbol)
+
+	var order *futures.CreateOrderResponse
+	err := submitOrder(clientOrderID, func() error {
+		var err error
+		order, err = service.Do(context.Background())
+		return err
+	}, func() error {
+		found, err := t.queryOrderByClientID(symbol, clientOrderID)
+		if err != nil {
+			return err
+		}
+		order = &futures.CreateOrderResponse{
+			Symbol:        found.Symbol,
+			OrderID:       found.OrderID,
+			ClientOrderID: found.ClientOrderID,
+			Status:        found.Status,
+			Type:          found.Type,
+			Side:          found.Side,
+			PositionSide:  found.PositionSide,
+			OrigQuantity:  found.OrigQuantity,
+			AvgPrice:      found.AvgPrice,
+		}
+		return nil
+	})
+	return order, err
 }
 
-// calculatePrecision 从stepSize计算精度
-func calculatePrecision(stepSize string) int {
-	// 去除尾部的0
-	stepSize = trimTrailingZeros(stepSize)
+// GetFills 获取since之后的成交记录
+// 用户数据流连接时（since不早于连接建立时间）直接返回推送收到的成交；
+// 否则走REST：币安成交接口必须指定币种，这里查询当前持仓和本交易器下过单的币种
+func (t *FuturesTrader) GetFills(since time.Time) ([]map[string]interface{}, error) {
+	if fills, ok := t.account.getFills(since); ok {
+		return fills, nil
+	}
+
+	symbols := make(map[string]bool)
+	t.tradedSymbolsMutex.Lock()
+	for symbol := range t.tradedSymbols {
+		symbols[symbol] = true
+	}
+	t.tradedSymbolsMutex.Unlock()
+
+	positions, err := t.GetPositions()
+	if err != nil {
+		return nil, err
+	}
+	for _, pos := range positions {
+		symbols[pos["symbol"].(string)] = true
+	}
 
-	// 查找小数点
-	dotIndex := -1
-	for i := 0; i < len(stepSize); i++ {
-		if stepSize[i] == '.' {
-			dotIndex = i
-			break
+	var result []map[string]interface{}
+	for symbol := range symbols {
+		trades, err := t.client.NewListAccountTradeService().
+			Symbol(symbol).
+			StartTime(since.UnixMilli()).
+			Do(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s 成交记录失败: %w", symbol, err)
+		}
+		for _, trade := range trades {
+			result = append(result, binanceFill(trade))
 		}
 	}
 
-	// 如果没有小数点或小数点在最后，精度为0
-	if dotIndex == -1 || dotIndex == len(stepSize)-1 {
-		return 0
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["time"].(int64) < result[j]["time"].(int64)
+	})
+	return result, nil
+}
+
+// binanceFill 币安成交记录转统一的成交格式
+func binanceFill(trade *futures.AccountTrade) map[string]interface{} {
+	price, _ := strconv.ParseFloat(trade.Price, 64)
+	quantity, _ := strconv.ParseFloat(trade.Quantity, 64)
+	fee, _ := strconv.ParseFloat(trade.Commission, 64)
+	realizedPnl, _ := strconv.ParseFloat(trade.RealizedPnl, 64)
+	return map[string]interface{}{
+		"symbol":      trade.Symbol,
+		"orderId":     trade.OrderID,
+		"side":        string(trade.Side),
+		"time":        trade.Time,
+		"price":       price,
+		"quantity":    quantity,
+		"fee":         fee,
+		"feeAsset":    trade.CommissionAsset,
+		"realizedPnl": realizedPnl,
+	}
+}
+
+// GetFundingPayments 获取since之后的资金费收支（资金流水中的FUNDING_FEE，不区分持仓方向）
+func (t *FuturesTrader) GetFundingPayments(since time.Time) ([]map[string]interface{}, error) {
+	incomes, err := t.client.NewGetIncomeHistoryService().
+		IncomeType("FUNDING_FEE").
+		StartTime(since.UnixMilli()).
+		Limit(1000).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取资金费记录失败: %w", err)
 	}
 
-	// 返回小数点后的位数
-	return len(stepSize) - dotIndex - 1
+	result := make([]map[string]interface{}, 0, len(incomes))
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		result = append(result, map[string]interface{}{
+			"symbol": income.Symbol,
+			"side":   "",
+			"time":   income.Time,
+			"amount": amount,
+			"asset":  income.Asset,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["time"].(int64) < result[j]["time"].(int64)
+	})
+	return result, nil
 }
 
-// trimTrailingZeros 去除尾部的0
-func trimTrailingZeros(s string) string {
-	// 如果没有小数点，直接返回
-	if !stringContains(s, ".") {
-		return s
+// GetFundingRate 获取当前资金费率和下次结算时间（premiumIndex）
+func (t *FuturesTrader) GetFundingRate(symbol string) (float64, time.Time, error) {
+	indexes, err := t.client.NewPremiumIndexService().Symbol(symbol).Do(context.Background())
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("获取资金费率失败: %w", err)
 	}
+	if len(indexes) == 0 {
+		return 0, time.Time{}, fmt.Errorf("未找到 %s 的资金费率", symbol)
+	}
+	rate, _ := strconv.ParseFloat(indexes[0].LastFundingRate, 64)
+	return rate, time.UnixMilli(indexes[0].NextFundingTime), nil
+}
+
+// orderResult 市价单的下单结果（包含成交信息，见addExecution）
+func (t *FuturesTrader) orderResult(order *futures.CreateOrderResponse) map[string]interface{} {
+	result := make(map[string]interface{})
+	result["orderId"] = order.OrderID
+	result["symbol"] = order.Symbol
+	result["status"] = order.Status
+	t.addExecution(result, order)
+	return result
+}
 
-	// 从后向前遍历，去除尾部的0
-	for len(s) > 0 && s[len(s)-1] == '0' {
-		s = s[:len(s)-1]
+// addExecution 按订单ID查询成交记录，在下单结果中补充成交均价和手续费
+// 成交记录查询失败时退回下单响应中的成交均价（不含手续费）
+func (t *FuturesTrader) addExecution(result map[string]interface{}, order *futures.CreateOrderResponse) {
+	trades, err := t.client.NewListAccountTradeService().
+		Symbol(order.Symbol).
+		OrderID(order.OrderID).
+		Do(context.Background())
+	if err == nil && len(trades) > 0 {
+		fills := make([]map[string]interface{}, 0, len(trades))
+		for _, trade := range trades {
+			fills = append(fills, binanceFill(trade))
+		}
+		addFillsExecution(result, fills, order.OrderID)
+		return
 	}
+	if err != nil {
+		log.Printf("  ⚠ 查询订单 %d 的成交记录失败: %v", order.OrderID, err)
+	}
+	avgPrice, _ := strconv.ParseFloat(order.AvgPrice, 64)
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	setOrderExecution(result, avgPrice, executed, 0, "")
+}
 
-	// 如果最后一位是小数点，也去掉
-	if len(s) > 0 && s[len(s)-1] == '.' {
-		s = s[:len(s)-1]
+// binanceOrderInfo 币安订单转统一的订单格式
+func binanceOrderInfo(order *futures.Order) map[string]interface{} {
+	price, _ := strconv.ParseFloat(order.Price, 64)
+	stopPrice, _ := strconv.ParseFloat(order.StopPrice, 64)
+	quantity, _ := strconv.ParseFloat(order.OrigQuantity, 64)
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+
+	orderType := string(order.Type)
+	if order.OrigType != "" {
+		orderType = string(order.OrigType)
 	}
 
-	return s
+	return map[string]interface{}{
+		"orderId":     order.OrderID,
+		"symbol":      order.Symbol,
+		"side":        string(order.Side),
+		"type":        orderType,
+		"status":      string(order.Status),
+		"price":       price,
+		"stopPrice":   stopPrice,
+		"quantity":    quantity,
+		"executedQty": executed,
+		"reduceOnly":  order.ReduceOnly || order.ClosePosition,
+	}
 }
 
-// FormatQuantity 格式化数量到正确的精度
-func (t *FuturesTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
-	precision, err := t.GetSymbolPrecision(symbol)
+// FormatPrice 按PRICE_FILTER的tickSize格式化价格
+func (t *FuturesTrader) FormatPrice(symbol string, price float64) (string, error) {
+	info, err := t.instruments.get(symbol)
 	if err != nil {
-		// 如果获取失败，使用默认格式
-		return fmt.Sprintf("%.3f", quantity), nil
+		return "", err
 	}
+	return info.FormatPrice(price), nil
+}
 
-	format := fmt.Sprintf("%%.%df", precision)
-	return fmt.Sprintf(format, quantity), nil
+// FormatQuantity 按LOT_SIZE的stepSize向下取整并格式化数量
+func (t *FuturesTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return t.instruments.formatQuantity(symbol, quantity)
 }
 
 // 辅助函数
@@ -0,0 +1,291 @@
+package trader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/gorilla/websocket"
+)
+
+// binanceKeepaliveInterval listenKey续期间隔（币安60分钟不续期即失效）
+var binanceKeepaliveInterval = 30 * time.Minute
+
+// binanceUserStream 币安用户数据流的连接状态
+type binanceUserStream struct {
+	runner *streamRunner
+
+	mu        sync.Mutex
+	listenKey string // 当前连接使用的listenKey（重连时重新获取）
+}
+
+// SetStreamURL 设置用户数据流的WebSocket地址（测试时指向模拟交易所）
+func (t *FuturesTrader) SetStreamURL(url string) {
+	t.streamURL = strings.TrimSuffix(url, "/")
+}
+
+// StartStream 创建listenKey并连接用户数据流（同一连接订阅全市场标记价格，用于更新未实现盈亏）
+// 连接后加载一次REST快照，之后余额、持仓、挂单和成交由推送更新
+func (t *FuturesTrader) StartStream() error {
+	t.streamMu.Lock()
+	defer t.streamMu.Unlock()
+	if t.stream != nil {
+		return nil
+	}
+
+	stream := &binanceUserStream{}
+	stream.runner = &streamRunner{
+		name:    "币安",
+		account: t.account,
+		dial: func() (*websocket.Conn, error) {
+			return t.dialUserStream(stream)
+		},
+		read:   t.readStream,
+		resync: t.resyncAccount,
+		ping: func(*websocket.Conn) error {
+			stream.mu.Lock()
+			listenKey := stream.listenKey
+			stream.mu.Unlock()
+			return t.client.NewKeepaliveUserStreamService().ListenKey(listenKey).Do(context.Background())
+		},
+		pingInterval: binanceKeepaliveInterval,
+	}
+	if err := stream.runner.start(); err != nil {
+		return err
+	}
+	t.stream = stream
+	log.Printf("✓ 币安用户数据流已连接，余额/持仓/挂单改为读取推送维护的本地数据")
+	return nil
+}
+
+// StopStream 关闭用户数据流并删除listenKey
+func (t *FuturesTrader) StopStream() {
+	t.streamMu.Lock()
+	stream := t.stream
+	t.stream = nil
+	t.streamMu.Unlock()
+	if stream == nil {
+		return
+	}
+
+	stream.runner.close()
+	stream.mu.Lock()
+	listenKey := stream.listenKey
+	stream.mu.Unlock()
+	if listenKey != "" {
+		if err := t.client.NewCloseUserStreamService().ListenKey(listenKey).Do(context.Background()); err != nil {
+			log.Printf("⚠️  关闭listenKey失败: %v", err)
+		}
+	}
+	log.Printf("✓ 币安用户数据流已关闭")
+}
+
+// Fills 实时成交通道（StopStream后关闭）
+func (t *FuturesTrader) Fills() <-chan map[string]interface{} {
+	return t.account.subscribeFills()
+}
+
+// dialUserStream 获取listenKey并连接组合流（用户数据 + 全市场标记价格）
+func (t *FuturesTrader) dialUserStream(stream *binanceUserStream) (*websocket.Conn, error) {
+	listenKey, err := t.client.NewStartUserStreamService().Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("创建listenKey失败: %w", err)
+	}
+	stream.mu.Lock()
+	stream.listenKey = listenKey
+	stream.mu.Unlock()
+
+	url := t.streamURL + "/stream?streams=" + listenKey + "/!markPrice@arr@1s"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	return conn, err
+}
+
+// resyncAccount 用REST查询的余额、持仓和全部挂单刷新本地模型
+func (t *FuturesTrader) resyncAccount() error {
+	balance, err := t.fetchBalance()
+	if err != nil {
+		return err
+	}
+	positions, err := t.fetchPositions()
+	if err != nil {
+		return err
+	}
+	orders, err := t.fetchOpenOrders("")
+	if err != nil {
+		return err
+	}
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	t.account.loadAccount(balance, wallet, positions)
+	t.account.loadOrders("", orders)
+	return nil
+}
+
+// streamBalance 用户数据流连接时从本地模型读取余额（本交易器刚下过单时先用REST刷新模型）
+func (t *FuturesTrader) streamBalance() (map[string]interface{}, bool) {
+	if !t.refreshDirtyAccount() {
+		return nil, false
+	}
+	return t.account.getBalance()
+}
+
+// streamPositions 用户数据流连接时从本地模型读取持仓（规则同streamBalance）
+func (t *FuturesTrader) streamPositions() ([]map[string]interface{}, bool) {
+	if !t.refreshDirtyAccount() {
+		return nil, false
+	}
+	return t.account.getPositions()
+}
+
+// refreshDirtyAccount 模型需要刷新时重新加载余额和持仓，返回模型是否可用
+func (t *FuturesTrader) refreshDirtyAccount() bool {
+	if !t.account.isLive() {
+		return false
+	}
+	if _, ok := t.account.getPositions(); ok {
+		return true
+	}
+
+	balance, err := t.fetchBalance()
+	if err != nil {
+		return false
+	}
+	positions, err := t.fetchPositions()
+	if err != nil {
+		return false
+	}
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	t.account.loadAccount(balance, wallet, positions)
+	return true
+}
+
+// readStream 处理连接上的推送，连接出错或listenKey过期时返回
+func (t *FuturesTrader) readStream(conn *websocket.Conn) error {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var envelope struct {
+			Stream string          `json:"stream"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(message, &envelope); err != nil {
+			log.Printf("⚠️  无法解析用户数据流消息: %v", err)
+			continue
+		}
+
+		if strings.HasPrefix(envelope.Stream, "!markPrice") {
+			var prices []futures.WsMarkPriceEvent
+			if err := json.Unmarshal(envelope.Data, &prices); err != nil {
+				continue
+			}
+			for _, price := range prices {
+				markPrice, _ := strconv.ParseFloat(price.MarkPrice, 64)
+				t.account.setMarkPrice(price.Symbol, markPrice)
+			}
+			continue
+		}
+
+		var event futures.WsUserDataEvent
+		if err := json.Unmarshal(envelope.Data, &event); err != nil {
+			// 不关心的事件类型（如保证金追加通知的新字段）直接忽略
+			continue
+		}
+		switch event.Event {
+		case futures.UserDataEventTypeOrderTradeUpdate:
+			t.handleOrderUpdate(event.OrderTradeUpdate)
+		case futures.UserDataEventTypeAccountUpdate:
+			t.handleAccountUpdate(event.AccountUpdate)
+		case futures.UserDataEventTypeListenKeyExpired:
+			return fmt.Errorf("listenKey已过期")
+		}
+	}
+}
+
+// handleOrderUpdate 订单状态更新挂单；有成交时记录成交并推送给订阅者
+func (t *FuturesTrader) handleOrderUpdate(update futures.WsOrderTradeUpdate) {
+	price, _ := strconv.ParseFloat(update.OriginalPrice, 64)
+	stopPrice, _ := strconv.ParseFloat(update.StopPrice, 64)
+	quantity, _ := strconv.ParseFloat(update.OriginalQty, 64)
+	executed, _ := strconv.ParseFloat(update.AccumulatedFilledQty, 64)
+	orderType := string(update.Type)
+	if update.OriginalType != "" {
+		orderType = string(update.OriginalType)
+	}
+
+	t.account.updateOrder(map[string]interface{}{
+		"orderId":     update.ID,
+		"symbol":      update.Symbol,
+		"side":        string(update.Side),
+		"type":        orderType,
+		"status":      string(update.Status),
+		"price":       price,
+		"stopPrice":   stopPrice,
+		"quantity":    quantity,
+		"executedQty": executed,
+		"reduceOnly":  update.IsReduceOnly || update.IsClosingPosition,
+	})
+
+	if update.ExecutionType != futures.OrderExecutionTypeTrade {
+		return
+	}
+	t.rememberSymbol(update.Symbol)
+	fillPrice, _ := strconv.ParseFloat(update.LastFilledPrice, 64)
+	fillQty, _ := strconv.ParseFloat(update.LastFilledQty, 64)
+	fee, _ := strconv.ParseFloat(update.Commission, 64)
+	realizedPnl, _ := strconv.ParseFloat(update.RealizedPnL, 64)
+	t.account.addFill(map[string]interface{}{
+		"symbol":      update.Symbol,
+		"orderId":     update.ID,
+		"side":        string(update.Side),
+		"time":        update.TradeTime,
+		"price":       fillPrice,
+		"quantity":    fillQty,
+		"fee":         fee,
+		"feeAsset":    update.CommissionAsset,
+		"realizedPnl": realizedPnl,
+		"orderType":   orderType,
+	})
+}
+
+// handleAccountUpdate 更新USDT钱包余额和推送中的持仓
+func (t *FuturesTrader) handleAccountUpdate(update futures.WsAccountUpdate) {
+	for _, balance := range update.Balances {
+		if balance.Asset != "USDT" {
+			continue
+		}
+		wallet, _ := strconv.ParseFloat(balance.Balance, 64)
+		t.account.setWallet(wallet)
+	}
+
+	for _, pos := range update.Positions {
+		amount, _ := strconv.ParseFloat(pos.Amount, 64)
+		entryPrice, _ := strconv.ParseFloat(pos.EntryPrice, 64)
+		unrealized, _ := strconv.ParseFloat(pos.UnrealizedPnL, 64)
+
+		switch pos.Side {
+		case futures.PositionSideTypeLong:
+			t.account.updatePosition(pos.Symbol, "long", math.Abs(amount), entryPrice, unrealized)
+		case futures.PositionSideTypeShort:
+			t.account.updatePosition(pos.Symbol, "short", math.Abs(amount), entryPrice, unrealized)
+		default:
+			// 单向持仓：数量的符号表示方向，另一个方向一定没有持仓
+			long, short := 0.0, 0.0
+			if amount > 0 {
+				long = amount
+			} else {
+				short = -amount
+			}
+			t.account.updatePosition(pos.Symbol, "long", long, entryPrice, unrealized)
+			t.account.updatePosition(pos.Symbol, "short", short, entryPrice, unrealized)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package trader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clientOrderLookupDelay 下单结果未知时，等待多久再按客户端订单ID查询（给交易所处理订单的时间）
+var clientOrderLookupDelay = time.Second
+
+// clientOrderIDs 生成下单使用的客户端订单ID
+// AutoTrader在每个决策执行前设置前缀，同一前缀下的订单依次编号为 前缀-1、前缀-2…；前缀为空时不带客户端订单ID
+type clientOrderIDs struct {
+	mu     sync.Mutex
+	prefix string
+	seq    int
+}
+
+// setPrefix 设置前缀并重新编号
+func (c *clientOrderIDs) setPrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prefix = prefix
+	c.seq = 0
+}
+
+// next 下一张订单的客户端订单ID（没有前缀时返回空字符串）
+func (c *clientOrderIDs) next() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prefix == "" {
+		return ""
+	}
+	c.seq++
+	return fmt.Sprintf("%s-%d", c.prefix, c.seq)
+}
+
+// submitOrder 提交带客户端订单ID的订单：place发送下单请求，lookup按客户端订单ID查询订单并填充返回值
+// 下单结果未知（请求超时、网关超时、连接中断）时先查询：交易所已接受的按成功处理，
+// 确认交易所没有这张订单才用同一个ID重试一次；没有客户端订单ID时直接返回下单结果
+func submitOrder(clientOrderID string, place func() error, lookup func() error) error {
+	err := place()
+	if err == nil || clientOrderID == "" || !isOrderOutcomeUnknown(err) {
+		return err
+	}
+
+	log.Printf("  ⚠ 下单结果未知（%v），按客户端订单ID %s 查询", err, clientOrderID)
+	time.Sleep(clientOrderLookupDelay)
+	lookupErr := lookup()
+	if lookupErr == nil {
+		log.Printf("  ✓ 订单 %s 已被交易所接受", clientOrderID)
+		return nil
+	}
+	if !errors.Is(lookupErr, ErrOrderNotFound) {
+		return fmt.Errorf("%w（按客户端订单ID %s 查询失败: %v）", err, clientOrderID, lookupErr)
+	}
+
+	log.Printf("  交易所没有订单 %s，重试一次", clientOrderID)
+	return place()
+}
+
+// isOrderOutcomeUnknown 下单请求的错误是否意味着结果未知（交易所可能已经接受了订单）
+func isOrderOutcomeUnknown(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// 币安/Aster -1007: Timeout waiting for response from backend server. Send status unknown; execution status unknown.
+	msg := err.Error()
+	for _, s := range []string{"-1007", "status 504", "HTTP 504", "timeout", "Timeout", "connection reset", "EOF"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
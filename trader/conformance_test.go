@@ -0,0 +1,32 @@
+package trader_test
+
+import (
+	"danto/trader"
+	"danto/trader/tradertest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	for _, adapter := range newTestAdapters(t) {
+		t.Run(adapter.name, func(t *testing.T) {
+			tradertest.RunConformance(t, tradertest.Harness{
+				Trader:           adapter.trader,
+				TimeoutNextOrder: adapter.server.TimeoutNextOrder,
+			})
+		})
+	}
+}
+
+func TestBinanceOneWayConformance(t *testing.T) {
+	binance := newTestAdapters(t)[0]
+	tradertest.RunConformance(t, tradertest.Harness{
+		Trader:           binance.trader,
+		TimeoutNextOrder: binance.server.TimeoutNextOrder,
+		PositionMode:     trader.PositionModeOneWay,
+	})
+}
+
+func TestFakeTraderConformance(t *testing.T) {
+	fake := trader.NewFakeTrader(10000, map[string]float64{"BTCUSDT": 100000})
+	tradertest.RunConformance(t, tradertest.Harness{Trader: fake})
+}
@@ -8,9 +8,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,6 +25,20 @@ type DeltaTrader struct {
 	apiSecret string
 	baseURL   string
 	client    *http.Client
+	trailing  *trailingEngine // client-side trailing stops (moves the stop loss order as price updates)
+
+	// client order IDs (see SetClientOrderIDPrefix)
+	clientIDs clientOrderIDs
+
+	// margin and position modes (see ConfigureAccountModes): isolated margin, one-way positions
+	modes accountModes
+
+	// instrument rules built from product metadata (see GetInstrument)
+	instruments *instrumentRegistry
+
+	// product metadata by symbol, loaded and refreshed together with the instrument rules
+	productsMu sync.Mutex
+	products   map[string]deltaProduct
 }
 
 // NewDeltaTrader creates new Delta Exchange trader
@@ -30,12 +48,21 @@ func NewDeltaTrader(apiKey, apiSecret string, testnet bool) *DeltaTrader {
 		baseURL = "https://testnet-api.delta.exchange"
 	}
 
-	return &DeltaTrader{
+	dt := &DeltaTrader{
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
 		baseURL:   baseURL,
 		client:    &http.Client{Timeout: 30 * time.Second},
 	}
+	dt.trailing = newTrailingEngine(dt, trailingStopInterval)
+	dt.modes.set(MarginModeIsolated, PositionModeOneWay)
+	dt.instruments = newInstrumentRegistry("Delta Exchange", dt.loadInstruments)
+	return dt
+}
+
+// SetBaseURL overrides the API base URL (e.g. a local stand-in server)
+func (dt *DeltaTrader) SetBaseURL(url string) {
+	dt.baseURL = strings.TrimSuffix(url, "/")
 }
 
 // generateSignature generates Delta Exchange API signature
@@ -143,14 +170,14 @@ func (dt *DeltaTrader) GetPositions() ([]map[string]interface{}, error) {
 	var response struct {
 		Success bool `json:"success"`
 		Result  []struct {
-			Symbol       string  `json:"symbol"`
-			Size         float64 `json:"size,string"`
-			EntryPrice   float64 `json:"entry_price,string"`
-			MarkPrice    float64 `json:"mark_price,string"`
-			PnL          float64 `json:"unrealized_pnl,string"`
-			PnLPercent   float64 `json:"unrealized_pnl_percent,string"`
-			Side         string  `json:"side"`
-			Leverage     int     `json:"leverage"`
+			Symbol           string  `json:"symbol"`
+			Size             float64 `json:"size,string"`
+			EntryPrice       float64 `json:"entry_price,string"`
+			MarkPrice        float64 `json:"mark_price,string"`
+			LiquidationPrice float64 `json:"liquidation_price,string"`
+			PnL              float64 `json:"unrealized_pnl,string"`
+			PnLPercent       float64 `json:"unrealized_pnl_percent,string"`
+			Leverage         float64 `json:"leverage"`
 		} `json:"result"`
 	}
 
@@ -162,55 +189,181 @@ func (dt *DeltaTrader) GetPositions() ([]map[string]interface{}, error) {
 		return nil, fmt.Errorf("API returned error")
 	}
 
+	// Delta is one-way: size is signed (in contracts), negative for shorts
 	var positions []map[string]interface{}
 	for _, pos := range response.Result {
-		if pos.Size != 0 {
-			positions = append(positions, map[string]interface{}{
-				"symbol":                 pos.Symbol,
-				"positionAmt":           pos.Size,
-				"entryPrice":            pos.EntryPrice,
-				"markPrice":             pos.MarkPrice,
-				"unRealizedProfit":      pos.PnL,
-				"percentage":            pos.PnLPercent,
-				"positionSide":          strings.ToUpper(pos.Side),
-				"leverage":              pos.Leverage,
-			})
+		if pos.Size == 0 {
+			continue
+		}
+		side := "long"
+		if pos.Size < 0 {
+			side = "short"
 		}
+		positions = append(positions, map[string]interface{}{
+			"symbol":           pos.Symbol,
+			"side":             side,
+			"positionAmt":      dt.baseQuantity(pos.Symbol, math.Abs(pos.Size)),
+			"entryPrice":       pos.EntryPrice,
+			"markPrice":        pos.MarkPrice,
+			"unRealizedProfit": pos.PnL,
+			"percentage":       pos.PnLPercent,
+			"leverage":         pos.Leverage,
+			"liquidationPrice": pos.LiquidationPrice,
+		})
 	}
 
 	return positions, nil
 }
 
-// getProductId gets product ID for symbol
-func (dt *DeltaTrader) getProductId(symbol string) (int, error) {
+// deltaProduct is the subset of product metadata the trader needs
+type deltaProduct struct {
+	ID            int     `json:"id"`
+	Symbol        string  `json:"symbol"`
+	ContractValue float64 `json:"contract_value,string"` // base asset per contract
+	TickSize      float64 `json:"tick_size,string"`
+	InitialMargin float64 `json:"initial_margin,string"` // percent of notional, max leverage = 100 / initial_margin
+	QuotingAsset  struct {
+		Symbol string `json:"symbol"`
+	} `json:"quoting_asset"`
+	SettlingAsset struct {
+		Symbol string `json:"symbol"`
+	} `json:"settling_asset"`
+}
+
+// getProduct gets the cached product metadata for symbol (products are only re-fetched when the
+// instrument rules are refreshed)
+func (dt *DeltaTrader) getProduct(symbol string) (deltaProduct, error) {
+	if _, err := dt.instruments.get(symbol); err != nil {
+		return deltaProduct{}, err
+	}
+
+	dt.productsMu.Lock()
+	defer dt.productsMu.Unlock()
+	product, ok := dt.products[symbol]
+	if !ok {
+		return deltaProduct{}, fmt.Errorf("product not found: %s", symbol)
+	}
+	return product, nil
+}
+
+// getProducts gets metadata for all products
+func (dt *DeltaTrader) getProducts() ([]deltaProduct, error) {
 	respBody, err := dt.makeRequest("GET", "/v2/products", nil)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	var response struct {
-		Success bool `json:"success"`
-		Result  []struct {
-			ID     int    `json:"id"`
-			Symbol string `json:"symbol"`
-		} `json:"result"`
+		Success bool           `json:"success"`
+		Result  []deltaProduct `json:"result"`
 	}
 
 	if err := json.Unmarshal(respBody, &response); err != nil {
-		return 0, fmt.Errorf("unmarshal response failed: %w", err)
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+	return response.Result, nil
+}
+
+// loadInstruments builds instrument rules from product metadata and caches the products.
+// Orders are sized in whole contracts, so the quantity step and minimum are one contract value.
+// Only linear contracts (settled in the quoting asset) are listed: inverse contracts are sized in
+// quote currency and don't fit the base-asset quantities used by the Trader interface
+func (dt *DeltaTrader) loadInstruments() ([]InstrumentInfo, error) {
+	products, err := dt.getProducts()
+	if err != nil {
+		return nil, err
 	}
 
-	for _, product := range response.Result {
-		if product.Symbol == symbol {
-			return product.ID, nil
+	infos := make([]InstrumentInfo, 0, len(products))
+	bySymbol := make(map[string]deltaProduct, len(products))
+	for _, product := range products {
+		if product.ContractValue <= 0 || product.SettlingAsset.Symbol != product.QuotingAsset.Symbol {
+			continue
 		}
+		maxLeverage := 0
+		if product.InitialMargin > 0 {
+			maxLeverage = int(100/product.InitialMargin + 1e-9)
+		}
+		infos = append(infos, InstrumentInfo{
+			Symbol:             product.Symbol,
+			TickSize:           product.TickSize,
+			StepSize:           product.ContractValue,
+			MinQuantity:        product.ContractValue,
+			MaxLeverage:        maxLeverage,
+			ContractMultiplier: product.ContractValue,
+		})
+		bySymbol[product.Symbol] = product
+	}
+
+	dt.productsMu.Lock()
+	dt.products = bySymbol
+	dt.productsMu.Unlock()
+	return infos, nil
+}
+
+// GetInstrument returns the cached instrument rules for symbol
+func (dt *DeltaTrader) GetInstrument(symbol string) (InstrumentInfo, error) {
+	return dt.instruments.get(symbol)
+}
+
+// getProductId gets product ID for symbol
+func (dt *DeltaTrader) getProductId(symbol string) (int, error) {
+	product, err := dt.getProduct(symbol)
+	if err != nil {
+		return 0, err
 	}
+	return product.ID, nil
+}
 
-	return 0, fmt.Errorf("product not found: %s", symbol)
+// toContracts converts a base-asset quantity (already rounded to the contract value) into whole contracts
+func toContracts(info InstrumentInfo, quantity float64) int64 {
+	return int64(math.Round(quantity / info.ContractMultiplier))
+}
+
+// baseQuantity converts a size in contracts reported by Delta into the base-asset quantity
+// (sizes of unknown products are returned unchanged)
+func (dt *DeltaTrader) baseQuantity(symbol string, contracts float64) float64 {
+	product, err := dt.getProduct(symbol)
+	if err != nil {
+		return contracts
+	}
+	value, _ := strconv.ParseFloat(strconv.FormatFloat(contracts*product.ContractValue, 'f', stepDecimals(product.ContractValue), 64), 64)
+	return value
+}
+
+// positionSize returns the open size for symbol on the given side ("long"/"short")
+func (dt *DeltaTrader) positionSize(symbol, side string) (float64, error) {
+	positions, err := dt.GetPositions()
+	if err != nil {
+		return 0, err
+	}
+	for _, pos := range positions {
+		if pos["symbol"] == symbol && pos["side"] == side {
+			return pos["positionAmt"].(float64), nil
+		}
+	}
+	return 0, noPositionError(symbol, side)
 }
 
 // OpenLong opens long position
 func (dt *DeltaTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	// Market orders are checked at the current price so min-notional and leverage tiers still apply
+	price, err := dt.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round the size down to whole contracts and check it against the instrument rules
+	info, quantity, _, err := dt.instruments.prepareOrder(symbol, quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delta keeps the order leverage per product
+	if err := dt.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
 	productId, err := dt.getProductId(symbol)
 	if err != nil {
 		return nil, err
@@ -218,13 +371,12 @@ func (dt *DeltaTrader) OpenLong(symbol string, quantity float64, leverage int) (
 
 	params := map[string]interface{}{
 		"product_id": productId,
-		"size":       quantity,
+		"size":       toContracts(info, quantity),
 		"side":       "buy",
 		"order_type": "market_order",
-		"leverage":   leverage,
 	}
 
-	respBody, err := dt.makeRequest("POST", "/v2/orders", params)
+	respBody, err := dt.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
@@ -233,12 +385,30 @@ func (dt *DeltaTrader) OpenLong(symbol string, quantity float64, leverage int) (
 	if err := json.Unmarshal(respBody, &response); err != nil {
 		return nil, fmt.Errorf("unmarshal response failed: %w", err)
 	}
+	dt.addExecution(response, symbol, respBody)
 
 	return response, nil
 }
 
 // OpenShort opens short position
 func (dt *DeltaTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	// Market orders are checked at the current price so min-notional and leverage tiers still apply
+	price, err := dt.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round the size down to whole contracts and check it against the instrument rules
+	info, quantity, _, err := dt.instruments.prepareOrder(symbol, quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delta keeps the order leverage per product
+	if err := dt.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
 	productId, err := dt.getProductId(symbol)
 	if err != nil {
 		return nil, err
@@ -246,13 +416,12 @@ func (dt *DeltaTrader) OpenShort(symbol string, quantity float64, leverage int)
 
 	params := map[string]interface{}{
 		"product_id": productId,
-		"size":       quantity,
+		"size":       toContracts(info, quantity),
 		"side":       "sell",
 		"order_type": "market_order",
-		"leverage":   leverage,
 	}
 
-	respBody, err := dt.makeRequest("POST", "/v2/orders", params)
+	respBody, err := dt.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
@@ -261,26 +430,147 @@ func (dt *DeltaTrader) OpenShort(symbol string, quantity float64, leverage int)
 	if err := json.Unmarshal(respBody, &response); err != nil {
 		return nil, fmt.Errorf("unmarshal response failed: %w", err)
 	}
+	dt.addExecution(response, symbol, respBody)
 
 	return response, nil
 }
 
-// CloseLong closes long position
+// OpenLimit places a limit entry order (GTC, IOC or POST_ONLY) without touching existing orders
+func (dt *DeltaTrader) OpenLimit(symbol, positionSide string, quantity, price float64, leverage int, timeInForce string) (map[string]interface{}, error) {
+	if err := validatePositionSide(positionSide); err != nil {
+		return nil, err
+	}
+
+	// Round size (to whole contracts) and price to the instrument rules before placing the order
+	info, quantity, price, err := dt.instruments.prepareOrder(symbol, quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dt.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	product, err := dt.getProduct(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	side := "buy"
+	if positionSide == "SHORT" {
+		side = "sell"
+	}
+
+	params := map[string]interface{}{
+		"product_id":    product.ID,
+		"size":          toContracts(info, quantity),
+		"side":          side,
+		"order_type":    "limit_order",
+		"limit_price":   strconv.FormatFloat(price, 'f', -1, 64),
+		"time_in_force": "gtc",
+	}
+	switch timeInForce {
+	case TimeInForceGTC:
+	case TimeInForceIOC:
+		params["time_in_force"] = "ioc"
+	case TimeInForcePostOnly:
+		params["post_only"] = true
+	default:
+		return nil, fmt.Errorf("unsupported time in force: %s", timeInForce)
+	}
+
+	respBody, err := dt.placeOrder(symbol, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result struct {
+			ID    int64  `json:"id"`
+			State string `json:"state"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+
+	// Map Delta order states onto the shared FILLED/NEW/EXPIRED statuses
+	status := "NEW"
+	switch response.Result.State {
+	case "closed":
+		status = "FILLED"
+	case "cancelled":
+		if timeInForce == TimeInForcePostOnly {
+			return nil, fmt.Errorf("post-only order for %s would have crossed the book and was cancelled", symbol)
+		}
+		status = "EXPIRED"
+	}
+
+	result := map[string]interface{}{
+		"orderId": response.Result.ID,
+		"symbol":  symbol,
+		"status":  status,
+	}
+	if status == "FILLED" {
+		dt.addExecution(result, symbol, respBody)
+	}
+	return result, nil
+}
+
+// addExecution copies the fill summary of an order response (average fill price, filled size and
+// paid commission) into result; sizes are reported in contracts and commissions in the product's settling asset
+func (dt *DeltaTrader) addExecution(result map[string]interface{}, symbol string, respBody []byte) {
+	var response struct {
+		Result struct {
+			Size             float64 `json:"size"`
+			UnfilledSize     float64 `json:"unfilled_size"`
+			AverageFillPrice string  `json:"average_fill_price"`
+			PaidCommission   string  `json:"paid_commission"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return
+	}
+	avgPrice, _ := strconv.ParseFloat(response.Result.AverageFillPrice, 64)
+	fee, _ := strconv.ParseFloat(response.Result.PaidCommission, 64)
+	feeAsset := ""
+	if product, err := dt.getProduct(symbol); err == nil {
+		feeAsset = product.SettlingAsset.Symbol
+	}
+	executed := dt.baseQuantity(symbol, response.Result.Size-response.Result.UnfilledSize)
+	setOrderExecution(result, avgPrice, executed, fee, feeAsset)
+}
+
+// CloseLong closes long position (quantity=0 closes the whole position)
 func (dt *DeltaTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	if quantity == 0 {
+		size, err := dt.positionSize(symbol, "long")
+		if err != nil {
+			return nil, err
+		}
+		quantity = size
+	}
+
+	// Closes are reduce-only, so only the rounding to whole contracts applies
+	info, quantity, _, err := dt.instruments.prepareOrder(symbol, quantity, 0, 0, true)
+	if err != nil {
+		return nil, err
+	}
+
 	productId, err := dt.getProductId(symbol)
 	if err != nil {
 		return nil, err
 	}
 
 	params := map[string]interface{}{
-		"product_id":    productId,
-		"size":          quantity,
-		"side":          "sell",
-		"order_type":    "market_order",
-		"reduce_only":   true,
+		"product_id":  productId,
+		"size":        toContracts(info, quantity),
+		"side":        "sell",
+		"order_type":  "market_order",
+		"reduce_only": true,
 	}
 
-	respBody, err := dt.makeRequest("POST", "/v2/orders", params)
+	respBody, err := dt.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
@@ -289,26 +579,41 @@ func (dt *DeltaTrader) CloseLong(symbol string, quantity float64) (map[string]in
 	if err := json.Unmarshal(respBody, &response); err != nil {
 		return nil, fmt.Errorf("unmarshal response failed: %w", err)
 	}
+	dt.addExecution(response, symbol, respBody)
 
 	return response, nil
 }
 
-// CloseShort closes short position
+// CloseShort closes short position (quantity=0 closes the whole position)
 func (dt *DeltaTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	if quantity == 0 {
+		size, err := dt.positionSize(symbol, "short")
+		if err != nil {
+			return nil, err
+		}
+		quantity = size
+	}
+
+	// Closes are reduce-only, so only the rounding to whole contracts applies
+	info, quantity, _, err := dt.instruments.prepareOrder(symbol, quantity, 0, 0, true)
+	if err != nil {
+		return nil, err
+	}
+
 	productId, err := dt.getProductId(symbol)
 	if err != nil {
 		return nil, err
 	}
 
 	params := map[string]interface{}{
-		"product_id":    productId,
-		"size":          quantity,
-		"side":          "buy",
-		"order_type":    "market_order",
-		"reduce_only":   true,
+		"product_id":  productId,
+		"size":        toContracts(info, quantity),
+		"side":        "buy",
+		"order_type":  "market_order",
+		"reduce_only": true,
 	}
 
-	respBody, err := dt.makeRequest("POST", "/v2/orders", params)
+	respBody, err := dt.placeOrder(symbol, params)
 	if err != nil {
 		return nil, err
 	}
@@ -317,26 +622,55 @@ func (dt *DeltaTrader) CloseShort(symbol string, quantity float64) (map[string]i
 	if err := json.Unmarshal(respBody, &response); err != nil {
 		return nil, fmt.Errorf("unmarshal response failed: %w", err)
 	}
+	dt.addExecution(response, symbol, respBody)
 
 	return response, nil
 }
 
-// SetLeverage sets leverage for symbol
+// SetLeverage sets the order leverage for symbol (returns ErrInvalidLeverage above the product's limit)
 func (dt *DeltaTrader) SetLeverage(symbol string, leverage int) error {
+	info, err := dt.instruments.get(symbol)
+	if err != nil {
+		return err
+	}
+	if info.MaxLeverage > 0 && leverage > info.MaxLeverage {
+		return fmt.Errorf("%w: %s allows at most %dx, requested %dx", ErrInvalidLeverage, symbol, info.MaxLeverage, leverage)
+	}
+
 	productId, err := dt.getProductId(symbol)
 	if err != nil {
 		return err
 	}
 
 	params := map[string]interface{}{
-		"product_id": productId,
-		"leverage":   leverage,
+		"leverage": leverage,
 	}
 
-	_, err = dt.makeRequest("POST", "/v2/positions/change_margin", params)
+	_, err = dt.makeRequest("POST", fmt.Sprintf("/v2/products/%d/orders/leverage", productId), params)
 	return err
 }
 
+// ConfigureAccountModes checks the configured modes against what Delta supports through the API:
+// positions are one-way (one net position per product, closes are sent reduce_only) with isolated margin,
+// so hedge mode and cross margin return ErrUnsupportedAccountMode
+func (dt *DeltaTrader) ConfigureAccountModes(marginMode, positionMode string) error {
+	if err := ValidateAccountModes(marginMode, positionMode); err != nil {
+		return err
+	}
+	if positionMode == PositionModeHedge {
+		return unsupportedModeError("Delta Exchange", positionMode)
+	}
+	if marginMode == MarginModeCross {
+		return unsupportedModeError("Delta Exchange", marginMode)
+	}
+	return nil
+}
+
+// AccountModes returns the margin and position modes in use
+func (dt *DeltaTrader) AccountModes() (marginMode, positionMode string) {
+	return dt.modes.get()
+}
+
 // GetMarketPrice gets current market price
 func (dt *DeltaTrader) GetMarketPrice(symbol string) (float64, error) {
 	endpoint := fmt.Sprintf("/v2/tickers/%s", symbol)
@@ -363,33 +697,55 @@ func (dt *DeltaTrader) GetMarketPrice(symbol string) (float64, error) {
 	return response.Result.Price, nil
 }
 
-// SetStopLoss sets stop loss order
+// SetStopLoss attaches a bracket stop loss to the position: it closes the whole position when triggered
+// (quantity is not used, the bracket follows the live position size)
 func (dt *DeltaTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
-	productId, err := dt.getProductId(symbol)
-	if err != nil {
+	return dt.placeBracket(symbol, positionSide, "stop_loss_order", stopPrice)
+}
+
+// SetTakeProfit attaches a bracket take profit to the position (see SetStopLoss)
+func (dt *DeltaTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return dt.placeBracket(symbol, positionSide, "take_profit_order", takeProfitPrice)
+}
+
+// placeBracket places one leg ("stop_loss_order" or "take_profit_order") of the position's bracket order;
+// Delta allows one leg of each type per position, triggering a market order for the whole position
+func (dt *DeltaTrader) placeBracket(symbol, positionSide, leg string, stopPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
 		return err
 	}
 
-	side := "sell"
-	if positionSide == "SHORT" {
-		side = "buy"
+	info, err := dt.instruments.get(symbol)
+	if err != nil {
+		return err
+	}
+	productId, err := dt.getProductId(symbol)
+	if err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
-		"product_id":   productId,
-		"size":         quantity,
-		"side":         side,
-		"order_type":   "stop_loss_order",
-		"stop_price":   stopPrice,
-		"reduce_only":  true,
+		"product_id":     productId,
+		"product_symbol": symbol,
+		leg: map[string]interface{}{
+			"order_type": "market_order",
+			"stop_price": info.FormatPrice(stopPrice),
+		},
+		"bracket_stop_trigger_method": "last_traded_price",
 	}
 
-	_, err = dt.makeRequest("POST", "/v2/orders", params)
+	_, err = dt.makeRequest("POST", "/v2/orders/bracket", params)
 	return err
 }
 
-// SetTakeProfit sets take profit order
-func (dt *DeltaTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+// placeTakeProfitOrder places a sized reduce-only take profit order (used for ladder levels, which
+// can't share the position's single bracket take profit)
+func (dt *DeltaTrader) placeTakeProfitOrder(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	info, quantity, _, err := dt.instruments.prepareOrder(symbol, quantity, 0, 0, true)
+	if err != nil {
+		return err
+	}
+
 	productId, err := dt.getProductId(symbol)
 	if err != nil {
 		return err
@@ -401,18 +757,45 @@ func (dt *DeltaTrader) SetTakeProfit(symbol string, positionSide string, quantit
 	}
 
 	params := map[string]interface{}{
-		"product_id":   productId,
-		"size":         quantity,
-		"side":         side,
-		"order_type":   "take_profit_order",
-		"limit_price":  takeProfitPrice,
-		"reduce_only":  true,
+		"product_id":  productId,
+		"size":        toContracts(info, quantity),
+		"side":        side,
+		"order_type":  "take_profit_order",
+		"stop_price":  info.FormatPrice(takeProfitPrice),
+		"reduce_only": true,
 	}
 
-	_, err = dt.makeRequest("POST", "/v2/orders", params)
+	_, err = dt.placeOrder(symbol, params)
 	return err
 }
 
+// UpdateStopLoss replaces the bracket stop loss: only one is allowed per position, so the old one is
+// cancelled first and restored if the new one can't be placed
+func (dt *DeltaTrader) UpdateStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return replaceProtectiveOrder(dt, symbol, positionSide, "STOP_MARKET", quantity, stopPrice, false)
+}
+
+// UpdateTakeProfit replaces the bracket take profit (see UpdateStopLoss)
+func (dt *DeltaTrader) UpdateTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return replaceProtectiveOrder(dt, symbol, positionSide, "TAKE_PROFIT_MARKET", quantity, takeProfitPrice, false)
+}
+
+// SetTakeProfitLadder places one reduce-only take profit order per ladder level
+func (dt *DeltaTrader) SetTakeProfitLadder(symbol string, positionSide string, quantity float64, levels []TakeProfitLevel) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+	return placeTakeProfitLadder(dt, symbol, positionSide, quantity, levels, func(quantity, price float64) error {
+		return dt.placeTakeProfitOrder(symbol, positionSide, quantity, price)
+	})
+}
+
+// SetTrailingStop sets a trailing stop, tracked client-side: the stop loss order is moved as price updates
+// (the quantity follows the live position)
+func (dt *DeltaTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackRate, activationPrice float64) error {
+	return dt.trailing.set(symbol, positionSide, callbackRate, activationPrice)
+}
+
 // CancelAllOrders cancels all orders for symbol
 func (dt *DeltaTrader) CancelAllOrders(symbol string) error {
 	productId, err := dt.getProductId(symbol)
@@ -428,8 +811,326 @@ func (dt *DeltaTrader) CancelAllOrders(symbol string) error {
 	return err
 }
 
-// FormatQuantity formats quantity to correct precision
+// CancelOrder cancels a single order
+func (dt *DeltaTrader) CancelOrder(symbol string, orderID int64) error {
+	productId, err := dt.getProductId(symbol)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]interface{}{
+		"id":         orderID,
+		"product_id": productId,
+	}
+
+	_, err = dt.makeRequest("DELETE", "/v2/orders", params)
+	return err
+}
+
+// deltaOrder is the subset of Delta order fields the trader reads
+type deltaOrder struct {
+	ID            int64   `json:"id"`
+	ProductSymbol string  `json:"product_symbol"`
+	OrderType     string  `json:"order_type"`
+	StopOrderType string  `json:"stop_order_type"`
+	Side          string  `json:"side"`
+	Size          float64 `json:"size"`
+	UnfilledSize  float64 `json:"unfilled_size"`
+	State         string  `json:"state"`
+	LimitPrice    string  `json:"limit_price"`
+	StopPrice     string  `json:"stop_price"`
+	ReduceOnly    bool    `json:"reduce_only"`
+}
+
+// toMap converts a Delta order into the shared order format (contract sizes are converted to
+// base-asset quantities with contractValue)
+func (o deltaOrder) toMap(symbol string, contractValue float64) map[string]interface{} {
+	price, _ := strconv.ParseFloat(o.LimitPrice, 64)
+	stopPrice, _ := strconv.ParseFloat(o.StopPrice, 64)
+
+	orderType := "LIMIT"
+	kind := o.OrderType
+	if o.StopOrderType != "" {
+		kind = o.StopOrderType
+	}
+	switch kind {
+	case "stop_loss_order":
+		orderType = "STOP_MARKET"
+	case "take_profit_order":
+		orderType = "TAKE_PROFIT_MARKET"
+	case "market_order":
+		orderType = "MARKET"
+	}
+
+	quantity := o.Size * contractValue
+	executed := (o.Size - o.UnfilledSize) * contractValue
+	status := "NEW"
+	switch o.State {
+	case "open", "pending":
+		if executed > 0 {
+			status = "PARTIALLY_FILLED"
+		}
+	case "closed":
+		status = "FILLED"
+	case "cancelled":
+		status = "CANCELED"
+	}
+
+	return map[string]interface{}{
+		"orderId":     o.ID,
+		"symbol":      symbol,
+		"side":        strings.ToUpper(o.Side),
+		"type":        orderType,
+		"status":      status,
+		"price":       price,
+		"stopPrice":   stopPrice,
+		"quantity":    quantity,
+		"executedQty": executed,
+		"reduceOnly":  o.ReduceOnly,
+	}
+}
+
+// GetOpenOrders gets open orders for symbol
+func (dt *DeltaTrader) GetOpenOrders(symbol string) ([]map[string]interface{}, error) {
+	productId, err := dt.getProductId(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := dt.makeRequest("GET", fmt.Sprintf("/v2/orders?product_ids=%d&states=open,pending", productId), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result []deltaOrder `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+
+	result := []map[string]interface{}{}
+	for _, order := range response.Result {
+		if order.ProductSymbol != symbol {
+			continue
+		}
+		result = append(result, order.toMap(symbol, dt.baseQuantity(symbol, 1)))
+	}
+	return result, nil
+}
+
+// GetOrder gets a single order by ID (including filled and cancelled orders)
+func (dt *DeltaTrader) GetOrder(symbol string, orderID int64) (map[string]interface{}, error) {
+	respBody, err := dt.makeRequest("GET", fmt.Sprintf("/v2/orders/%d", orderID), nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "open_order_not_found") || strings.Contains(err.Error(), "status 404") {
+			return nil, fmt.Errorf("%s order %d: %w", symbol, orderID, ErrOrderNotFound)
+		}
+		return nil, err
+	}
+
+	var response struct {
+		Result deltaOrder `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+	return response.Result.toMap(symbol, dt.baseQuantity(symbol, 1)), nil
+}
+
+// GetOrderByClientID gets a single order by client order ID
+func (dt *DeltaTrader) GetOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, error) {
+	respBody, err := dt.queryOrderByClientID(symbol, clientOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result deltaOrder `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+	return response.Result.toMap(symbol, dt.baseQuantity(symbol, 1)), nil
+}
+
+// queryOrderByClientID fetches the raw order response for a client order ID
+func (dt *DeltaTrader) queryOrderByClientID(symbol, clientOrderID string) ([]byte, error) {
+	respBody, err := dt.makeRequest("GET", "/v2/orders/client_order_id/"+url.PathEscape(clientOrderID), nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "open_order_not_found") || strings.Contains(err.Error(), "status 404") {
+			return nil, fmt.Errorf("%s order %s: %w", symbol, clientOrderID, ErrOrderNotFound)
+		}
+		return nil, err
+	}
+	return respBody, nil
+}
+
+// SetClientOrderIDPrefix sets the client_order_id prefix for subsequent orders
+func (dt *DeltaTrader) SetClientOrderIDPrefix(prefix string) {
+	dt.clientIDs.setPrefix(prefix)
+}
+
+// placeOrder posts an order tagged with the next client order ID; when the outcome is unknown
+// the order is looked up by that ID before any retry (see submitOrder)
+func (dt *DeltaTrader) placeOrder(symbol string, params map[string]interface{}) ([]byte, error) {
+	clientOrderID := dt.clientIDs.next()
+	if clientOrderID != "" {
+		params["client_order_id"] = clientOrderID
+	}
+
+	var respBody []byte
+	err := submitOrder(clientOrderID, func() error {
+		var err error
+		respBody, err = dt.makeRequest("POST", "/v2/orders", params)
+		return err
+	}, func() error {
+		var err error
+		respBody, err = dt.queryOrderByClientID(symbol, clientOrderID)
+		return err
+	})
+	return respBody, err
+}
+
+// GetFills gets fills since the given time
+func (dt *DeltaTrader) GetFills(since time.Time) ([]map[string]interface{}, error) {
+	respBody, err := dt.makeRequest("GET", fmt.Sprintf("/v2/fills?start_time=%d", since.UnixMicro()), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result []struct {
+			OrderID       string  `json:"order_id"`
+			ProductSymbol string  `json:"product_symbol"`
+			Side          string  `json:"side"`
+			Size          float64 `json:"size"`
+			Price         string  `json:"price"`
+			Commission    string  `json:"commission"`
+			CreatedAt     string  `json:"created_at"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(response.Result))
+	for _, fill := range response.Result {
+		orderID, _ := strconv.ParseInt(fill.OrderID, 10, 64)
+		price, _ := strconv.ParseFloat(fill.Price, 64)
+		fee, _ := strconv.ParseFloat(fill.Commission, 64)
+		createdAt, _ := time.Parse(time.RFC3339Nano, fill.CreatedAt)
+		// Sizes are in contracts; commissions are charged in the product's settling asset
+		feeAsset := ""
+		if product, err := dt.getProduct(fill.ProductSymbol); err == nil {
+			feeAsset = product.SettlingAsset.Symbol
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":      fill.ProductSymbol,
+			"orderId":     orderID,
+			"side":        strings.ToUpper(fill.Side),
+			"time":        createdAt.UnixMilli(),
+			"price":       price,
+			"quantity":    dt.baseQuantity(fill.ProductSymbol, fill.Size),
+			"fee":         fee,
+			"feeAsset":    feeAsset,
+			"realizedPnl": 0.0, // Delta fills don't report realized PnL
+		})
+	}
+	return result, nil
+}
+
+// deltaFundingInterval is the funding settlement interval of Delta perpetuals (every 8 hours from 00:00 UTC)
+const deltaFundingInterval = 8 * time.Hour
+
+// GetFundingPayments gets funding payments since the given time from the wallet transactions.
+// Delta reports them per product without the position side, so side is left empty
+func (dt *DeltaTrader) GetFundingPayments(since time.Time) ([]map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/v2/wallet/transactions?transaction_types=funding&start_time=%d", since.UnixMicro())
+	respBody, err := dt.makeRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result []struct {
+			Amount          string `json:"amount"`
+			TransactionType string `json:"transaction_type"`
+			ProductID       int    `json:"product_id"`
+			AssetSymbol     string `json:"asset_symbol"`
+			CreatedAt       string `json:"created_at"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(response.Result))
+	for _, tx := range response.Result {
+		if tx.TransactionType != "funding" {
+			continue
+		}
+		amount, _ := strconv.ParseFloat(tx.Amount, 64)
+		createdAt, _ := time.Parse(time.RFC3339Nano, tx.CreatedAt)
+		result = append(result, map[string]interface{}{
+			"symbol": dt.productSymbol(tx.ProductID),
+			"side":   "",
+			"time":   createdAt.UnixMilli(),
+			"amount": amount,
+			"asset":  tx.AssetSymbol,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["time"].(int64) < result[j]["time"].(int64)
+	})
+	return result, nil
+}
+
+// productSymbol returns the symbol of a product ID from the cached product metadata ("" if unknown)
+func (dt *DeltaTrader) productSymbol(productID int) string {
+	dt.productsMu.Lock()
+	loaded := dt.products != nil
+	dt.productsMu.Unlock()
+	if !loaded {
+		dt.instruments.refresh()
+	}
+
+	dt.productsMu.Lock()
+	defer dt.productsMu.Unlock()
+	for symbol, product := range dt.products {
+		if product.ID == productID {
+			return symbol
+		}
+	}
+	return ""
+}
+
+// GetFundingRate gets the current funding rate from the ticker (reported in percent) and the next
+// settlement time
+func (dt *DeltaTrader) GetFundingRate(symbol string) (float64, time.Time, error) {
+	respBody, err := dt.makeRequest("GET", fmt.Sprintf("/v2/tickers/%s", symbol), nil)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	var response struct {
+		Success bool `json:"success"`
+		Result  struct {
+			FundingRate string `json:"funding_rate"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return 0, time.Time{}, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+	if !response.Success {
+		return 0, time.Time{}, fmt.Errorf("API returned error")
+	}
+	rate, _ := strconv.ParseFloat(response.Result.FundingRate, 64)
+	return rate / 100, time.Now().Truncate(deltaFundingInterval).Add(deltaFundingInterval), nil
+}
+
+// FormatQuantity rounds quantity down to whole contracts (a multiple of the product's contract value);
+// e.g. a USD notional divided by the price becomes floor(quantity / contract_value) contracts
 func (dt *DeltaTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
-	// Delta Exchange typically uses 8 decimal places for precision
-	return fmt.Sprintf("%.8f", quantity), nil
+	return dt.instruments.formatQuantity(symbol, quantity)
 }
@@ -0,0 +1,43 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 各交易器统一返回的错误类型（用errors.Is判断，具体信息通过%w包装）
+var (
+	// ErrNoPosition 平仓/查询时没有对应方向的持仓
+	ErrNoPosition = errors.New("没有找到对应持仓")
+
+	// ErrInvalidPositionSide 持仓方向不是"LONG"或"SHORT"
+	ErrInvalidPositionSide = errors.New("无效的持仓方向")
+
+	// ErrInvalidQuantity 数量不大于0，或按步长取整后为0
+	ErrInvalidQuantity = errors.New("无效的数量")
+
+	// ErrInvalidLeverage 杠杆超过交易对（按持仓名义价值所在保证金档位）允许的最大杠杆
+	ErrInvalidLeverage = errors.New("杠杆超过上限")
+
+	// ErrOrderNotFound 查询的订单不存在
+	ErrOrderNotFound = errors.New("订单不存在")
+
+	// ErrUnsupportedAccountMode 交易所不支持所配置的保证金模式或持仓模式
+	ErrUnsupportedAccountMode = errors.New("不支持的账户模式")
+)
+
+// validatePositionSide 检查止损/止盈的持仓方向参数
+func validatePositionSide(positionSide string) error {
+	if positionSide != "LONG" && positionSide != "SHORT" {
+		return fmt.Errorf("%w: %q（应为LONG或SHORT）", ErrInvalidPositionSide, positionSide)
+	}
+	return nil
+}
+
+// noPositionError 平仓时找不到持仓的错误（side为"long"或"short"）
+func noPositionError(symbol, side string) error {
+	if side == "short" {
+		return fmt.Errorf("没有找到 %s 的空仓: %w", symbol, ErrNoPosition)
+	}
+	return fmt.Errorf("没有找到 %s 的多仓: %w", symbol, ErrNoPosition)
+}
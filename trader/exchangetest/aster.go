@@ -0,0 +1,306 @@
+package exchangetest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// asterServer Aster合约接口（接口格式与币安一致，路径为v3）
+type asterServer struct {
+	venue    *Venue
+	user     common.Address
+	signer   common.Address
+	settings *binanceAccountSettings
+
+	mu     sync.Mutex
+	nonces map[uint64]bool // 已使用的nonce
+}
+
+// NewAsterServer 启动模拟Aster服务：校验以太坊签名（参数JSON + user + signer + nonce 的ABI编码，
+// 由signer地址签名），持仓默认为单向模式（positionSide BOTH，空仓positionAmt为负），可通过positionSide/dual切换为双向模式
+func NewAsterServer(venue *Venue, user, signer string) *Server {
+	a := &asterServer{
+		venue:    venue,
+		user:     common.HexToAddress(user),
+		signer:   common.HexToAddress(signer),
+		settings: newBinanceAccountSettings(false),
+		nonces:   make(map[uint64]bool),
+	}
+	s := newServer(venue, a.handle)
+	s.orderTimeout = binanceOrderTimeout("/fapi/v3/order")
+	return s
+}
+
+func (a *asterServer) handle(w http.ResponseWriter, r *http.Request) {
+	params, _, err := readParams(r)
+	if err != nil {
+		binanceError(w, http.StatusBadRequest, -1100, "Illegal characters found in parameter.")
+		return
+	}
+
+	// 公共接口（无需签名）
+	switch r.Method + " " + r.URL.Path {
+	case "GET /fapi/v3/exchangeInfo", "GET /fapi/v1/exchangeInfo":
+		(&binanceServer{venue: a.venue}).exchangeInfo(w)
+		return
+	case "GET /fapi/v3/ticker/price", "GET /fapi/v1/ticker/price":
+		(&binanceServer{venue: a.venue}).tickerPrice(w, params.Get("symbol"))
+		return
+	case "GET /fapi/v3/premiumIndex", "GET /fapi/v1/premiumIndex":
+		binancePremiumIndex(w, a.venue, params.Get("symbol"))
+		return
+	}
+
+	if err := a.verify(params); err != nil {
+		binanceError(w, http.StatusUnauthorized, -1022, "Signature for this request is not valid. "+err.Error())
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /fapi/v3/balance":
+		a.balance(w)
+	case "GET /fapi/v3/positionRisk":
+		a.positionRisk(w, params.Get("symbol"))
+	case "POST /fapi/v3/leverage":
+		symbol := params.Get("symbol")
+		leverage, _ := strconv.Atoi(params.Get("leverage"))
+		if err := a.venue.setLeverage(symbol, leverage); err != nil {
+			if errors.Is(err, ErrUnknownSymbol) {
+				binanceVenueError(w, err)
+				return
+			}
+			binanceError(w, http.StatusBadRequest, -4028, "Leverage "+params.Get("leverage")+" is not valid")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"leverage": leverage, "maxNotionalValue": "1000000", "symbol": symbol})
+	case "POST /fapi/v3/marginType":
+		a.settings.changeMarginType(w, a.venue, params)
+	case "GET /fapi/v3/positionSide/dual":
+		a.settings.positionMode(w)
+	case "POST /fapi/v3/positionSide/dual":
+		a.settings.changePositionMode(w, a.venue, params)
+	case "POST /fapi/v3/order":
+		a.createOrder(w, params)
+	case "GET /fapi/v3/openOrders":
+		result := []map[string]interface{}{}
+		for _, order := range a.venue.OpenOrders() {
+			if params.Get("symbol") == "" || order.Symbol == params.Get("symbol") {
+				result = append(result, binanceOrder(order, "NEW", 0))
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+	case "GET /fapi/v3/order":
+		binanceQueryOrder(w, a.venue, params)
+	case "GET /fapi/v3/userTrades":
+		binanceUserTrades(w, a.venue, params)
+	case "GET /fapi/v3/leverageBracket":
+		binanceLeverageBrackets(w, a.venue, params.Get("symbol"))
+	case "GET /fapi/v3/income":
+		binanceIncome(w, a.venue, params)
+	case "DELETE /fapi/v3/order":
+		orderID, _ := strconv.ParseInt(params.Get("orderId"), 10, 64)
+		if err := a.venue.cancelOrder(params.Get("symbol"), orderID); err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"orderId": orderID, "symbol": params.Get("symbol"), "status": "CANCELED"})
+	case "DELETE /fapi/v3/allOpenOrders":
+		a.venue.cancelAll(params.Get("symbol"))
+		binanceError(w, http.StatusOK, 200, "The operation of cancel all open order is done.")
+	default:
+		binanceError(w, http.StatusNotFound, -5000, "Path "+r.URL.Path+", Method "+r.Method+" is invalid")
+	}
+}
+
+// verify 校验签名：与AsterTrader.sign相同的方式重建消息哈希，恢复出签名地址并与signer比对
+func (a *asterServer) verify(params url.Values) error {
+	if !strings.EqualFold(params.Get("user"), a.user.Hex()) {
+		return fmt.Errorf("unknown user %s", params.Get("user"))
+	}
+	if !strings.EqualFold(params.Get("signer"), a.signer.Hex()) {
+		return fmt.Errorf("unknown signer %s", params.Get("signer"))
+	}
+	nonce, err := strconv.ParseUint(params.Get("nonce"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid nonce")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.nonces[nonce] {
+		return fmt.Errorf("nonce already used")
+	}
+	if params.Get("timestamp") == "" {
+		return fmt.Errorf("missing timestamp")
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(params.Get("signature"), "0x"))
+	if err != nil || len(sig) != 65 {
+		return fmt.Errorf("malformed signature")
+	}
+
+	// 业务参数（不含签名相关字段）按key排序序列化为JSON，所有值为字符串
+	business := make(map[string]string)
+	for k := range params {
+		switch k {
+		case "user", "signer", "signature", "nonce":
+			continue
+		}
+		business[k] = params.Get(k)
+	}
+	jsonStr, err := json.Marshal(business)
+	if err != nil {
+		return err
+	}
+
+	tString, _ := abi.NewType("string", "", nil)
+	tAddress, _ := abi.NewType("address", "", nil)
+	tUint256, _ := abi.NewType("uint256", "", nil)
+	arguments := abi.Arguments{{Type: tString}, {Type: tAddress}, {Type: tAddress}, {Type: tUint256}}
+	packed, err := arguments.Pack(string(jsonStr), a.user, a.signer, new(big.Int).SetUint64(nonce))
+	if err != nil {
+		return err
+	}
+	hash := crypto.Keccak256(packed)
+	msgHash := crypto.Keccak256Hash([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(hash), hash)))
+
+	sig[64] -= 27
+	pubKey, err := crypto.SigToPub(msgHash.Bytes(), sig)
+	if err != nil {
+		return fmt.Errorf("recover failed: %w", err)
+	}
+	if crypto.PubkeyToAddress(*pubKey) != a.signer {
+		return fmt.Errorf("signature does not match signer")
+	}
+	a.nonces[nonce] = true
+	return nil
+}
+
+func (a *asterServer) balance(w http.ResponseWriter) {
+	balance := a.venue.Balance()
+	unrealized := a.venue.UnrealizedPnL()
+	available := balance + unrealized - a.venue.MarginUsed()
+	writeJSON(w, http.StatusOK, []map[string]interface{}{
+		{
+			"accountAlias":       "fake",
+			"asset":              "USDT",
+			"balance":            formatFloat(balance),
+			"crossWalletBalance": formatFloat(balance),
+			"crossUnPnl":         formatFloat(unrealized),
+			"availableBalance":   formatFloat(available),
+			"maxWithdrawAmount":  formatFloat(available),
+			"marginAvailable":    true,
+			"updateTime":         time.Now().UnixMilli(),
+		},
+	})
+}
+
+func (a *asterServer) positionRisk(w http.ResponseWriter, symbol string) {
+	result := []map[string]interface{}{}
+	for _, pos := range a.venue.Positions() {
+		if symbol != "" && pos.Symbol != symbol {
+			continue
+		}
+		markPrice, unrealized, liquidation := a.venue.positionDetails(pos)
+		amount := pos.Size
+		if pos.Side == "short" {
+			amount = -amount
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":           pos.Symbol,
+			"positionSide":     a.settings.positionSide(pos),
+			"positionAmt":      formatFloat(amount),
+			"entryPrice":       formatFloat(pos.EntryPrice),
+			"markPrice":        formatFloat(markPrice),
+			"unRealizedProfit": formatFloat(unrealized),
+			"liquidationPrice": formatFloat(liquidation),
+			"leverage":         strconv.Itoa(pos.Leverage),
+			"marginType":       a.settings.marginTypeOf(pos.Symbol),
+			"isolatedMargin":   "0",
+			"isAutoAddMargin":  "false",
+			"notional":         formatFloat(amount * markPrice),
+			"maxNotionalValue": "1000000",
+			"updateTime":       time.Now().UnixMilli(),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (a *asterServer) createOrder(w http.ResponseWriter, params url.Values) {
+	symbol := params.Get("symbol")
+	side := params.Get("side")
+	if side != "BUY" && side != "SELL" {
+		binanceError(w, http.StatusBadRequest, -1117, "Invalid side.")
+		return
+	}
+	positionSide := params.Get("positionSide")
+	if positionSide == "" {
+		positionSide = "BOTH"
+	}
+	if !a.settings.checkOrder(w, positionSide, params.Has("reduceOnly")) {
+		return
+	}
+	quantity, _ := strconv.ParseFloat(params.Get("quantity"), 64)
+	reduceOnly := params.Get("reduceOnly") == "true"
+
+	orderType := params.Get("type")
+	switch orderType {
+	case "MARKET":
+		fill, err := a.venue.marketOrder(symbol, side, positionSide, quantity, reduceOnly)
+		if err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		a.venue.setClientID(fill.OrderID, params.Get("newClientOrderId"))
+		writeJSON(w, http.StatusOK, binanceOrder(Order{ID: fill.OrderID, ClientID: params.Get("newClientOrderId"), Symbol: symbol, Type: orderType, Side: side, PositionSide: positionSide, Quantity: quantity}, "FILLED", fill.Price))
+
+	case "LIMIT":
+		price, _ := strconv.ParseFloat(params.Get("price"), 64)
+		order, fill, err := a.venue.limitOrder(symbol, side, positionSide, quantity, price, reduceOnly, binanceTimeInForce(params.Get("timeInForce")))
+		if errors.Is(err, ErrWouldTake) {
+			binanceError(w, http.StatusBadRequest, -5022, "Due to the order could not be executed as maker, the Post Only order will be rejected.")
+			return
+		}
+		if errors.Is(err, ErrNoMatch) {
+			writeJSON(w, http.StatusOK, binanceOrder(Order{Symbol: symbol, Type: orderType, Side: side, PositionSide: positionSide, Quantity: quantity, Price: price}, "EXPIRED", 0))
+			return
+		}
+		if err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		order.ClientID = params.Get("newClientOrderId")
+		a.venue.setClientID(order.ID, order.ClientID)
+		if fill != nil {
+			writeJSON(w, http.StatusOK, binanceOrder(order, "FILLED", fill.Price))
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrder(order, "NEW", 0))
+
+	case "STOP_MARKET", "TAKE_PROFIT_MARKET":
+		stopPrice, _ := strconv.ParseFloat(params.Get("stopPrice"), 64)
+		order, err := a.venue.placeConditional(Order{
+			ClientID: params.Get("newClientOrderId"), Symbol: symbol, Type: orderType, Side: side, PositionSide: positionSide, Quantity: quantity,
+			StopPrice: stopPrice, ReduceOnly: true, ClosePosition: params.Get("closePosition") == "true",
+		})
+		if err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrder(order, "NEW", 0))
+
+	default:
+		binanceError(w, http.StatusBadRequest, -1116, "Invalid orderType.")
+	}
+}
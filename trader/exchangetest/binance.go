@@ -0,0 +1,694 @@
+package exchangetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// binanceServer 币安U本位合约接口
+type binanceServer struct {
+	venue     *Venue
+	apiKey    string
+	secretKey string
+	settings  *binanceAccountSettings
+
+	listenKeys binanceListenKeys // 用户数据流（见binance_stream.go）
+	streams    *streamRegistry
+}
+
+// NewBinanceServer 启动模拟币安合约服务：校验X-MBX-APIKEY和HMAC-SHA256签名，
+// 持仓默认为双向模式（positionSide LONG/SHORT，空仓positionAmt为负），可通过positionSide/dual切换为单向模式
+// 用户数据流：/fapi/v1/listenKey 创建listenKey，WebSocket地址为 ws://{host}/ws/{listenKey}
+// 或组合流 ws://{host}/stream?streams={listenKey}/!markPrice@arr@1s（每秒推送标记价格）
+func NewBinanceServer(venue *Venue, apiKey, secretKey string) *Server {
+	b := &binanceServer{
+		venue:     venue,
+		apiKey:    apiKey,
+		secretKey: secretKey,
+		settings:  newBinanceAccountSettings(true),
+	}
+	s := newServer(venue, b.handle)
+	s.orderTimeout = binanceOrderTimeout("/fapi/v1/order")
+	b.streams = s.streams
+	return s
+}
+
+// binanceOrderTimeout 币安风格的下单超时（-1007，Aster接口相同）
+func binanceOrderTimeout(path string) orderTimeout {
+	return orderTimeout{
+		isOrder: func(r *http.Request, body []byte) bool {
+			return r.Method == http.MethodPost && r.URL.Path == path
+		},
+		status: http.StatusServiceUnavailable,
+		body:   `{"code":-1007,"msg":"Timeout waiting for response from backend server. Send status unknown; execution status unknown."}`,
+	}
+}
+
+// binanceError 币安格式的错误响应
+func binanceError(w http.ResponseWriter, status, code int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"code": code, "msg": msg})
+}
+
+// binanceVenueError 撮合错误转换为币安错误码
+func binanceVenueError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownSymbol):
+		binanceError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+	case errors.Is(err, ErrInvalidQuantity):
+		binanceError(w, http.StatusBadRequest, -1111, "Precision is over the maximum defined for this asset.")
+	case errors.Is(err, ErrInvalidPrice):
+		binanceError(w, http.StatusBadRequest, -4014, "Price not increased by tick size.")
+	case errors.Is(err, ErrInsufficientMargin):
+		binanceError(w, http.StatusBadRequest, -2019, "Margin is insufficient.")
+	case errors.Is(err, ErrReduceOnly):
+		binanceError(w, http.StatusBadRequest, -2022, "ReduceOnly Order is rejected.")
+	case errors.Is(err, ErrOrderNotFound):
+		binanceError(w, http.StatusBadRequest, -2011, "Unknown order sent.")
+	default:
+		binanceError(w, http.StatusBadRequest, -4028, err.Error())
+	}
+}
+
+func (b *binanceServer) handle(w http.ResponseWriter, r *http.Request) {
+	params, body, err := readParams(r)
+	if err != nil {
+		binanceError(w, http.StatusBadRequest, -1100, "Illegal characters found in parameter.")
+		return
+	}
+
+	// 用户数据流（只需要API Key / listenKey）
+	if r.URL.Path == "/fapi/v1/listenKey" {
+		b.listenKey(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/ws/") || r.URL.Path == "/stream" {
+		b.userStream(w, r)
+		return
+	}
+
+	// 公共接口（无需签名）
+	switch r.Method + " " + r.URL.Path {
+	case "GET /fapi/v1/exchangeInfo":
+		b.exchangeInfo(w)
+		return
+	case "GET /fapi/v1/ticker/price", "GET /fapi/v2/ticker/price":
+		b.tickerPrice(w, params.Get("symbol"))
+		return
+	case "GET /fapi/v1/time":
+		writeJSON(w, http.StatusOK, map[string]int64{"serverTime": time.Now().UnixMilli()})
+		return
+	case "GET /fapi/v1/premiumIndex":
+		binancePremiumIndex(w, b.venue, params.Get("symbol"))
+		return
+	}
+
+	if !b.authenticate(w, r, body) {
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /fapi/v2/account", "GET /fapi/v3/account":
+		b.account(w)
+	case "GET /fapi/v2/positionRisk", "GET /fapi/v3/positionRisk":
+		b.positionRisk(w, params.Get("symbol"))
+	case "POST /fapi/v1/leverage":
+		b.changeLeverage(w, params)
+	case "POST /fapi/v1/marginType":
+		b.settings.changeMarginType(w, b.venue, params)
+	case "GET /fapi/v1/positionSide/dual":
+		b.settings.positionMode(w)
+	case "POST /fapi/v1/positionSide/dual":
+		b.settings.changePositionMode(w, b.venue, params)
+	case "POST /fapi/v1/order":
+		b.createOrder(w, params)
+	case "GET /fapi/v1/openOrders":
+		b.openOrders(w, params.Get("symbol"))
+	case "GET /fapi/v1/order":
+		binanceQueryOrder(w, b.venue, params)
+	case "GET /fapi/v1/userTrades":
+		binanceUserTrades(w, b.venue, params)
+	case "GET /fapi/v1/leverageBracket":
+		binanceLeverageBrackets(w, b.venue, params.Get("symbol"))
+	case "GET /fapi/v1/income":
+		binanceIncome(w, b.venue, params)
+	case "DELETE /fapi/v1/order":
+		orderID, _ := strconv.ParseInt(params.Get("orderId"), 10, 64)
+		if err := b.venue.cancelOrder(params.Get("symbol"), orderID); err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"orderId": orderID, "symbol": params.Get("symbol"), "status": "CANCELED"})
+	case "DELETE /fapi/v1/allOpenOrders":
+		b.venue.cancelAll(params.Get("symbol"))
+		binanceError(w, http.StatusOK, 200, "The operation of cancel all open order is done.")
+	default:
+		binanceError(w, http.StatusNotFound, -5000, "Path "+r.URL.Path+", Method "+r.Method+" is invalid")
+	}
+}
+
+// authenticate 校验API Key和签名：signature = HMAC-SHA256(secret, querystring(不含signature) + body)
+func (b *binanceServer) authenticate(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	if r.Header.Get("X-MBX-APIKEY") != b.apiKey {
+		binanceError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+		return false
+	}
+
+	rawQuery := r.URL.RawQuery
+	idx := strings.LastIndex(rawQuery, "signature=")
+	if idx < 0 {
+		binanceError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'signature' was not sent, was empty/null, or malformed.")
+		return false
+	}
+	signature, _ := url.QueryUnescape(rawQuery[idx+len("signature="):])
+	payload := strings.TrimSuffix(rawQuery[:idx], "&") + string(body)
+
+	values, _ := url.ParseQuery(payload)
+	if values.Get("timestamp") == "" {
+		binanceError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'timestamp' was not sent, was empty/null, or malformed.")
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(b.secretKey))
+	mac.Write([]byte(payload))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature)) {
+		binanceError(w, http.StatusBadRequest, -1022, "Signature for this request is not valid.")
+		return false
+	}
+	return true
+}
+
+func (b *binanceServer) exchangeInfo(w http.ResponseWriter) {
+	var symbols []map[string]interface{}
+	for _, symbol := range b.venue.Symbols() {
+		inst, _ := b.venue.Instrument(symbol)
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":            inst.Symbol,
+			"pair":              inst.Symbol,
+			"contractType":      "PERPETUAL",
+			"status":            "TRADING",
+			"baseAsset":         strings.TrimSuffix(inst.Symbol, "USDT"),
+			"quoteAsset":        "USDT",
+			"marginAsset":       "USDT",
+			"pricePrecision":    decimals(inst.TickSize),
+			"quantityPrecision": decimals(inst.StepSize),
+			"filters": []map[string]interface{}{
+				{"filterType": "PRICE_FILTER", "tickSize": formatFloat(inst.TickSize), "minPrice": formatFloat(inst.TickSize), "maxPrice": "1000000"},
+				{"filterType": "LOT_SIZE", "stepSize": formatFloat(inst.StepSize), "minQty": formatFloat(inst.StepSize), "maxQty": "10000"},
+				{"filterType": "MARKET_LOT_SIZE", "stepSize": formatFloat(inst.StepSize), "minQty": formatFloat(inst.StepSize), "maxQty": "10000"},
+				{"filterType": "MIN_NOTIONAL", "notional": "5"},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"timezone":   "UTC",
+		"serverTime": time.Now().UnixMilli(),
+		"symbols":    symbols,
+	})
+}
+
+func (b *binanceServer) tickerPrice(w http.ResponseWriter, symbol string) {
+	price, ok := b.venue.Price(symbol)
+	if !ok {
+		binanceError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"symbol": symbol, "price": formatFloat(price), "time": time.Now().UnixMilli()})
+}
+
+func (b *binanceServer) account(w http.ResponseWriter) {
+	balance := b.venue.Balance()
+	unrealized := b.venue.UnrealizedPnL()
+	margin := b.venue.MarginUsed()
+	available := balance + unrealized - margin
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"feeTier":                     0,
+		"canTrade":                    true,
+		"totalInitialMargin":          formatFloat(margin),
+		"totalMaintMargin":            formatFloat(margin * 0.1),
+		"totalWalletBalance":          formatFloat(balance),
+		"totalUnrealizedProfit":       formatFloat(unrealized),
+		"totalMarginBalance":          formatFloat(balance + unrealized),
+		"totalPositionInitialMargin":  formatFloat(margin),
+		"totalOpenOrderInitialMargin": "0",
+		"totalCrossWalletBalance":     formatFloat(balance),
+		"totalCrossUnPnl":             formatFloat(unrealized),
+		"availableBalance":            formatFloat(available),
+		"maxWithdrawAmount":           formatFloat(available),
+		"assets": []map[string]interface{}{
+			{"asset": "USDT", "walletBalance": formatFloat(balance), "unrealizedProfit": formatFloat(unrealized), "availableBalance": formatFloat(available)},
+		},
+		"positions": []interface{}{},
+	})
+}
+
+func (b *binanceServer) positionRisk(w http.ResponseWriter, symbol string) {
+	result := []map[string]interface{}{}
+	for _, pos := range b.venue.Positions() {
+		if symbol != "" && pos.Symbol != symbol {
+			continue
+		}
+		markPrice, unrealized, liquidation := b.venue.positionDetails(pos)
+		amount := pos.Size
+		if pos.Side == "short" {
+			amount = -amount
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":           pos.Symbol,
+			"positionSide":     b.settings.positionSide(pos),
+			"positionAmt":      formatFloat(amount),
+			"entryPrice":       formatFloat(pos.EntryPrice),
+			"breakEvenPrice":   formatFloat(pos.EntryPrice),
+			"markPrice":        formatFloat(markPrice),
+			"unRealizedProfit": formatFloat(unrealized),
+			"liquidationPrice": formatFloat(liquidation),
+			"leverage":         strconv.Itoa(pos.Leverage),
+			"marginType":       b.settings.marginTypeOf(pos.Symbol),
+			"isolatedMargin":   formatFloat(pos.Size * pos.EntryPrice / float64(pos.Leverage)),
+			"isAutoAddMargin":  "false",
+			"notional":         formatFloat(amount * markPrice),
+			"maxNotionalValue": "1000000",
+			"updateTime":       time.Now().UnixMilli(),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (b *binanceServer) changeLeverage(w http.ResponseWriter, params url.Values) {
+	symbol := params.Get("symbol")
+	leverage, err := strconv.Atoi(params.Get("leverage"))
+	if err != nil {
+		binanceError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'leverage' was not sent, was empty/null, or malformed.")
+		return
+	}
+	if err := b.venue.setLeverage(symbol, leverage); err != nil {
+		if errors.Is(err, ErrUnknownSymbol) {
+			binanceVenueError(w, err)
+			return
+		}
+		binanceError(w, http.StatusBadRequest, -4028, "Leverage "+params.Get("leverage")+" is not valid")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"leverage": leverage, "maxNotionalValue": "1000000", "symbol": symbol})
+}
+
+func (b *binanceServer) createOrder(w http.ResponseWriter, params url.Values) {
+	symbol := params.Get("symbol")
+	side := params.Get("side")
+	positionSide := params.Get("positionSide")
+	if positionSide == "" {
+		positionSide = "BOTH"
+	}
+	if side != "BUY" && side != "SELL" {
+		binanceError(w, http.StatusBadRequest, -1117, "Invalid side.")
+		return
+	}
+	quantity, _ := strconv.ParseFloat(params.Get("quantity"), 64)
+	reduceOnly := params.Get("reduceOnly") == "true"
+	if !b.settings.checkOrder(w, positionSide, params.Has("reduceOnly")) {
+		return
+	}
+	closePosition := params.Get("closePosition") == "true"
+
+	orderType := params.Get("type")
+	switch orderType {
+	case "MARKET":
+		fill, err := b.venue.marketOrder(symbol, side, positionSide, quantity, reduceOnly)
+		if err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		b.venue.setClientID(fill.OrderID, params.Get("newClientOrderId"))
+		writeJSON(w, http.StatusOK, binanceOrder(Order{
+			ID: fill.OrderID, ClientID: params.Get("newClientOrderId"), Symbol: symbol, Type: orderType,
+			Side: side, PositionSide: positionSide, Quantity: quantity, ReduceOnly: reduceOnly,
+		}, "FILLED", fill.Price))
+
+	case "STOP_MARKET", "TAKE_PROFIT_MARKET":
+		// 每个方向只允许一张同类型的closePosition条件单
+		if closePosition {
+			for _, open := range b.venue.OpenOrders() {
+				if open.Symbol == symbol && open.Type == orderType && open.Side == side && open.PositionSide == positionSide && open.ClosePosition {
+					binanceError(w, http.StatusBadRequest, -4130, "An open stop or take profit order with GTE and closePosition in the direction is existing.")
+					return
+				}
+			}
+		}
+		stopPrice, _ := strconv.ParseFloat(params.Get("stopPrice"), 64)
+		order, err := b.venue.placeConditional(Order{
+			ClientID: params.Get("newClientOrderId"), Symbol: symbol, Type: orderType, Side: side,
+			PositionSide: positionSide, Quantity: quantity, StopPrice: stopPrice,
+			ReduceOnly: reduceOnly, ClosePosition: closePosition,
+		})
+		if err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrder(order, "NEW", 0))
+
+	case "TRAILING_STOP_MARKET":
+		callbackRate, _ := strconv.ParseFloat(params.Get("callbackRate"), 64)
+		if callbackRate < 0.1 || callbackRate > 10 {
+			binanceError(w, http.StatusBadRequest, -2007, "Invalid callBack rate.")
+			return
+		}
+		activationPrice, _ := strconv.ParseFloat(params.Get("activationPrice"), 64)
+		order, err := b.venue.placeConditional(Order{
+			ClientID: params.Get("newClientOrderId"), Symbol: symbol, Type: orderType, Side: side,
+			PositionSide: positionSide, Quantity: quantity, ReduceOnly: reduceOnly,
+			CallbackRate: callbackRate, ActivationPrice: activationPrice,
+		})
+		if err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrder(order, "NEW", 0))
+
+	case "LIMIT":
+		price, _ := strconv.ParseFloat(params.Get("price"), 64)
+		order, fill, err := b.venue.limitOrder(symbol, side, positionSide, quantity, price, reduceOnly, binanceTimeInForce(params.Get("timeInForce")))
+		if errors.Is(err, ErrWouldTake) {
+			binanceError(w, http.StatusBadRequest, -5022, "Due to the order could not be executed as maker, the Post Only order will be rejected.")
+			return
+		}
+		if errors.Is(err, ErrNoMatch) {
+			writeJSON(w, http.StatusOK, binanceOrder(Order{Symbol: symbol, Type: orderType, Side: side, PositionSide: positionSide, Quantity: quantity, Price: price}, "EXPIRED", 0))
+			return
+		}
+		if err != nil {
+			binanceVenueError(w, err)
+			return
+		}
+		order.ClientID = params.Get("newClientOrderId")
+		b.venue.setClientID(order.ID, order.ClientID)
+		if fill != nil {
+			writeJSON(w, http.StatusOK, binanceOrder(order, "FILLED", fill.Price))
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrder(order, "NEW", 0))
+
+	default:
+		binanceError(w, http.StatusBadRequest, -1116, "Invalid orderType.")
+	}
+}
+
+// binanceTimeInForce 币安的timeInForce转撮合参数（GTX即post-only）
+func binanceTimeInForce(tif string) string {
+	if tif == "GTX" {
+		return "POST_ONLY"
+	}
+	return tif
+}
+
+func (b *binanceServer) openOrders(w http.ResponseWriter, symbol string) {
+	result := []map[string]interface{}{}
+	for _, order := range b.venue.OpenOrders() {
+		if symbol == "" || order.Symbol == symbol {
+			result = append(result, binanceOrder(order, "NEW", 0))
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// binanceQueryOrder 按orderId或origClientOrderId查询单个订单（包括已成交/已撤销的订单），Aster共用
+func binanceQueryOrder(w http.ResponseWriter, venue *Venue, params url.Values) {
+	orderID, _ := strconv.ParseInt(params.Get("orderId"), 10, 64)
+	order, ok := venue.FindOrder(params.Get("symbol"), orderID)
+	if clientID := params.Get("origClientOrderId"); clientID != "" {
+		order, ok = venue.FindOrderByClientID(params.Get("symbol"), clientID)
+	}
+	if !ok {
+		binanceError(w, http.StatusBadRequest, -2013, "Order does not exist.")
+		return
+	}
+	result := binanceOrder(order, order.Status, order.AvgPrice)
+	result["executedQty"] = formatFloat(order.ExecutedQty)
+	result["cumQuote"] = formatFloat(order.ExecutedQty * order.AvgPrice)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// binanceLeverageBrackets 杠杆档位（symbol可选），Aster共用
+// 每个交易对两档：名义价值5万以下为最大杠杆，5万以上为最大杠杆的1/5（至少1倍）
+func binanceLeverageBrackets(w http.ResponseWriter, venue *Venue, symbol string) {
+	result := []map[string]interface{}{}
+	for _, s := range venue.Symbols() {
+		if symbol != "" && s != symbol {
+			continue
+		}
+		inst, _ := venue.Instrument(s)
+		result = append(result, map[string]interface{}{
+			"symbol": s,
+			"brackets": []map[string]interface{}{
+				{"bracket": 1, "initialLeverage": inst.MaxLeverage, "notionalCap": 50000, "notionalFloor": 0, "maintMarginRatio": 0.004, "cum": 0},
+				{"bracket": 2, "initialLeverage": max(inst.MaxLeverage/5, 1), "notionalCap": 1e9, "notionalFloor": 50000, "maintMarginRatio": 0.01, "cum": 300},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// binanceUserTrades 账户成交历史（symbol必填，startTime/orderId可选），Aster共用
+func binanceUserTrades(w http.ResponseWriter, venue *Venue, params url.Values) {
+	symbol := params.Get("symbol")
+	if symbol == "" {
+		binanceError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'symbol' was not sent, was empty/null, or malformed.")
+		return
+	}
+	startTime, _ := strconv.ParseInt(params.Get("startTime"), 10, 64)
+	orderID, _ := strconv.ParseInt(params.Get("orderId"), 10, 64)
+
+	result := []map[string]interface{}{}
+	for i, fill := range venue.Fills() {
+		if fill.Symbol != symbol || fill.Time.UnixMilli() < startTime || (orderID != 0 && fill.OrderID != orderID) {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"id":              i + 1,
+			"orderId":         fill.OrderID,
+			"symbol":          fill.Symbol,
+			"side":            fill.Side,
+			"positionSide":    fill.PositionSide,
+			"buyer":           fill.Side == "BUY",
+			"maker":           false,
+			"price":           formatFloat(fill.Price),
+			"qty":             formatFloat(fill.Quantity),
+			"quoteQty":        formatFloat(fill.Price * fill.Quantity),
+			"realizedPnl":     formatFloat(fill.RealizedPnL),
+			"commission":      formatFloat(fill.Fee),
+			"commissionAsset": "USDT",
+			"time":            fill.Time.UnixMilli(),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// binanceFundingInterval 币安（及Aster）的资金费结算间隔（UTC 0点、8点、16点）
+const binanceFundingInterval = 8 * time.Hour
+
+// binancePremiumIndex 标记价格和资金费率（symbol为空时返回所有交易对的数组），Aster共用
+func binancePremiumIndex(w http.ResponseWriter, venue *Venue, symbol string) {
+	now := time.Now()
+	index := func(symbol string) map[string]interface{} {
+		price, _ := venue.Price(symbol)
+		return map[string]interface{}{
+			"symbol":               symbol,
+			"markPrice":            formatFloat(price),
+			"indexPrice":           formatFloat(price),
+			"estimatedSettlePrice": formatFloat(price),
+			"lastFundingRate":      formatFloat(venue.FundingRate(symbol)),
+			"interestRate":         "0.00010000",
+			"nextFundingTime":      nextFundingTime(now, binanceFundingInterval).UnixMilli(),
+			"time":                 now.UnixMilli(),
+		}
+	}
+	if symbol != "" {
+		if _, ok := venue.Instrument(symbol); !ok {
+			binanceVenueError(w, ErrUnknownSymbol)
+			return
+		}
+		writeJSON(w, http.StatusOK, index(symbol))
+		return
+	}
+	result := []map[string]interface{}{}
+	for _, s := range venue.Symbols() {
+		result = append(result, index(s))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// binanceIncome 资金流水（只有资金费FUNDING_FEE；symbol/incomeType/startTime/endTime可选），Aster共用
+func binanceIncome(w http.ResponseWriter, venue *Venue, params url.Values) {
+	startTime, _ := strconv.ParseInt(params.Get("startTime"), 10, 64)
+	endTime, _ := strconv.ParseInt(params.Get("endTime"), 10, 64)
+	incomeType := params.Get("incomeType")
+
+	result := []map[string]interface{}{}
+	if incomeType != "" && incomeType != "FUNDING_FEE" {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	for i, payment := range venue.FundingPayments() {
+		t := payment.Time.UnixMilli()
+		if (params.Get("symbol") != "" && payment.Symbol != params.Get("symbol")) || t < startTime || (endTime > 0 && t > endTime) {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":     payment.Symbol,
+			"incomeType": "FUNDING_FEE",
+			"income":     formatFloat(payment.Amount),
+			"asset":      "USDT",
+			"info":       "FUNDING_FEE",
+			"time":       t,
+			"tranId":     i + 1,
+			"tradeId":    "",
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// binanceOrder 币安格式的订单
+func binanceOrder(order Order, status string, avgPrice float64) map[string]interface{} {
+	executed := 0.0
+	if status == "FILLED" {
+		executed = order.Quantity
+	}
+	result := map[string]interface{}{
+		"orderId":       order.ID,
+		"clientOrderId": order.ClientID,
+		"symbol":        order.Symbol,
+		"status":        status,
+		"type":          order.Type,
+		"origType":      order.Type,
+		"side":          order.Side,
+		"positionSide":  order.PositionSide,
+		"price":         formatFloat(order.Price),
+		"avgPrice":      formatFloat(avgPrice),
+		"stopPrice":     formatFloat(order.StopPrice),
+		"origQty":       formatFloat(order.Quantity),
+		"executedQty":   formatFloat(executed),
+		"cumQuote":      formatFloat(executed * avgPrice),
+		"reduceOnly":    order.ReduceOnly,
+		"closePosition": order.ClosePosition,
+		"timeInForce":   "GTC",
+		"workingType":   "CONTRACT_PRICE",
+		"updateTime":    time.Now().UnixMilli(),
+	}
+	if order.Type == "TRAILING_STOP_MARKET" {
+		result["activatePrice"] = formatFloat(order.ActivationPrice)
+		result["priceRate"] = formatFloat(order.CallbackRate)
+	}
+	return result
+}
+
+// binanceAccountSettings 币安/Aster账户的保证金模式（按币种）和持仓模式（双向/单向）
+type binanceAccountSettings struct {
+	mu         sync.Mutex
+	marginType map[string]string // symbol -> "ISOLATED" / "CROSSED"（未设置时为全仓）
+	hedge      bool              // 双向持仓模式
+}
+
+// newBinanceAccountSettings 创建账户设置（hedge为初始持仓模式）
+func newBinanceAccountSettings(hedge bool) *binanceAccountSettings {
+	return &binanceAccountSettings{marginType: make(map[string]string), hedge: hedge}
+}
+
+// marginTypeOf 持仓接口中的保证金模式（"isolated"/"cross"）
+func (s *binanceAccountSettings) marginTypeOf(symbol string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.marginType[symbol] == "ISOLATED" {
+		return "isolated"
+	}
+	return "cross"
+}
+
+// positionSide 持仓接口中的positionSide（单向持仓模式为BOTH）
+func (s *binanceAccountSettings) positionSide(pos Position) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.hedge {
+		return "BOTH"
+	}
+	return strings.ToUpper(pos.Side)
+}
+
+// checkOrder 按持仓模式检查下单参数：双向持仓必须带LONG/SHORT且不能带reduceOnly，单向持仓只能是BOTH
+func (s *binanceAccountSettings) checkOrder(w http.ResponseWriter, positionSide string, hasReduceOnly bool) bool {
+	s.mu.Lock()
+	hedge := s.hedge
+	s.mu.Unlock()
+	if hedge != (positionSide != "BOTH") {
+		binanceError(w, http.StatusBadRequest, -4061, "Order's position side does not match user's setting.")
+		return false
+	}
+	if hedge && hasReduceOnly {
+		binanceError(w, http.StatusBadRequest, -1106, "Parameter 'reduceonly' sent when not required.")
+		return false
+	}
+	return true
+}
+
+func (s *binanceAccountSettings) changeMarginType(w http.ResponseWriter, venue *Venue, params url.Values) {
+	symbol := params.Get("symbol")
+	if _, ok := venue.Instrument(symbol); !ok {
+		binanceVenueError(w, ErrUnknownSymbol)
+		return
+	}
+	marginType := strings.ToUpper(params.Get("marginType"))
+	if marginType != "ISOLATED" && marginType != "CROSSED" {
+		binanceError(w, http.StatusBadRequest, -4000, "Invalid marginType.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.marginType[symbol]
+	if current == "" {
+		current = "CROSSED"
+	}
+	if current == marginType {
+		binanceError(w, http.StatusBadRequest, -4046, "No need to change margin type.")
+		return
+	}
+	s.marginType[symbol] = marginType
+	binanceError(w, http.StatusOK, 200, "success")
+}
+
+func (s *binanceAccountSettings) positionMode(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]bool{"dualSidePosition": s.hedge})
+}
+
+// changePositionMode 切换持仓模式（有持仓或挂单时交易所拒绝切换）
+func (s *binanceAccountSettings) changePositionMode(w http.ResponseWriter, venue *Venue, params url.Values) {
+	hedge, err := strconv.ParseBool(params.Get("dualSidePosition"))
+	if err != nil {
+		binanceError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'dualSidePosition' was not sent, was empty/null, or malformed.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hedge == hedge {
+		binanceError(w, http.StatusBadRequest, -4059, "No need to change position side.")
+		return
+	}
+	if len(venue.Positions()) > 0 {
+		binanceError(w, http.StatusBadRequest, -4068, "Position side cannot be changed if there exists position.")
+		return
+	}
+	if len(venue.OpenOrders()) > 0 {
+		binanceError(w, http.StatusBadRequest, -4067, "Position side cannot be changed if there exists open orders.")
+		return
+	}
+	s.hedge = hedge
+	binanceError(w, http.StatusOK, 200, "success")
+}
@@ -0,0 +1,249 @@
+package exchangetest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// binanceListenKeys 用户数据流的listenKey（POST创建/PUT续期/DELETE关闭，每个账户同时只有一个）
+type binanceListenKeys struct {
+	mu  sync.Mutex
+	key string
+}
+
+// listenKey 处理 /fapi/v1/listenKey：只校验X-MBX-APIKEY，不需要签名
+func (b *binanceServer) listenKey(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-MBX-APIKEY") != b.apiKey {
+		binanceError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+		return
+	}
+
+	b.listenKeys.mu.Lock()
+	defer b.listenKeys.mu.Unlock()
+	switch r.Method {
+	case http.MethodPost:
+		// 已有未关闭的listenKey时返回同一个
+		if b.listenKeys.key == "" {
+			buf := make([]byte, 32)
+			rand.Read(buf)
+			b.listenKeys.key = hex.EncodeToString(buf)
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"listenKey": b.listenKeys.key})
+	case http.MethodPut:
+		if b.listenKeys.key == "" {
+			binanceError(w, http.StatusBadRequest, -1125, "This listenKey does not exist.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"listenKey": b.listenKeys.key})
+	case http.MethodDelete:
+		b.listenKeys.key = ""
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	default:
+		binanceError(w, http.StatusNotFound, -5000, "Path "+r.URL.Path+", Method "+r.Method+" is invalid")
+	}
+}
+
+// userStream 用户数据流WebSocket：订单状态变化推送ORDER_TRADE_UPDATE，成交后再推送ACCOUNT_UPDATE（钱包余额和该交易对的持仓）
+// /ws/{listenKey} 直接推送事件；/stream?streams={listenKey}/... 为组合流，事件包装为{"stream","data"}，
+// 订阅了!markPrice@arr@1s时每秒推送全部交易对的标记价格
+func (b *binanceServer) userStream(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/ws/")
+	combined := r.URL.Path == "/stream"
+	markPrices := false
+	if combined {
+		key = ""
+		for _, name := range strings.Split(r.URL.Query().Get("streams"), "/") {
+			switch {
+			case name == "!markPrice@arr@1s" || name == "!markPrice@arr":
+				markPrices = true
+			case !strings.Contains(name, "@"):
+				key = name
+			}
+		}
+	}
+
+	b.listenKeys.mu.Lock()
+	valid := key != "" && key == b.listenKeys.key
+	b.listenKeys.mu.Unlock()
+	if !valid {
+		binanceError(w, http.StatusBadRequest, -1125, "This listenKey does not exist.")
+		return
+	}
+
+	events, unsubscribe := b.venue.Subscribe()
+	defer unsubscribe()
+	conn, release, err := b.streams.upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	write := func(stream string, data interface{}) error {
+		if !combined {
+			return conn.WriteJSON(data)
+		}
+		return conn.WriteJSON(map[string]interface{}{"stream": stream, "data": data})
+	}
+
+	// 读取到错误（客户端关闭或CloseStreams）时结束推送
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var ticks <-chan time.Time
+	if markPrices {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticks:
+			if err := write("!markPrice@arr@1s", b.markPriceUpdates()); err != nil {
+				return
+			}
+		case event := <-events:
+			if event.Funding != nil {
+				if err := write(key, b.accountUpdate(event.Funding.Symbol, "FUNDING_FEE")); err != nil {
+					return
+				}
+				continue
+			}
+			if err := write(key, b.orderTradeUpdate(event)); err != nil {
+				return
+			}
+			if event.Fill != nil {
+				if err := write(key, b.accountUpdate(event.Order.Symbol, "ORDER")); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// markPriceUpdates 全市场标记价格推送（markPriceUpdate数组）
+func (b *binanceServer) markPriceUpdates() []map[string]interface{} {
+	now := time.Now()
+	next := nextFundingTime(now, binanceFundingInterval).UnixMilli()
+	var updates []map[string]interface{}
+	for _, symbol := range b.venue.Symbols() {
+		price, _ := b.venue.Price(symbol)
+		updates = append(updates, map[string]interface{}{
+			"e": "markPriceUpdate", "E": now.UnixMilli(), "s": symbol,
+			"p": formatFloat(price), "i": formatFloat(price), "P": formatFloat(price),
+			"r": formatFloat(b.venue.FundingRate(symbol)), "T": next,
+		})
+	}
+	return updates
+}
+
+// orderTradeUpdate ORDER_TRADE_UPDATE事件（有成交时x为TRADE并带本次成交的价格、数量、手续费和已实现盈亏）
+func (b *binanceServer) orderTradeUpdate(event Event) map[string]interface{} {
+	order := event.Order
+	now := time.Now().UnixMilli()
+	executionType := "NEW"
+	switch order.Status {
+	case "CANCELED":
+		executionType = "CANCELED"
+	case "EXPIRED":
+		executionType = "EXPIRED"
+	}
+	lastQty, lastPrice, fee, realized := 0.0, 0.0, 0.0, 0.0
+	tradeTime := now
+	if fill := event.Fill; fill != nil {
+		executionType = "TRADE"
+		lastQty, lastPrice, fee, realized = fill.Quantity, fill.Price, fill.Fee, fill.RealizedPnL
+		tradeTime = fill.Time.UnixMilli()
+	}
+	return map[string]interface{}{
+		"e": "ORDER_TRADE_UPDATE",
+		"E": now,
+		"T": tradeTime,
+		"o": map[string]interface{}{
+			"s":  order.Symbol,
+			"c":  order.ClientID,
+			"S":  order.Side,
+			"o":  order.Type,
+			"f":  "GTC",
+			"q":  formatFloat(order.Quantity),
+			"p":  formatFloat(order.Price),
+			"ap": formatFloat(order.AvgPrice),
+			"sp": formatFloat(order.StopPrice),
+			"x":  executionType,
+			"X":  order.Status,
+			"i":  order.ID,
+			"l":  formatFloat(lastQty),
+			"z":  formatFloat(order.ExecutedQty),
+			"L":  formatFloat(lastPrice),
+			"N":  "USDT",
+			"n":  formatFloat(fee),
+			"T":  tradeTime,
+			"t":  order.ID,
+			"R":  order.ReduceOnly,
+			"wt": "CONTRACT_PRICE",
+			"ot": order.Type,
+			"ps": order.PositionSide,
+			"cp": order.ClosePosition,
+			"rp": formatFloat(realized),
+		},
+	}
+}
+
+// accountUpdate ACCOUNT_UPDATE事件：USDT钱包余额，以及symbol在各持仓方向上的持仓（已平仓的方向数量为0）
+// reason为事件原因（成交为ORDER，资金费结算为FUNDING_FEE）
+func (b *binanceServer) accountUpdate(symbol, reason string) map[string]interface{} {
+	b.settings.mu.Lock()
+	sides := []string{"BOTH"}
+	if b.settings.hedge {
+		sides = []string{"LONG", "SHORT"}
+	}
+	b.settings.mu.Unlock()
+
+	var positions []map[string]interface{}
+	for _, positionSide := range sides {
+		entry := map[string]interface{}{
+			"s": symbol, "pa": "0", "ep": "0", "cr": "0", "up": "0",
+			"mt": b.settings.marginTypeOf(symbol), "iw": "0", "ps": positionSide,
+		}
+		for _, pos := range b.venue.Positions() {
+			if pos.Symbol != symbol || (positionSide != "BOTH" && strings.ToLower(positionSide) != pos.Side) {
+				continue
+			}
+			_, unrealized, _ := b.venue.positionDetails(pos)
+			amount := pos.Size
+			if pos.Side == "short" {
+				amount = -amount
+			}
+			entry["pa"] = formatFloat(amount)
+			entry["ep"] = formatFloat(pos.EntryPrice)
+			entry["up"] = formatFloat(unrealized)
+		}
+		positions = append(positions, entry)
+	}
+
+	balance := formatFloat(b.venue.Balance())
+	now := time.Now().UnixMilli()
+	return map[string]interface{}{
+		"e": "ACCOUNT_UPDATE",
+		"E": now,
+		"T": now,
+		"a": map[string]interface{}{
+			"m": reason,
+			"B": []map[string]interface{}{{"a": "USDT", "wb": balance, "cw": balance, "bc": "0"}},
+			"P": positions,
+		},
+	}
+}
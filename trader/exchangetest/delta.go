@@ -0,0 +1,593 @@
+package exchangetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deltaServer Delta Exchange v2接口（单向持仓模式，数量以整数张合约计，每张合约为contract_value个基础资产）
+type deltaServer struct {
+	venue     *Venue
+	apiKey    string
+	apiSecret string
+}
+
+// NewDeltaServer 启动模拟Delta Exchange服务：校验api-key和HMAC-SHA256签名
+// （method + path + querystring + body + timestamp），持仓为单向模式（空仓size为负）
+// 每张合约对应交易对的一个数量步长（StepSize），下单的size必须为正整数张
+func NewDeltaServer(venue *Venue, apiKey, apiSecret string) *Server {
+	d := &deltaServer{venue: venue, apiKey: apiKey, apiSecret: apiSecret}
+	s := newServer(venue, d.handle)
+	s.orderTimeout = orderTimeout{
+		isOrder: func(r *http.Request, body []byte) bool {
+			return r.Method == http.MethodPost && r.URL.Path == "/v2/orders"
+		},
+		status: http.StatusGatewayTimeout,
+		body:   `{"success":false,"error":{"code":"gateway_timeout"}}`,
+	}
+	return s
+}
+
+// deltaError Delta格式的错误响应
+func deltaError(w http.ResponseWriter, status int, code string, context map[string]interface{}) {
+	errBody := map[string]interface{}{"code": code}
+	if context != nil {
+		errBody["context"] = context
+	}
+	writeJSON(w, status, map[string]interface{}{"success": false, "error": errBody})
+}
+
+// deltaVenueError 撮合错误转换为Delta错误码
+func deltaVenueError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownSymbol):
+		deltaError(w, http.StatusBadRequest, "invalid_contract", nil)
+	case errors.Is(err, ErrInvalidQuantity):
+		deltaError(w, http.StatusBadRequest, "invalid_order_size", nil)
+	case errors.Is(err, ErrInvalidPrice):
+		deltaError(w, http.StatusBadRequest, "invalid_price", nil)
+	case errors.Is(err, ErrInsufficientMargin):
+		deltaError(w, http.StatusBadRequest, "insufficient_margin", nil)
+	case errors.Is(err, ErrReduceOnly):
+		deltaError(w, http.StatusBadRequest, "immediate_liquidation_or_reduce_only_violation", nil)
+	case errors.Is(err, ErrOrderNotFound):
+		deltaError(w, http.StatusNotFound, "open_order_not_found", nil)
+	default:
+		deltaError(w, http.StatusBadRequest, "bad_schema", map[string]interface{}{"message": err.Error()})
+	}
+}
+
+// deltaSuccess Delta格式的成功响应
+func deltaSuccess(w http.ResponseWriter, result interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "result": result})
+}
+
+func (d *deltaServer) handle(w http.ResponseWriter, r *http.Request) {
+	_, body, err := readParams(r)
+	if err != nil {
+		deltaError(w, http.StatusBadRequest, "bad_schema", nil)
+		return
+	}
+
+	// 公共接口（无需签名）
+	if r.Method == http.MethodGet {
+		switch {
+		case r.URL.Path == "/v2/products":
+			d.products(w)
+			return
+		case strings.HasPrefix(r.URL.Path, "/v2/tickers/"):
+			d.ticker(w, strings.TrimPrefix(r.URL.Path, "/v2/tickers/"))
+			return
+		}
+	}
+
+	if !d.authenticate(w, r, body) {
+		return
+	}
+
+	var payload map[string]interface{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			deltaError(w, http.StatusBadRequest, "bad_schema", map[string]interface{}{"message": "invalid json"})
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/wallet/balances":
+		d.balances(w)
+	case r.Method == http.MethodGet && (r.URL.Path == "/v2/positions" || r.URL.Path == "/v2/positions/margined"):
+		d.positions(w)
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/orders":
+		d.createOrder(w, payload)
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/orders/bracket":
+		d.createBracket(w, payload)
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/orders":
+		d.openOrders(w)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v2/orders/"):
+		d.queryOrder(w, strings.TrimPrefix(r.URL.Path, "/v2/orders/"))
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/fills":
+		d.fills(w, r.URL.Query().Get("start_time"))
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/wallet/transactions":
+		d.transactions(w, r.URL.Query())
+	case r.Method == http.MethodDelete && r.URL.Path == "/v2/orders":
+		symbol, ok := d.productSymbol(payload["product_id"])
+		if !ok {
+			deltaVenueError(w, ErrUnknownSymbol)
+			return
+		}
+		id, _ := payload["id"].(float64)
+		if err := d.venue.cancelOrder(symbol, int64(id)); err != nil {
+			deltaVenueError(w, err)
+			return
+		}
+		deltaSuccess(w, map[string]interface{}{"id": int64(id), "state": "cancelled"})
+	case r.Method == http.MethodDelete && r.URL.Path == "/v2/orders/all":
+		symbol, ok := d.productSymbol(payload["product_id"])
+		if !ok {
+			deltaVenueError(w, ErrUnknownSymbol)
+			return
+		}
+		d.venue.cancelAll(symbol)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v2/products/") && strings.HasSuffix(r.URL.Path, "/orders/leverage"):
+		d.setLeverage(w, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v2/products/"), "/orders/leverage"), payload)
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/positions/change_margin":
+		// 调整逐仓保证金（需要delta_margin参数）
+		if _, ok := payload["delta_margin"]; !ok {
+			deltaError(w, http.StatusBadRequest, "bad_schema", map[string]interface{}{"schema_errors": []map[string]string{{"param": "delta_margin", "message": "is required"}}})
+			return
+		}
+		deltaSuccess(w, map[string]interface{}{})
+	default:
+		deltaError(w, http.StatusNotFound, "not_found", nil)
+	}
+}
+
+// authenticate 校验api-key、时间戳（5分钟内）和签名
+func (d *deltaServer) authenticate(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	if r.Header.Get("api-key") != d.apiKey {
+		deltaError(w, http.StatusUnauthorized, "invalid_api_key", nil)
+		return false
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get("timestamp"), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)).Abs() > 5*time.Minute {
+		deltaError(w, http.StatusUnauthorized, "expired_signature", nil)
+		return false
+	}
+
+	query := ""
+	if r.URL.RawQuery != "" {
+		query = "?" + r.URL.RawQuery
+	}
+	message := r.Method + r.URL.Path + query + string(body) + r.Header.Get("timestamp")
+	mac := hmac.New(sha256.New, []byte(d.apiSecret))
+	mac.Write([]byte(message))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("signature"))) {
+		deltaError(w, http.StatusUnauthorized, "Signature Mismatch", map[string]interface{}{"signature_data": message})
+		return false
+	}
+	return true
+}
+
+// productID 交易对的product_id（按添加顺序从1开始）
+func (d *deltaServer) productID(symbol string) int {
+	for i, s := range d.venue.Symbols() {
+		if s == symbol {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// productSymbol product_id转交易对
+func (d *deltaServer) productSymbol(id interface{}) (string, bool) {
+	n, ok := id.(float64)
+	if !ok {
+		return "", false
+	}
+	symbols := d.venue.Symbols()
+	if n < 1 || int(n) > len(symbols) {
+		return "", false
+	}
+	return symbols[int(n)-1], true
+}
+
+// contracts 基础资产数量转合约张数
+func (d *deltaServer) contracts(symbol string, quantity float64) float64 {
+	inst, ok := d.venue.Instrument(symbol)
+	if !ok || inst.StepSize <= 0 {
+		return quantity
+	}
+	return math.Round(quantity / inst.StepSize)
+}
+
+// orderSize 解析下单的size（必须为正整数张合约），返回对应的基础资产数量
+func (d *deltaServer) orderSize(symbol string, size interface{}) (float64, error) {
+	contracts, err := strconv.ParseFloat(fmt.Sprint(size), 64)
+	if err != nil || contracts <= 0 || contracts != math.Trunc(contracts) {
+		return 0, ErrInvalidQuantity
+	}
+	inst, ok := d.venue.Instrument(symbol)
+	if !ok {
+		return 0, ErrUnknownSymbol
+	}
+	return contracts * inst.StepSize, nil
+}
+
+func (d *deltaServer) products(w http.ResponseWriter) {
+	var result []map[string]interface{}
+	for i, symbol := range d.venue.Symbols() {
+		inst, _ := d.venue.Instrument(symbol)
+		result = append(result, map[string]interface{}{
+			"id":               i + 1,
+			"symbol":           symbol,
+			"contract_type":    "perpetual_futures",
+			"contract_value":   formatFloat(inst.StepSize),
+			"tick_size":        formatFloat(inst.TickSize),
+			"state":            "live",
+			"default_leverage": "20",
+			"initial_margin":   formatFloat(100 / float64(inst.MaxLeverage)), // 百分比，最大杠杆 = 100 / initial_margin
+			"quoting_asset":    map[string]string{"symbol": "USDT"},
+			"settling_asset":   map[string]string{"symbol": "USDT"},
+		})
+	}
+	deltaSuccess(w, result)
+}
+
+func (d *deltaServer) ticker(w http.ResponseWriter, symbol string) {
+	price, ok := d.venue.Price(symbol)
+	if !ok {
+		deltaError(w, http.StatusNotFound, "invalid_contract", nil)
+		return
+	}
+	deltaSuccess(w, map[string]interface{}{
+		"symbol":     symbol,
+		"product_id": d.productID(symbol),
+		"close":      formatFloat(price),
+		"mark_price": formatFloat(price),
+		"spot_price": formatFloat(price),
+		// 资金费率为百分比（每8小时结算一次）
+		"funding_rate": formatFloat(d.venue.FundingRate(symbol) * 100),
+		"timestamp":    time.Now().UnixMicro(),
+	})
+}
+
+func (d *deltaServer) balances(w http.ResponseWriter) {
+	balance := d.venue.Balance()
+	available := balance + d.venue.UnrealizedPnL() - d.venue.MarginUsed()
+	deltaSuccess(w, []map[string]interface{}{
+		{
+			"asset":             "USDT",
+			"asset_symbol":      "USDT",
+			"balance":           formatFloat(balance),
+			"available_balance": formatFloat(available),
+			"position_margin":   formatFloat(d.venue.MarginUsed()),
+			"order_margin":      "0",
+		},
+	})
+}
+
+func (d *deltaServer) positions(w http.ResponseWriter) {
+	result := []map[string]interface{}{}
+	for _, pos := range d.venue.Positions() {
+		markPrice, unrealized, liquidation := d.venue.positionDetails(pos)
+		size := pos.Size
+		side := "buy"
+		if pos.Side == "short" {
+			size = -size
+			side = "sell"
+		}
+		margin := pos.Size * pos.EntryPrice / float64(pos.Leverage)
+		result = append(result, map[string]interface{}{
+			"product_id":             d.productID(pos.Symbol),
+			"symbol":                 pos.Symbol,
+			"product_symbol":         pos.Symbol,
+			"size":                   formatFloat(d.contracts(pos.Symbol, size)),
+			"side":                   side,
+			"entry_price":            formatFloat(pos.EntryPrice),
+			"mark_price":             formatFloat(markPrice),
+			"liquidation_price":      formatFloat(liquidation),
+			"margin":                 formatFloat(margin),
+			"unrealized_pnl":         formatFloat(unrealized),
+			"unrealized_pnl_percent": formatFloat(unrealized / margin * 100),
+			"leverage":               pos.Leverage,
+		})
+	}
+	deltaSuccess(w, result)
+}
+
+func (d *deltaServer) setLeverage(w http.ResponseWriter, productID string, payload map[string]interface{}) {
+	id, _ := strconv.Atoi(productID)
+	symbol, ok := d.productSymbol(float64(id))
+	if !ok {
+		deltaVenueError(w, ErrUnknownSymbol)
+		return
+	}
+	leverage, err := strconv.Atoi(fmt.Sprint(payload["leverage"]))
+	if err != nil {
+		deltaError(w, http.StatusBadRequest, "bad_schema", map[string]interface{}{"message": "leverage is required"})
+		return
+	}
+	if err := d.venue.setLeverage(symbol, leverage); err != nil {
+		deltaError(w, http.StatusBadRequest, "invalid_leverage", nil)
+		return
+	}
+	deltaSuccess(w, map[string]interface{}{"leverage": strconv.Itoa(leverage), "product_id": id})
+}
+
+func (d *deltaServer) createOrder(w http.ResponseWriter, payload map[string]interface{}) {
+	symbol, ok := d.productSymbol(payload["product_id"])
+	if !ok {
+		deltaVenueError(w, ErrUnknownSymbol)
+		return
+	}
+	side := strings.ToUpper(fmt.Sprint(payload["side"]))
+	if side != "BUY" && side != "SELL" {
+		deltaError(w, http.StatusBadRequest, "bad_schema", map[string]interface{}{"message": "side must be buy or sell"})
+		return
+	}
+	size, err := d.orderSize(symbol, payload["size"])
+	if err != nil {
+		deltaVenueError(w, err)
+		return
+	}
+	reduceOnly := fmt.Sprint(payload["reduce_only"]) == "true"
+	clientID, _ := payload["client_order_id"].(string)
+
+	switch orderType := fmt.Sprint(payload["order_type"]); orderType {
+	case "market_order":
+		fill, err := d.venue.marketOrder(symbol, side, "BOTH", size, reduceOnly)
+		if err != nil {
+			deltaVenueError(w, err)
+			return
+		}
+		d.venue.setClientID(fill.OrderID, clientID)
+		deltaSuccess(w, d.order(Order{ID: fill.OrderID, ClientID: clientID, Symbol: symbol, Side: side, Quantity: size, ReduceOnly: reduceOnly, Fee: fill.Fee}, orderType, "closed", fill.Price))
+
+	case "limit_order":
+		price, _ := strconv.ParseFloat(fmt.Sprint(payload["limit_price"]), 64)
+		timeInForce := strings.ToUpper(fmt.Sprint(payload["time_in_force"]))
+		if fmt.Sprint(payload["post_only"]) == "true" {
+			timeInForce = "POST_ONLY"
+		}
+		order, fill, err := d.venue.limitOrder(symbol, side, "BOTH", size, price, reduceOnly, timeInForce)
+		if errors.Is(err, ErrNoMatch) || errors.Is(err, ErrWouldTake) {
+			// Delta会直接取消未成交的IOC单和会吃单的post-only单
+			deltaSuccess(w, d.order(Order{Symbol: symbol, Side: side, Quantity: size, Price: price}, orderType, "cancelled", 0))
+			return
+		}
+		if err != nil {
+			deltaVenueError(w, err)
+			return
+		}
+		order.ClientID = clientID
+		d.venue.setClientID(order.ID, clientID)
+		if fill != nil {
+			deltaSuccess(w, d.order(order, orderType, "closed", fill.Price))
+			return
+		}
+		deltaSuccess(w, d.order(order, orderType, "open", 0))
+
+	case "stop_loss_order", "take_profit_order":
+		// 止损/止盈单：触发价为stop_price（止盈单也接受limit_price作为触发价）
+		stopPrice, _ := strconv.ParseFloat(fmt.Sprint(payload["stop_price"]), 64)
+		if stopPrice <= 0 {
+			stopPrice, _ = strconv.ParseFloat(fmt.Sprint(payload["limit_price"]), 64)
+		}
+		conditional := "STOP_MARKET"
+		if orderType == "take_profit_order" {
+			conditional = "TAKE_PROFIT_MARKET"
+		}
+		order, err := d.venue.placeConditional(Order{
+			ClientID: clientID, Symbol: symbol, Type: conditional, Side: side, PositionSide: "BOTH",
+			Quantity: size, StopPrice: stopPrice, ReduceOnly: true,
+		})
+		if err != nil {
+			deltaVenueError(w, err)
+			return
+		}
+		deltaSuccess(w, d.order(order, orderType, "pending", 0))
+
+	default:
+		deltaError(w, http.StatusBadRequest, "bad_schema", map[string]interface{}{"message": "invalid order_type " + orderType})
+	}
+}
+
+// createBracket 为当前持仓挂止损/止盈（stop_loss_order/take_profit_order），触发时平掉全部持仓
+// 每个持仓每种类型只允许一张，已有时返回错误（需要先撤销）
+func (d *deltaServer) createBracket(w http.ResponseWriter, payload map[string]interface{}) {
+	symbol, ok := d.productSymbol(payload["product_id"])
+	if !ok {
+		deltaVenueError(w, ErrUnknownSymbol)
+		return
+	}
+	pos, ok := d.venue.Position(symbol, "long")
+	side := "SELL"
+	if !ok {
+		pos, ok = d.venue.Position(symbol, "short")
+		side = "BUY"
+	}
+	if !ok {
+		deltaError(w, http.StatusBadRequest, "no_position_for_bracket_order", nil)
+		return
+	}
+
+	legs := map[string]string{"stop_loss_order": "STOP_MARKET", "take_profit_order": "TAKE_PROFIT_MARKET"}
+	result := map[string]interface{}{}
+	for key, conditional := range legs {
+		leg, ok := payload[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, open := range d.venue.OpenOrders() {
+			if open.Symbol == symbol && open.Type == conditional && open.ClosePosition {
+				deltaError(w, http.StatusBadRequest, "bracket_order_already_exists", map[string]interface{}{"order_type": key})
+				return
+			}
+		}
+		stopPrice, _ := strconv.ParseFloat(fmt.Sprint(leg["stop_price"]), 64)
+		order, err := d.venue.placeConditional(Order{
+			Symbol: symbol, Type: conditional, Side: side, PositionSide: "BOTH",
+			Quantity: pos.Size, StopPrice: stopPrice, ReduceOnly: true, ClosePosition: true,
+		})
+		if err != nil {
+			deltaVenueError(w, err)
+			return
+		}
+		result[key] = d.order(order, key, "pending", 0)
+	}
+	if len(result) == 0 {
+		deltaError(w, http.StatusBadRequest, "bad_schema", map[string]interface{}{"message": "stop_loss_order or take_profit_order is required"})
+		return
+	}
+	deltaSuccess(w, result)
+}
+
+func (d *deltaServer) openOrders(w http.ResponseWriter) {
+	result := []map[string]interface{}{}
+	for _, order := range d.venue.OpenOrders() {
+		result = append(result, d.order(order, deltaOrderType(order), "open", 0))
+	}
+	deltaSuccess(w, result)
+}
+
+// queryOrder 按ID（/v2/orders/{id}）或客户端订单ID（/v2/orders/client_order_id/{id}）查询订单（包括已成交/已撤销的订单）
+func (d *deltaServer) queryOrder(w http.ResponseWriter, path string) {
+	if clientID, ok := strings.CutPrefix(path, "client_order_id/"); ok {
+		order, found := d.venue.FindOrderByClientID("", clientID)
+		if !found {
+			deltaVenueError(w, ErrOrderNotFound)
+			return
+		}
+		d.writeOrder(w, order)
+		return
+	}
+
+	id, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		deltaError(w, http.StatusBadRequest, "bad_schema", nil)
+		return
+	}
+	for _, symbol := range d.venue.Symbols() {
+		if order, ok := d.venue.FindOrder(symbol, id); ok {
+			d.writeOrder(w, order)
+			return
+		}
+	}
+	deltaVenueError(w, ErrOrderNotFound)
+}
+
+// writeOrder 输出订单查询结果（按订单状态转换Delta的state）
+func (d *deltaServer) writeOrder(w http.ResponseWriter, order Order) {
+	state := "open"
+	switch order.Status {
+	case "FILLED":
+		state = "closed"
+	case "CANCELED", "EXPIRED":
+		state = "cancelled"
+	}
+	result := d.order(order, deltaOrderType(order), state, order.AvgPrice)
+	result["unfilled_size"] = d.contracts(order.Symbol, order.Quantity-order.ExecutedQty)
+	deltaSuccess(w, result)
+}
+
+// fills 成交历史（start_time为微秒时间戳）
+func (d *deltaServer) fills(w http.ResponseWriter, startTime string) {
+	since, _ := strconv.ParseInt(startTime, 10, 64)
+	result := []map[string]interface{}{}
+	for i, fill := range d.venue.Fills() {
+		if fill.Time.UnixMicro() < since {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"id":             i + 1,
+			"order_id":       strconv.FormatInt(fill.OrderID, 10),
+			"product_id":     d.productID(fill.Symbol),
+			"product_symbol": fill.Symbol,
+			"side":           strings.ToLower(fill.Side),
+			"size":           d.contracts(fill.Symbol, fill.Quantity),
+			"price":          formatFloat(fill.Price),
+			"commission":     formatFloat(fill.Fee),
+			"role":           "taker",
+			"created_at":     fill.Time.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	deltaSuccess(w, result)
+}
+
+// transactions 钱包流水（只有资金费funding；transaction_types/start_time可选，时间为微秒）
+func (d *deltaServer) transactions(w http.ResponseWriter, query url.Values) {
+	since, _ := strconv.ParseInt(query.Get("start_time"), 10, 64)
+	result := []map[string]interface{}{}
+	if types := query.Get("transaction_types"); types != "" && !strings.Contains(types, "funding") {
+		deltaSuccess(w, result)
+		return
+	}
+	balance := d.venue.Balance()
+	for i, payment := range d.venue.FundingPayments() {
+		if payment.Time.UnixMicro() < since {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"id":               i + 1,
+			"amount":           formatFloat(payment.Amount),
+			"balance":          formatFloat(balance),
+			"transaction_type": "funding",
+			"product_id":       d.productID(payment.Symbol),
+			"asset_symbol":     "USDT",
+			"meta_data":        map[string]interface{}{"funding_rate": formatFloat(payment.Rate * 100)},
+			"created_at":       payment.Time.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	deltaSuccess(w, result)
+}
+
+// deltaOrderType 订单类型转Delta的order_type
+func deltaOrderType(order Order) string {
+	switch order.Type {
+	case "STOP_MARKET":
+		return "stop_loss_order"
+	case "TAKE_PROFIT_MARKET":
+		return "take_profit_order"
+	case "MARKET":
+		return "market_order"
+	}
+	return "limit_order"
+}
+
+// order Delta格式的订单（size和unfilled_size为合约张数）
+func (d *deltaServer) order(order Order, orderType, state string, avgPrice float64) map[string]interface{} {
+	size := d.contracts(order.Symbol, order.Quantity)
+	unfilled := size
+	if state == "closed" {
+		unfilled = 0
+	}
+	return map[string]interface{}{
+		"id":                 order.ID,
+		"client_order_id":    order.ClientID,
+		"product_id":         d.productID(order.Symbol),
+		"product_symbol":     order.Symbol,
+		"order_type":         orderType,
+		"side":               strings.ToLower(order.Side),
+		"size":               size,
+		"unfilled_size":      unfilled,
+		"state":              state,
+		"limit_price":        formatFloat(order.Price),
+		"stop_price":         formatFloat(order.StopPrice),
+		"reduce_only":        order.ReduceOnly,
+		"average_fill_price": formatFloat(avgPrice),
+		"paid_commission":    formatFloat(order.Fee),
+		"created_at":         time.Now().UTC().Format(time.RFC3339),
+	}
+}
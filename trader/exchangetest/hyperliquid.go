@@ -0,0 +1,625 @@
+package exchangetest
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sonirico/go-hyperliquid"
+)
+
+// hyperliquidServer Hyperliquid接口（/info + /exchange，单向持仓，币种名为去掉USDT后缀的symbol）
+type hyperliquidServer struct {
+	venue    *Venue
+	agentKey *ecdsa.PrivateKey
+	wallet   string
+
+	mu     sync.Mutex
+	nonces map[int64]bool  // 已使用的nonce
+	cross  map[string]bool // symbol -> 全仓（updateLeverage的isCross）
+
+	streams *streamRegistry
+}
+
+// NewHyperliquidServer 启动模拟Hyperliquid服务：/exchange请求用agentKey按L1 action规则重新签名，
+// 与请求中的签名比对（签名是确定性的），clearinghouseState/openOrders只接受wallet地址
+// 账户推送：ws://{host}/ws 支持订阅wallet的userFills、orderUpdates和webData2
+func NewHyperliquidServer(venue *Venue, agentKey *ecdsa.PrivateKey, wallet string) *Server {
+	h := &hyperliquidServer{
+		venue:    venue,
+		agentKey: agentKey,
+		wallet:   wallet,
+		nonces:   make(map[int64]bool),
+		cross:    make(map[string]bool),
+	}
+	s := newServer(venue, h.handle)
+	h.streams = s.streams
+	s.orderTimeout = orderTimeout{
+		isOrder: func(r *http.Request, body []byte) bool {
+			var req struct {
+				Action struct {
+					Type string `json:"type"`
+				} `json:"action"`
+			}
+			return r.URL.Path == "/exchange" && json.Unmarshal(body, &req) == nil && req.Action.Type == "order"
+		},
+		status: http.StatusGatewayTimeout,
+		body:   "Gateway Timeout",
+	}
+	return s
+}
+
+// hyperliquidErr Hyperliquid格式的错误响应（HTTP 200 + status=err）
+func hyperliquidErr(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "err", "response": msg})
+}
+
+// hyperliquidOK Hyperliquid格式的成功响应
+func hyperliquidOK(w http.ResponseWriter, responseType string, data interface{}) {
+	response := map[string]interface{}{"type": responseType}
+	if data != nil {
+		response["data"] = data
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "response": response})
+}
+
+func (h *hyperliquidServer) handle(w http.ResponseWriter, r *http.Request) {
+	// 账户推送（WebSocket，见hyperliquid_stream.go）
+	if r.URL.Path == "/ws" {
+		h.stream(w, r)
+		return
+	}
+
+	_, body, err := readParams(r)
+	if err != nil || r.Method != http.MethodPost {
+		http.Error(w, "Failed to deserialize the JSON body", http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/info":
+		h.info(w, body)
+	case "/exchange":
+		h.exchange(w, body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// coin 交易对转币种名（BTCUSDT -> BTC）
+func coin(symbol string) string {
+	return strings.TrimSuffix(symbol, "USDT")
+}
+
+// assetSymbol 资产编号（meta.universe中的下标）转交易对
+func (h *hyperliquidServer) assetSymbol(asset int) (string, bool) {
+	symbols := h.venue.Symbols()
+	if asset < 0 || asset >= len(symbols) {
+		return "", false
+	}
+	return symbols[asset], true
+}
+
+func (h *hyperliquidServer) info(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Type      string          `json:"type"`
+		User      string          `json:"user"`
+		Oid       json.RawMessage `json:"oid"` // 订单ID（数字）或cloid（字符串）
+		StartTime int64           `json:"startTime"`
+		EndTime   int64           `json:"endTime"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Failed to deserialize the JSON body", http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch req.Type {
+	case "meta":
+		writeJSON(w, http.StatusOK, h.meta())
+
+	case "metaAndAssetCtxs":
+		ctxs := []map[string]interface{}{}
+		for _, symbol := range h.venue.Symbols() {
+			price, _ := h.venue.Price(symbol)
+			ctxs = append(ctxs, map[string]interface{}{
+				"funding":      formatFloat(h.venue.FundingRate(symbol)),
+				"openInterest": "0",
+				"prevDayPx":    formatFloat(price),
+				"dayNtlVlm":    "0",
+				"premium":      "0",
+				"oraclePx":     formatFloat(price),
+				"markPx":       formatFloat(price),
+				"midPx":        formatFloat(price),
+				"impactPxs":    []string{formatFloat(price), formatFloat(price)},
+				"dayBaseVlm":   "0",
+			})
+		}
+		writeJSON(w, http.StatusOK, []interface{}{h.meta(), ctxs})
+
+	case "spotMeta":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"universe": []interface{}{}, "tokens": []interface{}{}})
+
+	case "allMids":
+		mids := make(map[string]string)
+		for _, symbol := range h.venue.Symbols() {
+			price, _ := h.venue.Price(symbol)
+			mids[coin(symbol)] = formatFloat(price)
+		}
+		writeJSON(w, http.StatusOK, mids)
+
+	case "clearinghouseState":
+		if !strings.EqualFold(req.User, h.wallet) {
+			// 未知地址返回空账户
+			writeJSON(w, http.StatusOK, h.userState(false))
+			return
+		}
+		writeJSON(w, http.StatusOK, h.userState(true))
+
+	case "openOrders", "frontendOpenOrders":
+		result := []map[string]interface{}{}
+		if strings.EqualFold(req.User, h.wallet) {
+			for _, order := range h.venue.OpenOrders() {
+				result = append(result, hyperliquidOrder(order, req.Type == "frontendOpenOrders"))
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	case "orderStatus":
+		order, ok := h.findOrder(req.Oid)
+		if !ok || !strings.EqualFold(req.User, h.wallet) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"status": "unknownOid"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "order",
+			"order": map[string]interface{}{
+				"order":           hyperliquidOrder(order, true),
+				"status":          hyperliquidStatus(order),
+				"statusTimestamp": order.Time.UnixMilli(),
+			},
+		})
+
+	case "userFills", "userFillsByTime":
+		result := []map[string]interface{}{}
+		if strings.EqualFold(req.User, h.wallet) {
+			for i, fill := range h.venue.Fills() {
+				if fill.Time.UnixMilli() < req.StartTime {
+					continue
+				}
+				result = append(result, hyperliquidFill(fill, i+1))
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	case "userFunding":
+		result := []map[string]interface{}{}
+		if strings.EqualFold(req.User, h.wallet) {
+			for i, payment := range h.venue.FundingPayments() {
+				t := payment.Time.UnixMilli()
+				if t < req.StartTime || (req.EndTime > 0 && t > req.EndTime) {
+					continue
+				}
+				result = append(result, hyperliquidFunding(payment, i+1))
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	default:
+		http.Error(w, "Failed to deserialize the JSON body into the target type", http.StatusUnprocessableEntity)
+	}
+}
+
+// meta 永续合约的币种列表（universe下标即资产编号）
+func (h *hyperliquidServer) meta() map[string]interface{} {
+	universe := []map[string]interface{}{}
+	for _, symbol := range h.venue.Symbols() {
+		inst, _ := h.venue.Instrument(symbol)
+		universe = append(universe, map[string]interface{}{
+			"name":        coin(symbol),
+			"szDecimals":  decimals(inst.StepSize),
+			"maxLeverage": inst.MaxLeverage,
+		})
+	}
+	return map[string]interface{}{"universe": universe, "marginTables": []interface{}{}}
+}
+
+// hyperliquidFunding Hyperliquid格式的资金费记录（szi为带符号的持仓数量，usdc为账户收支；seq用于生成hash）
+func hyperliquidFunding(payment FundingPayment, seq int) map[string]interface{} {
+	size := payment.Size
+	if payment.Side == "short" {
+		size = -size
+	}
+	return map[string]interface{}{
+		"time": payment.Time.UnixMilli(),
+		"hash": fmt.Sprintf("0x%064x", seq),
+		"delta": map[string]interface{}{
+			"type":        "funding",
+			"coin":        coin(payment.Symbol),
+			"usdc":        formatFloat(payment.Amount),
+			"szi":         formatFloat(size),
+			"fundingRate": formatFloat(payment.Rate),
+			"nSamples":    nil,
+		},
+	}
+}
+
+// findOrder 按订单ID（数字）或cloid（字符串）查找订单
+func (h *hyperliquidServer) findOrder(oid json.RawMessage) (Order, bool) {
+	var cloid string
+	if json.Unmarshal(oid, &cloid) == nil {
+		return h.venue.FindOrderByClientID("", cloid)
+	}
+	var id int64
+	if err := json.Unmarshal(oid, &id); err != nil {
+		return Order{}, false
+	}
+	for _, symbol := range h.venue.Symbols() {
+		if order, ok := h.venue.FindOrder(symbol, id); ok {
+			return order, true
+		}
+	}
+	return Order{}, false
+}
+
+// hyperliquidStatus 订单状态转为Hyperliquid的订单状态（触发单成交为triggered）
+func hyperliquidStatus(order Order) string {
+	switch order.Status {
+	case "FILLED":
+		if order.Type != "LIMIT" {
+			return "triggered"
+		}
+		return "filled"
+	case "CANCELED":
+		return "canceled"
+	case "EXPIRED":
+		return "rejected"
+	}
+	return "open"
+}
+
+// hyperliquidFill Hyperliquid格式的成交（tid为成交在venue中的序号，从1开始）
+func hyperliquidFill(fill Fill, tid int) map[string]interface{} {
+	side := "B"
+	if fill.Side == "SELL" {
+		side = "A"
+	}
+	return map[string]interface{}{
+		"coin":      coin(fill.Symbol),
+		"px":        formatFloat(fill.Price),
+		"sz":        formatFloat(fill.Quantity),
+		"side":      side,
+		"time":      fill.Time.UnixMilli(),
+		"closedPnl": formatFloat(fill.RealizedPnL),
+		"hash":      fmt.Sprintf("0x%064x", tid),
+		"oid":       fill.OrderID,
+		"crossed":   true,
+		"fee":       formatFloat(fill.Fee),
+		"feeToken":  "USDC",
+		"tid":       tid,
+	}
+}
+
+// hyperliquidOrder Hyperliquid格式的订单（frontend=true时包含订单类型、触发价等字段）
+func hyperliquidOrder(order Order, frontend bool) map[string]interface{} {
+	side := "B"
+	if order.Side == "SELL" {
+		side = "A"
+	}
+	price := order.Price
+	if price == 0 {
+		price = order.StopPrice
+	}
+	result := map[string]interface{}{
+		"coin":      coin(order.Symbol),
+		"limitPx":   formatFloat(price),
+		"oid":       order.ID,
+		"side":      side,
+		"sz":        formatFloat(order.Quantity - order.ExecutedQty),
+		"timestamp": order.Time.UnixMilli(),
+	}
+	if !frontend {
+		return result
+	}
+
+	orderType, triggerCondition := "Limit", "N/A"
+	switch order.Type {
+	case "STOP_MARKET":
+		orderType = "Stop Market"
+	case "TAKE_PROFIT_MARKET":
+		orderType = "Take Profit Market"
+	case "MARKET":
+		orderType = "Market"
+	}
+	if order.StopPrice > 0 {
+		triggerCondition = "Price above " + formatFloat(order.StopPrice)
+		if (order.Type == "STOP_MARKET") == (order.Side == "SELL") {
+			triggerCondition = "Price below " + formatFloat(order.StopPrice)
+		}
+	}
+	result["origSz"] = formatFloat(order.Quantity)
+	result["orderType"] = orderType
+	result["isTrigger"] = order.StopPrice > 0
+	result["triggerPx"] = formatFloat(order.StopPrice)
+	result["triggerCondition"] = triggerCondition
+	result["reduceOnly"] = order.ReduceOnly
+	result["isPositionTpsl"] = false
+	result["tif"] = nil
+	result["cloid"] = nil
+	if order.ClientID != "" {
+		result["cloid"] = order.ClientID
+	}
+	return result
+}
+
+// userState 账户状态（clearinghouseState）
+func (h *hyperliquidServer) userState(known bool) map[string]interface{} {
+	positions := []map[string]interface{}{}
+	balance, marginUsed, unrealized, notional := 0.0, 0.0, 0.0, 0.0
+	if known {
+		balance = h.venue.Balance()
+		marginUsed = h.venue.MarginUsed()
+		unrealized = h.venue.UnrealizedPnL()
+		for _, pos := range h.venue.Positions() {
+			markPrice, pnl, liquidation := h.venue.positionDetails(pos)
+			size := pos.Size
+			if pos.Side == "short" {
+				size = -size
+			}
+			margin := pos.Size * pos.EntryPrice / float64(pos.Leverage)
+			notional += pos.Size * markPrice
+			marginType := "isolated"
+			h.mu.Lock()
+			if h.cross[pos.Symbol] {
+				marginType = "cross"
+			}
+			h.mu.Unlock()
+			positions = append(positions, map[string]interface{}{
+				"type": "oneWay",
+				"position": map[string]interface{}{
+					"coin":           coin(pos.Symbol),
+					"entryPx":        formatFloat(pos.EntryPrice),
+					"leverage":       map[string]interface{}{"type": marginType, "value": pos.Leverage},
+					"liquidationPx":  formatFloat(liquidation),
+					"marginUsed":     formatFloat(margin),
+					"positionValue":  formatFloat(pos.Size * markPrice),
+					"returnOnEquity": formatFloat(pnl / margin),
+					"szi":            formatFloat(size),
+					"unrealizedPnl":  formatFloat(pnl),
+				},
+			})
+		}
+	}
+
+	summary := map[string]string{
+		"accountValue":    formatFloat(balance + unrealized),
+		"totalMarginUsed": formatFloat(marginUsed),
+		"totalNtlPos":     formatFloat(notional),
+		"totalRawUsd":     formatFloat(balance),
+	}
+	return map[string]interface{}{
+		"assetPositions":     positions,
+		"marginSummary":      summary,
+		"crossMarginSummary": summary,
+		"withdrawable":       formatFloat(balance + unrealized - marginUsed),
+	}
+}
+
+// exchangeRequest /exchange请求体
+type exchangeRequest struct {
+	Action    json.RawMessage             `json:"action"`
+	Nonce     int64                       `json:"nonce"`
+	Signature hyperliquid.SignatureResult `json:"signature"`
+}
+
+func (h *hyperliquidServer) exchange(w http.ResponseWriter, body []byte) {
+	var req exchangeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Failed to deserialize the JSON body", http.StatusUnprocessableEntity)
+		return
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(req.Action, &head); err != nil {
+		http.Error(w, "Failed to deserialize the JSON body", http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch head.Type {
+	case "order":
+		var action hyperliquid.OrderAction
+		if err := json.Unmarshal(req.Action, &action); err != nil {
+			http.Error(w, "Failed to deserialize the JSON body", http.StatusUnprocessableEntity)
+			return
+		}
+		if h.verify(w, action, req) {
+			h.order(w, action)
+		}
+
+	case "cancel":
+		var action hyperliquid.CancelAction
+		if err := json.Unmarshal(req.Action, &action); err != nil {
+			http.Error(w, "Failed to deserialize the JSON body", http.StatusUnprocessableEntity)
+			return
+		}
+		if h.verify(w, action, req) {
+			h.cancel(w, action)
+		}
+
+	case "updateLeverage":
+		var action hyperliquid.UpdateLeverageAction
+		if err := json.Unmarshal(req.Action, &action); err != nil {
+			http.Error(w, "Failed to deserialize the JSON body", http.StatusUnprocessableEntity)
+			return
+		}
+		if !h.verify(w, action, req) {
+			return
+		}
+		symbol, ok := h.assetSymbol(action.Asset)
+		if !ok {
+			hyperliquidErr(w, fmt.Sprintf("Invalid asset: %d", action.Asset))
+			return
+		}
+		if err := h.venue.setLeverage(symbol, action.Leverage); err != nil {
+			hyperliquidErr(w, "Invalid leverage value")
+			return
+		}
+		h.mu.Lock()
+		h.cross[symbol] = action.IsCross
+		h.mu.Unlock()
+		hyperliquidOK(w, "default", nil)
+
+	default:
+		http.Error(w, "Failed to deserialize the JSON body into the target type", http.StatusUnprocessableEntity)
+	}
+}
+
+// verify 用agent私钥对action重新签名并比对，同时拒绝重复nonce
+func (h *hyperliquidServer) verify(w http.ResponseWriter, action interface{}, req exchangeRequest) bool {
+	expected, err := hyperliquid.SignL1Action(h.agentKey, action, "", req.Nonce, nil, false)
+	if err != nil || !strings.EqualFold(expected.R, req.Signature.R) ||
+		!strings.EqualFold(expected.S, req.Signature.S) || expected.V != req.Signature.V {
+		// 真实服务会从签名恢复出一个随机地址并报告其不存在
+		hyperliquidErr(w, "User or API Wallet does not exist.")
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.nonces[req.Nonce] {
+		hyperliquidErr(w, fmt.Sprintf("Invalid nonce: duplicate nonce %d", req.Nonce))
+		return false
+	}
+	h.nonces[req.Nonce] = true
+	return true
+}
+
+func (h *hyperliquidServer) order(w http.ResponseWriter, action hyperliquid.OrderAction) {
+	statuses := []map[string]interface{}{}
+	for _, wire := range action.Orders {
+		statuses = append(statuses, h.placeOrder(wire))
+	}
+	hyperliquidOK(w, "order", map[string]interface{}{"statuses": statuses})
+}
+
+// placeOrder 处理单个订单，返回filled/resting/error状态
+func (h *hyperliquidServer) placeOrder(wire hyperliquid.OrderWire) map[string]interface{} {
+	symbol, ok := h.assetSymbol(wire.Asset)
+	if !ok {
+		return map[string]interface{}{"error": fmt.Sprintf("Invalid asset: %d", wire.Asset)}
+	}
+	inst, _ := h.venue.Instrument(symbol)
+	size, err := strconv.ParseFloat(wire.Size, 64)
+	if err != nil || size <= 0 {
+		return map[string]interface{}{"error": "Order has zero size."}
+	}
+	if !isMultiple(size, inst.StepSize) {
+		return map[string]interface{}{"error": "Order has invalid size."}
+	}
+	price, err := strconv.ParseFloat(wire.LimitPx, 64)
+	if err != nil || price <= 0 {
+		return map[string]interface{}{"error": "Order has invalid price."}
+	}
+	side := "SELL"
+	if wire.IsBuy {
+		side = "BUY"
+	}
+	clientID := ""
+	if wire.Cloid != nil {
+		clientID = *wire.Cloid
+	}
+
+	// 触发单（止损/止盈）
+	if trigger := wire.OrderType.Trigger; trigger != nil {
+		triggerPx, err := strconv.ParseFloat(trigger.TriggerPx, 64)
+		if err != nil || triggerPx <= 0 {
+			return map[string]interface{}{"error": "Order has invalid price."}
+		}
+		orderType := "STOP_MARKET"
+		if trigger.Tpsl == hyperliquid.TakeProfit {
+			orderType = "TAKE_PROFIT_MARKET"
+		}
+		order, err := h.venue.placeConditional(Order{
+			ClientID: clientID, Symbol: symbol, Type: orderType, Side: side, PositionSide: "BOTH",
+			Quantity: size, StopPrice: triggerPx, ReduceOnly: wire.ReduceOnly,
+		})
+		if err != nil {
+			return map[string]interface{}{"error": hyperliquidVenueError(err, wire.Asset)}
+		}
+		return map[string]interface{}{"resting": map[string]interface{}{"oid": order.ID}}
+	}
+
+	// 限价单：Hyperliquid价格按有效数字而非tick校验，这里对齐到tick后撮合
+	timeInForce := "GTC"
+	if wire.OrderType.Limit != nil {
+		switch wire.OrderType.Limit.Tif {
+		case hyperliquid.TifIoc:
+			timeInForce = "IOC"
+		case hyperliquid.TifAlo:
+			timeInForce = "POST_ONLY"
+		}
+	}
+	price = math.Round(price/inst.TickSize) * inst.TickSize
+	order, fill, err := h.venue.limitOrder(symbol, side, "BOTH", size, price, wire.ReduceOnly, timeInForce)
+	if err != nil {
+		return map[string]interface{}{"error": hyperliquidVenueError(err, wire.Asset)}
+	}
+	h.venue.setClientID(order.ID, clientID)
+	if fill != nil {
+		return map[string]interface{}{"filled": map[string]interface{}{
+			"totalSz": formatFloat(fill.Quantity),
+			"avgPx":   formatFloat(fill.Price),
+			"oid":     fill.OrderID,
+		}}
+	}
+	return map[string]interface{}{"resting": map[string]interface{}{"oid": order.ID}}
+}
+
+// hyperliquidVenueError 撮合错误转换为Hyperliquid错误文案
+func hyperliquidVenueError(err error, asset int) string {
+	switch {
+	case errors.Is(err, ErrWouldTake):
+		return fmt.Sprintf("Post only order would have immediately matched. asset=%d", asset)
+	case errors.Is(err, ErrNoMatch):
+		return fmt.Sprintf("Order could not immediately match against any resting orders. asset=%d", asset)
+	case errors.Is(err, ErrInsufficientMargin):
+		return fmt.Sprintf("Insufficient margin to place order. asset=%d", asset)
+	case errors.Is(err, ErrReduceOnly):
+		return fmt.Sprintf("Reduce only order would increase position. asset=%d", asset)
+	case errors.Is(err, ErrInvalidQuantity):
+		return "Order has invalid size."
+	case errors.Is(err, ErrInvalidPrice):
+		return "Order has invalid price."
+	default:
+		return err.Error()
+	}
+}
+
+func (h *hyperliquidServer) cancel(w http.ResponseWriter, action hyperliquid.CancelAction) {
+	statuses := []interface{}{}
+	for _, c := range action.Cancels {
+		symbol, ok := h.assetSymbol(c.Asset)
+		if !ok {
+			statuses = append(statuses, map[string]string{"error": fmt.Sprintf("Invalid asset: %d", c.Asset)})
+			continue
+		}
+		if err := h.venue.cancelOrder(symbol, c.OrderID); err != nil {
+			statuses = append(statuses, map[string]string{"error": "Order was never placed, already canceled, or filled. asset=" + strconv.Itoa(c.Asset)})
+			continue
+		}
+		statuses = append(statuses, "success")
+	}
+	hyperliquidOK(w, "cancel", map[string]interface{}{"statuses": statuses})
+}
+
+// AgentAddress 私钥对应的地址（便于测试中作为钱包地址）
+func AgentAddress(key *ecdsa.PrivateKey) string {
+	return crypto.PubkeyToAddress(key.PublicKey).Hex()
+}
@@ -0,0 +1,135 @@
+package exchangetest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hyperliquidCommand 客户端发送的WebSocket命令（subscribe/unsubscribe/ping）
+type hyperliquidCommand struct {
+	Method       string `json:"method"`
+	Subscription struct {
+		Type string `json:"type"`
+		User string `json:"user"`
+	} `json:"subscription"`
+}
+
+// stream 账户推送（/ws）：客户端subscribe后推送对应频道，消息格式为{"channel","data"}
+//   - userFills：订阅时先推送历史成交快照（isSnapshot=true），之后每笔成交推送一次
+//   - orderUpdates：订单状态变化
+//   - webData2：订阅时、每次订单变化或资金费结算后和每秒推送账户状态（clearinghouseState）和前端格式的挂单
+//
+// 只接受wallet地址的订阅；客户端发送ping时回复pong
+func (h *hyperliquidServer) stream(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := h.venue.Subscribe()
+	defer unsubscribe()
+	conn, release, err := h.streams.upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	// 读取到错误（客户端关闭或CloseStreams）时结束推送
+	commands := make(chan hyperliquidCommand)
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(closed)
+		for {
+			var cmd hyperliquidCommand
+			if err := conn.ReadJSON(&cmd); err != nil {
+				return
+			}
+			select {
+			case commands <- cmd:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	write := func(channel string, data interface{}) error {
+		return conn.WriteJSON(map[string]interface{}{"channel": channel, "data": data})
+	}
+	subscribed := make(map[string]bool)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-closed:
+			return
+
+		case cmd := <-commands:
+			switch cmd.Method {
+			case "ping":
+				err = conn.WriteJSON(map[string]string{"channel": "pong"})
+			case "subscribe", "unsubscribe":
+				if !strings.EqualFold(cmd.Subscription.User, h.wallet) {
+					err = write("error", "Invalid subscription: unknown user "+cmd.Subscription.User)
+					break
+				}
+				subscribed[cmd.Subscription.Type] = cmd.Method == "subscribe"
+				err = write("subscriptionResponse", cmd)
+				if err == nil && cmd.Method == "subscribe" {
+					switch cmd.Subscription.Type {
+					case "userFills":
+						err = write("userFills", h.userFills(true, h.venue.Fills(), 1))
+					case "webData2":
+						err = write("webData2", h.webData2())
+					}
+				}
+			}
+
+		case <-ticker.C:
+			if subscribed["webData2"] {
+				err = write("webData2", h.webData2())
+			}
+
+		case event := <-events:
+			// 资金费结算只改变余额，由webData2推送
+			if event.Funding == nil && subscribed["orderUpdates"] {
+				err = write("orderUpdates", []map[string]interface{}{{
+					"order":           hyperliquidOrder(event.Order, true),
+					"status":          hyperliquidStatus(event.Order),
+					"statusTimestamp": time.Now().UnixMilli(),
+				}})
+			}
+			if err == nil && event.Fill != nil && subscribed["userFills"] {
+				err = write("userFills", h.userFills(false, []Fill{*event.Fill}, len(h.venue.Fills())))
+			}
+			if err == nil && subscribed["webData2"] {
+				err = write("webData2", h.webData2())
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// userFills userFills频道的消息（firstTid为第一笔成交的序号）
+func (h *hyperliquidServer) userFills(snapshot bool, fills []Fill, firstTid int) map[string]interface{} {
+	result := []map[string]interface{}{}
+	for i, fill := range fills {
+		result = append(result, hyperliquidFill(fill, firstTid+i))
+	}
+	return map[string]interface{}{"isSnapshot": snapshot, "user": h.wallet, "fills": result}
+}
+
+// webData2 webData2频道的消息：账户状态和前端格式的挂单
+func (h *hyperliquidServer) webData2() map[string]interface{} {
+	orders := []map[string]interface{}{}
+	for _, order := range h.venue.OpenOrders() {
+		orders = append(orders, hyperliquidOrder(order, true))
+	}
+	return map[string]interface{}{
+		"clearinghouseState": h.userState(true),
+		"openOrders":         orders,
+		"serverTime":         time.Now().UnixMilli(),
+		"user":               h.wallet,
+	}
+}
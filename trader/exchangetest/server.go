@@ -0,0 +1,187 @@
+package exchangetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Server 模拟交易所HTTP服务（内嵌httptest.Server，URL即为交易器的base URL）
+type Server struct {
+	*httptest.Server
+	Venue *Venue
+
+	mu       sync.Mutex
+	failures []injectedFailure
+	requests []string
+
+	// 下单超时注入（见TimeoutNextOrder）
+	orderTimeout  orderTimeout
+	timeoutOrders int
+
+	// 当前连接中的WebSocket推送（见CloseStreams）
+	streams *streamRegistry
+}
+
+// streamRegistry 记录服务端的WebSocket连接（CloseStreams时全部断开）
+type streamRegistry struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]bool
+}
+
+// streamUpgrader 模拟服务的WebSocket升级（不检查Origin）
+var streamUpgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+// upgrade 把请求升级为WebSocket连接并记录，返回的release在连接结束时调用
+func (r *streamRegistry) upgrade(w http.ResponseWriter, req *http.Request) (*websocket.Conn, func(), error) {
+	conn, err := streamUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.mu.Lock()
+	r.conns[conn] = true
+	r.mu.Unlock()
+	return conn, func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+	}, nil
+}
+
+// CloseStreams 断开所有WebSocket推送连接（模拟断线，用于验证交易器的重连和REST回退）
+func (s *Server) CloseStreams() {
+	s.streams.mu.Lock()
+	defer s.streams.mu.Unlock()
+	for conn := range s.streams.conns {
+		conn.Close()
+	}
+}
+
+// orderTimeout 交易所下单请求超时时的表现：isOrder识别下单请求，status/body为返回给客户端的响应
+type orderTimeout struct {
+	isOrder func(r *http.Request, body []byte) bool
+	status  int
+	body    string
+}
+
+// injectedFailure 注入的错误响应
+type injectedFailure struct {
+	status int
+	body   string
+}
+
+// newServer 启动服务，handler处理已通过错误注入检查的请求
+func newServer(venue *Venue, handler http.HandlerFunc) *Server {
+	s := &Server{Venue: venue, streams: &streamRegistry{conns: make(map[*websocket.Conn]bool)}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		var failure *injectedFailure
+		if len(s.failures) > 0 {
+			failure = &s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if failure != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(failure.status)
+			io.WriteString(w, failure.body)
+			return
+		}
+		if s.takeOrderTimeout(r) {
+			// 订单照常撮合，但客户端只收到超时响应
+			handler(httptest.NewRecorder(), r)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(s.orderTimeout.status)
+			io.WriteString(w, s.orderTimeout.body)
+			return
+		}
+		handler(w, r)
+	}))
+	return s
+}
+
+// FailNext 让接下来的一次请求返回指定的状态码和响应体（可多次调用排队）
+func (s *Server) FailNext(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, injectedFailure{status: status, body: body})
+}
+
+// TimeoutNextOrder 让接下来的一次下单请求超时：交易所照常接受并撮合订单，客户端却收到超时响应
+// （币安/Aster为-1007，Delta/Hyperliquid为504），用于验证交易器按客户端订单ID确认结果、不会重复下单
+func (s *Server) TimeoutNextOrder() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeoutOrders++
+}
+
+// takeOrderTimeout 请求是下单请求且有待注入的超时时返回true（读取过的body会重新放回请求）
+func (s *Server) takeOrderTimeout(r *http.Request) bool {
+	s.mu.Lock()
+	pending := s.timeoutOrders > 0 && s.orderTimeout.isOrder != nil
+	s.mu.Unlock()
+	if !pending {
+		return false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !s.orderTimeout.isOrder(r, body) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timeoutOrders == 0 {
+		return false
+	}
+	s.timeoutOrders--
+	return true
+}
+
+// Requests 返回收到的全部请求（"METHOD /path"）
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// readParams 合并querystring和表单body中的参数（DELETE请求的body也会解析），同时返回原始body
+func readParams(r *http.Request) (url.Values, []byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := r.URL.Query()
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" && len(body) > 0 {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, vs := range form {
+			for _, v := range vs {
+				params.Add(k, v)
+			}
+		}
+	}
+	return params, body, nil
+}
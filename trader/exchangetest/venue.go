@@ -0,0 +1,828 @@
+// Package exchangetest 提供进程内的模拟交易所HTTP服务（币安、Aster、Delta、Hyperliquid），
+// 校验各平台的请求签名，并返回与真实接口格式一致的订单、持仓、余额和错误响应，
+// 用于在不连接真实交易所的情况下测试交易器实现
+package exchangetest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 撮合错误（各平台服务器会转换为对应的错误码/错误格式）
+var (
+	ErrUnknownSymbol      = errors.New("unknown symbol")
+	ErrInvalidQuantity    = errors.New("invalid quantity")
+	ErrInvalidPrice       = errors.New("invalid price")
+	ErrInsufficientMargin = errors.New("insufficient margin")
+	ErrReduceOnly         = errors.New("reduce only order would increase position")
+	ErrNoMatch            = errors.New("order could not immediately match")
+	ErrWouldTake          = errors.New("post only order would immediately match")
+	ErrOrderNotFound      = errors.New("order not found")
+)
+
+// Instrument 交易对规则
+type Instrument struct {
+	Symbol      string  // 交易对（如 BTCUSDT）
+	Price       float64 // 初始价格
+	StepSize    float64 // 数量步长
+	TickSize    float64 // 价格步长
+	MaxLeverage int     // 最大杠杆（0=125）
+	FeeRate     float64 // 手续费率（按成交额收取，如0.0005；0表示不收手续费）
+}
+
+// Position 持仓（Size始终为正数，方向由Side表示）
+type Position struct {
+	Symbol     string
+	Side       string // "long" 或 "short"
+	Size       float64
+	EntryPrice float64
+	Leverage   int
+}
+
+// Order 订单（挂单中的限价单/条件单，或已结束的历史订单）
+type Order struct {
+	ID            int64
+	ClientID      string
+	Symbol        string
+	Type          string // "LIMIT", "STOP_MARKET", "TAKE_PROFIT_MARKET", "TRAILING_STOP_MARKET"
+	Side          string // "BUY" 或 "SELL"
+	PositionSide  string // "LONG", "SHORT" 或 "BOTH"（单向持仓）
+	Quantity      float64
+	Price         float64 // 限价单价格
+	StopPrice     float64 // 条件单触发价（跟踪止损为按极值计算的当前触发价）
+	ReduceOnly    bool
+	ClosePosition bool // 触发时平掉全部持仓（忽略Quantity）
+	Time          time.Time
+
+	CallbackRate    float64 // 跟踪止损回调比例（百分比）
+	ActivationPrice float64 // 跟踪止损激活价（0表示下单即激活）
+	extreme         float64 // 跟踪止损激活后的最高价（卖单）/最低价（买单），0表示未激活
+
+	Status      string  // "NEW", "FILLED", "CANCELED", "EXPIRED"
+	ExecutedQty float64 // 已成交数量
+	AvgPrice    float64 // 成交均价
+	Fee         float64 // 手续费
+}
+
+// Fill 成交记录
+type Fill struct {
+	OrderID      int64
+	Symbol       string
+	Side         string // "BUY" 或 "SELL"
+	PositionSide string
+	Quantity     float64
+	Price        float64
+	RealizedPnL  float64
+	Fee          float64 // 手续费（USDT，从钱包余额扣除）
+	Time         time.Time
+}
+
+// FundingPayment 资金费结算记录
+type FundingPayment struct {
+	Symbol string
+	Side   string  // 持仓方向 "long" 或 "short"
+	Size   float64 // 结算时的持仓数量
+	Price  float64 // 结算价格
+	Rate   float64 // 资金费率（为正时多仓向空仓支付）
+	Amount float64 // 账户收支（USDT，正数为收入，负数为支出，已计入钱包余额）
+	Time   time.Time
+}
+
+// Event 账户变化事件（各平台服务器转换为WebSocket推送）
+// 订单挂出、成交、撤销或过期时产生，成交时Fill为该订单的成交记录；
+// 资金费结算时只有Funding（每个持仓一个事件）
+type Event struct {
+	Order   Order
+	Fill    *Fill
+	Funding *FundingPayment
+}
+
+// Venue 模拟交易所的撮合与账户状态（各平台服务器共用）
+// 市价单按当前价格立即成交；条件单在SetPrice触及触发价时按市价成交
+type Venue struct {
+	mu          sync.Mutex
+	balance     float64
+	instruments map[string]*Instrument
+	symbols     []string // 按添加顺序（用作Delta product_id、Hyperliquid asset编号）
+	prices      map[string]float64
+	leverage    map[string]int
+	positions   map[string]*Position // symbol|side -> 持仓
+	orders      []Order              // 挂单中的订单
+	history     []Order              // 已结束的订单（成交、撤销、过期）
+	fills       []Fill
+	nextID      int64
+
+	fundingRates map[string]float64 // 当前资金费率（见SetFundingRate）
+	funding      []FundingPayment   // 资金费结算记录
+
+	subscribers map[int]chan Event // 事件订阅（见Subscribe）
+	nextSub     int
+}
+
+// NewVenue 创建模拟交易所账户（balance为USDT钱包余额）
+func NewVenue(balance float64, instruments ...Instrument) *Venue {
+	v := &Venue{
+		balance:     balance,
+		instruments: make(map[string]*Instrument),
+		prices:      make(map[string]float64),
+		leverage:    make(map[string]int),
+		positions:   make(map[string]*Position),
+		nextID:      1000,
+		subscribers: make(map[int]chan Event),
+
+		fundingRates: make(map[string]float64),
+	}
+	for _, inst := range instruments {
+		v.AddInstrument(inst)
+	}
+	return v
+}
+
+// DefaultInstruments 常用交易对的默认规则
+func DefaultInstruments() []Instrument {
+	return []Instrument{
+		{Symbol: "BTCUSDT", Price: 100000, StepSize: 0.001, TickSize: 0.1, MaxLeverage: 125},
+		{Symbol: "ETHUSDT", Price: 4000, StepSize: 0.001, TickSize: 0.01, MaxLeverage: 100},
+		{Symbol: "SOLUSDT", Price: 200, StepSize: 0.01, TickSize: 0.01, MaxLeverage: 50},
+	}
+}
+
+// AddInstrument 添加交易对
+func (v *Venue) AddInstrument(inst Instrument) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if inst.MaxLeverage <= 0 {
+		inst.MaxLeverage = 125
+	}
+	if _, exists := v.instruments[inst.Symbol]; !exists {
+		v.symbols = append(v.symbols, inst.Symbol)
+	}
+	v.instruments[inst.Symbol] = &inst
+	v.prices[inst.Symbol] = inst.Price
+}
+
+// Subscribe 订阅账户变化事件，返回事件通道和取消订阅函数（消费不及时、通道已满时丢弃事件）
+func (v *Venue) Subscribe() (<-chan Event, func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	id := v.nextSub
+	v.nextSub++
+	ch := make(chan Event, 256)
+	v.subscribers[id] = ch
+	return ch, func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		delete(v.subscribers, id)
+	}
+}
+
+// publishLocked 把事件发给所有订阅者（调用方需持有锁）
+func (v *Venue) publishLocked(event Event) {
+	for _, ch := range v.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Instrument 获取交易对规则
+func (v *Venue) Instrument(symbol string) (Instrument, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	inst, ok := v.instruments[symbol]
+	if !ok {
+		return Instrument{}, false
+	}
+	return *inst, true
+}
+
+// Symbols 按添加顺序返回所有交易对
+func (v *Venue) Symbols() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]string(nil), v.symbols...)
+}
+
+// SetPrice 设置价格，并触发已到价的条件单
+func (v *Venue) SetPrice(symbol string, price float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.prices[symbol] = price
+	v.triggerLocked(symbol)
+}
+
+// Price 获取当前价格
+func (v *Venue) Price(symbol string) (float64, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	price, ok := v.prices[symbol]
+	return price, ok
+}
+
+// Balance 钱包余额（不含未实现盈亏）
+func (v *Venue) Balance() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.balance
+}
+
+// Leverage 获取交易对当前杠杆（未设置时为20）
+func (v *Venue) Leverage(symbol string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.leverageLocked(symbol)
+}
+
+// Positions 返回所有持仓（按交易对和方向排序）
+func (v *Venue) Positions() []Position {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	result := make([]Position, 0, len(v.positions))
+	for _, pos := range v.positions {
+		result = append(result, *pos)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Symbol != result[j].Symbol {
+			return result[i].Symbol < result[j].Symbol
+		}
+		return result[i].Side < result[j].Side
+	})
+	return result
+}
+
+// Position 获取指定方向的持仓
+func (v *Venue) Position(symbol, side string) (Position, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	pos, ok := v.positions[positionKey(symbol, side)]
+	if !ok {
+		return Position{}, false
+	}
+	return *pos, true
+}
+
+// OpenOrders 返回所有挂单
+func (v *Venue) OpenOrders() []Order {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]Order(nil), v.orders...)
+}
+
+// FindOrder 按ID查找订单（包括已结束的历史订单）
+func (v *Venue) FindOrder(symbol string, orderID int64) (Order, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, order := range v.orders {
+		if order.ID == orderID && order.Symbol == symbol {
+			return order, true
+		}
+	}
+	for i := len(v.history) - 1; i >= 0; i-- {
+		if v.history[i].ID == orderID && v.history[i].Symbol == symbol {
+			return v.history[i], true
+		}
+	}
+	return Order{}, false
+}
+
+// FindOrderByClientID 按客户端订单ID查找订单（包括已结束的历史订单，symbol为空时不限交易对）
+func (v *Venue) FindOrderByClientID(symbol, clientID string) (Order, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if clientID == "" {
+		return Order{}, false
+	}
+	match := func(order Order) bool {
+		return order.ClientID == clientID && (symbol == "" || order.Symbol == symbol)
+	}
+	for _, order := range v.orders {
+		if match(order) {
+			return order, true
+		}
+	}
+	for i := len(v.history) - 1; i >= 0; i-- {
+		if match(v.history[i]) {
+			return v.history[i], true
+		}
+	}
+	return Order{}, false
+}
+
+// Fills 返回所有成交记录
+func (v *Venue) Fills() []Fill {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]Fill(nil), v.fills...)
+}
+
+// SetFundingRate 设置交易对当前的资金费率（各平台的行情接口返回该费率，SettleFunding按该费率结算）
+func (v *Venue) SetFundingRate(symbol string, rate float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fundingRates[symbol] = rate
+}
+
+// FundingRate 获取交易对当前的资金费率（未设置时为0）
+func (v *Venue) FundingRate(symbol string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.fundingRates[symbol]
+}
+
+// SettleFunding 按当前资金费率和价格结算交易对所有持仓的资金费：
+// 多仓支付 数量×价格×费率，空仓收取同样的金额（费率为负时方向相反），收支计入钱包余额
+func (v *Venue) SettleFunding(symbol string) []FundingPayment {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	rate := v.fundingRates[symbol]
+	price := v.prices[symbol]
+	now := time.Now()
+
+	var payments []FundingPayment
+	for _, side := range []string{"long", "short"} {
+		pos, ok := v.positions[positionKey(symbol, side)]
+		if !ok {
+			continue
+		}
+		amount := -pos.Size * price * rate
+		if side == "short" {
+			amount = -amount
+		}
+		payment := FundingPayment{Symbol: symbol, Side: side, Size: pos.Size, Price: price, Rate: rate, Amount: amount, Time: now}
+		v.balance += amount
+		v.funding = append(v.funding, payment)
+		payments = append(payments, payment)
+		v.publishLocked(Event{Funding: &payment})
+	}
+	return payments
+}
+
+// FundingPayments 返回所有资金费结算记录
+func (v *Venue) FundingPayments() []FundingPayment {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]FundingPayment(nil), v.funding...)
+}
+
+// nextFundingTime 按固定间隔结算（从UTC零点开始）时now之后的下次结算时间
+func nextFundingTime(now time.Time, interval time.Duration) time.Time {
+	return now.Truncate(interval).Add(interval)
+}
+
+// UnrealizedPnL 所有持仓的未实现盈亏
+func (v *Venue) UnrealizedPnL() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.unrealizedLocked()
+}
+
+// MarginUsed 所有持仓占用的保证金
+func (v *Venue) MarginUsed() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.marginUsedLocked()
+}
+
+// setLeverage 设置杠杆
+func (v *Venue) setLeverage(symbol string, leverage int) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	inst, ok := v.instruments[symbol]
+	if !ok {
+		return ErrUnknownSymbol
+	}
+	if leverage < 1 || leverage > inst.MaxLeverage {
+		return fmt.Errorf("leverage %d is not valid (max %d)", leverage, inst.MaxLeverage)
+	}
+	v.leverage[symbol] = leverage
+	for _, side := range []string{"long", "short"} {
+		if pos, exists := v.positions[positionKey(symbol, side)]; exists {
+			pos.Leverage = leverage
+		}
+	}
+	return nil
+}
+
+// marketOrder 市价成交；positionSide为"BOTH"时按单向持仓处理（先减反向仓位，剩余部分开仓）
+func (v *Venue) marketOrder(symbol, side, positionSide string, quantity float64, reduceOnly bool) (Fill, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fill, err := v.marketOrderLocked(symbol, side, positionSide, quantity, reduceOnly, v.nextOrderIDLocked())
+	if err != nil {
+		return Fill{}, err
+	}
+	v.finishLocked(Order{ID: fill.OrderID, Symbol: symbol, Type: "MARKET", Side: side, PositionSide: positionSide, Quantity: quantity, ReduceOnly: reduceOnly, Time: fill.Time}, "FILLED", &fill)
+	return fill, nil
+}
+
+// limitOrder 限价单：价格可立即成交时按当前价成交，否则挂单
+// timeInForce为"IOC"时不能立即成交返回ErrNoMatch，为"POST_ONLY"时会立即成交返回ErrWouldTake
+func (v *Venue) limitOrder(symbol, side, positionSide string, quantity, price float64, reduceOnly bool, timeInForce string) (Order, *Fill, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	inst, ok := v.instruments[symbol]
+	if !ok {
+		return Order{}, nil, ErrUnknownSymbol
+	}
+	if price <= 0 || !isMultiple(price, inst.TickSize) {
+		return Order{}, nil, ErrInvalidPrice
+	}
+
+	market := v.prices[symbol]
+	marketable := (side == "BUY" && price >= market) || (side == "SELL" && price <= market)
+	if marketable && timeInForce == "POST_ONLY" {
+		return Order{}, nil, ErrWouldTake
+	}
+	id := v.nextOrderIDLocked()
+	if marketable {
+		fill, err := v.marketOrderLocked(symbol, side, positionSide, quantity, reduceOnly, id)
+		if err != nil {
+			return Order{}, nil, err
+		}
+		order := Order{ID: id, Symbol: symbol, Type: "LIMIT", Side: side, PositionSide: positionSide, Quantity: quantity, Price: price, ReduceOnly: reduceOnly, Time: fill.Time}
+		return v.finishLocked(order, "FILLED", &fill), &fill, nil
+	}
+	if timeInForce == "IOC" {
+		return Order{}, nil, ErrNoMatch
+	}
+	if err := v.checkQuantityLocked(inst, quantity); err != nil {
+		return Order{}, nil, err
+	}
+
+	order := Order{ID: id, Symbol: symbol, Type: "LIMIT", Side: side, PositionSide: positionSide, Quantity: quantity, Price: price, ReduceOnly: reduceOnly, Time: time.Now(), Status: "NEW"}
+	v.orders = append(v.orders, order)
+	v.publishLocked(Event{Order: order})
+	return order, nil, nil
+}
+
+// placeConditional 挂止损/止盈条件单
+func (v *Venue) placeConditional(order Order) (Order, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	inst, ok := v.instruments[order.Symbol]
+	if !ok {
+		return Order{}, ErrUnknownSymbol
+	}
+	if order.Type == "TRAILING_STOP_MARKET" {
+		if order.CallbackRate <= 0 {
+			return Order{}, ErrInvalidPrice
+		}
+		order.trail(v.prices[order.Symbol])
+	} else if order.StopPrice <= 0 {
+		return Order{}, ErrInvalidPrice
+	}
+	if !order.ClosePosition {
+		if err := v.checkQuantityLocked(inst, order.Quantity); err != nil {
+			return Order{}, err
+		}
+	}
+	order.ID = v.nextOrderIDLocked()
+	order.Time = time.Now()
+	order.Status = "NEW"
+	v.orders = append(v.orders, order)
+	v.publishLocked(Event{Order: order})
+	return order, nil
+}
+
+// setClientID 记录订单的客户端订单ID（市价单/限价单下单后由各平台服务器调用）
+func (v *Venue) setClientID(orderID int64, clientID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.orders {
+		if v.orders[i].ID == orderID {
+			v.orders[i].ClientID = clientID
+		}
+	}
+	for i := range v.history {
+		if v.history[i].ID == orderID {
+			v.history[i].ClientID = clientID
+		}
+	}
+}
+
+// cancelOrder 取消单个挂单
+func (v *Venue) cancelOrder(symbol string, orderID int64) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i, order := range v.orders {
+		if order.ID == orderID && order.Symbol == symbol {
+			v.orders = append(v.orders[:i], v.orders[i+1:]...)
+			v.finishLocked(order, "CANCELED", nil)
+			return nil
+		}
+	}
+	return ErrOrderNotFound
+}
+
+// cancelAll 取消交易对的所有挂单，返回取消数量
+func (v *Venue) cancelAll(symbol string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	remaining := v.orders[:0]
+	cancelled := 0
+	for _, order := range v.orders {
+		if order.Symbol == symbol {
+			v.finishLocked(order, "CANCELED", nil)
+			cancelled++
+			continue
+		}
+		remaining = append(remaining, order)
+	}
+	v.orders = remaining
+	return cancelled
+}
+
+// marketOrderLocked 市价成交（调用方需持有锁）
+func (v *Venue) marketOrderLocked(symbol, side, positionSide string, quantity float64, reduceOnly bool, orderID int64) (Fill, error) {
+	inst, ok := v.instruments[symbol]
+	if !ok {
+		return Fill{}, ErrUnknownSymbol
+	}
+	if err := v.checkQuantityLocked(inst, quantity); err != nil {
+		return Fill{}, err
+	}
+	price := v.prices[symbol]
+	fill := Fill{OrderID: orderID, Symbol: symbol, Side: side, PositionSide: positionSide, Quantity: quantity, Price: price, Time: time.Now()}
+
+	remaining := quantity
+	switch positionSide {
+	case "LONG", "SHORT":
+		// 双向持仓：持仓方向由positionSide决定
+		posSide := strings.ToLower(positionSide)
+		opening := (positionSide == "LONG" && side == "BUY") || (positionSide == "SHORT" && side == "SELL")
+		if !opening {
+			pos, exists := v.positions[positionKey(symbol, posSide)]
+			if !exists || pos.Size+epsilon(inst.StepSize) < quantity {
+				return Fill{}, ErrReduceOnly
+			}
+			fill.RealizedPnL = v.reduceLocked(pos, quantity, price)
+			remaining = 0
+		} else if reduceOnly {
+			return Fill{}, ErrReduceOnly
+		}
+		if remaining > 0 {
+			if err := v.openLocked(symbol, posSide, remaining, price); err != nil {
+				return Fill{}, err
+			}
+		}
+
+	default:
+		// 单向持仓：先减少反向持仓，剩余数量开新仓
+		opposite := "short"
+		openSide := "long"
+		if side == "SELL" {
+			opposite, openSide = "long", "short"
+		}
+		if pos, exists := v.positions[positionKey(symbol, opposite)]; exists {
+			reduce := math.Min(pos.Size, remaining)
+			fill.RealizedPnL = v.reduceLocked(pos, reduce, price)
+			remaining -= reduce
+		} else if reduceOnly {
+			return Fill{}, ErrReduceOnly
+		}
+		if remaining > epsilon(inst.StepSize) && !reduceOnly {
+			if err := v.openLocked(symbol, openSide, remaining, price); err != nil {
+				return Fill{}, err
+			}
+		}
+	}
+
+	fill.Fee = quantity * price * inst.FeeRate
+	v.balance -= fill.Fee
+	v.fills = append(v.fills, fill)
+	return fill, nil
+}
+
+// openLocked 开仓或加仓（检查保证金）
+func (v *Venue) openLocked(symbol, side string, quantity, price float64) error {
+	leverage := v.leverageLocked(symbol)
+	required := quantity * price / float64(leverage)
+	available := v.balance + v.unrealizedLocked() - v.marginUsedLocked()
+	if required > available+1e-9 {
+		return ErrInsufficientMargin
+	}
+
+	key := positionKey(symbol, side)
+	if pos, exists := v.positions[key]; exists {
+		total := pos.Size + quantity
+		pos.EntryPrice = (pos.EntryPrice*pos.Size + price*quantity) / total
+		pos.Size = total
+		return nil
+	}
+	v.positions[key] = &Position{Symbol: symbol, Side: side, Size: quantity, EntryPrice: price, Leverage: leverage}
+	return nil
+}
+
+// reduceLocked 减仓并结算已实现盈亏
+func (v *Venue) reduceLocked(pos *Position, quantity, price float64) float64 {
+	pnl := (price - pos.EntryPrice) * quantity
+	if pos.Side == "short" {
+		pnl = -pnl
+	}
+	v.balance += pnl
+	pos.Size -= quantity
+	if pos.Size <= epsilon(v.instruments[pos.Symbol].StepSize) {
+		delete(v.positions, positionKey(pos.Symbol, pos.Side))
+	}
+	return pnl
+}
+
+// triggerLocked 触发已到价的条件单和限价单
+func (v *Venue) triggerLocked(symbol string) {
+	price := v.prices[symbol]
+	for i := range v.orders {
+		if v.orders[i].Symbol == symbol && v.orders[i].Type == "TRAILING_STOP_MARKET" {
+			v.orders[i].trail(price)
+		}
+	}
+	var remaining []Order
+	var triggered []Order
+	for _, order := range v.orders {
+		if order.Symbol == symbol && orderTriggered(order, price) {
+			triggered = append(triggered, order)
+			continue
+		}
+		remaining = append(remaining, order)
+	}
+	v.orders = remaining
+
+	for _, order := range triggered {
+		quantity := order.Quantity
+		if order.ClosePosition {
+			side := "long"
+			if order.Side == "BUY" {
+				side = "short"
+			}
+			pos, exists := v.positions[positionKey(symbol, side)]
+			if !exists {
+				v.finishLocked(order, "EXPIRED", nil)
+				continue
+			}
+			quantity = pos.Size
+		}
+		reduceOnly := order.ReduceOnly || order.Type != "LIMIT"
+		fill, err := v.marketOrderLocked(symbol, order.Side, order.PositionSide, quantity, reduceOnly, order.ID)
+		if err != nil {
+			// 触发时已无可减的持仓等情况，订单过期
+			v.finishLocked(order, "EXPIRED", nil)
+			continue
+		}
+		v.finishLocked(order, "FILLED", &fill)
+	}
+}
+
+// finishLocked 把订单记入历史（fill为nil表示未成交）
+func (v *Venue) finishLocked(order Order, status string, fill *Fill) Order {
+	order.Status = status
+	if fill != nil {
+		order.ExecutedQty = fill.Quantity
+		order.AvgPrice = fill.Price
+		order.Fee = fill.Fee
+	}
+	v.history = append(v.history, order)
+	v.publishLocked(Event{Order: order, Fill: fill})
+	return order
+}
+
+// orderTriggered 判断挂单在当前价格下是否触发
+func orderTriggered(order Order, price float64) bool {
+	switch order.Type {
+	case "LIMIT":
+		return (order.Side == "BUY" && price <= order.Price) || (order.Side == "SELL" && price >= order.Price)
+	case "STOP_MARKET":
+		return (order.Side == "SELL" && price <= order.StopPrice) || (order.Side == "BUY" && price >= order.StopPrice)
+	case "TRAILING_STOP_MARKET":
+		if order.extreme == 0 {
+			return false
+		}
+		return (order.Side == "SELL" && price <= order.StopPrice) || (order.Side == "BUY" && price >= order.StopPrice)
+	case "TAKE_PROFIT_MARKET":
+		return (order.Side == "SELL" && price >= order.StopPrice) || (order.Side == "BUY" && price <= order.StopPrice)
+	}
+	return false
+}
+
+// trail 按最新价格更新跟踪止损的极值和触发价（价格到达激活价前不跟踪）
+func (o *Order) trail(price float64) {
+	if o.extreme == 0 {
+		if o.ActivationPrice > 0 && ((o.Side == "SELL" && price < o.ActivationPrice) || (o.Side == "BUY" && price > o.ActivationPrice)) {
+			return
+		}
+		o.extreme = price
+	}
+	if (o.Side == "SELL" && price > o.extreme) || (o.Side == "BUY" && price < o.extreme) {
+		o.extreme = price
+	}
+	if o.Side == "SELL" {
+		o.StopPrice = o.extreme * (1 - o.CallbackRate/100)
+	} else {
+		o.StopPrice = o.extreme * (1 + o.CallbackRate/100)
+	}
+}
+
+// checkQuantityLocked 数量必须为正且是步长的整数倍
+func (v *Venue) checkQuantityLocked(inst *Instrument, quantity float64) error {
+	if quantity <= 0 || !isMultiple(quantity, inst.StepSize) {
+		return ErrInvalidQuantity
+	}
+	return nil
+}
+
+// unrealizedLocked 未实现盈亏合计
+func (v *Venue) unrealizedLocked() float64 {
+	total := 0.0
+	for _, pos := range v.positions {
+		pnl := (v.prices[pos.Symbol] - pos.EntryPrice) * pos.Size
+		if pos.Side == "short" {
+			pnl = -pnl
+		}
+		total += pnl
+	}
+	return total
+}
+
+// marginUsedLocked 占用保证金合计（按开仓价值/杠杆）
+func (v *Venue) marginUsedLocked() float64 {
+	total := 0.0
+	for _, pos := range v.positions {
+		total += pos.Size * pos.EntryPrice / float64(pos.Leverage)
+	}
+	return total
+}
+
+// leverageLocked 交易对当前杠杆（未设置时为20）
+func (v *Venue) leverageLocked(symbol string) int {
+	if leverage, ok := v.leverage[symbol]; ok {
+		return leverage
+	}
+	return 20
+}
+
+// nextOrderIDLocked 生成订单ID
+func (v *Venue) nextOrderIDLocked() int64 {
+	v.nextID++
+	return v.nextID
+}
+
+// positionDetails 持仓的标记价格、未实现盈亏和强平价
+func (v *Venue) positionDetails(pos Position) (markPrice, unrealized, liquidation float64) {
+	markPrice, _ = v.Price(pos.Symbol)
+	unrealized = (markPrice - pos.EntryPrice) * pos.Size
+	liquidation = pos.EntryPrice * (1 - 1/float64(pos.Leverage))
+	if pos.Side == "short" {
+		unrealized = -unrealized
+		liquidation = pos.EntryPrice * (1 + 1/float64(pos.Leverage))
+	}
+	return markPrice, unrealized, liquidation
+}
+
+// positionKey 持仓map的key
+func positionKey(symbol, side string) string {
+	return symbol + "|" + side
+}
+
+// isMultiple 判断value是否为step的整数倍（step<=0时不检查）
+func isMultiple(value, step float64) bool {
+	if step <= 0 {
+		return true
+	}
+	ratio := value / step
+	return math.Abs(ratio-math.Round(ratio)) < 1e-6
+}
+
+// epsilon 数量比较的容差
+func epsilon(step float64) float64 {
+	if step <= 0 {
+		return 1e-12
+	}
+	return step / 2
+}
+
+// decimals 步长对应的小数位数（如0.001 -> 3）
+func decimals(step float64) int {
+	if step <= 0 {
+		return 8
+	}
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// formatFloat 数字转字符串（交易所接口中的价格/数量均为字符串）
+func formatFloat(value float64) string {
+	if value == 0 {
+		return "0" // 避免输出"-0"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
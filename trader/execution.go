@@ -0,0 +1,75 @@
+package trader
+
+import (
+	"danto/logger"
+	"log"
+)
+
+// setOrderExecution 在下单结果中写入成交信息：avgPrice（成交均价）、executedQty（成交数量）、fee（手续费）、feeAsset（手续费币种）
+// quantity为0表示交易所没有返回成交，不写入
+func setOrderExecution(result map[string]interface{}, avgPrice, quantity, fee float64, feeAsset string) {
+	if quantity <= 0 || avgPrice <= 0 {
+		return
+	}
+	result["avgPrice"] = avgPrice
+	result["executedQty"] = quantity
+	result["fee"] = fee
+	result["feeAsset"] = feeAsset
+}
+
+// addFillsExecution 汇总fills（GetFills格式）中属于orderID的成交，按数量加权计算成交均价后写入下单结果
+// 没有属于该订单的成交时不写入
+func addFillsExecution(result map[string]interface{}, fills []map[string]interface{}, orderID int64) {
+	notional, quantity, fee := 0.0, 0.0, 0.0
+	feeAsset := ""
+	for _, fill := range fills {
+		if id, _ := fill["orderId"].(int64); id != orderID {
+			continue
+		}
+		price, _ := fill["price"].(float64)
+		qty, _ := fill["quantity"].(float64)
+		f, _ := fill["fee"].(float64)
+		notional += price * qty
+		quantity += qty
+		fee += f
+		if asset, _ := fill["feeAsset"].(string); asset != "" {
+			feeAsset = asset
+		}
+	}
+	if quantity > 0 {
+		setOrderExecution(result, notional/quantity, quantity, fee, feeAsset)
+	}
+}
+
+// recordExecution 把下单结果中的成交信息写入决策记录（actionRecord.Price为下单前的参考价）：
+// 参考价记为ExpectedPrice，Price和Quantity改为实际成交均价和成交数量，并记录滑点和手续费
+// buy表示买入方向的订单（开多、平空、多仓加仓）；交易所没有返回成交信息时不改动（不记ExpectedPrice，执行成本统计忽略该动作）
+func recordExecution(actionRecord *logger.DecisionAction, order map[string]interface{}, buy bool) {
+	expected := actionRecord.Price
+	avgPrice, _ := order["avgPrice"].(float64)
+	if avgPrice <= 0 || expected <= 0 {
+		return
+	}
+	actionRecord.ExpectedPrice = expected
+	actionRecord.Price = avgPrice
+	if quantity, _ := order["executedQty"].(float64); quantity > 0 {
+		actionRecord.Quantity = quantity
+	}
+	actionRecord.Fee, _ = order["fee"].(float64)
+	actionRecord.FeeAsset, _ = order["feeAsset"].(string)
+	actionRecord.SlippageBps = slippageBps(expected, avgPrice, buy)
+	log.Printf("  💸 成交均价 %.4f（参考价 %.4f，滑点 %.2f bps），手续费 %.4f %s",
+		avgPrice, expected, actionRecord.SlippageBps, actionRecord.Fee, actionRecord.FeeAsset)
+}
+
+// slippageBps 成交价相对参考价的滑点（基点），正数表示比参考价不利（买入更贵或卖出更便宜）
+func slippageBps(expected, actual float64, buy bool) float64 {
+	if expected <= 0 {
+		return 0
+	}
+	bps := (actual - expected) / expected * 10000
+	if !buy {
+		bps = -bps
+	}
+	return bps
+}
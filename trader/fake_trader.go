@@ -0,0 +1,779 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// FakeTrader 内存中的模拟交易器（不访问任何交易所），用于离线测试和回放
+// 市价单立即成交（可设置滑点），限价单在SetPrice穿过挂单价时按挂单价成交，止损/止盈单在SetPrice穿过触发价时按市价平仓，持仓格式与其他交易器一致（positionAmt为正数，方向看side）
+type FakeTrader struct {
+	mu sync.Mutex
+
+	walletBalance float64
+	prices        map[string]float64
+	leverage      map[string]int
+	positions     map[string]*fakePosition // symbol_side -> 持仓
+	orders        []FakeOrder              // 挂单中的限价开仓单和止损/止盈单
+	finished      []FakeOrder              // 已成交/已撤销/已过期的挂单（供GetOrder查询）
+	fills         []FakeOrder              // 全部成交记录
+	nextOrderID   int64
+	trailing      *trailingEngine          // 跟踪止损（由SetPrice驱动，不启动后台检查）
+	clientIDs     clientOrderIDs           // 客户端订单ID
+	modes         accountModes             // 保证金模式和持仓模式（只记录，持仓始终按方向分开记账）
+	fundingRates  map[string]float64       // 当前资金费率（见SetFundingRate）
+	funding       []map[string]interface{} // 资金费结算记录（GetFundingPayments的格式）
+
+	StepSize    float64 // 数量步长（默认0.001）
+	FeeRate     float64 // 手续费率（按成交额收取，从钱包余额扣除，默认0）
+	SlippageBps float64 // 市价成交的滑点（基点，按不利方向成交，默认0）
+}
+
+// fakePosition 模拟持仓
+type fakePosition struct {
+	symbol     string
+	side       string // "long" 或 "short"
+	quantity   float64
+	entryPrice float64
+	leverage   int
+}
+
+// FakeOrder 模拟订单记录
+type FakeOrder struct {
+	OrderID      int64
+	ClientID     string // 客户端订单ID（没有设置前缀时为空）
+	Symbol       string
+	Type         string // "OPEN_LONG", "OPEN_SHORT", "CLOSE_LONG", "CLOSE_SHORT", "STOP_LOSS", "TAKE_PROFIT", "LIMIT"
+	PositionSide string // "LONG" 或 "SHORT"
+	Quantity     float64
+	Price        float64
+	Status       string    // "NEW", "FILLED", "CANCELED", "EXPIRED"
+	RealizedPnL  float64   // 平仓成交的已实现盈亏
+	Fee          float64   // 成交的手续费（USDT）
+	Time         time.Time // 下单/成交时间
+}
+
+// NewFakeTrader 创建模拟交易器
+func NewFakeTrader(balance float64, prices map[string]float64) *FakeTrader {
+	t := &FakeTrader{
+		walletBalance: balance,
+		prices:        make(map[string]float64),
+		leverage:      make(map[string]int),
+		positions:     make(map[string]*fakePosition),
+		fundingRates:  make(map[string]float64),
+		nextOrderID:   1,
+		StepSize:      0.001,
+	}
+	for symbol, price := range prices {
+		t.prices[symbol] = price
+	}
+	t.trailing = newTrailingEngine(t, 0)
+	t.modes.set(MarginModeIsolated, PositionModeHedge)
+	return t
+}
+
+// SetPrice 设置币种的市场价格（影响持仓的未实现盈亏），价格穿过的限价开仓单按挂单价成交，
+// 价格穿过的止损/止盈单按新价格平仓，并按新价格移动跟踪止损
+func (t *FakeTrader) SetPrice(symbol string, price float64) {
+	t.setPrice(symbol, price)
+	t.trailing.poll()
+}
+
+func (t *FakeTrader) setPrice(symbol string, price float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices[symbol] = price
+
+	remaining := t.orders[:0]
+	var filled []FakeOrder
+	for _, order := range t.orders {
+		crossed := (order.PositionSide == "LONG" && price <= order.Price) || (order.PositionSide == "SHORT" && price >= order.Price)
+		if order.Symbol == symbol && order.Type == "LIMIT" && crossed {
+			filled = append(filled, order)
+			continue
+		}
+		remaining = append(remaining, order)
+	}
+	t.orders = remaining
+	for _, order := range filled {
+		t.openLocked(symbol, sideFromPositionSide(order.PositionSide), order.Quantity, order.Price, t.leverage[symbol])
+		// 成交记录沿用挂单的订单号，与交易所一致
+		t.fills[len(t.fills)-1].OrderID = order.OrderID
+		t.fills[len(t.fills)-1].ClientID = order.ClientID
+		t.finishLocked(order, "FILLED")
+	}
+
+	var triggered []FakeOrder
+	remaining = t.orders[:0]
+	for _, order := range t.orders {
+		if order.Symbol == symbol && order.triggered(price) {
+			triggered = append(triggered, order)
+			continue
+		}
+		remaining = append(remaining, order)
+	}
+	t.orders = remaining
+	for _, order := range triggered {
+		// 前面的触发单可能已平掉全部持仓
+		if _, err := t.closeLocked(symbol, sideFromPositionSide(order.PositionSide), order.Quantity); err != nil {
+			t.finishLocked(order, "EXPIRED")
+			continue
+		}
+		t.fills[len(t.fills)-1].OrderID = order.OrderID
+		t.fills[len(t.fills)-1].ClientID = order.ClientID
+		t.finishLocked(order, "FILLED")
+	}
+}
+
+// Orders 返回当前挂着的限价开仓单和止损/止盈单
+func (t *FakeTrader) Orders() []FakeOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]FakeOrder(nil), t.orders...)
+}
+
+// Fills 返回全部成交记录（开平仓）
+func (t *FakeTrader) Fills() []FakeOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]FakeOrder(nil), t.fills...)
+}
+
+// GetBalance 获取账户余额
+func (t *FakeTrader) GetBalance() (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unrealized := 0.0
+	marginUsed := 0.0
+	for _, pos := range t.positions {
+		price := t.prices[pos.symbol]
+		unrealized += pos.pnl(price)
+		marginUsed += pos.quantity * pos.entryPrice / float64(pos.leverage)
+	}
+
+	return map[string]interface{}{
+		"totalWalletBalance":    t.walletBalance,
+		"availableBalance":      t.walletBalance + unrealized - marginUsed,
+		"totalUnrealizedProfit": unrealized,
+	}, nil
+}
+
+// GetPositions 获取所有持仓
+func (t *FakeTrader) GetPositions() ([]map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var result []map[string]interface{}
+	for _, pos := range t.positions {
+		price := t.prices[pos.symbol]
+		liquidation := pos.entryPrice * (1 - 1/float64(pos.leverage))
+		if pos.side == "short" {
+			liquidation = pos.entryPrice * (1 + 1/float64(pos.leverage))
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":           pos.symbol,
+			"side":             pos.side,
+			"positionAmt":      pos.quantity,
+			"entryPrice":       pos.entryPrice,
+			"markPrice":        price,
+			"unRealizedProfit": pos.pnl(price),
+			"leverage":         float64(pos.leverage),
+			"liquidationPrice": liquidation,
+		})
+	}
+	return result, nil
+}
+
+// OpenLong 开多仓
+func (t *FakeTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.open(symbol, "long", quantity, leverage)
+}
+
+// OpenShort 开空仓
+func (t *FakeTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.open(symbol, "short", quantity, leverage)
+}
+
+// OpenLimit 限价开仓：价格可立即成交时按市价成交，否则挂单（IOC直接撤销，POST_ONLY返回错误）
+func (t *FakeTrader) OpenLimit(symbol, positionSide string, quantity, price float64, leverage int, timeInForce string) (map[string]interface{}, error) {
+	if err := validatePositionSide(positionSide); err != nil {
+		return nil, err
+	}
+	if timeInForce != TimeInForceGTC && timeInForce != TimeInForceIOC && timeInForce != TimeInForcePostOnly {
+		return nil, fmt.Errorf("不支持的timeInForce: %s", timeInForce)
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("限价必须大于0")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	quantity = t.roundStep(quantity)
+	if quantity <= 0 {
+		return nil, fmt.Errorf("开仓数量必须大于0: %w", ErrInvalidQuantity)
+	}
+	market, ok := t.prices[symbol]
+	if !ok || market <= 0 {
+		return nil, fmt.Errorf("没有 %s 的价格", symbol)
+	}
+	if leverage > 0 {
+		t.leverage[symbol] = leverage
+	}
+
+	side := sideFromPositionSide(positionSide)
+	marketable := (side == "long" && price >= market) || (side == "short" && price <= market)
+	if marketable {
+		if timeInForce == TimeInForcePostOnly {
+			return nil, fmt.Errorf("post-only限价单 %.4f 会立即成交（市价 %.4f）", price, market)
+		}
+		return t.tagFillLocked(t.openLocked(symbol, side, quantity, market, leverage))
+	}
+
+	order := FakeOrder{
+		OrderID:      t.nextOrderID,
+		ClientID:     t.clientIDs.next(),
+		Symbol:       symbol,
+		Type:         "LIMIT",
+		PositionSide: positionSide,
+		Quantity:     quantity,
+		Price:        price,
+		Status:       "NEW",
+		Time:         time.Now(),
+	}
+	t.nextOrderID++
+
+	status := "NEW"
+	if timeInForce == TimeInForceIOC {
+		status = "EXPIRED"
+		t.finishLocked(order, status)
+	} else {
+		t.orders = append(t.orders, order)
+	}
+	return map[string]interface{}{
+		"orderId": order.OrderID,
+		"symbol":  symbol,
+		"status":  status,
+	}, nil
+}
+
+// CancelOrder 取消单个挂单
+func (t *FakeTrader) CancelOrder(symbol string, orderID int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, order := range t.orders {
+		if order.Symbol == symbol && order.OrderID == orderID {
+			t.orders = append(t.orders[:i], t.orders[i+1:]...)
+			t.finishLocked(order, "CANCELED")
+			return nil
+		}
+	}
+	return fmt.Errorf("%s 订单 %d: %w", symbol, orderID, ErrOrderNotFound)
+}
+
+// GetOpenOrders 获取该币种的挂单
+func (t *FakeTrader) GetOpenOrders(symbol string) ([]map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := []map[string]interface{}{}
+	for _, order := range t.orders {
+		if order.Symbol == symbol {
+			result = append(result, order.info())
+		}
+	}
+	return result, nil
+}
+
+// GetOrder 查询单个订单（挂单、已结束的挂单和成交记录）
+func (t *FakeTrader) GetOrder(symbol string, orderID int64) (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, list := range [][]FakeOrder{t.orders, t.finished, t.fills} {
+		for _, order := range list {
+			if order.Symbol == symbol && order.OrderID == orderID {
+				return order.info(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s 订单 %d: %w", symbol, orderID, ErrOrderNotFound)
+}
+
+// GetOrderByClientID 按客户端订单ID查询订单
+func (t *FakeTrader) GetOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, list := range [][]FakeOrder{t.orders, t.finished, t.fills} {
+		for _, order := range list {
+			if order.Symbol == symbol && clientOrderID != "" && order.ClientID == clientOrderID {
+				return order.info(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s 订单 %s: %w", symbol, clientOrderID, ErrOrderNotFound)
+}
+
+// SetClientOrderIDPrefix 设置之后下单使用的客户端订单ID前缀
+func (t *FakeTrader) SetClientOrderIDPrefix(prefix string) {
+	t.clientIDs.setPrefix(prefix)
+}
+
+// GetFills 获取since之后的成交记录
+func (t *FakeTrader) GetFills(since time.Time) ([]map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := []map[string]interface{}{}
+	for _, fill := range t.fills {
+		if fill.Time.Before(since) {
+			continue
+		}
+		info := fill.info()
+		result = append(result, map[string]interface{}{
+			"symbol":      fill.Symbol,
+			"orderId":     fill.OrderID,
+			"side":        info["side"],
+			"time":        fill.Time.UnixMilli(),
+			"price":       fill.Price,
+			"quantity":    fill.Quantity,
+			"fee":         fill.Fee,
+			"feeAsset":    "USDT",
+			"realizedPnl": fill.RealizedPnL,
+		})
+	}
+	return result, nil
+}
+
+// SetFundingRate 设置币种当前的资金费率（GetFundingRate返回该费率，SettleFunding按该费率结算）
+func (t *FakeTrader) SetFundingRate(symbol string, rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fundingRates[symbol] = rate
+}
+
+// SettleFunding 按当前资金费率和价格结算币种所有持仓的资金费：多仓支付 数量×价格×费率，空仓收取（费率为负时相反）
+func (t *FakeTrader) SettleFunding(symbol string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rate := t.fundingRates[symbol]
+	for _, side := range []string{"long", "short"} {
+		pos, ok := t.positions[symbol+"_"+side]
+		if !ok {
+			continue
+		}
+		amount := -pos.quantity * t.prices[symbol] * rate
+		if side == "short" {
+			amount = -amount
+		}
+		t.walletBalance += amount
+		t.funding = append(t.funding, map[string]interface{}{
+			"symbol": symbol,
+			"side":   side,
+			"time":   time.Now().UnixMilli(),
+			"amount": amount,
+			"asset":  "USDT",
+		})
+	}
+}
+
+// GetFundingPayments 获取since之后的资金费收支
+func (t *FakeTrader) GetFundingPayments(since time.Time) ([]map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := []map[string]interface{}{}
+	for _, payment := range t.funding {
+		if payment["time"].(int64) >= since.UnixMilli() {
+			result = append(result, payment)
+		}
+	}
+	return result, nil
+}
+
+// GetFundingRate 获取当前资金费率（按8小时结算，从UTC零点开始）
+func (t *FakeTrader) GetFundingRate(symbol string) (float64, time.Time, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.prices[symbol]; !ok {
+		return 0, time.Time{}, fmt.Errorf("未找到 %s 的价格", symbol)
+	}
+	return t.fundingRates[symbol], time.Now().Truncate(8 * time.Hour).Add(8 * time.Hour), nil
+}
+
+// CloseLong 平多仓（quantity=0表示全部平仓）
+func (t *FakeTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.close(symbol, "long", quantity)
+}
+
+// CloseShort 平空仓（quantity=0表示全部平仓）
+func (t *FakeTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.close(symbol, "short", quantity)
+}
+
+// SetLeverage 设置杠杆
+func (t *FakeTrader) SetLeverage(symbol string, leverage int) error {
+	if leverage <= 0 {
+		return fmt.Errorf("杠杆必须大于0: %d", leverage)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leverage[symbol] = leverage
+	return nil
+}
+
+// ConfigureAccountModes 记录配置的模式（空字符串保持默认的逐仓+双向持仓）
+func (t *FakeTrader) ConfigureAccountModes(marginMode, positionMode string) error {
+	if err := ValidateAccountModes(marginMode, positionMode); err != nil {
+		return err
+	}
+	current, currentPosition := t.modes.get()
+	if marginMode == "" {
+		marginMode = current
+	}
+	if positionMode == "" {
+		positionMode = currentPosition
+	}
+	t.modes.set(marginMode, positionMode)
+	return nil
+}
+
+// AccountModes 返回当前的保证金模式和持仓模式
+func (t *FakeTrader) AccountModes() (marginMode, positionMode string) {
+	return t.modes.get()
+}
+
+// GetMarketPrice 获取市场价格
+func (t *FakeTrader) GetMarketPrice(symbol string) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	price, ok := t.prices[symbol]
+	if !ok || price <= 0 {
+		return 0, fmt.Errorf("没有 %s 的价格", symbol)
+	}
+	return price, nil
+}
+
+// SetStopLoss 设置止损单
+func (t *FakeTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return t.placeProtective(symbol, "STOP_LOSS", positionSide, quantity, stopPrice)
+}
+
+// SetTakeProfit 设置止盈单
+func (t *FakeTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.placeProtective(symbol, "TAKE_PROFIT", positionSide, quantity, takeProfitPrice)
+}
+
+// UpdateStopLoss 替换止损单（先挂新单再撤旧单）
+func (t *FakeTrader) UpdateStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "STOP_MARKET", quantity, stopPrice, true)
+}
+
+// UpdateTakeProfit 替换止盈单（先挂新单再撤旧单）
+func (t *FakeTrader) UpdateTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "TAKE_PROFIT_MARKET", quantity, takeProfitPrice, true)
+}
+
+// SetTakeProfitLadder 按阶梯挂多张止盈单
+func (t *FakeTrader) SetTakeProfitLadder(symbol string, positionSide string, quantity float64, levels []TakeProfitLevel) error {
+	return placeTakeProfitLadder(t, symbol, positionSide, quantity, levels, func(quantity, price float64) error {
+		return t.SetTakeProfit(symbol, positionSide, quantity, price)
+	})
+}
+
+// SetTrailingStop 设置跟踪止损（按SetPrice的价格移动止损单）
+func (t *FakeTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackRate, activationPrice float64) error {
+	return t.trailing.set(symbol, positionSide, callbackRate, activationPrice)
+}
+
+// CancelAllOrders 取消该币种的所有挂单
+func (t *FakeTrader) CancelAllOrders(symbol string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cancelLocked(symbol)
+	return nil
+}
+
+// FormatQuantity 格式化数量到正确的精度
+func (t *FakeTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	info, err := t.GetInstrument(symbol)
+	if err != nil {
+		return "", err
+	}
+	return info.FormatQuantity(quantity), nil
+}
+
+// GetInstrument 返回交易对规则（所有交易对共用StepSize，不限制价格步长、名义价值和杠杆）
+func (t *FakeTrader) GetInstrument(symbol string) (InstrumentInfo, error) {
+	return InstrumentInfo{
+		Symbol:             symbol,
+		StepSize:           t.StepSize,
+		MinQuantity:        t.StepSize,
+		ContractMultiplier: 1,
+	}, nil
+}
+
+// open 按市价开仓（同方向已有持仓时加仓并重新计算均价）
+func (t *FakeTrader) open(symbol, side string, quantity float64, leverage int) (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	quantity = t.roundStep(quantity)
+	if quantity <= 0 {
+		return nil, fmt.Errorf("开仓数量必须大于0: %w", ErrInvalidQuantity)
+	}
+	price, ok := t.prices[symbol]
+	if !ok || price <= 0 {
+		return nil, fmt.Errorf("没有 %s 的价格", symbol)
+	}
+	return t.tagFillLocked(t.openLocked(symbol, side, quantity, t.slippedPrice(price, side == "long"), leverage))
+}
+
+// openLocked 按指定价格成交开仓（调用方需持有锁）
+func (t *FakeTrader) openLocked(symbol, side string, quantity, price float64, leverage int) (map[string]interface{}, error) {
+	if leverage <= 0 {
+		leverage = t.leverage[symbol]
+	}
+	if leverage <= 0 {
+		leverage = 1
+	}
+
+	key := symbol + "_" + side
+	if pos, exists := t.positions[key]; exists {
+		total := pos.quantity + quantity
+		pos.entryPrice = (pos.entryPrice*pos.quantity + price*quantity) / total
+		pos.quantity = total
+		pos.leverage = leverage
+	} else {
+		t.positions[key] = &fakePosition{symbol: symbol, side: side, quantity: quantity, entryPrice: price, leverage: leverage}
+	}
+
+	orderType := "OPEN_LONG"
+	if side == "short" {
+		orderType = "OPEN_SHORT"
+	}
+	return t.fillLocked(symbol, orderType, side, quantity, price), nil
+}
+
+// close 按市价平仓并把已实现盈亏计入钱包余额
+func (t *FakeTrader) close(symbol, side string, quantity float64) (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tagFillLocked(t.closeLocked(symbol, side, quantity))
+}
+
+// closeLocked 按当前价格平仓，数量超过持仓时全部平仓（调用方需持有锁）
+func (t *FakeTrader) closeLocked(symbol, side string, quantity float64) (map[string]interface{}, error) {
+	key := symbol + "_" + side
+	pos, exists := t.positions[key]
+	if !exists {
+		return nil, noPositionError(symbol, side)
+	}
+
+	quantity = t.roundStep(quantity)
+	if quantity <= 0 || quantity > pos.quantity {
+		quantity = pos.quantity
+	}
+	price := t.slippedPrice(t.prices[symbol], side == "short")
+
+	realized := pos.pnl(price) * quantity / pos.quantity
+	t.walletBalance += realized
+	pos.quantity -= quantity
+	if pos.quantity <= t.StepSize/2 {
+		delete(t.positions, key)
+		t.cancelLocked(symbol)
+	}
+
+	orderType := "CLOSE_LONG"
+	if side == "short" {
+		orderType = "CLOSE_SHORT"
+	}
+	result := t.fillLocked(symbol, orderType, side, quantity, price)
+	t.fills[len(t.fills)-1].RealizedPnL = realized
+	result["realizedPnl"] = realized
+	return result, nil
+}
+
+// placeProtective 挂止损/止盈单（需要对应方向的持仓）
+func (t *FakeTrader) placeProtective(symbol, orderType, positionSide string, quantity, price float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+	if price <= 0 {
+		return fmt.Errorf("触发价格必须大于0")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.positions[symbol+"_"+sideFromPositionSide(positionSide)]; !exists {
+		return noPositionError(symbol, sideFromPositionSide(positionSide))
+	}
+	t.orders = append(t.orders, FakeOrder{
+		OrderID:      t.nextOrderID,
+		ClientID:     t.clientIDs.next(),
+		Symbol:       symbol,
+		Type:         orderType,
+		PositionSide: positionSide,
+		Quantity:     t.roundStep(quantity),
+		Price:        price,
+		Status:       "NEW",
+		Time:         time.Now(),
+	})
+	t.nextOrderID++
+	return nil
+}
+
+// fillLocked 记录一笔成交并返回订单结果（调用方需持有锁）
+func (t *FakeTrader) fillLocked(symbol, orderType, side string, quantity, price float64) map[string]interface{} {
+	order := FakeOrder{
+		OrderID:      t.nextOrderID,
+		Symbol:       symbol,
+		Type:         orderType,
+		PositionSide: positionSideFromSide(side),
+		Quantity:     quantity,
+		Price:        price,
+		Status:       "FILLED",
+		Fee:          quantity * price * t.FeeRate,
+		Time:         time.Now(),
+	}
+	t.nextOrderID++
+	t.fills = append(t.fills, order)
+	t.walletBalance -= order.Fee
+
+	result := map[string]interface{}{
+		"orderId": order.OrderID,
+		"symbol":  symbol,
+		"status":  "FILLED",
+	}
+	setOrderExecution(result, price, quantity, order.Fee, "USDT")
+	return result
+}
+
+// slippedPrice 按SlippageBps向不利方向调整的市价成交价（buy为买入）
+func (t *FakeTrader) slippedPrice(price float64, buy bool) float64 {
+	if buy {
+		return price * (1 + t.SlippageBps/10000)
+	}
+	return price * (1 - t.SlippageBps/10000)
+}
+
+// tagFillLocked 为刚记录的市价成交设置客户端订单ID（调用方需持有锁）
+func (t *FakeTrader) tagFillLocked(result map[string]interface{}, err error) (map[string]interface{}, error) {
+	if err == nil {
+		t.fills[len(t.fills)-1].ClientID = t.clientIDs.next()
+	}
+	return result, err
+}
+
+// cancelLocked 取消该币种的所有挂单（调用方需持有锁）
+func (t *FakeTrader) cancelLocked(symbol string) {
+	remaining := t.orders[:0]
+	var canceled []FakeOrder
+	for _, order := range t.orders {
+		if order.Symbol != symbol {
+			remaining = append(remaining, order)
+			continue
+		}
+		canceled = append(canceled, order)
+	}
+	t.orders = remaining
+	for _, order := range canceled {
+		t.finishLocked(order, "CANCELED")
+	}
+}
+
+// finishLocked 记录结束的挂单（调用方需持有锁）
+func (t *FakeTrader) finishLocked(order FakeOrder, status string) {
+	order.Status = status
+	t.finished = append(t.finished, order)
+}
+
+// triggered 止损/止盈单是否被价格触发
+func (o FakeOrder) triggered(price float64) bool {
+	long := o.PositionSide == "LONG"
+	switch o.Type {
+	case "STOP_LOSS":
+		return (long && price <= o.Price) || (!long && price >= o.Price)
+	case "TAKE_PROFIT":
+		return (long && price >= o.Price) || (!long && price <= o.Price)
+	}
+	return false
+}
+
+// info 转为统一的订单格式
+func (o FakeOrder) info() map[string]interface{} {
+	orderType := "MARKET"
+	price, stopPrice := o.Price, 0.0
+	reduceOnly := false
+	switch o.Type {
+	case "LIMIT":
+		orderType = "LIMIT"
+	case "STOP_LOSS", "TAKE_PROFIT":
+		orderType = "STOP_MARKET"
+		if o.Type == "TAKE_PROFIT" {
+			orderType = "TAKE_PROFIT_MARKET"
+		}
+		price, stopPrice = 0, o.Price
+		reduceOnly = true
+	case "CLOSE_LONG", "CLOSE_SHORT":
+		reduceOnly = true
+	}
+	// 开仓与持仓方向相同，平仓和止损/止盈单与持仓方向相反
+	side := "BUY"
+	if (o.PositionSide == "SHORT") != reduceOnly {
+		side = "SELL"
+	}
+
+	executed := 0.0
+	if o.Status == "FILLED" {
+		executed = o.Quantity
+	}
+	return map[string]interface{}{
+		"orderId":     o.OrderID,
+		"symbol":      o.Symbol,
+		"side":        side,
+		"type":        orderType,
+		"status":      o.Status,
+		"price":       price,
+		"stopPrice":   stopPrice,
+		"quantity":    o.Quantity,
+		"executedQty": executed,
+		"reduceOnly":  reduceOnly,
+	}
+}
+
+// roundStep 按步长向下取整
+func (t *FakeTrader) roundStep(quantity float64) float64 {
+	if t.StepSize <= 0 {
+		return quantity
+	}
+	return math.Floor(quantity/t.StepSize+1e-9) * t.StepSize
+}
+
+// pnl 按价格计算未实现盈亏
+func (p *fakePosition) pnl(price float64) float64 {
+	if p.side == "short" {
+		return (p.entryPrice - price) * p.quantity
+	}
+	return (price - p.entryPrice) * p.quantity
+}
+
+// sideFromPositionSide "LONG"/"SHORT" -> "long"/"short"
+func sideFromPositionSide(positionSide string) string {
+	if positionSide == "SHORT" {
+		return "short"
+	}
+	return "long"
+}
+
+// positionSideFromSide "long"/"short" -> "LONG"/"SHORT"
+func positionSideFromSide(side string) string {
+	if side == "short" {
+		return "SHORT"
+	}
+	return "LONG"
+}
@@ -0,0 +1,240 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sonirico/go-hyperliquid"
+)
+
+// hyperliquidPingInterval 心跳间隔（Hyperliquid 60秒内没有消息会断开连接）
+var hyperliquidPingInterval = 50 * time.Second
+
+// hyperliquidStreamURL API地址对应的WebSocket地址（https://api.hyperliquid.xyz -> wss://api.hyperliquid.xyz/ws）
+func hyperliquidStreamURL(apiURL string) string {
+	url := strings.TrimSuffix(apiURL, "/")
+	switch {
+	case strings.HasPrefix(url, "https://"):
+		url = "wss://" + strings.TrimPrefix(url, "https://")
+	case strings.HasPrefix(url, "http://"):
+		url = "ws://" + strings.TrimPrefix(url, "http://")
+	}
+	return url + "/ws"
+}
+
+// StartStream 连接WebSocket并订阅userFills、orderUpdates和webData2
+// 连接后加载一次REST快照，之后余额、持仓和挂单由webData2/orderUpdates更新，成交（包括止损止盈触发）由userFills实时推送
+func (t *HyperliquidTrader) StartStream() error {
+	t.streamMu.Lock()
+	defer t.streamMu.Unlock()
+	if t.stream != nil {
+		return nil
+	}
+
+	stream := &streamRunner{
+		name:    "Hyperliquid",
+		account: t.account,
+		dial:    t.dialStream,
+		read:    t.readStream,
+		resync:  t.resyncAccount,
+		ping: func(conn *websocket.Conn) error {
+			// 只有心跳在连接建立后写入，不会与订阅请求并发
+			return conn.WriteJSON(map[string]string{"method": "ping"})
+		},
+		pingInterval: hyperliquidPingInterval,
+	}
+	if err := stream.start(); err != nil {
+		return err
+	}
+	t.stream = stream
+	log.Printf("✓ Hyperliquid账户推送已连接，余额/持仓/挂单改为读取推送维护的本地数据")
+	return nil
+}
+
+// StopStream 关闭账户推送
+func (t *HyperliquidTrader) StopStream() {
+	t.streamMu.Lock()
+	stream := t.stream
+	t.stream = nil
+	t.streamMu.Unlock()
+	if stream == nil {
+		return
+	}
+	stream.close()
+	log.Printf("✓ Hyperliquid账户推送已关闭")
+}
+
+// Fills 实时成交通道（StopStream后关闭）
+func (t *HyperliquidTrader) Fills() <-chan map[string]interface{} {
+	return t.account.subscribeFills()
+}
+
+// dialStream 建立连接并订阅账户相关的频道
+func (t *HyperliquidTrader) dialStream() (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(hyperliquidStreamURL(t.apiURL), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range []string{hyperliquid.ChannelUserFills, hyperliquid.ChannelOrderUpdates, hyperliquid.ChannelWebData2} {
+		subscribe := map[string]interface{}{
+			"method":       "subscribe",
+			"subscription": map[string]string{"type": channel, "user": t.walletAddr},
+		}
+		if err := conn.WriteJSON(subscribe); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("订阅%s失败: %w", channel, err)
+		}
+	}
+	return conn, nil
+}
+
+// resyncAccount 用REST查询的账户状态和全部挂单刷新本地模型
+func (t *HyperliquidTrader) resyncAccount() error {
+	if err := t.reloadAccount(); err != nil {
+		return err
+	}
+	openOrders, err := t.exchange.Info().FrontendOpenOrders(t.ctx, t.walletAddr)
+	if err != nil {
+		return fmt.Errorf("获取挂单失败: %w", err)
+	}
+	t.account.loadOrders("", hyperliquidOpenOrders(openOrders))
+	return nil
+}
+
+// reloadAccount 用REST查询的账户状态刷新本地模型的余额和持仓
+func (t *HyperliquidTrader) reloadAccount() error {
+	accountState, err := t.exchange.Info().UserState(t.ctx, t.walletAddr)
+	if err != nil {
+		return fmt.Errorf("获取账户信息失败: %w", err)
+	}
+	balance := hyperliquidBalance(accountState)
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	t.account.loadAccount(balance, wallet, hyperliquidPositions(accountState))
+	return nil
+}
+
+// refreshDirtyAccount 模型需要刷新时重新加载余额和持仓，返回模型是否可用
+func (t *HyperliquidTrader) refreshDirtyAccount() bool {
+	if !t.account.isLive() {
+		return false
+	}
+	if _, ok := t.account.getPositions(); ok {
+		return true
+	}
+	return t.reloadAccount() == nil
+}
+
+// readStream 处理连接上的推送，连接出错时返回
+func (t *HyperliquidTrader) readStream(conn *websocket.Conn) error {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var envelope struct {
+			Channel string          `json:"channel"`
+			Data    json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(message, &envelope); err != nil {
+			log.Printf("⚠️  无法解析Hyperliquid推送消息: %v", err)
+			continue
+		}
+
+		switch envelope.Channel {
+		case hyperliquid.ChannelWebData2:
+			// 库中的WebData2只保留挂单的基本字段，这里按前端挂单格式解析（包含订单类型和触发价）
+			var data struct {
+				ClearinghouseState *hyperliquid.UserState          `json:"clearinghouseState"`
+				OpenOrders         []hyperliquid.FrontendOpenOrder `json:"openOrders"`
+			}
+			if err := json.Unmarshal(envelope.Data, &data); err != nil || data.ClearinghouseState == nil {
+				continue
+			}
+			balance := hyperliquidBalance(data.ClearinghouseState)
+			wallet, _ := balance["totalWalletBalance"].(float64)
+			t.account.pushSnapshot(balance, wallet, hyperliquidPositions(data.ClearinghouseState), hyperliquidOpenOrders(data.OpenOrders))
+
+		case hyperliquid.ChannelOrderUpdates:
+			var updates []hyperliquid.WsOrder
+			if err := json.Unmarshal(envelope.Data, &updates); err != nil {
+				continue
+			}
+			for _, update := range updates {
+				t.handleOrderUpdate(update)
+			}
+
+		case hyperliquid.ChannelUserFills:
+			var data struct {
+				IsSnapshot bool               `json:"isSnapshot"`
+				Fills      []hyperliquid.Fill `json:"fills"`
+			}
+			if err := json.Unmarshal(envelope.Data, &data); err != nil {
+				continue
+			}
+			// 订阅时的第一条消息是历史成交快照，连接之前的成交由GetFills走REST查询
+			if data.IsSnapshot {
+				continue
+			}
+			// 成交不带订单类型：沿用见过的类型，不知道的订单（如在网页端挂的止损单）留空，由使用方查询订单
+			for _, fill := range data.Fills {
+				info := hyperliquidFill(fill)
+				info["orderType"] = t.account.orderType(fill.Oid)
+				t.account.addFill(info)
+			}
+		}
+	}
+}
+
+// handleOrderUpdate 订单状态推送更新本地挂单
+// 推送只有订单基本字段：已知的挂单只更新状态和成交数量，新挂单的类型沿用之前见过的类型（如本交易器挂出的触发单）
+func (t *HyperliquidTrader) handleOrderUpdate(update hyperliquid.WsOrder) {
+	order := update.Order
+	price, _ := strconv.ParseFloat(order.LimitPx, 64)
+	remaining, _ := strconv.ParseFloat(order.Sz, 64)
+	quantity, _ := strconv.ParseFloat(order.OrigSz, 64)
+
+	var status string
+	switch update.Status {
+	case hyperliquid.OrderStatusValueOpen:
+		status = "NEW"
+		if remaining < quantity {
+			status = "PARTIALLY_FILLED"
+		}
+	case hyperliquid.OrderStatusValueFilled, hyperliquid.OrderStatusValueTriggered:
+		status = "FILLED"
+		remaining = 0
+	case hyperliquid.OrderStatusValueRejected:
+		status = "REJECTED"
+	default:
+		status = "CANCELED"
+	}
+
+	info := map[string]interface{}{
+		"orderId":     order.Oid,
+		"symbol":      order.Coin + "USDT",
+		"side":        hyperliquidOrderSide(order.Side),
+		"status":      status,
+		"price":       price,
+		"quantity":    quantity,
+		"executedQty": quantity - remaining,
+	}
+	switch orderType := t.account.orderType(order.Oid); orderType {
+	case "":
+		info["type"] = "LIMIT"
+		info["stopPrice"] = 0.0
+		info["reduceOnly"] = false
+	case "LIMIT":
+	default:
+		// 触发单的limitPx就是下单时的触发价
+		info["type"] = orderType
+		info["stopPrice"] = price
+		info["reduceOnly"] = true
+	}
+	t.account.updateOrder(info)
+}
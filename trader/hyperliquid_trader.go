@@ -1,11 +1,21 @@
 package trader
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/sonirico/go-hyperliquid"
@@ -16,22 +26,39 @@ type HyperliquidTrader struct {
 	exchange   *hyperliquid.Exchange
 	ctx        context.Context
 	walletAddr string
-	meta       *hyperliquid.Meta // 缓存meta信息（包含精度等）
+	trailing   *trailingEngine // Hyperliquid不支持原生跟踪止损，由客户端按价格移动止损单
+	clientIDs  clientOrderIDs  // 客户端订单ID（下单时转为cloid）
+	modes      accountModes    // 保证金模式（设置杠杆时的isCross）和持仓模式（只支持单向持仓）
+
+	// 币种规则（meta中的szDecimals、最大杠杆和保证金档位，按币种名如"BTC"索引，见GetInstrument）
+	instruments *instrumentRegistry
+
+	// 账户推送（见StartStream）：连接正常时余额、持仓、挂单和成交从本地模型读取
+	apiURL   string
+	account  *accountModel
+	stream   *streamRunner
+	streamMu sync.Mutex
 }
 
 // NewHyperliquidTrader 创建Hyperliquid交易器
 func NewHyperliquidTrader(privateKeyHex string, walletAddr string, testnet bool) (*HyperliquidTrader, error) {
-	// 解析私钥
-	privateKey, err := crypto.HexToECDSA(privateKeyHex)
-	if err != nil {
-		return nil, fmt.Errorf("解析私钥失败: %w", err)
-	}
-
 	// 选择API URL
 	apiURL := hyperliquid.MainnetAPIURL
 	if testnet {
 		apiURL = hyperliquid.TestnetAPIURL
 	}
+	return NewHyperliquidTraderWithURL(privateKeyHex, walletAddr, apiURL)
+}
+
+// NewHyperliquidTraderWithURL 使用指定API地址创建Hyperliquid交易器（用于本地模拟服务）
+// 注意：只有主网地址会按主网规则签名，其余地址均按测试网签名
+func NewHyperliquidTraderWithURL(privateKeyHex string, walletAddr string, apiURL string) (*HyperliquidTrader, error) {
+	// 解析私钥
+	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+	testnet := apiURL != hyperliquid.MainnetAPIURL
 
 	// // 从私钥生成钱包地址
 	// pubKey := privateKey.Public()
@@ -56,22 +83,32 @@ func NewHyperliquidTrader(privateKeyHex string, walletAddr string, testnet bool)
 
 	log.Printf("✓ Hyperliquid交易器初始化成功 (testnet=%v, wallet=%s)", testnet, walletAddr)
 
-	// 获取meta信息（包含精度等配置）
-	meta, err := exchange.Info().Meta(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("获取meta信息失败: %w", err)
-	}
-
-	return &HyperliquidTrader{
+	t := &HyperliquidTrader{
 		exchange:   exchange,
 		ctx:        ctx,
 		walletAddr: walletAddr,
-		meta:       meta,
-	}, nil
+		apiURL:     apiURL,
+		account:    newAccountModel(),
+	}
+	t.trailing = newTrailingEngine(t, trailingStopInterval)
+	t.modes.set(MarginModeIsolated, PositionModeOneWay)
+
+	// 获取meta信息（包含精度等配置）
+	t.instruments = newInstrumentRegistry("Hyperliquid", t.loadInstruments)
+	if err := t.instruments.refresh(); err != nil {
+		return nil, fmt.Errorf("获取meta信息失败: %w", err)
+	}
+	return t, nil
 }
 
-// GetBalance 获取账户余额
+// GetBalance 获取账户余额（账户推送连接时读取本地模型）
 func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
+	if t.refreshDirtyAccount() {
+		if balance, ok := t.account.getBalance(); ok {
+			return balance, nil
+		}
+	}
+
 	log.Printf("🔄 正在调用Hyperliquid API获取账户余额...")
 
 	// 获取账户状态
@@ -82,13 +119,28 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 	}
 
 	// 解析余额信息（MarginSummary字段都是string）
-	result := make(map[string]interface{})
-
 	// 🔍 调试：打印API返回的完整CrossMarginSummary结构
 	summaryJSON, _ := json.MarshalIndent(accountState.MarginSummary, "  ", "  ")
 	log.Printf("🔍 [DEBUG] Hyperliquid API CrossMarginSummary完整数据:")
 	log.Printf("%s", string(summaryJSON))
 
+	result := hyperliquidBalance(accountState)
+	walletBalance := result["totalWalletBalance"].(float64)
+	totalUnrealizedPnl := result["totalUnrealizedProfit"].(float64)
+	totalMarginUsed, _ := strconv.ParseFloat(accountState.MarginSummary.TotalMarginUsed, 64)
+	log.Printf("✓ Hyperliquid 账户: 总净值=%.2f (钱包%.2f+未实现%.2f), 可用=%.2f, 保证金占用=%.2f",
+		walletBalance+totalUnrealizedPnl,
+		walletBalance,
+		totalUnrealizedPnl,
+		result["availableBalance"],
+		totalMarginUsed)
+
+	return result, nil
+}
+
+// hyperliquidBalance 账户状态（clearinghouseState）转为统一的余额格式
+func hyperliquidBalance(accountState *hyperliquid.UserState) map[string]interface{} {
+	result := make(map[string]interface{})
 	accountValue, _ := strconv.ParseFloat(accountState.MarginSummary.AccountValue, 64)
 	totalMarginUsed, _ := strconv.ParseFloat(accountState.MarginSummary.TotalMarginUsed, 64)
 
@@ -110,25 +162,27 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 	result["totalWalletBalance"] = walletBalanceWithoutUnrealized // 钱包余额（不含未实现盈亏）
 	result["availableBalance"] = accountValue - totalMarginUsed   // 可用余额（总净值 - 占用保证金）
 	result["totalUnrealizedProfit"] = totalUnrealizedPnl          // 未实现盈亏
-
-	log.Printf("✓ Hyperliquid 账户: 总净值=%.2f (钱包%.2f+未实现%.2f), 可用=%.2f, 保证金占用=%.2f",
-		accountValue,
-		walletBalanceWithoutUnrealized,
-		totalUnrealizedPnl,
-		result["availableBalance"],
-		totalMarginUsed)
-
-	return result, nil
+	return result
 }
 
-// GetPositions 获取所有持仓
+// GetPositions 获取所有持仓（账户推送连接时读取本地模型）
 func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
+	if t.refreshDirtyAccount() {
+		if positions, ok := t.account.getPositions(); ok {
+			return positions, nil
+		}
+	}
+
 	// 获取账户状态
 	accountState, err := t.exchange.Info().UserState(t.ctx, t.walletAddr)
 	if err != nil {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
 	}
+	return hyperliquidPositions(accountState), nil
+}
 
+// hyperliquidPositions 账户状态（clearinghouseState）中的持仓转为统一的持仓格式
+func hyperliquidPositions(accountState *hyperliquid.UserState) []map[string]interface{} {
 	var result []map[string]interface{}
 
 	// 遍历所有持仓
@@ -184,7 +238,7 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 		result = append(result, posMap)
 	}
 
-	return result, nil
+	return result
 }
 
 // SetLeverage 设置杠杆
@@ -192,28 +246,42 @@ func (t *HyperliquidTrader) SetLeverage(symbol string, leverage int) error {
 	// Hyperliquid symbol格式（去掉USDT后缀）
 	coin := convertSymbolToHyperliquid(symbol)
 
-	// 调用UpdateLeverage (leverage int, name string, isCross bool)
-	_, err := t.exchange.UpdateLeverage(t.ctx, leverage, coin, false) // false = 逐仓模式
+	// 调用UpdateLeverage (leverage int, name string, isCross bool)，保证金模式随杠杆一起设置
+	marginMode, _ := t.modes.get()
+	_, err := t.exchange.UpdateLeverage(t.ctx, leverage, coin, marginMode == MarginModeCross)
+	t.account.markDirty("")
 	if err != nil {
 		return fmt.Errorf("设置杠杆失败: %w", err)
 	}
 
-	log.Printf("  ✓ %s 杠杆已切换为 %dx", symbol, leverage)
+	log.Printf("  ✓ %s 杠杆已切换为 %dx（%s）", symbol, leverage, marginMode)
 	return nil
 }
 
-// OpenLong 开多仓
-func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	// 先取消该币种的所有委托单
-	if err := t.CancelAllOrders(symbol); err != nil {
-		log.Printf("  ⚠ 取消旧委托单失败: %v", err)
+// ConfigureAccountModes Hyperliquid每个币种只有一个净持仓（单向持仓），配置双向持仓时返回ErrUnsupportedAccountMode
+// 保证金模式在每次设置杠杆时通过isCross指定（默认逐仓）
+func (t *HyperliquidTrader) ConfigureAccountModes(marginMode, positionMode string) error {
+	if err := ValidateAccountModes(marginMode, positionMode); err != nil {
+		return err
 	}
-
-	// 设置杠杆
-	if err := t.SetLeverage(symbol, leverage); err != nil {
-		return nil, err
+	if positionMode == PositionModeHedge {
+		return unsupportedModeError("Hyperliquid", positionMode)
+	}
+	if marginMode == "" {
+		marginMode = MarginModeIsolated
 	}
+	t.modes.set(marginMode, PositionModeOneWay)
+	log.Printf("  ✓ Hyperliquid账户模式: 保证金 %s, 持仓 %s", marginMode, PositionModeOneWay)
+	return nil
+}
+
+// AccountModes 返回当前使用的保证金模式和持仓模式
+func (t *HyperliquidTrader) AccountModes() (marginMode, positionMode string) {
+	return t.modes.get()
+}
 
+// OpenLong 开多仓
+func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
 	// Hyperliquid symbol格式
 	coin := convertSymbolToHyperliquid(symbol)
 
@@ -223,12 +291,21 @@ func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage i
 		return nil, err
 	}
 
-	// ⚠️ 关键：根据币种精度要求，四舍五入数量
-	roundedQuantity := t.roundToSzDecimals(coin, quantity)
-	log.Printf("  📏 数量精度处理: %.8f -> %.8f (szDecimals=%d)", quantity, roundedQuantity, t.getSzDecimals(coin))
+	// ⚠️ 关键：按币种规则取整数量（szDecimals），检查最小订单价值和杠杆档位
+	// 不撤销已有挂单：加仓时持仓的止损止盈、跟踪止损和限价开仓单都要保留
+	info, roundedQuantity, _, err := t.instruments.prepareOrder(coin, quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("  📏 数量精度处理: %.8f -> %.8f (stepSize=%v)", quantity, roundedQuantity, info.StepSize)
+
+	// 设置杠杆
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
 
 	// ⚠️ 关键：价格也需要处理为5位有效数字
-	aggressivePrice := t.roundPriceToSigfigs(price * 1.01)
+	aggressivePrice := info.RoundPrice(price * 1.01)
 	log.Printf("  💰 价格精度处理: %.8f -> %.8f (5位有效数字)", price*1.01, aggressivePrice)
 
 	// 创建市价买入订单（使用IOC limit order with aggressive price）
@@ -245,7 +322,8 @@ func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage i
 		ReduceOnly: false,
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	placedAt := time.Now()
+	status, err := t.placeOrder(order)
 	if err != nil {
 		return nil, fmt.Errorf("开多仓失败: %w", err)
 	}
@@ -256,37 +334,37 @@ func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage i
 	result["orderId"] = 0 // Hyperliquid没有返回order ID
 	result["symbol"] = symbol
 	result["status"] = "FILLED"
+	t.addExecution(result, symbol, status, placedAt)
 
 	return result, nil
 }
 
 // OpenShort 开空仓
 func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	// 先取消该币种的所有委托单
-	if err := t.CancelAllOrders(symbol); err != nil {
-		log.Printf("  ⚠ 取消旧委托单失败: %v", err)
-	}
-
-	// 设置杠杆
-	if err := t.SetLeverage(symbol, leverage); err != nil {
-		return nil, err
-	}
-
 	// Hyperliquid symbol格式
 	coin := convertSymbolToHyperliquid(symbol)
 
-	// 获取当前价格
+	// 获取当前价格（用于市价单）
 	price, err := t.GetMarketPrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	// ⚠️ 关键：根据币种精度要求，四舍五入数量
-	roundedQuantity := t.roundToSzDecimals(coin, quantity)
-	log.Printf("  📏 数量精度处理: %.8f -> %.8f (szDecimals=%d)", quantity, roundedQuantity, t.getSzDecimals(coin))
+	// ⚠️ 关键：按币种规则取整数量（szDecimals），检查最小订单价值和杠杆档位
+	// 不撤销已有挂单：加仓时持仓的止损止盈、跟踪止损和限价开仓单都要保留
+	info, roundedQuantity, _, err := t.instruments.prepareOrder(coin, quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("  📏 数量精度处理: %.8f -> %.8f (stepSize=%v)", quantity, roundedQuantity, info.StepSize)
+
+	// 设置杠杆
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
 
 	// ⚠️ 关键：价格也需要处理为5位有效数字
-	aggressivePrice := t.roundPriceToSigfigs(price * 0.99)
+	aggressivePrice := info.RoundPrice(price * 0.99)
 	log.Printf("  💰 价格精度处理: %.8f -> %.8f (5位有效数字)", price*0.99, aggressivePrice)
 
 	// 创建市价卖出订单
@@ -303,7 +381,8 @@ func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage
 		ReduceOnly: false,
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	placedAt := time.Now()
+	status, err := t.placeOrder(order)
 	if err != nil {
 		return nil, fmt.Errorf("开空仓失败: %w", err)
 	}
@@ -314,7 +393,79 @@ func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage
 	result["orderId"] = 0
 	result["symbol"] = symbol
 	result["status"] = "FILLED"
+	t.addExecution(result, symbol, status, placedAt)
+
+	return result, nil
+}
+
+// OpenLimit 限价开仓（GTC/IOC/POST_ONLY对应Hyperliquid的Gtc/Ioc/Alo），不会取消已有的止损止盈单
+func (t *HyperliquidTrader) OpenLimit(symbol, positionSide string, quantity, price float64, leverage int, timeInForce string) (map[string]interface{}, error) {
+	if err := validatePositionSide(positionSide); err != nil {
+		return nil, err
+	}
+
+	var tif hyperliquid.Tif
+	switch timeInForce {
+	case TimeInForceGTC:
+		tif = hyperliquid.TifGtc
+	case TimeInForceIOC:
+		tif = hyperliquid.TifIoc
+	case TimeInForcePostOnly:
+		tif = hyperliquid.TifAlo
+	default:
+		return nil, fmt.Errorf("不支持的timeInForce: %s", timeInForce)
+	}
+
+	// 按币种规则取整数量和价格，检查最小订单价值和杠杆档位
+	_, roundedQuantity, roundedPrice, err := t.instruments.prepareOrder(convertSymbolToHyperliquid(symbol), quantity, price, leverage, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 设置杠杆
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	coin := convertSymbolToHyperliquid(symbol)
+
+	order := hyperliquid.CreateOrderRequest{
+		Coin:  coin,
+		IsBuy: positionSide == "LONG",
+		Size:  roundedQuantity,
+		Price: roundedPrice,
+		OrderType: hyperliquid.OrderType{
+			Limit: &hyperliquid.LimitOrderType{
+				Tif: tif,
+			},
+		},
+		ReduceOnly: false,
+	}
+
+	result := make(map[string]interface{})
+	result["symbol"] = symbol
+
+	placedAt := time.Now()
+	status, err := t.placeOrder(order)
+	switch {
+	case err != nil && tif == hyperliquid.TifIoc && strings.Contains(err.Error(), "could not immediately match"):
+		// IOC未能成交时Hyperliquid返回错误，这里按未成交已撤销处理
+		result["orderId"] = int64(0)
+		result["status"] = "EXPIRED"
+	case err != nil:
+		return nil, fmt.Errorf("限价开仓失败: %w", err)
+	case status.Filled != nil:
+		result["orderId"] = int64(status.Filled.Oid)
+		result["status"] = "FILLED"
+		t.addExecution(result, symbol, status, placedAt)
+	case status.Resting != nil:
+		result["orderId"] = status.Resting.Oid
+		result["status"] = "NEW"
+	default:
+		return nil, fmt.Errorf("限价开仓失败: 未知的订单状态 %s", status.String())
+	}
 
+	log.Printf("✓ 限价开仓委托成功: %s %s 数量: %.4f 价格: %.4f (%s) 状态: %s", symbol, positionSide, roundedQuantity, roundedPrice, timeInForce, result["status"])
 	return result, nil
 }
 
@@ -335,25 +486,28 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 		}
 
 		if quantity == 0 {
-			return nil, fmt.Errorf("没有找到 %s 的多仓", symbol)
+			return nil, noPositionError(symbol, "long")
 		}
 	}
 
 	// Hyperliquid symbol格式
 	coin := convertSymbolToHyperliquid(symbol)
 
+	// ⚠️ 关键：按币种规则取整数量（平仓不检查最小订单价值）
+	info, roundedQuantity, _, err := t.instruments.prepareOrder(coin, quantity, 0, 0, true)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("  📏 数量精度处理: %.8f -> %.8f (stepSize=%v)", quantity, roundedQuantity, info.StepSize)
+
 	// 获取当前价格
 	price, err := t.GetMarketPrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	// ⚠️ 关键：根据币种精度要求，四舍五入数量
-	roundedQuantity := t.roundToSzDecimals(coin, quantity)
-	log.Printf("  📏 数量精度处理: %.8f -> %.8f (szDecimals=%d)", quantity, roundedQuantity, t.getSzDecimals(coin))
-
 	// ⚠️ 关键：价格也需要处理为5位有效数字
-	aggressivePrice := t.roundPriceToSigfigs(price * 0.99)
+	aggressivePrice := info.RoundPrice(price * 0.99)
 	log.Printf("  💰 价格精度处理: %.8f -> %.8f (5位有效数字)", price*0.99, aggressivePrice)
 
 	// 创建平仓订单（卖出 + ReduceOnly）
@@ -370,7 +524,8 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 		ReduceOnly: true, // 只平仓，不开新仓
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	placedAt := time.Now()
+	status, err := t.placeOrder(order)
 	if err != nil {
 		return nil, fmt.Errorf("平多仓失败: %w", err)
 	}
@@ -386,6 +541,7 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 	result["orderId"] = 0
 	result["symbol"] = symbol
 	result["status"] = "FILLED"
+	t.addExecution(result, symbol, status, placedAt)
 
 	return result, nil
 }
@@ -407,25 +563,28 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 		}
 
 		if quantity == 0 {
-			return nil, fmt.Errorf("没有找到 %s 的空仓", symbol)
+			return nil, noPositionError(symbol, "short")
 		}
 	}
 
 	// Hyperliquid symbol格式
 	coin := convertSymbolToHyperliquid(symbol)
 
+	// ⚠️ 关键：按币种规则取整数量（平仓不检查最小订单价值）
+	info, roundedQuantity, _, err := t.instruments.prepareOrder(coin, quantity, 0, 0, true)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("  📏 数量精度处理: %.8f -> %.8f (stepSize=%v)", quantity, roundedQuantity, info.StepSize)
+
 	// 获取当前价格
 	price, err := t.GetMarketPrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	// ⚠️ 关键：根据币种精度要求，四舍五入数量
-	roundedQuantity := t.roundToSzDecimals(coin, quantity)
-	log.Printf("  📏 数量精度处理: %.8f -> %.8f (szDecimals=%d)", quantity, roundedQuantity, t.getSzDecimals(coin))
-
 	// ⚠️ 关键：价格也需要处理为5位有效数字
-	aggressivePrice := t.roundPriceToSigfigs(price * 1.01)
+	aggressivePrice := info.RoundPrice(price * 1.01)
 	log.Printf("  💰 价格精度处理: %.8f -> %.8f (5位有效数字)", price*1.01, aggressivePrice)
 
 	// 创建平仓订单（买入 + ReduceOnly）
@@ -442,7 +601,8 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 		ReduceOnly: true,
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	placedAt := time.Now()
+	status, err := t.placeOrder(order)
 	if err != nil {
 		return nil, fmt.Errorf("平空仓失败: %w", err)
 	}
@@ -458,6 +618,7 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 	result["orderId"] = 0
 	result["symbol"] = symbol
 	result["status"] = "FILLED"
+	t.addExecution(result, symbol, status, placedAt)
 
 	return result, nil
 }
@@ -473,6 +634,7 @@ func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
 	}
 
 	// 取消该币种的所有挂单
+	defer t.account.markDirty(symbol)
 	for _, order := range openOrders {
 		if order.Coin == coin {
 			_, err := t.exchange.Cancel(t.ctx, coin, order.Oid)
@@ -486,6 +648,371 @@ func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
 	return nil
 }
 
+// CancelOrder 取消单个挂单
+func (t *HyperliquidTrader) CancelOrder(symbol string, orderID int64) error {
+	coin := convertSymbolToHyperliquid(symbol)
+	_, err := t.exchange.Cancel(t.ctx, coin, orderID)
+	t.account.markDirty(symbol)
+	if err != nil {
+		return fmt.Errorf("取消订单失败: %w", err)
+	}
+
+	log.Printf("  ✓ 已取消 %s 订单 %d", symbol, orderID)
+	return nil
+}
+
+// GetOpenOrders 获取该币种的挂单（包含止盈止损触发单，账户推送连接时读取本地模型）
+func (t *HyperliquidTrader) GetOpenOrders(symbol string) ([]map[string]interface{}, error) {
+	if orders, ok := t.account.getOpenOrders(symbol); ok {
+		return orders, nil
+	}
+
+	openOrders, err := t.exchange.Info().FrontendOpenOrders(t.ctx, t.walletAddr)
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	result := []map[string]interface{}{}
+	for _, order := range hyperliquidOpenOrders(openOrders) {
+		if order["symbol"] == symbol {
+			result = append(result, order)
+		}
+	}
+	if t.account.isLive() {
+		t.account.loadOrders(symbol, result)
+	}
+	return result, nil
+}
+
+// hyperliquidOpenOrders 前端挂单列表转为统一的订单格式
+func hyperliquidOpenOrders(openOrders []hyperliquid.FrontendOpenOrder) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for _, order := range openOrders {
+		status := "NEW"
+		if order.Sz < order.OrigSz {
+			status = "PARTIALLY_FILLED"
+		}
+		result = append(result, map[string]interface{}{
+			"orderId":     order.Oid,
+			"symbol":      order.Coin + "USDT",
+			"side":        hyperliquidOrderSide(string(order.Side)),
+			"type":        hyperliquidOrderType(order.OrderType),
+			"status":      status,
+			"price":       order.LimitPx,
+			"stopPrice":   order.TriggerPx,
+			"quantity":    order.OrigSz,
+			"executedQty": order.OrigSz - order.Sz,
+			"reduceOnly":  order.ReduceOnly,
+		})
+	}
+	return result
+}
+
+// GetOrder 查询单个订单
+func (t *HyperliquidTrader) GetOrder(symbol string, orderID int64) (map[string]interface{}, error) {
+	queried, err := t.exchange.Info().QueryOrderByOid(t.ctx, t.walletAddr, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	if queried.Status != hyperliquid.OrderQueryStatusSuccess {
+		return nil, fmt.Errorf("%s 订单 %d: %w", symbol, orderID, ErrOrderNotFound)
+	}
+	return hyperliquidOrderInfo(symbol, queried.Order), nil
+}
+
+// GetOrderByClientID 按客户端订单ID查询订单（按cloid查询）
+func (t *HyperliquidTrader) GetOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, error) {
+	queried, err := t.queryOrderByClientID(symbol, clientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return hyperliquidOrderInfo(symbol, *queried), nil
+}
+
+// queryOrderByClientID 按cloid查询订单
+func (t *HyperliquidTrader) queryOrderByClientID(symbol, clientOrderID string) (*hyperliquid.OrderQueryResponse, error) {
+	queried, err := t.exchange.Info().QueryOrderByCloid(t.ctx, t.walletAddr, hyperliquidCloid(clientOrderID))
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	if queried.Status != hyperliquid.OrderQueryStatusSuccess {
+		return nil, fmt.Errorf("%s 订单 %s: %w", symbol, clientOrderID, ErrOrderNotFound)
+	}
+	return &queried.Order, nil
+}
+
+// SetClientOrderIDPrefix 设置之后下单使用的客户端订单ID前缀（下单时转为cloid）
+func (t *HyperliquidTrader) SetClientOrderIDPrefix(prefix string) {
+	t.clientIDs.setPrefix(prefix)
+}
+
+// hyperliquidCloid 客户端订单ID转为Hyperliquid的cloid（128位十六进制，取SHA-256的前16字节）
+func hyperliquidCloid(clientOrderID string) string {
+	sum := sha256.Sum256([]byte(clientOrderID))
+	return "0x" + hex.EncodeToString(sum[:16])
+}
+
+// placeOrder 带cloid下单，下单结果未知时先按cloid查询订单（见submitOrder）
+func (t *HyperliquidTrader) placeOrder(order hyperliquid.CreateOrderRequest) (hyperliquid.OrderStatus, error) {
+	clientOrderID := t.clientIDs.next()
+	if clientOrderID != "" {
+		cloid := hyperliquidCloid(clientOrderID)
+		order.ClientOrderID = &cloid
+	}
+
+	// 下单结果以REST为准，推送可能晚到：下次读取先刷新
+	defer t.account.markDirty(order.Coin + "USDT")
+
+	var status hyperliquid.OrderStatus
+	err := submitOrder(clientOrderID, func() error {
+		var err error
+		status, err = t.exchange.Order(t.ctx, order, nil)
+		return err
+	}, func() error {
+		queried, err := t.queryOrderByClientID(order.Coin+"USDT", clientOrderID)
+		if err != nil {
+			return err
+		}
+		// 按查询到的订单状态还原下单结果
+		switch queried.Status {
+		case hyperliquid.OrderStatusValueOpen:
+			status = hyperliquid.OrderStatus{Resting: &hyperliquid.OrderStatusResting{Oid: queried.Order.Oid, ClientID: queried.Order.Cloid}}
+		case hyperliquid.OrderStatusValueFilled, hyperliquid.OrderStatusValueTriggered:
+			status = hyperliquid.OrderStatus{Filled: &hyperliquid.OrderStatusFilled{Oid: int(queried.Order.Oid), TotalSz: queried.Order.OrigSz, AvgPx: queried.Order.LimitPx}}
+		default:
+			return fmt.Errorf("订单 %s 未被执行: %s", clientOrderID, queried.Status)
+		}
+		return nil
+	})
+
+	// 记下触发单的类型：触发成交时成交推送只带订单ID
+	if err == nil && status.Resting != nil && order.OrderType.Trigger != nil {
+		orderType := "STOP_MARKET"
+		if order.OrderType.Trigger.Tpsl == "tp" {
+			orderType = "TAKE_PROFIT_MARKET"
+		}
+		t.account.rememberOrderType(status.Resting.Oid, orderType)
+	}
+	return status, err
+}
+
+// addExecution 按成交的订单ID查询成交记录，在下单结果中补充成交均价和手续费（placedAt为下单时间）
+// 成交记录查询失败时退回下单响应中的成交均价和数量（不含手续费）
+func (t *HyperliquidTrader) addExecution(result map[string]interface{}, symbol string, status hyperliquid.OrderStatus, placedAt time.Time) {
+	if status.Filled == nil {
+		return
+	}
+	oid := int64(status.Filled.Oid)
+
+	// 向前多查一分钟，避免本地时钟与交易所不一致漏掉成交
+	fills, err := t.GetFills(placedAt.Add(-time.Minute))
+	if err != nil {
+		log.Printf("  ⚠ 查询 %s 订单 %d 的成交记录失败: %v", symbol, oid, err)
+	} else {
+		addFillsExecution(result, fills, oid)
+		if _, ok := result["avgPrice"]; ok {
+			return
+		}
+	}
+	avgPrice, _ := strconv.ParseFloat(status.Filled.AvgPx, 64)
+	quantity, _ := strconv.ParseFloat(status.Filled.TotalSz, 64)
+	setOrderExecution(result, avgPrice, quantity, 0, "")
+}
+
+// hyperliquidOrderInfo Hyperliquid订单查询结果转为统一的订单格式
+func hyperliquidOrderInfo(symbol string, queried hyperliquid.OrderQueryResponse) map[string]interface{} {
+	order := queried.Order
+	price, _ := strconv.ParseFloat(order.LimitPx, 64)
+	stopPrice, _ := strconv.ParseFloat(order.TriggerPx, 64)
+	remaining, _ := strconv.ParseFloat(order.Sz, 64)
+	quantity, _ := strconv.ParseFloat(order.OrigSz, 64)
+
+	// Hyperliquid的订单状态转为统一状态
+	var status string
+	switch queried.Status {
+	case hyperliquid.OrderStatusValueOpen:
+		status = "NEW"
+		if remaining < quantity {
+			status = "PARTIALLY_FILLED"
+		}
+	case hyperliquid.OrderStatusValueFilled, hyperliquid.OrderStatusValueTriggered:
+		status = "FILLED"
+		remaining = 0
+	case hyperliquid.OrderStatusValueRejected:
+		status = "REJECTED"
+	default:
+		status = "CANCELED"
+	}
+
+	return map[string]interface{}{
+		"orderId":     order.Oid,
+		"symbol":      symbol,
+		"side":        hyperliquidOrderSide(string(order.Side)),
+		"type":        hyperliquidOrderType(order.OrderType),
+		"status":      status,
+		"price":       price,
+		"stopPrice":   stopPrice,
+		"quantity":    quantity,
+		"executedQty": quantity - remaining,
+		"reduceOnly":  order.ReduceOnly,
+	}
+}
+
+// GetFills 获取since之后的成交记录（账户推送连接且since不早于连接建立时间时读取本地记录）
+func (t *HyperliquidTrader) GetFills(since time.Time) ([]map[string]interface{}, error) {
+	if fills, ok := t.account.getFills(since); ok {
+		return fills, nil
+	}
+
+	fills, err := t.exchange.Info().UserFillsByTime(t.ctx, t.walletAddr, since.UnixMilli(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取成交记录失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(fills))
+	for _, fill := range fills {
+		result = append(result, hyperliquidFill(fill))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["time"].(int64) < result[j]["time"].(int64)
+	})
+	return result, nil
+}
+
+// hyperliquidFill Hyperliquid成交记录转统一的成交格式
+func hyperliquidFill(fill hyperliquid.Fill) map[string]interface{} {
+	price, _ := strconv.ParseFloat(fill.Price, 64)
+	quantity, _ := strconv.ParseFloat(fill.Size, 64)
+	fee, _ := strconv.ParseFloat(fill.Fee, 64)
+	realizedPnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64)
+	return map[string]interface{}{
+		"symbol":      fill.Coin + "USDT",
+		"orderId":     fill.Oid,
+		"side":        hyperliquidOrderSide(fill.Side),
+		"time":        fill.Time,
+		"price":       price,
+		"quantity":    quantity,
+		"fee":         fee,
+		"feeAsset":    fill.FeeToken,
+		"realizedPnl": realizedPnl,
+	}
+}
+
+// hyperliquidFundingInterval Hyperliquid每小时结算一次资金费
+const hyperliquidFundingInterval = time.Hour
+
+// GetFundingPayments 获取since之后的资金费收支（userFunding，按szi的符号区分持仓方向）
+// 库中的UserFundingHistory只有请求字段，这里直接请求/info并解析
+func (t *HyperliquidTrader) GetFundingPayments(since time.Time) ([]map[string]interface{}, error) {
+	var records []struct {
+		Time  int64 `json:"time"`
+		Delta struct {
+			Type string `json:"type"`
+			Coin string `json:"coin"`
+			Usdc string `json:"usdc"`
+			Szi  string `json:"szi"`
+		} `json:"delta"`
+	}
+	request := map[string]interface{}{"type": "userFunding", "user": t.walletAddr, "startTime": since.UnixMilli()}
+	if err := t.postInfo(request, &records); err != nil {
+		return nil, fmt.Errorf("获取资金费记录失败: %w", err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		if record.Delta.Type != "funding" {
+			continue
+		}
+		amount, _ := strconv.ParseFloat(record.Delta.Usdc, 64)
+		size, _ := strconv.ParseFloat(record.Delta.Szi, 64)
+		side := "long"
+		if size < 0 {
+			side = "short"
+		}
+		result = append(result, map[string]interface{}{
+			"symbol": record.Delta.Coin + "USDT",
+			"side":   side,
+			"time":   record.Time,
+			"amount": amount,
+			"asset":  "USDC",
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["time"].(int64) < result[j]["time"].(int64)
+	})
+	return result, nil
+}
+
+// GetFundingRate 获取当前资金费率（metaAndAssetCtxs中的funding，为1小时的费率）和下次结算时间（下一个整点）
+func (t *HyperliquidTrader) GetFundingRate(symbol string) (float64, time.Time, error) {
+	coin := convertSymbolToHyperliquid(symbol)
+	metaAndCtxs, err := t.exchange.Info().MetaAndAssetCtxs(t.ctx)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("获取资金费率失败: %w", err)
+	}
+	for i, asset := range metaAndCtxs.Universe {
+		if asset.Name != coin || i >= len(metaAndCtxs.Ctxs) {
+			continue
+		}
+		rate, _ := strconv.ParseFloat(metaAndCtxs.Ctxs[i].Funding, 64)
+		return rate, time.Now().Truncate(hyperliquidFundingInterval).Add(hyperliquidFundingInterval), nil
+	}
+	return 0, time.Time{}, fmt.Errorf("未找到 %s 的资金费率", symbol)
+}
+
+// postInfo 直接请求/info接口（用于库没有正确封装的查询），响应解析到result
+func (t *HyperliquidTrader) postInfo(request map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, strings.TrimSuffix(t.apiURL, "/")+"/info", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hyperliquidHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// hyperliquidHTTPClient postInfo使用的HTTP客户端
+var hyperliquidHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// hyperliquidOrderSide B/A 转为 BUY/SELL
+func hyperliquidOrderSide(side string) string {
+	if side == "B" {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+// hyperliquidOrderType 前端订单类型转为统一的订单类型
+func hyperliquidOrderType(orderType string) string {
+	switch orderType {
+	case "Stop Market", "Stop Limit":
+		return "STOP_MARKET"
+	case "Take Profit Market", "Take Profit Limit":
+		return "TAKE_PROFIT_MARKET"
+	case "Market":
+		return "MARKET"
+	default:
+		return "LIMIT"
+	}
+}
+
 // GetMarketPrice 获取市场价格
 func (t *HyperliquidTrader) GetMarketPrice(symbol string) (float64, error) {
 	coin := convertSymbolToHyperliquid(symbol)
@@ -510,15 +1037,20 @@ func (t *HyperliquidTrader) GetMarketPrice(symbol string) (float64, error) {
 
 // SetStopLoss 设置止损单
 func (t *HyperliquidTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+
 	coin := convertSymbolToHyperliquid(symbol)
 
 	isBuy := positionSide == "SHORT" // 空仓止损=买入，多仓止损=卖出
 
-	// ⚠️ 关键：根据币种精度要求，四舍五入数量
-	roundedQuantity := t.roundToSzDecimals(coin, quantity)
-
-	// ⚠️ 关键：价格也需要处理为5位有效数字
-	roundedStopPrice := t.roundPriceToSigfigs(stopPrice)
+	// ⚠️ 关键：按币种规则取整数量和触发价（5位有效数字）
+	info, roundedQuantity, roundedStopPrice, err := t.instruments.prepareOrder(coin, quantity, stopPrice, 0, true)
+	if err != nil {
+		return err
+	}
+	log.Printf("  📏 %s 数量 %v 价格 %v (stepSize=%v)", symbol, roundedQuantity, roundedStopPrice, info.StepSize)
 
 	// 创建止损单（Trigger Order）
 	order := hyperliquid.CreateOrderRequest{
@@ -536,7 +1068,7 @@ func (t *HyperliquidTrader) SetStopLoss(symbol string, positionSide string, quan
 		ReduceOnly: true,
 	}
 
-	_, err := t.exchange.Order(t.ctx, order, nil)
+	_, err = t.placeOrder(order)
 	if err != nil {
 		return fmt.Errorf("设置止损失败: %w", err)
 	}
@@ -547,15 +1079,20 @@ func (t *HyperliquidTrader) SetStopLoss(symbol string, positionSide string, quan
 
 // SetTakeProfit 设置止盈单
 func (t *HyperliquidTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+
 	coin := convertSymbolToHyperliquid(symbol)
 
 	isBuy := positionSide == "SHORT" // 空仓止盈=买入，多仓止盈=卖出
 
-	// ⚠️ 关键：根据币种精度要求，四舍五入数量
-	roundedQuantity := t.roundToSzDecimals(coin, quantity)
-
-	// ⚠️ 关键：价格也需要处理为5位有效数字
-	roundedTakeProfitPrice := t.roundPriceToSigfigs(takeProfitPrice)
+	// ⚠️ 关键：按币种规则取整数量和触发价（5位有效数字）
+	info, roundedQuantity, roundedTakeProfitPrice, err := t.instruments.prepareOrder(coin, quantity, takeProfitPrice, 0, true)
+	if err != nil {
+		return err
+	}
+	log.Printf("  📏 %s 数量 %v 价格 %v (stepSize=%v)", symbol, roundedQuantity, roundedTakeProfitPrice, info.StepSize)
 
 	// 创建止盈单（Trigger Order）
 	order := hyperliquid.CreateOrderRequest{
@@ -573,7 +1110,7 @@ func (t *HyperliquidTrader) SetTakeProfit(symbol string, positionSide string, qu
 		ReduceOnly: true,
 	}
 
-	_, err := t.exchange.Order(t.ctx, order, nil)
+	_, err = t.placeOrder(order)
 	if err != nil {
 		return fmt.Errorf("设置止盈失败: %w", err)
 	}
@@ -582,84 +1119,73 @@ func (t *HyperliquidTrader) SetTakeProfit(symbol string, positionSide string, qu
 	return nil
 }
 
-// FormatQuantity 格式化数量到正确的精度
-func (t *HyperliquidTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
-	coin := convertSymbolToHyperliquid(symbol)
-	szDecimals := t.getSzDecimals(coin)
-
-	// 使用szDecimals格式化数量
-	formatStr := fmt.Sprintf("%%.%df", szDecimals)
-	return fmt.Sprintf(formatStr, quantity), nil
+// UpdateStopLoss 替换止损单（先挂新单再撤旧单）
+func (t *HyperliquidTrader) UpdateStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "STOP_MARKET", quantity, stopPrice, true)
 }
 
-// getSzDecimals 获取币种的数量精度
-func (t *HyperliquidTrader) getSzDecimals(coin string) int {
-	if t.meta == nil {
-		log.Printf("⚠️  meta信息为空，使用默认精度4")
-		return 4 // 默认精度
-	}
-
-	// 在meta.Universe中查找对应的币种
-	for _, asset := range t.meta.Universe {
-		if asset.Name == coin {
-			return asset.SzDecimals
-		}
-	}
-
-	log.Printf("⚠️  未找到 %s 的精度信息，使用默认精度4", coin)
-	return 4 // 默认精度
+// UpdateTakeProfit 替换止盈单（先挂新单再撤旧单）
+func (t *HyperliquidTrader) UpdateTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return replaceProtectiveOrder(t, symbol, positionSide, "TAKE_PROFIT_MARKET", quantity, takeProfitPrice, true)
 }
 
-// roundToSzDecimals 将数量四舍五入到正确的精度
-func (t *HyperliquidTrader) roundToSzDecimals(coin string, quantity float64) float64 {
-	szDecimals := t.getSzDecimals(coin)
+// SetTakeProfitLadder 按阶梯挂多张止盈单（止盈触发单本身即为只减仓）
+func (t *HyperliquidTrader) SetTakeProfitLadder(symbol string, positionSide string, quantity float64, levels []TakeProfitLevel) error {
+	return placeTakeProfitLadder(t, symbol, positionSide, quantity, levels, func(quantity, price float64) error {
+		return t.SetTakeProfit(symbol, positionSide, quantity, price)
+	})
+}
 
-	// 计算倍数（10^szDecimals）
-	multiplier := 1.0
-	for i := 0; i < szDecimals; i++ {
-		multiplier *= 10.0
-	}
+// SetTrailingStop 设置跟踪止损（客户端跟踪引擎随价格移动止损单，数量按当前持仓）
+func (t *HyperliquidTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackRate, activationPrice float64) error {
+	return t.trailing.set(symbol, positionSide, callbackRate, activationPrice)
+}
 
-	// 四舍五入
-	return float64(int(quantity*multiplier+0.5)) / multiplier
+// FormatQuantity 按szDecimals向下取整并格式化数量
+func (t *HyperliquidTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return t.instruments.formatQuantity(convertSymbolToHyperliquid(symbol), quantity)
 }
 
-// roundPriceToSigfigs 将价格四舍五入到5位有效数字
-// Hyperliquid要求价格使用5位有效数字（significant figures）
-func (t *HyperliquidTrader) roundPriceToSigfigs(price float64) float64 {
-	if price == 0 {
-		return 0
-	}
+// GetInstrument 获取币种规则（Symbol为Hyperliquid币种名，如"BTC"）
+func (t *HyperliquidTrader) GetInstrument(symbol string) (InstrumentInfo, error) {
+	return t.instruments.get(convertSymbolToHyperliquid(symbol))
+}
 
-	const sigfigs = 5 // Hyperliquid标准：5位有效数字
+// hyperliquidMinOrderValue Hyperliquid开仓订单的最小价值（USDC）
+const hyperliquidMinOrderValue = 10
 
-	// 计算价格的数量级
-	var magnitude float64
-	if price < 0 {
-		magnitude = -price
-	} else {
-		magnitude = price
+// loadInstruments 从meta加载全部币种规则
+// 数量步长为10^-szDecimals；价格最多5位有效数字且不超过6-szDecimals位小数；保证金档位来自marginTables
+func (t *HyperliquidTrader) loadInstruments() ([]InstrumentInfo, error) {
+	meta, err := t.exchange.Info().Meta(t.ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// 计算需要的倍数
-	multiplier := 1.0
-	for magnitude >= 10 {
-		magnitude /= 10
-		multiplier /= 10
-	}
-	for magnitude < 1 {
-		magnitude *= 10
-		multiplier *= 10
+	tables := make(map[int][]MarginTier)
+	for _, table := range meta.MarginTables {
+		for _, tier := range table.MarginTiers {
+			floor, _ := strconv.ParseFloat(tier.LowerBound, 64)
+			tables[table.ID] = append(tables[table.ID], MarginTier{NotionalFloor: floor, MaxLeverage: tier.MaxLeverage})
+		}
 	}
 
-	// 应用有效数字精度
-	for i := 0; i < sigfigs-1; i++ {
-		multiplier *= 10
+	infos := make([]InstrumentInfo, 0, len(meta.Universe))
+	for _, asset := range meta.Universe {
+		step := math.Pow10(-asset.SzDecimals)
+		infos = append(infos, InstrumentInfo{
+			Symbol:             asset.Name,
+			TickSize:           math.Pow10(asset.SzDecimals - 6),
+			PriceSigFigs:       5,
+			StepSize:           step,
+			MinQuantity:        step,
+			MinNotional:        hyperliquidMinOrderValue,
+			MaxLeverage:        asset.MaxLeverage,
+			ContractMultiplier: 1,
+			MarginTiers:        tables[asset.MarginTableId],
+		})
 	}
-
-	// 四舍五入
-	rounded := float64(int(price*multiplier+0.5)) / multiplier
-	return rounded
+	return infos, nil
 }
 
 // convertSymbolToHyperliquid 将标准symbol转换为Hyperliquid格式
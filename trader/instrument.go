@@ -0,0 +1,257 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// instrumentRefreshInterval 交易对规则缓存的有效期（过期后下次下单时重新加载）
+var instrumentRefreshInterval = time.Hour
+
+const (
+	// instrumentMissRetry 查询不到交易对时，距离上次加载超过这个时间才重新加载（新上线的交易对）
+	instrumentMissRetry = time.Minute
+	// instrumentFailureBackoff 加载失败后，距离上次失败超过这个时间才重新加载（交易所故障期间不反复请求）
+	instrumentFailureBackoff = 30 * time.Second
+)
+
+// MarginTier 保证金档位：持仓名义价值达到NotionalFloor后适用的最大杠杆
+type MarginTier struct {
+	NotionalFloor         float64 // 档位的名义价值下限
+	MaxLeverage           int     // 该档位允许的最大杠杆
+	MaintenanceMarginRate float64 // 维持保证金率（交易所不提供时为0）
+}
+
+// InstrumentInfo 交易对规则（各交易所统一格式，数量均以基础资产计）
+type InstrumentInfo struct {
+	Symbol             string
+	TickSize           float64      // 价格步长（0表示不限制）
+	PriceSigFigs       int          // 价格最多的有效数字位数（Hyperliquid为5，0表示不限制），与TickSize取较大的步长
+	StepSize           float64      // 数量步长
+	MinQuantity        float64      // 最小下单数量
+	MinNotional        float64      // 开仓的最小名义价值（数量×价格，0表示不限制）
+	MaxLeverage        int          // 最大杠杆（0表示未知）
+	ContractMultiplier float64      // 每张合约对应的基础资产数量（按币下单的交易所为1）
+	MarginTiers        []MarginTier // 按NotionalFloor从小到大排列（交易所不提供时为空）
+}
+
+// RoundQuantity 将数量向下取整到步长的整数倍（不会超过可用数量或持仓数量）
+func (i InstrumentInfo) RoundQuantity(quantity float64) float64 {
+	if i.StepSize <= 0 {
+		return quantity
+	}
+	rounded := math.Floor(quantity/i.StepSize+1e-9) * i.StepSize
+	value, _ := strconv.ParseFloat(strconv.FormatFloat(rounded, 'f', stepDecimals(i.StepSize), 64), 64)
+	return value
+}
+
+// RoundPrice 将价格四舍五入到价格步长（PriceSigFigs>0时同时限制有效数字位数）
+func (i InstrumentInfo) RoundPrice(price float64) float64 {
+	tick := i.priceTick(price)
+	if tick <= 0 {
+		return price
+	}
+	value, _ := strconv.ParseFloat(strconv.FormatFloat(math.Round(price/tick)*tick, 'f', stepDecimals(tick), 64), 64)
+	return value
+}
+
+// FormatQuantity 向下取整后格式化数量（小数位数与步长一致）
+func (i InstrumentInfo) FormatQuantity(quantity float64) string {
+	if i.StepSize <= 0 {
+		return strconv.FormatFloat(quantity, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(i.RoundQuantity(quantity), 'f', stepDecimals(i.StepSize), 64)
+}
+
+// FormatPrice 取整后格式化价格（小数位数与实际使用的价格步长一致）
+func (i InstrumentInfo) FormatPrice(price float64) string {
+	tick := i.priceTick(price)
+	if tick <= 0 {
+		return strconv.FormatFloat(price, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(i.RoundPrice(price), 'f', stepDecimals(tick), 64)
+}
+
+// MaxLeverageFor 返回名义价值为notional的持仓允许的最大杠杆（没有档位信息时为MaxLeverage）
+func (i InstrumentInfo) MaxLeverageFor(notional float64) int {
+	maxLeverage := i.MaxLeverage
+	for _, tier := range i.MarginTiers {
+		if notional < tier.NotionalFloor {
+			break
+		}
+		maxLeverage = tier.MaxLeverage
+	}
+	return maxLeverage
+}
+
+// CheckOrder 下单前按交易对规则检查（quantity需已按步长取整）：
+//   - 数量大于0且不小于MinQuantity，否则返回ErrInvalidQuantity
+//   - 开仓（reduceOnly=false）时名义价值不小于MinNotional（ErrInvalidQuantity），杠杆不超过所在档位的上限（ErrInvalidLeverage）
+//
+// price为0时不检查名义价值，leverage为0时不检查杠杆
+func (i InstrumentInfo) CheckOrder(quantity, price float64, leverage int, reduceOnly bool) error {
+	if quantity <= 0 {
+		return fmt.Errorf("%w: %s 数量按步长 %v 取整后为0", ErrInvalidQuantity, i.Symbol, i.StepSize)
+	}
+	if i.MinQuantity > 0 && quantity < i.MinQuantity-1e-12 {
+		return fmt.Errorf("%w: %s 数量 %v 小于最小下单数量 %v", ErrInvalidQuantity, i.Symbol, quantity, i.MinQuantity)
+	}
+	if reduceOnly || price <= 0 {
+		return nil
+	}
+
+	notional := quantity * price
+	if i.MinNotional > 0 && notional < i.MinNotional {
+		return fmt.Errorf("%w: %s 名义价值 %.2f 小于最小值 %.2f", ErrInvalidQuantity, i.Symbol, notional, i.MinNotional)
+	}
+	if maxLeverage := i.MaxLeverageFor(notional); leverage > 0 && maxLeverage > 0 && leverage > maxLeverage {
+		return fmt.Errorf("%w: %s 名义价值 %.2f 最多允许 %dx，请求 %dx", ErrInvalidLeverage, i.Symbol, notional, maxLeverage, leverage)
+	}
+	return nil
+}
+
+// priceTick 返回价格price实际使用的步长
+func (i InstrumentInfo) priceTick(price float64) float64 {
+	tick := i.TickSize
+	if i.PriceSigFigs > 0 && price > 0 {
+		sigTick := math.Pow10(int(math.Floor(math.Log10(price))) + 1 - i.PriceSigFigs)
+		if sigTick > tick {
+			tick = sigTick
+		}
+	}
+	return tick
+}
+
+// stepDecimals 步长的小数位数（如0.001为3，0.5为1，10为0）
+func stepDecimals(step float64) int {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	for idx := 0; idx < len(s); idx++ {
+		if s[idx] == '.' {
+			return len(s) - idx - 1
+		}
+	}
+	return 0
+}
+
+// instrumentRegistry 按交易所加载并缓存交易对规则
+// 缓存超过instrumentRefreshInterval后重新加载；重新加载失败时继续使用旧数据，避免交易所接口抖动影响下单，
+// 并在instrumentFailureBackoff内不再重试
+type instrumentRegistry struct {
+	venue string                           // 交易所名称（用于日志）
+	load  func() ([]InstrumentInfo, error) // 加载该交易所全部交易对的规则
+
+	mu       sync.Mutex
+	items    map[string]InstrumentInfo
+	loadedAt time.Time
+	failedAt time.Time // 最近一次加载失败的时间（成功后清零）
+	lastErr  error     // 最近一次加载失败的错误
+}
+
+// newInstrumentRegistry 创建交易对规则缓存（第一次使用时加载）
+func newInstrumentRegistry(venue string, load func() ([]InstrumentInfo, error)) *instrumentRegistry {
+	return &instrumentRegistry{venue: venue, load: load}
+}
+
+// get 返回交易对规则
+func (r *instrumentRegistry) get(symbol string) (InstrumentInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.items == nil || time.Since(r.loadedAt) >= instrumentRefreshInterval {
+		if err := r.retryLocked(); err != nil && r.items == nil {
+			return InstrumentInfo{}, err
+		}
+	}
+	if info, ok := r.items[symbol]; ok {
+		return info, nil
+	}
+
+	// 可能是新上线的交易对，距离上次加载超过一分钟时重新加载一次
+	if time.Since(r.loadedAt) >= instrumentMissRetry {
+		if err := r.retryLocked(); err != nil {
+			return InstrumentInfo{}, err
+		}
+		if info, ok := r.items[symbol]; ok {
+			return info, nil
+		}
+	}
+	return InstrumentInfo{}, fmt.Errorf("未找到 %s 交易对 %s 的交易规则", r.venue, symbol)
+}
+
+// refresh 立即重新加载全部交易对规则
+func (r *instrumentRegistry) refresh() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.refreshLocked()
+}
+
+// retryLocked 重新加载，但距离上次失败不足instrumentFailureBackoff时直接返回上次的错误
+func (r *instrumentRegistry) retryLocked() error {
+	if !r.failedAt.IsZero() && time.Since(r.failedAt) < instrumentFailureBackoff {
+		return r.lastErr
+	}
+	return r.refreshLocked()
+}
+
+func (r *instrumentRegistry) refreshLocked() error {
+	infos, err := r.load()
+	if err != nil {
+		if r.items != nil {
+			log.Printf("⚠️  刷新%s交易规则失败，继续使用缓存: %v", r.venue, err)
+		}
+		r.failedAt = time.Now()
+		r.lastErr = fmt.Errorf("获取%s交易规则失败: %w", r.venue, err)
+		return r.lastErr
+	}
+
+	items := make(map[string]InstrumentInfo, len(infos))
+	for _, info := range infos {
+		if info.ContractMultiplier == 0 {
+			info.ContractMultiplier = 1
+		}
+		sort.Slice(info.MarginTiers, func(a, b int) bool {
+			return info.MarginTiers[a].NotionalFloor < info.MarginTiers[b].NotionalFloor
+		})
+		if info.MaxLeverage == 0 && len(info.MarginTiers) > 0 {
+			info.MaxLeverage = info.MarginTiers[0].MaxLeverage // 最低档位的杠杆最高
+		}
+		items[info.Symbol] = info
+	}
+	r.items = items
+	r.loadedAt = time.Now()
+	r.failedAt = time.Time{}
+	r.lastErr = nil
+	log.Printf("✓ 已加载%s交易规则: %d 个交易对", r.venue, len(items))
+	return nil
+}
+
+// prepareOrder 下单前的统一处理：数量向下取整到步长，价格（price>0时）取整到价格步长，再按CheckOrder检查
+// 返回交易对规则和取整后的数量、价格
+func (r *instrumentRegistry) prepareOrder(symbol string, quantity, price float64, leverage int, reduceOnly bool) (InstrumentInfo, float64, float64, error) {
+	info, err := r.get(symbol)
+	if err != nil {
+		return InstrumentInfo{}, 0, 0, err
+	}
+	quantity = info.RoundQuantity(quantity)
+	if price > 0 {
+		price = info.RoundPrice(price)
+	}
+	if err := info.CheckOrder(quantity, price, leverage, reduceOnly); err != nil {
+		return InstrumentInfo{}, 0, 0, err
+	}
+	return info, quantity, price, nil
+}
+
+// formatQuantity FormatQuantity的统一实现（向下取整到步长，不足一个步长时为0）
+func (r *instrumentRegistry) formatQuantity(symbol string, quantity float64) (string, error) {
+	info, err := r.get(symbol)
+	if err != nil {
+		return "", err
+	}
+	return info.FormatQuantity(quantity), nil
+}
@@ -0,0 +1,46 @@
+package trader
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstrumentRegistryBacksOffAfterFailure(t *testing.T) {
+	calls := 0
+	fail := true
+	registry := newInstrumentRegistry("test", func() ([]InstrumentInfo, error) {
+		calls++
+		if fail {
+			return nil, errors.New("venue down")
+		}
+		return []InstrumentInfo{{Symbol: "BTCUSDT", StepSize: 0.001}}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := registry.get("BTCUSDT"); err == nil {
+			t.Fatal("加载失败时应返回错误")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("退避期内不应重复加载: calls=%d", calls)
+	}
+
+	// 退避结束后重新加载成功
+	fail = false
+	registry.failedAt = registry.failedAt.Add(-instrumentFailureBackoff)
+	if _, err := registry.get("BTCUSDT"); err != nil {
+		t.Fatalf("退避结束后应重新加载: %v", err)
+	}
+
+	// 缓存过期后刷新失败：继续使用旧数据，且退避期内不再请求
+	fail = true
+	registry.loadedAt = registry.loadedAt.Add(-instrumentRefreshInterval)
+	for i := 0; i < 3; i++ {
+		if info, err := registry.get("BTCUSDT"); err != nil || info.StepSize != 0.001 {
+			t.Fatalf("刷新失败时应返回缓存: %+v, %v", info, err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("刷新失败后应退避: calls=%d", calls)
+	}
+}
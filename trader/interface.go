@@ -1,41 +1,139 @@
 package trader
 
+import "time"
+
+// 限价单的有效方式（OpenLimit的timeInForce参数）
+const (
+	TimeInForceGTC      = "GTC"       // 一直有效，直到成交或撤单
+	TimeInForceIOC      = "IOC"       // 立即成交，未成交部分撤销
+	TimeInForcePostOnly = "POST_ONLY" // 只做Maker，会立即成交时交易所拒绝该订单
+)
+
 // Trader 交易器统一接口
 // 支持多个交易平台（币安、Hyperliquid等）
 type Trader interface {
 	// GetBalance 获取账户余额
 	GetBalance() (map[string]interface{}, error)
 
-	// GetPositions 获取所有持仓
+	// GetPositions 获取所有持仓（只返回数量不为0的持仓）
+	// 每个持仓包含以下字段，数值均为float64：
+	//   symbol(string), side("long"/"short"), positionAmt(数量，始终为正数),
+	//   entryPrice, markPrice, unRealizedProfit, liquidationPrice, leverage
 	GetPositions() ([]map[string]interface{}, error)
 
 	// OpenLong 开多仓
+	// 返回orderId(int64)、symbol和status；交易所返回了成交信息时还包含
+	//   avgPrice(成交均价), executedQty(成交数量), fee(手续费) 均为float64, feeAsset(手续费币种, string)
+	// OpenShort/CloseLong/CloseShort以及立即成交的OpenLimit返回同样的字段
 	OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error)
 
 	// OpenShort 开空仓
 	OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error)
 
-	// CloseLong 平多仓（quantity=0表示全部平仓）
+	// OpenLimit 限价开仓（positionSide为"LONG"或"SHORT"）
+	// 返回orderId(int64)、symbol和status："FILLED"已成交，"NEW"挂单中，"EXPIRED"IOC未成交已撤销
+	// POST_ONLY订单会立即成交时交易所拒绝下单，返回错误
+	OpenLimit(symbol, positionSide string, quantity, price float64, leverage int, timeInForce string) (map[string]interface{}, error)
+
+	// CancelOrder 取消单个挂单
+	CancelOrder(symbol string, orderID int64) error
+
+	// CloseLong 平多仓（quantity=0表示全部平仓，没有持仓时返回ErrNoPosition）
 	CloseLong(symbol string, quantity float64) (map[string]interface{}, error)
 
-	// CloseShort 平空仓（quantity=0表示全部平仓）
+	// CloseShort 平空仓（quantity=0表示全部平仓，没有持仓时返回ErrNoPosition）
 	CloseShort(symbol string, quantity float64) (map[string]interface{}, error)
 
 	// SetLeverage 设置杠杆
 	SetLeverage(symbol string, leverage int) error
 
+	// ConfigureAccountModes 启动时调用：检测账户当前的持仓模式，按配置切换保证金模式（MarginMode*）和持仓模式（PositionMode*）
+	// 空字符串表示使用默认值（币安/Hyperliquid默认逐仓，持仓模式沿用账户当前设置）
+	// 之后的下单按实际模式发送positionSide/reduceOnly；交易所不支持所配置的模式时返回ErrUnsupportedAccountMode
+	ConfigureAccountModes(marginMode, positionMode string) error
+
+	// AccountModes 返回当前使用的保证金模式和持仓模式（ConfigureAccountModes之前为交易器的默认值）
+	AccountModes() (marginMode, positionMode string)
+
 	// GetMarketPrice 获取市场价格
 	GetMarketPrice(symbol string) (float64, error)
 
-	// SetStopLoss 设置止损单
+	// SetStopLoss 设置止损单（positionSide为"LONG"或"SHORT"，否则返回ErrInvalidPositionSide）
 	SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error
 
-	// SetTakeProfit 设置止盈单
+	// SetTakeProfit 设置止盈单（positionSide同SetStopLoss）
 	SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error
 
-	// CancelAllOrders 取消该币种的所有挂单
+	// UpdateStopLoss 替换持仓已有的止损单（没有旧止损单时直接挂新单）
+	// 替换失败时保留原来的止损单，不会让持仓失去保护
+	UpdateStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error
+
+	// UpdateTakeProfit 替换持仓已有的止盈单（规则同UpdateStopLoss）
+	UpdateTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error
+
+	// SetTakeProfitLadder 按阶梯挂多张只减仓止盈单（每档数量为quantity×Fraction，按交易所精度取整）
+	// 各档比例之和可以小于1，剩余数量不挂止盈（如留给跟踪止损）；不会撤销已有的止盈单
+	SetTakeProfitLadder(symbol string, positionSide string, quantity float64, levels []TakeProfitLevel) error
+
+	// SetTrailingStop 设置跟踪止损（callbackRate为回调百分比，如1.5表示1.5%；activationPrice为激活价，0表示立即激活）
+	// 币安使用原生TRAILING_STOP_MARKET单；其他交易所由客户端跟踪引擎随价格移动持仓的止损单（只朝有利方向移动）
+	// 重复调用会替换该持仓已有的跟踪止损（如持仓数量变化后）
+	SetTrailingStop(symbol string, positionSide string, quantity, callbackRate, activationPrice float64) error
+
+	// CancelAllOrders 取消该币种的所有挂单（包括止损止盈单）
 	CancelAllOrders(symbol string) error
 
-	// FormatQuantity 格式化数量到正确的精度
+	// FormatQuantity 格式化数量到正确的精度（向下取整为交易对数量步长的整数倍）
 	FormatQuantity(symbol string, quantity float64) (string, error)
+
+	// GetInstrument 获取交易对规则（价格/数量步长、最小数量和名义价值、最大杠杆、保证金档位等）
+	// 规则按交易所缓存并定期刷新；下单前各交易器都按该规则取整并检查，不满足时返回ErrInvalidQuantity或ErrInvalidLeverage
+	GetInstrument(symbol string) (InstrumentInfo, error)
+
+	// GetOpenOrders 获取该币种挂单中的订单（包括止损止盈单）
+	// 每个订单包含：orderId(int64), symbol(string), side("BUY"/"SELL"),
+	//   type("LIMIT"/"MARKET"/"STOP_MARKET"/"TAKE_PROFIT_MARKET"/"TRAILING_STOP_MARKET"),
+	//   status("NEW"/"PARTIALLY_FILLED"/"FILLED"/"CANCELED"/"EXPIRED"/"REJECTED"),
+	//   price, stopPrice, quantity(原始数量), executedQty(已成交数量) 均为float64, reduceOnly(bool)
+	GetOpenOrders(symbol string) ([]map[string]interface{}, error)
+
+	// GetOrder 查询单个订单（包括已成交/已撤销的订单，字段同GetOpenOrders，不存在时返回ErrOrderNotFound）
+	GetOrder(symbol string, orderID int64) (map[string]interface{}, error)
+
+	// GetOrderByClientID 按客户端订单ID查询订单（字段同GetOrder，不存在时返回ErrOrderNotFound）
+	GetOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, error)
+
+	// SetClientOrderIDPrefix 设置之后下单使用的客户端订单ID前缀（空字符串表示不带客户端订单ID）
+	// 同一前缀下的订单依次编号为 前缀-1、前缀-2…（前缀不超过24个字符）；下单结果未知（如请求超时）时
+	// 先按客户端订单ID查询，交易所已接受的订单按成功处理，确认没有收到才用同一个ID重试一次
+	SetClientOrderIDPrefix(prefix string)
+
+	// GetFills 获取since之后的成交记录（按时间从早到晚）
+	// 每笔成交包含：symbol(string), orderId(int64), side("BUY"/"SELL"), time(int64毫秒),
+	//   price, quantity, fee, realizedPnl 均为float64（交易所不提供的字段为0）, feeAsset(手续费币种, string)
+	GetFills(since time.Time) ([]map[string]interface{}, error)
+
+	// GetFundingPayments 获取since之后的资金费收支（按时间从早到晚）
+	// 每笔包含：symbol(string), side("long"/"short"，交易所不提供持仓方向时为空字符串), time(int64毫秒),
+	//   amount(float64，正数为收入，负数为支出), asset(结算币种, string)
+	GetFundingPayments(since time.Time) ([]map[string]interface{}, error)
+
+	// GetFundingRate 获取当前资金费率和下次结算时间
+	// rate为一个结算周期的费率（为正时多仓向空仓支付 数量×标记价×rate），各交易所的结算周期不同（如币安8小时、Hyperliquid 1小时）
+	GetFundingRate(symbol string) (rate float64, nextFundingTime time.Time, err error)
+}
+
+// AccountStream 支持账户推送（WebSocket）的交易器实现的可选接口
+// 推送连接正常时GetBalance/GetPositions/GetOpenOrders/GetFills读取本地模型，不再每次调用REST；
+// 断线期间自动退回REST查询并在后台重连
+type AccountStream interface {
+	// StartStream 建立推送连接并加载账户快照（首次连接失败时返回错误，交易器继续使用REST）
+	StartStream() error
+
+	// StopStream 关闭推送连接（之后的读取全部走REST，Fills通道被关闭）
+	StopStream()
+
+	// Fills 实时成交通道（字段同GetFills，另有orderType：触发成交的订单类型，如"STOP_MARKET"表示止损单触发；
+	// 推送不带订单类型的交易所（Hyperliquid）遇到没见过的订单时为空字符串，需要时用GetOrder查询）
+	Fills() <-chan map[string]interface{}
 }
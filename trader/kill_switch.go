@@ -0,0 +1,268 @@
+package trader
+
+import (
+	"danto/logger"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// flattenAttempts 紧急平仓最多执行的轮数（每轮撤单、平仓后重新检查账户）
+	flattenAttempts = 5
+	// flattenRetryDelay 紧急平仓未完成时下一轮之前的等待时间
+	flattenRetryDelay = 2 * time.Second
+)
+
+// FlattenReport 紧急平仓后交易员的最终状态
+type FlattenReport struct {
+	TraderID           string                   `json:"trader_id"`
+	TraderName         string                   `json:"trader_name"`
+	Exchange           string                   `json:"exchange"`
+	Flat               bool                     `json:"flat"`                          // 是否已没有持仓和挂单
+	Attempts           int                      `json:"attempts"`                      // 执行的轮数
+	Closed             []map[string]interface{} `json:"closed,omitempty"`              // 已平掉的持仓：symbol, side, quantity, price
+	RemainingPositions []map[string]interface{} `json:"remaining_positions,omitempty"` // 仍未平掉的持仓：symbol, side, quantity
+	RemainingOrders    map[string]int           `json:"remaining_orders,omitempty"`    // 仍未撤销的挂单数量 (symbol -> 数量)
+	Errors             []string                 `json:"errors,omitempty"`              // 各轮出现的错误
+}
+
+// Flatten 紧急平仓（kill switch）：停止交易循环，撤销所有挂单并市价平掉所有持仓，失败时重试，返回最终状态
+// 第一轮立即执行；之后等待进行中的交易周期结束（触发后周期内不会再下单），再检查账户并重试直到清空
+// 撤单范围为持仓币种以及本交易员记录的挂单/止损止盈币种（接口不支持查询全部币种的挂单）
+func (at *AutoTrader) Flatten() *FlattenReport {
+	at.killed.Store(true)
+	at.Stop()
+	log.Printf("🚨 [%s] 紧急平仓：停止交易，撤销所有挂单并市价平掉所有持仓", at.name)
+
+	report := &FlattenReport{
+		TraderID:   at.id,
+		TraderName: at.name,
+		Exchange:   at.exchange,
+	}
+	record := &logger.DecisionRecord{
+		ExecutionLog: []string{"🚨 紧急平仓"},
+		Success:      true,
+	}
+
+	at.flattenPass(report, record, nil)
+
+	at.cycleMu.Lock()
+	defer at.cycleMu.Unlock()
+	tracked := at.trackedSymbols()
+	for report.Attempts < flattenAttempts {
+		if !report.Flat {
+			time.Sleep(flattenRetryDelay)
+		}
+		at.flattenPass(report, record, tracked)
+		if report.Flat {
+			break
+		}
+	}
+	for _, entry := range at.pendingEntries {
+		record.Decisions = append(record.Decisions, logger.DecisionAction{
+			Action:    "cancel_entry",
+			Symbol:    entry.Symbol,
+			Side:      entry.Side,
+			Quantity:  entry.Quantity,
+			Price:     entry.Price,
+			OrderID:   entry.OrderID,
+			Timestamp: time.Now(),
+			Success:   true,
+			Reasoning: "紧急平仓撤销限价开仓单",
+			Exchange:  at.exchange,
+		})
+	}
+	at.pendingEntries = make(map[string]*pendingEntry)
+	at.targetsMu.Lock()
+	at.protectiveTargets = make(map[string]protectiveTarget)
+	at.targetsMu.Unlock()
+	// 推送收到、还没写入决策日志的交易所触发平仓（之后不再有交易周期），排在紧急平仓之前
+	record.Decisions = append(at.takeStreamCloses(), record.Decisions...)
+
+	if report.Flat {
+		log.Printf("✅ [%s] 紧急平仓完成（%d轮），平仓%d笔", at.name, report.Attempts, len(report.Closed))
+	} else {
+		record.Success = false
+		record.ErrorMessage = fmt.Sprintf("紧急平仓未完成: 剩余持仓%d个，剩余挂单币种%d个",
+			len(report.RemainingPositions), len(report.RemainingOrders))
+		log.Printf("❌ [%s] %s", at.name, record.ErrorMessage)
+	}
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ 保存决策记录失败: %v", err)
+	}
+	return report
+}
+
+// flattenPass 执行一轮紧急平仓：撤销相关币种的挂单，平掉所有持仓，然后重新读取账户更新report和record的账户快照
+func (at *AutoTrader) flattenPass(report *FlattenReport, record *logger.DecisionRecord, tracked []string) {
+	report.Attempts++
+	attempt := report.Attempts
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		report.Flat = false
+		report.Errors = append(report.Errors, fmt.Sprintf("第%d轮获取持仓失败: %v", attempt, err))
+		return
+	}
+
+	symbols := positionSymbols(positions, tracked)
+	// 先撤单（包括止损止盈单），避免平仓过程中条件单触发或限价开仓单成交
+	for _, symbol := range symbols {
+		if err := at.trader.CancelAllOrders(symbol); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("第%d轮撤销%s挂单失败: %v", attempt, symbol, err))
+		}
+	}
+
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		markPrice, _ := pos["markPrice"].(float64)
+		var order map[string]interface{}
+		if side == "long" {
+			order, err = at.trader.CloseLong(symbol, 0)
+		} else {
+			order, err = at.trader.CloseShort(symbol, 0)
+		}
+		if errors.Is(err, ErrNoPosition) {
+			continue
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("第%d轮平%s %s失败: %v", attempt, symbol, side, err))
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 紧急平仓失败: %v", symbol, side, err))
+			continue
+		}
+
+		quantity, _ := pos["positionAmt"].(float64)
+		if quantity < 0 {
+			quantity = -quantity
+		}
+		action := logger.DecisionAction{
+			Action:    "close_" + side,
+			Symbol:    symbol,
+			Quantity:  quantity,
+			Price:     markPrice,
+			Timestamp: time.Now(),
+			Success:   true,
+			Reasoning: "紧急平仓",
+			Exchange:  at.exchange,
+		}
+		if orderID, ok := order["orderId"].(int64); ok {
+			action.OrderID = orderID
+		}
+		at.markOrderLogged(action.OrderID)
+		recordExecution(&action, order, side == "short")
+		record.Decisions = append(record.Decisions, action)
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 紧急平仓 %.4f @ %.4f", symbol, side, action.Quantity, action.Price))
+		report.Closed = append(report.Closed, map[string]interface{}{
+			"symbol":   symbol,
+			"side":     side,
+			"quantity": action.Quantity,
+			"price":    action.Price,
+		})
+		log.Printf("  ✓ 紧急平仓 %s %s %.4f @ %.4f", symbol, side, action.Quantity, action.Price)
+	}
+
+	at.checkFlat(report, symbols)
+	at.snapshotAccount(report, record)
+}
+
+// snapshotAccount 平仓后重新读取账户余额，写入决策记录的账户快照（口径与交易周期记录一致，供净值曲线使用）
+// 读取失败时保留上一轮的快照
+func (at *AutoTrader) snapshotAccount(report *FlattenReport, record *logger.DecisionRecord) {
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("第%d轮获取账户余额失败: %v", report.Attempts, err))
+		return
+	}
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	unrealized, _ := balance["totalUnrealizedProfit"].(float64)
+	available, _ := balance["availableBalance"].(float64)
+	totalEquity := wallet + unrealized
+	record.AccountState = logger.AccountSnapshot{
+		TotalBalance:          totalEquity,
+		AvailableBalance:      available,
+		TotalUnrealizedProfit: totalEquity - at.initialBalance,
+		PositionCount:         len(report.RemainingPositions),
+	}
+}
+
+// checkFlat 重新读取持仓和symbols的挂单，更新report中的剩余状态
+func (at *AutoTrader) checkFlat(report *FlattenReport, symbols []string) {
+	report.Flat = true
+	report.RemainingPositions = nil
+	report.RemainingOrders = nil
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		report.Flat = false
+		report.Errors = append(report.Errors, fmt.Sprintf("第%d轮检查持仓失败: %v", report.Attempts, err))
+		return
+	}
+	for _, pos := range positions {
+		quantity, _ := pos["positionAmt"].(float64)
+		if quantity < 0 {
+			quantity = -quantity
+		}
+		report.Flat = false
+		report.RemainingPositions = append(report.RemainingPositions, map[string]interface{}{
+			"symbol":   pos["symbol"],
+			"side":     pos["side"],
+			"quantity": quantity,
+		})
+	}
+
+	for _, symbol := range positionSymbols(positions, symbols) {
+		orders, err := at.trader.GetOpenOrders(symbol)
+		if err != nil {
+			report.Flat = false
+			report.Errors = append(report.Errors, fmt.Sprintf("第%d轮检查%s挂单失败: %v", report.Attempts, symbol, err))
+			continue
+		}
+		if len(orders) > 0 {
+			if report.RemainingOrders == nil {
+				report.RemainingOrders = make(map[string]int)
+			}
+			report.Flat = false
+			report.RemainingOrders[symbol] = len(orders)
+		}
+	}
+}
+
+// trackedSymbols 本交易员记录了挂单（限价开仓单、止损止盈）的币种
+func (at *AutoTrader) trackedSymbols() []string {
+	var symbols []string
+	for _, entry := range at.pendingEntries {
+		symbols = append(symbols, entry.Symbol)
+	}
+	for key := range at.protectiveTargets {
+		if i := strings.LastIndex(key, "_"); i > 0 {
+			symbols = append(symbols, key[:i])
+		}
+	}
+	return symbols
+}
+
+// positionSymbols 持仓币种与extra合并去重（按字母排序）
+func positionSymbols(positions []map[string]interface{}, extra []string) []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(symbol string) {
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		add(symbol)
+	}
+	for _, symbol := range extra {
+		add(symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
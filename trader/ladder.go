@@ -0,0 +1,96 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// TakeProfitLevel 止盈阶梯的一档
+type TakeProfitLevel struct {
+	Price    float64 // 触发价
+	Fraction float64 // 这一档平掉的持仓比例（0-1）
+}
+
+// splitLadderQuantity 按各档比例拆分持仓数量，并按交易所精度取整
+// 按累计比例取整后再做差，取整误差不会累积；比例之和为1时最后一档正好平掉剩余的全部数量
+// 数量取整为0的档位返回0（由调用方跳过，其数量自然并入下一档）
+func splitLadderQuantity(t Trader, symbol string, quantity float64, levels []TakeProfitLevel) ([]float64, error) {
+	format := func(q float64) (float64, error) {
+		s, err := t.FormatQuantity(symbol, q)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(s, 64)
+	}
+
+	total, err := format(quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	quantities := make([]float64, len(levels))
+	cumFraction, placed := 0.0, 0.0
+	for i, level := range levels {
+		cumFraction += level.Fraction
+		cum := total
+		if cumFraction < 1-1e-9 {
+			if cum, err = format(quantity * cumFraction); err != nil {
+				return nil, err
+			}
+		}
+		if cum-placed <= 0 {
+			continue
+		}
+		if quantities[i], err = format(cum - placed); err != nil {
+			return nil, err
+		}
+		placed += quantities[i]
+	}
+	return quantities, nil
+}
+
+// placeTakeProfitLadder 按阶梯逐档挂只减仓止盈单，place负责挂单一档（数量已按精度取整）
+func placeTakeProfitLadder(t Trader, symbol, positionSide string, quantity float64, levels []TakeProfitLevel, place func(quantity, price float64) error) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+	if len(levels) == 0 {
+		return fmt.Errorf("止盈阶梯至少需要一档")
+	}
+	sum := 0.0
+	for i, level := range levels {
+		if level.Price <= 0 {
+			return fmt.Errorf("第%d档止盈价必须大于0: %.4f", i+1, level.Price)
+		}
+		if level.Fraction <= 0 {
+			return fmt.Errorf("第%d档止盈比例必须大于0: %.4f: %w", i+1, level.Fraction, ErrInvalidQuantity)
+		}
+		sum += level.Fraction
+	}
+	if sum > 1+1e-6 {
+		return fmt.Errorf("止盈阶梯比例之和不能超过100%%: %.2f%%", sum*100)
+	}
+
+	quantities, err := splitLadderQuantity(t, symbol, quantity, levels)
+	if err != nil {
+		return err
+	}
+
+	placed := 0
+	for i, level := range levels {
+		if quantities[i] <= 0 {
+			log.Printf("  ⚠ 第%d档止盈数量取整后为0，并入下一档", i+1)
+			continue
+		}
+		if err := place(quantities[i], level.Price); err != nil {
+			return fmt.Errorf("挂第%d档止盈单失败: %w", i+1, err)
+		}
+		placed++
+		log.Printf("  止盈阶梯 第%d档: %.4f 数量 %s", i+1, level.Price, strconv.FormatFloat(quantities[i], 'f', -1, 64))
+	}
+	if placed == 0 {
+		return fmt.Errorf("持仓数量 %.8f 太小，无法拆分止盈阶梯: %w", quantity, ErrInvalidQuantity)
+	}
+	return nil
+}
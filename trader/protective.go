@@ -0,0 +1,71 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+)
+
+// replaceProtectiveOrder 替换持仓的止损单（STOP_MARKET）或止盈单（TAKE_PROFIT_MARKET）
+// placeFirst=true：先挂新单再撤旧单，任何时刻持仓都有保护
+// placeFirst=false：交易所每个方向只允许一张同类全仓条件单（如币安closePosition），
+// 只能先撤旧单再挂新单，新单失败时按原触发价恢复旧单
+func replaceProtectiveOrder(t Trader, symbol, positionSide, orderType string, quantity, price float64, placeFirst bool) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+	if price <= 0 {
+		return fmt.Errorf("触发价格必须大于0: %.4f", price)
+	}
+
+	place := t.SetStopLoss
+	if orderType == "TAKE_PROFIT_MARKET" {
+		place = t.SetTakeProfit
+	}
+
+	// 找出该持仓方向上已有的同类条件单（方向与持仓相反）
+	closingSide := "SELL"
+	if positionSide == "SHORT" {
+		closingSide = "BUY"
+	}
+	orders, err := t.GetOpenOrders(symbol)
+	if err != nil {
+		return fmt.Errorf("获取挂单失败: %w", err)
+	}
+	var existing []map[string]interface{}
+	for _, order := range orders {
+		if order["type"] == orderType && order["side"] == closingSide {
+			existing = append(existing, order)
+		}
+	}
+
+	if placeFirst {
+		if err := place(symbol, positionSide, quantity, price); err != nil {
+			return err
+		}
+		for _, order := range existing {
+			orderID, _ := order["orderId"].(int64)
+			if err := t.CancelOrder(symbol, orderID); err != nil {
+				log.Printf("  ⚠ 撤销旧的%s单 %d 失败: %v", orderType, orderID, err)
+			}
+		}
+		return nil
+	}
+
+	for _, order := range existing {
+		orderID, _ := order["orderId"].(int64)
+		if err := t.CancelOrder(symbol, orderID); err != nil {
+			return fmt.Errorf("撤销旧的%s单 %d 失败: %w", orderType, orderID, err)
+		}
+	}
+	if err := place(symbol, positionSide, quantity, price); err != nil {
+		// 恢复原来的条件单
+		for _, order := range existing {
+			oldPrice, _ := order["stopPrice"].(float64)
+			if restoreErr := place(symbol, positionSide, quantity, oldPrice); restoreErr != nil {
+				log.Printf("  ❌ 恢复原%s单(%.4f)失败，持仓可能没有保护: %v", orderType, oldPrice, restoreErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,666 @@
+// Package tradertest 提供Trader实现的一致性测试套件（只在测试中使用，不会链接进生产程序）
+package tradertest
+
+import (
+	"danto/trader"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Harness 一致性测试的被测对象
+// Trader应连接到可控的交易所（如trader/exchangetest中的模拟服务），测试会真实下单
+type Harness struct {
+	Trader   trader.Trader
+	Symbol   string  // 测试交易对（默认BTCUSDT）
+	Quantity float64 // 每次开仓数量（需为StepSize的偶数倍，默认0.01）
+	StepSize float64 // 交易对数量步长（默认0.001）
+	Leverage int     // 开仓杠杆（默认5）
+
+	// TimeoutNextOrder 让交易所接受下一笔订单但向客户端返回超时（可选，如exchangetest.Server.TimeoutNextOrder）
+	TimeoutNextOrder func()
+
+	// 测试开始时传给ConfigureAccountModes的保证金模式和持仓模式（空字符串表示交易器默认）
+	MarginMode   string
+	PositionMode string
+}
+
+// RunConformance 对Trader实现运行一致性测试，所有交易器（包括新接入的交易所）都必须通过：
+//   - ConfigureAccountModes拒绝无效的模式，配置后AccountModes返回实际使用的模式，之后的测试都在该模式下下单
+//   - GetPositions字段齐全、类型正确，side为"long"/"short"，positionAmt为正数
+//   - 多空两个方向都能开仓、部分平仓，quantity=0时全部平仓
+//   - 止损/止盈单挂在正确的方向（多仓用SELL，空仓用BUY），UpdateStopLoss/UpdateTakeProfit替换后只剩新单，
+//     CancelAllOrders会清掉它们
+//   - 对已有持仓加仓（再次OpenLong）不会撤销已有挂单：持仓的止损止盈、限价开仓单和双向持仓模式下另一方向的止损止盈都保留
+//   - GetOpenOrders/GetOrder返回统一的订单格式，撤单后GetOrder为CANCELED，不存在的订单返回ErrOrderNotFound
+//   - GetFills包含开平仓的成交；市价开平仓返回成交均价、成交数量和手续费
+//   - SetTrailingStop在平仓方向挂出跟踪止损（原生TRAILING_STOP_MARKET或客户端移动的STOP_MARKET），
+//     触发价按回调比例计算，重复设置后只保留一张，回调比例无效时返回错误
+//   - SetTakeProfitLadder在平仓方向按比例挂出多张止盈单，数量为步长的整数倍，比例之和超过100%时返回错误
+//   - 设置客户端订单ID前缀后，订单可以用GetOrderByClientID查到；下单超时但交易所已接受时按成功处理，不会重复下单
+//   - FormatQuantity的结果是步长的整数倍
+//   - 没有持仓时平仓返回ErrNoPosition，持仓方向错误时返回ErrInvalidPositionSide
+func RunConformance(t *testing.T, h Harness) {
+	if h.Symbol == "" {
+		h.Symbol = "BTCUSDT"
+	}
+	if h.Quantity == 0 {
+		h.Quantity = 0.01
+	}
+	if h.StepSize == 0 {
+		h.StepSize = 0.001
+	}
+	if h.Leverage == 0 {
+		h.Leverage = 5
+	}
+
+	t.Run("AccountModes", func(t *testing.T) {
+		conformanceAccountModes(t, h)
+	})
+
+	t.Run("MarketPrice", func(t *testing.T) {
+		price, err := h.Trader.GetMarketPrice(h.Symbol)
+		if err != nil {
+			t.Fatalf("GetMarketPrice: %v", err)
+		}
+		if price <= 0 {
+			t.Fatalf("GetMarketPrice = %v, 应大于0", price)
+		}
+	})
+
+	t.Run("FormatQuantity", func(t *testing.T) {
+		for _, quantity := range []float64{h.Quantity, h.Quantity + h.StepSize*0.4, h.StepSize * 7.3} {
+			formatted, err := h.Trader.FormatQuantity(h.Symbol, quantity)
+			if err != nil {
+				t.Fatalf("FormatQuantity(%v): %v", quantity, err)
+			}
+			value, err := strconv.ParseFloat(formatted, 64)
+			if err != nil {
+				t.Fatalf("FormatQuantity(%v) = %q, 不是数字", quantity, formatted)
+			}
+			if !conformanceMultiple(value, h.StepSize) {
+				t.Errorf("FormatQuantity(%v) = %q, 不是步长%v的整数倍", quantity, formatted, h.StepSize)
+			}
+			if math.Abs(value-quantity) >= h.StepSize {
+				t.Errorf("FormatQuantity(%v) = %q, 偏差超过一个步长", quantity, formatted)
+			}
+		}
+	})
+
+	start := time.Now().Add(-time.Second)
+	for _, side := range []string{"long", "short"} {
+		side := side
+		t.Run("Lifecycle_"+side, func(t *testing.T) {
+			conformanceLifecycle(t, h, side)
+		})
+	}
+
+	t.Run("Fills", func(t *testing.T) {
+		fills, err := h.Trader.GetFills(start)
+		if err != nil {
+			t.Fatalf("GetFills: %v", err)
+		}
+		count := 0
+		for _, fill := range fills {
+			if fill["symbol"] != h.Symbol {
+				continue
+			}
+			count++
+			if _, ok := fill["orderId"].(int64); !ok {
+				t.Errorf("成交的orderId应为int64, 实际: %#v", fill["orderId"])
+			}
+			if side := fill["side"]; side != "BUY" && side != "SELL" {
+				t.Errorf("成交的side应为BUY/SELL, 实际: %#v", side)
+			}
+			if _, ok := fill["time"].(int64); !ok {
+				t.Errorf("成交的time应为int64毫秒, 实际: %#v", fill["time"])
+			}
+			for _, key := range []string{"price", "quantity", "fee", "realizedPnl"} {
+				if _, ok := fill[key].(float64); !ok {
+					t.Errorf("成交字段%s应为float64, 实际: %#v", key, fill[key])
+				}
+			}
+			if _, ok := fill["feeAsset"].(string); !ok {
+				t.Errorf("成交的feeAsset应为string, 实际: %#v", fill["feeAsset"])
+			}
+		}
+		// 每个方向：开仓、部分平仓、全部平仓
+		if count < 6 {
+			t.Errorf("GetFills返回%d笔%s成交, 期望至少6笔", count, h.Symbol)
+		}
+	})
+
+	t.Run("Orders", func(t *testing.T) {
+		conformanceOrders(t, h)
+	})
+
+	t.Run("AddKeepsOrders", func(t *testing.T) {
+		conformanceAddKeepsOrders(t, h)
+	})
+
+	t.Run("TrailingStop", func(t *testing.T) {
+		conformanceTrailingStop(t, h)
+	})
+
+	t.Run("TakeProfitLadder", func(t *testing.T) {
+		conformanceTakeProfitLadder(t, h)
+	})
+
+	t.Run("Execution", func(t *testing.T) {
+		conformanceExecution(t, h)
+	})
+
+	t.Run("ClientOrderID", func(t *testing.T) {
+		conformanceClientOrderID(t, h)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := h.Trader.CloseLong(h.Symbol, 0); !errors.Is(err, trader.ErrNoPosition) {
+			t.Errorf("无持仓时CloseLong应返回ErrNoPosition, 实际: %v", err)
+		}
+		if _, err := h.Trader.CloseShort(h.Symbol, 0); !errors.Is(err, trader.ErrNoPosition) {
+			t.Errorf("无持仓时CloseShort应返回ErrNoPosition, 实际: %v", err)
+		}
+		if err := h.Trader.SetStopLoss(h.Symbol, "BOTH", h.Quantity, 1); !errors.Is(err, trader.ErrInvalidPositionSide) {
+			t.Errorf("SetStopLoss(BOTH)应返回ErrInvalidPositionSide, 实际: %v", err)
+		}
+		if err := h.Trader.SetTakeProfit(h.Symbol, "long", h.Quantity, 1); !errors.Is(err, trader.ErrInvalidPositionSide) {
+			t.Errorf("SetTakeProfit(long)应返回ErrInvalidPositionSide, 实际: %v", err)
+		}
+	})
+}
+
+// conformanceLifecycle 单个方向的完整流程：开仓 → 挂止损止盈 → 替换止损止盈 → 撤单 → 部分平仓 → 全部平仓
+func conformanceLifecycle(t *testing.T, h Harness, side string) {
+	price, err := h.Trader.GetMarketPrice(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetMarketPrice: %v", err)
+	}
+
+	positionSide, protectiveSide := "LONG", "SELL"
+	stopPrice, takeProfitPrice := price*0.95, price*1.05
+	openPosition, closePosition := h.Trader.OpenLong, h.Trader.CloseLong
+	if side == "short" {
+		positionSide, protectiveSide = "SHORT", "BUY"
+		stopPrice, takeProfitPrice = price*1.05, price*0.95
+		openPosition, closePosition = h.Trader.OpenShort, h.Trader.CloseShort
+	}
+
+	if _, err := openPosition(h.Symbol, h.Quantity, h.Leverage); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	// 无论测试结果如何都尽量清理，避免影响后续用例
+	defer func() {
+		h.Trader.CancelAllOrders(h.Symbol)
+		closePosition(h.Symbol, 0)
+	}()
+
+	pos := conformanceFindPosition(t, h, side)
+	if pos == nil {
+		t.Fatalf("开仓后GetPositions中没有%s持仓", side)
+	}
+	if amount := pos["positionAmt"].(float64); math.Abs(amount-h.Quantity) > h.StepSize/2 {
+		t.Errorf("positionAmt = %v, 期望 %v", amount, h.Quantity)
+	}
+
+	// 止损止盈
+	if err := h.Trader.SetStopLoss(h.Symbol, positionSide, h.Quantity, stopPrice); err != nil {
+		t.Fatalf("SetStopLoss: %v", err)
+	}
+	if err := h.Trader.SetTakeProfit(h.Symbol, positionSide, h.Quantity, takeProfitPrice); err != nil {
+		t.Fatalf("SetTakeProfit: %v", err)
+	}
+	conformanceProtective(t, h, protectiveSide, price, stopPrice, takeProfitPrice)
+
+	// 替换止损止盈：旧单撤销，只剩新单
+	stopPrice, takeProfitPrice = price*0.97, price*1.08
+	if side == "short" {
+		stopPrice, takeProfitPrice = price*1.03, price*0.92
+	}
+	if err := h.Trader.UpdateStopLoss(h.Symbol, positionSide, h.Quantity, stopPrice); err != nil {
+		t.Fatalf("UpdateStopLoss: %v", err)
+	}
+	if err := h.Trader.UpdateTakeProfit(h.Symbol, positionSide, h.Quantity, takeProfitPrice); err != nil {
+		t.Fatalf("UpdateTakeProfit: %v", err)
+	}
+	conformanceProtective(t, h, protectiveSide, price, stopPrice, takeProfitPrice)
+
+	if err := h.Trader.CancelAllOrders(h.Symbol); err != nil {
+		t.Fatalf("CancelAllOrders: %v", err)
+	}
+	if orders, err := h.Trader.GetOpenOrders(h.Symbol); err != nil {
+		t.Fatalf("GetOpenOrders: %v", err)
+	} else if len(orders) != 0 {
+		t.Errorf("CancelAllOrders后仍有%d个挂单", len(orders))
+	}
+
+	// 部分平仓
+	if _, err := closePosition(h.Symbol, h.Quantity/2); err != nil {
+		t.Fatalf("部分平仓失败: %v", err)
+	}
+	pos = conformanceFindPosition(t, h, side)
+	if pos == nil {
+		t.Fatalf("部分平仓后持仓消失")
+	}
+	if amount := pos["positionAmt"].(float64); math.Abs(amount-h.Quantity/2) > h.StepSize/2 {
+		t.Errorf("部分平仓后positionAmt = %v, 期望 %v", amount, h.Quantity/2)
+	}
+
+	// quantity=0 全部平仓
+	if _, err := closePosition(h.Symbol, 0); err != nil {
+		t.Fatalf("全部平仓失败: %v", err)
+	}
+	if pos := conformanceFindPosition(t, h, side); pos != nil {
+		t.Errorf("quantity=0平仓后仍有持仓: %v", pos)
+	}
+}
+
+// conformanceProtective 检查挂单中正好有一张止损单和一张止盈单，方向和触发价正确
+func conformanceProtective(t *testing.T, h Harness, protectiveSide string, price, stopPrice, takeProfitPrice float64) {
+	t.Helper()
+	orders, err := h.Trader.GetOpenOrders(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetOpenOrders: %v", err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("挂单数量 = %d, 期望2（止损+止盈）: %v", len(orders), orders)
+	}
+	types := make(map[interface{}]float64)
+	for _, order := range orders {
+		if order["side"] != protectiveSide {
+			t.Errorf("止损/止盈方向 = %v, 期望 %s", order["side"], protectiveSide)
+		}
+		stop, _ := order["stopPrice"].(float64)
+		types[order["type"]] = stop
+	}
+	for orderType, want := range map[string]float64{"STOP_MARKET": stopPrice, "TAKE_PROFIT_MARKET": takeProfitPrice} {
+		got, ok := types[orderType]
+		if !ok {
+			t.Errorf("挂单中没有%s, 实际类型: %v", orderType, types)
+		} else if math.Abs(got-want) > price*0.001 {
+			t.Errorf("%s的stopPrice = %v, 期望约 %v", orderType, got, want)
+		}
+	}
+}
+
+// conformanceOrders 限价挂单的查询和撤单：GetOpenOrders → GetOrder → CancelOrder → GetOrder
+func conformanceOrders(t *testing.T, h Harness) {
+	price, err := h.Trader.GetMarketPrice(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetMarketPrice: %v", err)
+	}
+
+	// 远低于市价的限价买单，不会成交
+	result, err := h.Trader.OpenLimit(h.Symbol, "LONG", h.Quantity, price*0.8, h.Leverage, trader.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("OpenLimit: %v", err)
+	}
+	defer h.Trader.CancelAllOrders(h.Symbol)
+	orderID, ok := result["orderId"].(int64)
+	if !ok {
+		t.Fatalf("OpenLimit的orderId应为int64, 实际: %#v", result["orderId"])
+	}
+
+	orders, err := h.Trader.GetOpenOrders(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetOpenOrders: %v", err)
+	}
+	found := false
+	for _, order := range orders {
+		if order["orderId"] != orderID {
+			continue
+		}
+		found = true
+		if order["type"] != "LIMIT" || order["side"] != "BUY" || order["status"] != "NEW" {
+			t.Errorf("限价挂单 type/side/status = %v/%v/%v, 期望 LIMIT/BUY/NEW", order["type"], order["side"], order["status"])
+		}
+		for _, key := range []string{"price", "stopPrice", "quantity", "executedQty"} {
+			if _, ok := order[key].(float64); !ok {
+				t.Errorf("订单字段%s应为float64, 实际: %#v", key, order[key])
+			}
+		}
+		if _, ok := order["reduceOnly"].(bool); !ok {
+			t.Errorf("订单字段reduceOnly应为bool, 实际: %#v", order["reduceOnly"])
+		}
+		if quantity, _ := order["quantity"].(float64); math.Abs(quantity-h.Quantity) > h.StepSize/2 {
+			t.Errorf("挂单数量 = %v, 期望 %v", quantity, h.Quantity)
+		}
+	}
+	if !found {
+		t.Fatalf("GetOpenOrders中没有刚下的限价单 %d: %v", orderID, orders)
+	}
+
+	order, err := h.Trader.GetOrder(h.Symbol, orderID)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if order["status"] != "NEW" {
+		t.Errorf("挂单中的订单status = %v, 期望NEW", order["status"])
+	}
+
+	if err := h.Trader.CancelOrder(h.Symbol, orderID); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	order, err = h.Trader.GetOrder(h.Symbol, orderID)
+	if err != nil {
+		t.Fatalf("撤单后GetOrder: %v", err)
+	}
+	if order["status"] != "CANCELED" {
+		t.Errorf("撤单后status = %v, 期望CANCELED", order["status"])
+	}
+
+	if _, err := h.Trader.GetOrder(h.Symbol, 987654321); !errors.Is(err, trader.ErrOrderNotFound) {
+		t.Errorf("不存在的订单应返回ErrOrderNotFound, 实际: %v", err)
+	}
+}
+
+// conformanceAddKeepsOrders 多仓挂好止损止盈和一张限价开仓单（双向持仓模式下再开一个带止损止盈的空仓），
+// 然后对多仓加仓，检查加仓前的挂单全部保留
+func conformanceAddKeepsOrders(t *testing.T, h Harness) {
+	price, err := h.Trader.GetMarketPrice(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetMarketPrice: %v", err)
+	}
+	defer func() {
+		h.Trader.CancelAllOrders(h.Symbol)
+		h.Trader.CloseLong(h.Symbol, 0)
+		h.Trader.CloseShort(h.Symbol, 0)
+	}()
+
+	if _, err := h.Trader.OpenLong(h.Symbol, h.Quantity, h.Leverage); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := h.Trader.SetStopLoss(h.Symbol, "LONG", h.Quantity, price*0.95); err != nil {
+		t.Fatalf("SetStopLoss: %v", err)
+	}
+	if err := h.Trader.SetTakeProfit(h.Symbol, "LONG", h.Quantity, price*1.05); err != nil {
+		t.Fatalf("SetTakeProfit: %v", err)
+	}
+	if _, err := h.Trader.OpenLimit(h.Symbol, "LONG", h.Quantity, price*0.8, h.Leverage, trader.TimeInForceGTC); err != nil {
+		t.Fatalf("OpenLimit: %v", err)
+	}
+	if _, positionMode := h.Trader.AccountModes(); positionMode == trader.PositionModeHedge {
+		if _, err := h.Trader.OpenShort(h.Symbol, h.Quantity, h.Leverage); err != nil {
+			t.Fatalf("开空仓失败: %v", err)
+		}
+		if err := h.Trader.SetStopLoss(h.Symbol, "SHORT", h.Quantity, price*1.05); err != nil {
+			t.Fatalf("SetStopLoss(SHORT): %v", err)
+		}
+		if err := h.Trader.SetTakeProfit(h.Symbol, "SHORT", h.Quantity, price*0.95); err != nil {
+			t.Fatalf("SetTakeProfit(SHORT): %v", err)
+		}
+	}
+
+	before, err := h.Trader.GetOpenOrders(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetOpenOrders: %v", err)
+	}
+	if _, err := h.Trader.OpenLong(h.Symbol, h.Quantity, h.Leverage); err != nil {
+		t.Fatalf("加仓失败: %v", err)
+	}
+	after, err := h.Trader.GetOpenOrders(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetOpenOrders: %v", err)
+	}
+
+	remaining := make(map[interface{}]bool)
+	for _, order := range after {
+		remaining[order["orderId"]] = true
+	}
+	for _, order := range before {
+		if !remaining[order["orderId"]] {
+			t.Errorf("加仓撤销了已有挂单: %v", order)
+		}
+	}
+}
+
+// conformanceTrailingStop 多仓设置1%回调的跟踪止损，检查平仓方向只有一张触发价约为价格99%的跟踪止损
+func conformanceTrailingStop(t *testing.T, h Harness) {
+	price, err := h.Trader.GetMarketPrice(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetMarketPrice: %v", err)
+	}
+	if _, err := h.Trader.OpenLong(h.Symbol, h.Quantity, h.Leverage); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	defer func() {
+		h.Trader.CancelAllOrders(h.Symbol)
+		h.Trader.CloseLong(h.Symbol, 0)
+	}()
+
+	if err := h.Trader.SetTrailingStop(h.Symbol, "LONG", h.Quantity, 0, 0); err == nil {
+		t.Errorf("回调比例为0时SetTrailingStop应返回错误")
+	}
+	if err := h.Trader.SetTrailingStop(h.Symbol, "both", h.Quantity, 1, 0); !errors.Is(err, trader.ErrInvalidPositionSide) {
+		t.Errorf("SetTrailingStop(both)应返回ErrInvalidPositionSide, 实际: %v", err)
+	}
+
+	// 设置两次：第二次替换第一次
+	for i := 0; i < 2; i++ {
+		if err := h.Trader.SetTrailingStop(h.Symbol, "LONG", h.Quantity, 1, 0); err != nil {
+			t.Fatalf("SetTrailingStop: %v", err)
+		}
+	}
+
+	orders, err := h.Trader.GetOpenOrders(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetOpenOrders: %v", err)
+	}
+	var trailing []map[string]interface{}
+	for _, order := range orders {
+		if order["type"] == "TRAILING_STOP_MARKET" || order["type"] == "STOP_MARKET" {
+			trailing = append(trailing, order)
+		}
+	}
+	if len(trailing) != 1 {
+		t.Fatalf("设置跟踪止损后应有1张止损类挂单, 实际%d张: %v", len(trailing), orders)
+	}
+	if trailing[0]["side"] != "SELL" {
+		t.Errorf("多仓跟踪止损的side应为SELL, 实际: %v", trailing[0]["side"])
+	}
+	stopPrice, _ := trailing[0]["stopPrice"].(float64)
+	if math.Abs(stopPrice-price*0.99) > price*0.002 {
+		t.Errorf("跟踪止损触发价 = %v, 期望约 %v", stopPrice, price*0.99)
+	}
+}
+
+// conformanceTakeProfitLadder 空仓挂两档止盈（50%和30%），检查平仓方向有两张止盈单，数量按比例拆分
+func conformanceTakeProfitLadder(t *testing.T, h Harness) {
+	price, err := h.Trader.GetMarketPrice(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetMarketPrice: %v", err)
+	}
+	if _, err := h.Trader.OpenShort(h.Symbol, h.Quantity, h.Leverage); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	defer func() {
+		h.Trader.CancelAllOrders(h.Symbol)
+		h.Trader.CloseShort(h.Symbol, 0)
+	}()
+
+	invalid := []trader.TakeProfitLevel{{Price: price * 0.95, Fraction: 0.7}, {Price: price * 0.9, Fraction: 0.5}}
+	if err := h.Trader.SetTakeProfitLadder(h.Symbol, "SHORT", h.Quantity, invalid); err == nil {
+		t.Errorf("比例之和超过100%%时SetTakeProfitLadder应返回错误")
+	}
+
+	levels := []trader.TakeProfitLevel{{Price: price * 0.95, Fraction: 0.5}, {Price: price * 0.9, Fraction: 0.3}}
+	if err := h.Trader.SetTakeProfitLadder(h.Symbol, "SHORT", h.Quantity, levels); err != nil {
+		t.Fatalf("SetTakeProfitLadder: %v", err)
+	}
+
+	orders, err := h.Trader.GetOpenOrders(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetOpenOrders: %v", err)
+	}
+	total := 0.0
+	count := 0
+	for _, order := range orders {
+		if order["type"] != "TAKE_PROFIT_MARKET" {
+			continue
+		}
+		count++
+		if order["side"] != "BUY" {
+			t.Errorf("空仓止盈单的side应为BUY, 实际: %v", order["side"])
+		}
+		quantity, _ := order["quantity"].(float64)
+		if quantity <= 0 || !conformanceMultiple(quantity, h.StepSize) {
+			t.Errorf("止盈单数量 %v 不是步长 %v 的整数倍", quantity, h.StepSize)
+		}
+		total += quantity
+	}
+	if count != 2 {
+		t.Fatalf("挂两档止盈后应有2张止盈单, 实际%d张: %v", count, orders)
+	}
+	if math.Abs(total-h.Quantity*0.8) > h.StepSize/2 {
+		t.Errorf("止盈单总数量 = %v, 期望 %v", total, h.Quantity*0.8)
+	}
+}
+
+// conformanceExecution 市价开仓和平仓的返回值包含成交信息：avgPrice接近市场价，executedQty等于下单数量
+func conformanceExecution(t *testing.T, h Harness) {
+	price, err := h.Trader.GetMarketPrice(h.Symbol)
+	if err != nil {
+		t.Fatalf("GetMarketPrice: %v", err)
+	}
+	opened, err := h.Trader.OpenShort(h.Symbol, h.Quantity, h.Leverage)
+	if err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	closed, err := h.Trader.CloseShort(h.Symbol, 0)
+	if err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+
+	for name, result := range map[string]map[string]interface{}{"OpenShort": opened, "CloseShort": closed} {
+		avgPrice, ok := result["avgPrice"].(float64)
+		if !ok || math.Abs(avgPrice-price)/price > 0.05 {
+			t.Errorf("%s的avgPrice应为接近%.4f的float64, 实际: %#v", name, price, result["avgPrice"])
+		}
+		if quantity, _ := result["executedQty"].(float64); math.Abs(quantity-h.Quantity) > h.StepSize/2 {
+			t.Errorf("%s的executedQty = %#v, 期望 %v", name, result["executedQty"], h.Quantity)
+		}
+		if _, ok := result["fee"].(float64); !ok {
+			t.Errorf("%s的fee应为float64, 实际: %#v", name, result["fee"])
+		}
+		if _, ok := result["feeAsset"].(string); !ok {
+			t.Errorf("%s的feeAsset应为string, 实际: %#v", name, result["feeAsset"])
+		}
+	}
+}
+
+// conformanceClientOrderID 设置前缀后开多仓，按客户端订单ID查询；
+// 提供了TimeoutNextOrder时再开一次超时的仓位，检查交易器按成功处理且持仓只增加一份
+func conformanceClientOrderID(t *testing.T, h Harness) {
+	prefix := "conf" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	h.Trader.SetClientOrderIDPrefix(prefix)
+	defer func() {
+		h.Trader.SetClientOrderIDPrefix("")
+		h.Trader.CancelAllOrders(h.Symbol)
+		h.Trader.CloseLong(h.Symbol, 0)
+	}()
+
+	if _, err := h.Trader.OpenLong(h.Symbol, h.Quantity, h.Leverage); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	order, err := h.Trader.GetOrderByClientID(h.Symbol, prefix+"-1")
+	if err != nil {
+		t.Fatalf("GetOrderByClientID(%s-1): %v", prefix, err)
+	}
+	if order["status"] != "FILLED" || order["side"] != "BUY" {
+		t.Errorf("开多仓的订单应为已成交的BUY单, 实际: %v", order)
+	}
+	if _, ok := order["orderId"].(int64); !ok {
+		t.Errorf("订单的orderId应为int64, 实际: %#v", order["orderId"])
+	}
+	if _, err := h.Trader.GetOrderByClientID(h.Symbol, prefix+"-99"); !errors.Is(err, trader.ErrOrderNotFound) {
+		t.Errorf("不存在的客户端订单ID应返回ErrOrderNotFound, 实际: %v", err)
+	}
+
+	if h.TimeoutNextOrder == nil {
+		return
+	}
+	h.TimeoutNextOrder()
+	if _, err := h.Trader.OpenLong(h.Symbol, h.Quantity, h.Leverage); err != nil {
+		t.Fatalf("下单超时但交易所已接受时应按成功处理, 实际: %v", err)
+	}
+	if _, err := h.Trader.GetOrderByClientID(h.Symbol, prefix+"-2"); err != nil {
+		t.Errorf("超时的订单应能按客户端订单ID查到: %v", err)
+	}
+	position := conformanceFindPosition(t, h, "long")
+	if amt, _ := position["positionAmt"].(float64); math.Abs(amt-2*h.Quantity) > h.StepSize/2 {
+		t.Errorf("超时后持仓数量 = %v, 期望 %v（订单不应重复提交）", amt, 2*h.Quantity)
+	}
+}
+
+// conformanceFindPosition 查找持仓并检查字段类型
+func conformanceFindPosition(t *testing.T, h Harness, side string) map[string]interface{} {
+	t.Helper()
+	positions, err := h.Trader.GetPositions()
+	if err != nil {
+		t.Fatalf("GetPositions: %v", err)
+	}
+
+	var found map[string]interface{}
+	invalid := false
+	for _, pos := range positions {
+		if s, ok := pos["symbol"].(string); !ok {
+			t.Errorf("持仓的symbol不是string: %#v", pos["symbol"])
+			invalid = true
+			continue
+		} else if s != h.Symbol {
+			continue
+		}
+		posSide, ok := pos["side"].(string)
+		if !ok || (posSide != "long" && posSide != "short") {
+			t.Errorf("持仓的side应为\"long\"或\"short\", 实际: %#v", pos["side"])
+			invalid = true
+			continue
+		}
+		for _, key := range []string{"positionAmt", "entryPrice", "markPrice", "unRealizedProfit", "liquidationPrice", "leverage"} {
+			if _, ok := pos[key].(float64); !ok {
+				t.Errorf("持仓字段%s应为float64, 实际: %#v", key, pos[key])
+				invalid = true
+			}
+		}
+		if amount, ok := pos["positionAmt"].(float64); ok && amount <= 0 {
+			t.Errorf("positionAmt应为正数（方向看side）, 实际: %v", amount)
+			invalid = true
+		}
+		if posSide == side {
+			found = pos
+		}
+	}
+	if invalid {
+		t.FailNow()
+	}
+	return found
+}
+
+// conformanceMultiple 判断value是否为step的整数倍（允许浮点误差）
+func conformanceMultiple(value, step float64) bool {
+	n := value / step
+	return math.Abs(n-math.Round(n)) < 1e-6
+}
+
+// conformanceAccountModes 配置保证金模式和持仓模式
+func conformanceAccountModes(t *testing.T, h Harness) {
+	if err := h.Trader.ConfigureAccountModes("portfolio", ""); err == nil {
+		t.Errorf("ConfigureAccountModes(portfolio)应返回错误")
+	}
+	if err := h.Trader.ConfigureAccountModes(h.MarginMode, h.PositionMode); err != nil {
+		t.Fatalf("ConfigureAccountModes(%q, %q): %v", h.MarginMode, h.PositionMode, err)
+	}
+
+	marginMode, positionMode := h.Trader.AccountModes()
+	if positionMode != trader.PositionModeHedge && positionMode != trader.PositionModeOneWay {
+		t.Errorf("AccountModes的持仓模式应为%s或%s, 实际: %q", trader.PositionModeHedge, trader.PositionModeOneWay, positionMode)
+	}
+	if h.PositionMode != "" && positionMode != h.PositionMode {
+		t.Errorf("持仓模式 = %q, 期望%q", positionMode, h.PositionMode)
+	}
+	if h.MarginMode != "" && marginMode != h.MarginMode {
+		t.Errorf("保证金模式 = %q, 期望%q", marginMode, h.MarginMode)
+	}
+}
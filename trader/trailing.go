@@ -0,0 +1,198 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// trailingStopInterval 客户端跟踪止损检查价格的间隔
+const trailingStopInterval = 5 * time.Second
+
+// trailingStop 一个持仓的客户端跟踪止损
+type trailingStop struct {
+	Symbol          string
+	PositionSide    string  // "LONG" 或 "SHORT"
+	CallbackRate    float64 // 回调比例（百分比）
+	ActivationPrice float64 // 激活价（0表示立即激活）
+	Extreme         float64 // 激活后的最高价（多仓）/最低价（空仓），0表示尚未激活
+	StopPrice       float64 // 最近一次挂出/确认的止损价，0表示需要重新确认
+}
+
+// trailingEngine 客户端跟踪止损：交易所没有原生跟踪止损时，按最新价格移动持仓的止损单
+// 止损只会朝有利方向移动（多仓上移、空仓下移），持仓平掉后自动停止跟踪
+type trailingEngine struct {
+	trader   Trader
+	interval time.Duration // 后台检查间隔，0表示不启动后台检查（由调用方驱动poll）
+
+	mu      sync.Mutex
+	stops   map[string]*trailingStop // symbol_positionSide -> 跟踪止损
+	running bool
+}
+
+func newTrailingEngine(t Trader, interval time.Duration) *trailingEngine {
+	return &trailingEngine{
+		trader:   t,
+		interval: interval,
+		stops:    make(map[string]*trailingStop),
+	}
+}
+
+// set 添加或更新持仓的跟踪止损（保留已记录的极值），并立即按当前价格检查一次
+func (e *trailingEngine) set(symbol, positionSide string, callbackRate, activationPrice float64) error {
+	if err := validatePositionSide(positionSide); err != nil {
+		return err
+	}
+	if callbackRate <= 0 || callbackRate >= 100 {
+		return fmt.Errorf("跟踪止损回调比例必须在0-100%%之间: %.2f", callbackRate)
+	}
+
+	e.mu.Lock()
+	key := symbol + "_" + positionSide
+	stop, exists := e.stops[key]
+	if !exists {
+		stop = &trailingStop{Symbol: symbol, PositionSide: positionSide}
+		e.stops[key] = stop
+	}
+	stop.CallbackRate = callbackRate
+	stop.ActivationPrice = activationPrice
+	stop.StopPrice = 0 // 止损单可能已被撤销或替换，下次检查时以交易所为准
+	if e.interval > 0 && !e.running {
+		e.running = true
+		go e.run()
+	}
+	e.mu.Unlock()
+
+	log.Printf("  跟踪止损设置: %s %s 回调 %.2f%%（客户端跟踪）", symbol, positionSide, callbackRate)
+	e.poll()
+	return nil
+}
+
+// run 后台定时检查，没有需要跟踪的持仓时退出
+func (e *trailingEngine) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		e.poll()
+
+		e.mu.Lock()
+		if len(e.stops) == 0 {
+			e.running = false
+			e.mu.Unlock()
+			return
+		}
+		e.mu.Unlock()
+	}
+}
+
+// poll 按最新价格更新每个跟踪止损的极值，止损价改善超过回调距离的10%时移动交易所上的止损单
+func (e *trailingEngine) poll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.stops) == 0 {
+		return
+	}
+
+	positions, err := e.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠ 跟踪止损获取持仓失败: %v", err)
+		return
+	}
+
+	for key, stop := range e.stops {
+		amount := 0.0
+		for _, pos := range positions {
+			if pos["symbol"] == stop.Symbol && pos["side"] == sideFromPositionSide(stop.PositionSide) {
+				amount, _ = pos["positionAmt"].(float64)
+			}
+		}
+		if amount <= 0 {
+			delete(e.stops, key) // 持仓已平仓
+			continue
+		}
+
+		price, err := e.trader.GetMarketPrice(stop.Symbol)
+		if err != nil {
+			log.Printf("⚠ 跟踪止损获取 %s 价格失败: %v", stop.Symbol, err)
+			continue
+		}
+		level, ok := stop.update(price)
+		if !ok {
+			continue
+		}
+
+		// 最小移动距离：避免每次小幅波动都替换止损单
+		minMove := stop.Extreme * stop.CallbackRate / 100 * 0.1
+		if stop.StopPrice > 0 && !stop.better(level, stop.StopPrice, minMove) {
+			continue
+		}
+
+		// 交易所上的止损单可能已被调整到更有利的位置（如AI调整止损），不能往回移
+		current, err := e.currentStop(stop)
+		if err != nil {
+			log.Printf("⚠ 跟踪止损获取 %s 挂单失败: %v", stop.Symbol, err)
+			continue
+		}
+		if current > 0 && !stop.better(level, current, 0) {
+			stop.StopPrice = current
+			continue
+		}
+
+		if err := e.trader.UpdateStopLoss(stop.Symbol, stop.PositionSide, amount, level); err != nil {
+			log.Printf("⚠ 跟踪止损移动 %s %s 失败: %v", stop.Symbol, stop.PositionSide, err)
+			continue
+		}
+		log.Printf("🪜 跟踪止损 %s %s 移至 %.4f（极值 %.4f，回调 %.2f%%）", stop.Symbol, stop.PositionSide, level, stop.Extreme, stop.CallbackRate)
+		stop.StopPrice = level
+	}
+}
+
+// currentStop 交易所上该持仓最有利的止损价（没有止损单返回0）
+func (e *trailingEngine) currentStop(stop *trailingStop) (float64, error) {
+	orders, err := e.trader.GetOpenOrders(stop.Symbol)
+	if err != nil {
+		return 0, err
+	}
+	closingSide := "SELL"
+	if stop.PositionSide == "SHORT" {
+		closingSide = "BUY"
+	}
+	current := 0.0
+	for _, order := range orders {
+		if order["type"] != "STOP_MARKET" || order["side"] != closingSide {
+			continue
+		}
+		stopPrice, _ := order["stopPrice"].(float64)
+		if current == 0 || stop.better(stopPrice, current, 0) {
+			current = stopPrice
+		}
+	}
+	return current, nil
+}
+
+// update 按最新价格更新极值，返回当前的跟踪止损价（未激活时返回false）
+func (s *trailingStop) update(price float64) (float64, bool) {
+	long := s.PositionSide == "LONG"
+	if s.Extreme == 0 {
+		if s.ActivationPrice > 0 && ((long && price < s.ActivationPrice) || (!long && price > s.ActivationPrice)) {
+			return 0, false
+		}
+		s.Extreme = price
+	}
+	if (long && price > s.Extreme) || (!long && price < s.Extreme) {
+		s.Extreme = price
+	}
+	if long {
+		return s.Extreme * (1 - s.CallbackRate/100), true
+	}
+	return s.Extreme * (1 + s.CallbackRate/100), true
+}
+
+// better 止损价a是否比b更有利（多仓更高、空仓更低）至少minMove
+func (s *trailingStop) better(a, b, minMove float64) bool {
+	if s.PositionSide == "LONG" {
+		return a > b+minMove
+	}
+	return a < b-minMove
+}